@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 
 	_ "github.com/RaihanurRahman2022/PersonalVault/docs"
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/handlers"
@@ -10,16 +14,24 @@ import (
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/routes"
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
 	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/middleware"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
 	"github.com/RaihanurRahman2022/PersonalVault/pkg/database"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/logger"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/ratelimit"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"gorm.io/gorm"
 )
 
 type AppConfig struct {
-	Config   *config.Config
-	Router   *gin.Engine
+	Config *config.Config
+	Logger *slog.Logger
+	DB     *gorm.DB
+	Router *gin.Engine
+	// S3Router serves the S3 gateway; nil when S3_GATEWAY_PORT is not set
+	S3Router *gin.Engine
+	Services *services.Services
 	Handlers *handlers.Handlers
 }
 
@@ -45,82 +57,162 @@ type AppConfig struct {
 
 func main() {
 	app, err := InitializeApp()
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		log.Fatalf("Faild to initialize application: %v", err)
+		slog.Error("failed to initialize application", "error", err)
+		os.Exit(1)
 	}
 
-	log.Println("Started server on port: " + app.Config.Server.Port)
-	if err := app.Router.Run(":" + app.Config.Server.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if err := app.Run(); err != nil {
+		app.Logger.Error("server stopped with error", "error", err)
+		os.Exit(1)
 	}
 }
 
 func InitializeApp() (*AppConfig, error) {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found: %v", err)
+	cfg, err := config.LoadConfig(os.Args[1:])
+	if err != nil {
+		return nil, err
 	}
 
-	cfg, err := config.LoadConfig()
+	log, err := initializeLogger(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	if cfg.JWT.Secret == config.DefaultJWTSecret {
+		log.Warn("JWT_SECRET is not set, tokens are signed with the default secret", "environment", cfg.Environment)
+	}
+
 	// Initialize database
-	db, err := initializeDatabase()
+	db, err := initializeDatabase(cfg.Database, log)
 	if err != nil {
 		return nil, err
 	}
 
-	repos := initializeRepositories(db)
+	repos, err := initializeRepositories(db, cfg, log)
+	if err != nil {
+		return nil, err
+	}
 
-	srvc := initializeService(repos)
+	// Nothing is uploading yet, so any temp file left over is from a crash
+	if removed, err := repos.Driver.RemoveStaleTempFiles(context.Background()); err != nil {
+		log.Warn("failed to clean up stale upload temp files", "error", err)
+	} else if removed > 0 {
+		log.Info("removed stale upload temp files", "count", removed)
+	}
+	if removed, err := repos.Fetch.RemoveAllPartials(); err != nil {
+		log.Warn("failed to clean up interrupted URL fetches", "error", err)
+	} else if removed > 0 {
+		log.Info("removed interrupted URL fetches", "count", removed)
+	}
+
+	srvc := initializeService(repos, cfg, log)
+
+	if removed, err := srvc.S3Gateway.RemoveStaleUploads(context.Background()); err != nil {
+		log.Warn("failed to clean up stale multipart uploads", "error", err)
+	} else if removed > 0 {
+		log.Info("removed stale multipart uploads", "count", removed)
+	}
 
-	handlers := initializeHandlers(srvc)
+	handlers := initializeHandlers(srvc, log)
 
-	router := configureRouter(handlers, db)
+	limiter := middleware.NewRateLimiter(ratelimit.NewMemoryStore(), log)
+	router := configureRouter(handlers, limiter, cfg, db, log)
+
+	var s3Router *gin.Engine
+	if cfg.S3Gateway.Port != "" {
+		s3Router = configureS3Router(handlers, limiter, cfg, db, log)
+	}
 
 	return &AppConfig{
 		Config:   cfg,
+		Logger:   log,
+		DB:       db,
 		Router:   router,
+		S3Router: s3Router,
+		Services: srvc,
 		Handlers: handlers,
 	}, nil
 }
 
-func initializeDatabase() (*gorm.DB, error) {
-	if err := database.SetupDatabase(); err != nil {
+func initializeLogger(cfg *config.Config) (*slog.Logger, error) {
+	log, err := logger.New(os.Stdout, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %v", err)
+	}
+
+	// Anything still using the default logger gets the same format and redaction
+	slog.SetDefault(log)
+	return log, nil
+}
+
+func initializeDatabase(cfg config.DatabaseConfig, log *slog.Logger) (*gorm.DB, error) {
+	if err := database.SetupDatabase(cfg); err != nil {
 		return nil, fmt.Errorf("failed to setup database: %v", err)
 	}
 
-	db, err := database.InitDB()
+	db, err := database.InitDB(cfg, log)
 	if err != nil {
 		return nil, err
 	}
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database instance: %v", err)
+	}
+	monitoring.RegisterDBStats(sqlDB)
+
 	return db, nil
 }
 
-func initializeRepositories(db *gorm.DB) *repositories.Repositories {
-	return repositories.NewRepositories(db)
+func initializeRepositories(db *gorm.DB, cfg *config.Config, log *slog.Logger) (*repositories.Repositories, error) {
+	repos, err := repositories.NewRepositories(db, cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize repositories: %w", err)
+	}
+	return repos, nil
 }
 
-func initializeService(repo *repositories.Repositories) *services.Services {
-	return services.NewServices(repo)
+func initializeService(repo *repositories.Repositories, cfg *config.Config, log *slog.Logger) *services.Services {
+	return services.NewServices(repo, cfg, log)
 }
 
-func initializeHandlers(srvc *services.Services) *handlers.Handlers {
-	return handlers.NewHandlers(srvc)
+func initializeHandlers(srvc *services.Services, log *slog.Logger) *handlers.Handlers {
+	return handlers.NewHandlers(srvc, log)
 }
 
-func configureRouter(handlers *handlers.Handlers, db *gorm.DB) *gin.Engine {
-	router := gin.Default()
+func configureRouter(handlers *handlers.Handlers, limiter *middleware.RateLimiter, cfg *config.Config, db *gorm.DB, log *slog.Logger) *gin.Engine {
+	router := gin.New()
+
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.LoggerMiddleware(log))
+	router.Use(middleware.MetricsMiddleware())
+	router.Use(gin.Recovery())
 
 	router.SetTrustedProxies(nil)
 
-	corsConfig := config.PrepareCORSCOnfig()
+	corsConfig := config.PrepareCORSCOnfig(cfg.CORS)
 
 	router.Use(cors.New(corsConfig))
 
-	routes.SetupRoutes(router, handlers, db)
+	routes.SetupRoutes(router, handlers, limiter, cfg, db)
 	return router
 
 }
+
+func configureS3Router(handlers *handlers.Handlers, limiter *middleware.RateLimiter, cfg *config.Config, db *gorm.DB, log *slog.Logger) *gin.Engine {
+	router := gin.New()
+
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.LoggerMiddleware(log))
+	router.Use(middleware.MetricsMiddleware())
+	router.Use(gin.Recovery())
+
+	router.SetTrustedProxies(nil)
+
+	routes.SetupS3Routes(router, handlers, limiter, cfg, db)
+	return router
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// Run serves HTTP, and the S3 gateway when it is enabled, until SIGINT or SIGTERM is
+// received and then shuts down gracefully. With TLS_CERT_FILE both serve HTTPS, the
+// certificate is reloaded when it changes or on SIGHUP, and TLS_REDIRECT_PORT serves
+// redirects to HTTPS. Shutting down goes:
+//
+//  1. readiness starts failing so no new traffic is routed to this instance,
+//  2. the listeners are closed and in-flight requests get up to
+//     SERVER_SHUTDOWN_TIMEOUT to finish,
+//  3. if they don't, every request context is cancelled, which stops upload
+//     worker pools, and remaining connections are closed,
+//  4. background jobs and photo indexing are cancelled, queued audit entries and transfer
+//     usage are flushed and the database pool is closed.
+//
+// Change event streams never finish on their own, so they are ended before draining.
+func (app *AppConfig) Run() error {
+	// Every request context derives from baseCtx, so cancelling it aborts all
+	// in-flight work that honours its context.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	servers := []*http.Server{app.newServer(baseCtx, app.Config.Server.Port, app.Router)}
+	if app.S3Router != nil {
+		servers = append(servers, app.newServer(baseCtx, app.Config.S3Gateway.Port, app.S3Router))
+	}
+
+	if app.Config.TLS.Enabled() {
+		certs, err := newCertReloader(app.Config.TLS, app.Logger)
+		if err != nil {
+			app.Services.Events.Close()
+			app.Services.Text.Close()
+			app.close()
+			return fmt.Errorf("failed to start server: %w", err)
+		}
+		watchCtx, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		go certs.watch(watchCtx)
+
+		servers[0].TLSConfig = certs.tlsConfig(true)
+		// S3 clients authenticate by signing requests and have no way to send a client
+		// certificate
+		for _, srv := range servers[1:] {
+			srv.TLSConfig = certs.tlsConfig(false)
+		}
+		if port := app.Config.TLS.RedirectPort; port != "" {
+			servers = append(servers, app.newServer(baseCtx, port, redirectHandler(app.Config.Server.Port)))
+		}
+	}
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			app.Logger.Info("starting server", "addr", srv.Addr, "tls", srv.TLSConfig != nil)
+			var err error
+			if srv.TLSConfig != nil {
+				// The certificate comes from TLSConfig
+				err = srv.ListenAndServeTLS("", "")
+			} else {
+				err = srv.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- fmt.Errorf("%s: %w", srv.Addr, err)
+			}
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		app.Services.Events.Close()
+		app.Services.Text.Close()
+		app.shutdown(servers, cancelRequests)
+		app.close()
+		return fmt.Errorf("failed to start server: %w", err)
+	case <-signalCtx.Done():
+		app.Logger.Info("shutdown signal received, draining requests", "timeout", app.Config.Server.ShutdownTimeout)
+	}
+	// A second signal kills the process immediately
+	stop()
+
+	app.Services.Health.MarkShuttingDown()
+	app.Services.Events.Close()
+	app.Services.Text.Close()
+	app.shutdown(servers, cancelRequests)
+
+	app.close()
+	app.Logger.Info("server stopped")
+	return nil
+}
+
+func (app *AppConfig) newServer(baseCtx context.Context, port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadTimeout:       app.Config.Server.ReadTimeout,
+		ReadHeaderTimeout: app.Config.Server.ReadHeaderTimeout,
+		WriteTimeout:      app.Config.Server.WriteTimeout,
+		IdleTimeout:       app.Config.Server.IdleTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+}
+
+// shutdown drains all servers together within one SERVER_SHUTDOWN_TIMEOUT, then cancels
+// whatever is still running.
+func (app *AppConfig) shutdown(servers []*http.Server, cancelRequests context.CancelFunc) {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.Config.Server.ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var timedOut atomic.Bool
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				app.Logger.Warn("requests did not finish in time, cancelling them", "addr", srv.Addr, "error", err)
+				timedOut.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !timedOut.Load() {
+		return
+	}
+	cancelRequests()
+	for _, srv := range servers {
+		if err := srv.Close(); err != nil {
+			app.Logger.Error("failed to close server", "addr", srv.Addr, "error", err)
+		}
+	}
+}
+
+// close releases resources that outlive individual requests.
+func (app *AppConfig) close() {
+	app.Services.Photos.Close()
+	app.Services.Jobs.Close()
+	app.Services.Audit.Close()
+	app.Services.Activity.Close()
+	app.Services.Sync.Close()
+	app.Services.Transfer.Close()
+
+	sqlDB, err := app.DB.DB()
+	if err != nil {
+		app.Logger.Error("failed to get database instance", "error", err)
+		return
+	}
+	if err := sqlDB.Close(); err != nil {
+		app.Logger.Error("failed to close database", "error", err)
+	}
+}
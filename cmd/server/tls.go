@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+)
+
+// certReloader serves the certificate and client CAs of TLS_CERT_FILE, TLS_KEY_FILE and
+// TLS_CLIENT_CA_FILE, reloading them when the files change or on SIGHUP without
+// restarting the listeners. Connections already open keep the certificate they were
+// made with. A reload that fails keeps the previous certificate.
+type certReloader struct {
+	cfg    config.TLSConfig
+	logger *slog.Logger
+
+	mu     sync.RWMutex
+	config *tls.Config
+	// clientConfig is config with the client CAs, the same without TLS_CLIENT_CA_FILE
+	clientConfig *tls.Config
+	// stamps are the modification times and sizes of the files when they were loaded
+	stamps map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newCertReloader(cfg config.TLSConfig, logger *slog.Logger) (*certReloader, error) {
+	r := &certReloader{
+		cfg:    cfg,
+		logger: logger.With("component", "tls"),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// tlsConfig returns the config of a server, which asks the reloader for the current
+// certificate of each connection. Only servers with clientCerts verify client
+// certificates.
+func (r *certReloader) tlsConfig(clientCerts bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: r.cfg.MinVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			if clientCerts {
+				return r.clientConfig, nil
+			}
+			return r.config, nil
+		},
+	}
+}
+
+func (r *certReloader) reload() error {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile}
+	if r.cfg.ClientCAFile != "" {
+		files = append(files, r.cfg.ClientCAFile)
+	}
+	stamps, err := statFiles(files)
+	if err != nil {
+		return err
+	}
+	tlsConfig, clientConfig, err := r.load()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Files that fail to load aren't tried again until they change
+	r.stamps = stamps
+	if err != nil {
+		return err
+	}
+	r.config, r.clientConfig = tlsConfig, clientConfig
+	return nil
+}
+
+// load reads the certificate and the client CAs. It returns the config without and with
+// client certificates.
+func (r *certReloader) load() (*tls.Config, *tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   r.cfg.MinVersion,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{cert},
+	}
+
+	clientConfig := tlsConfig
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("failed to load client CA file: %s has no PEM certificates", r.cfg.ClientCAFile)
+		}
+		clientConfig = tlsConfig.Clone()
+		clientConfig.ClientCAs = pool
+		clientConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if r.cfg.ClientCertOptional {
+			clientConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+		r.logger.Info("loaded TLS certificate", "subject", leaf.Subject.String(), "not_after", leaf.NotAfter)
+	}
+	return tlsConfig, clientConfig, nil
+}
+
+// changed reports whether any of the files was modified since it was loaded.
+func (r *certReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for file, stamp := range r.stamps {
+		info, err := os.Stat(file)
+		if err != nil {
+			// Being replaced; the next check will see the new file
+			return false
+		}
+		if !info.ModTime().Equal(stamp.modTime) || info.Size() != stamp.size {
+			return true
+		}
+	}
+	return false
+}
+
+// watch reloads the certificate on SIGHUP and, every TLS_RELOAD_INTERVAL, when its files
+// changed, until ctx is done.
+func (r *certReloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if r.cfg.ReloadInterval > 0 {
+		ticker := time.NewTicker(r.cfg.ReloadInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.Info("SIGHUP received, reloading TLS certificate")
+		case <-tick:
+			if !r.changed() {
+				continue
+			}
+			r.logger.Info("TLS certificate files changed, reloading")
+		}
+		if err := r.reload(); err != nil {
+			r.logger.Error("failed to reload TLS certificate, keeping the previous one", "error", err)
+		}
+	}
+}
+
+func statFiles(files []string) (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS file: %w", err)
+		}
+		stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps, nil
+}
+
+// redirectHandler sends plain HTTP requests to the same URL on the HTTPS port.
+func redirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]")
+		}
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		// 308 keeps the method and body of API calls; browsers treat it like a 301
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
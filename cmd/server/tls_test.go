@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+)
+
+// testCert is a certificate and its key, self-signed without a parent.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+var testSerial int64
+
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testSerial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(testSerial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		// Self-signed certificates are their own CA
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func (c *testCert) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.cert)
+	return pool
+}
+
+// writeTestFile replaces a file with a modification time that always differs from the
+// previous one, however coarse the file system's clock.
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Duration(testSerial) * time.Second)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+}
+
+// newTestReloader writes the certificate and key of cert and loads them.
+func newTestReloader(t *testing.T, cert *testCert, cfg config.TLSConfig) *certReloader {
+	t.Helper()
+	dir := t.TempDir()
+	cfg.CertFile = filepath.Join(dir, "cert.pem")
+	cfg.KeyFile = filepath.Join(dir, "key.pem")
+	writeTestFile(t, cfg.CertFile, cert.certPEM)
+	writeTestFile(t, cfg.KeyFile, cert.keyPEM)
+
+	r, err := newCertReloader(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// servedName returns the common name of the certificate the reloader currently serves.
+func servedName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	tlsConfig, err := r.tlsConfig(false).GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+// waitForName waits for the reloader to serve the certificate named name, calling poke
+// while waiting.
+func waitForName(t *testing.T, r *certReloader, name string, poke func()) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for servedName(t, r) != name {
+		if time.Now().After(deadline) {
+			t.Fatalf("still serving %q, want %q", servedName(t, r), name)
+		}
+		poke()
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCertReloaderReloadsChangedFiles(t *testing.T) {
+	r := newTestReloader(t, newTestCert(t, "first", nil), config.TLSConfig{ReloadInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watch(ctx)
+
+	second := newTestCert(t, "second", nil)
+	writeTestFile(t, r.cfg.CertFile, second.certPEM)
+	writeTestFile(t, r.cfg.KeyFile, second.keyPEM)
+	waitForName(t, r, "second", func() {})
+}
+
+func TestCertReloaderReloadsOnSIGHUP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no SIGHUP on Windows")
+	}
+	// Until watch listens for it, SIGHUP would kill the test
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	r := newTestReloader(t, newTestCert(t, "first", nil), config.TLSConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.watch(ctx)
+
+	second := newTestCert(t, "second", nil)
+	writeTestFile(t, r.cfg.CertFile, second.certPEM)
+	writeTestFile(t, r.cfg.KeyFile, second.keyPEM)
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForName(t, r, "second", func() {
+		if err := process.Signal(syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestCertReloaderKeepsCertificateOnInvalidPair(t *testing.T) {
+	first := newTestCert(t, "first", nil)
+	r := newTestReloader(t, first, config.TLSConfig{})
+
+	// The new certificate with the old key
+	second := newTestCert(t, "second", nil)
+	writeTestFile(t, r.cfg.CertFile, second.certPEM)
+	if err := r.reload(); err == nil {
+		t.Fatal("reload of a certificate with another key succeeded")
+	}
+	if name := servedName(t, r); name != "first" {
+		t.Errorf("serving %q after a failed reload, want the previous certificate", name)
+	}
+	// The invalid files aren't tried again until they change
+	if r.changed() {
+		t.Error("files that failed to load are reported as changed")
+	}
+
+	writeTestFile(t, r.cfg.KeyFile, []byte("not a key"))
+	if !r.changed() {
+		t.Error("changed key file not reported")
+	}
+	if err := r.reload(); err == nil {
+		t.Fatal("reload of an invalid key succeeded")
+	}
+	if name := servedName(t, r); name != "first" {
+		t.Errorf("serving %q after a failed reload, want the previous certificate", name)
+	}
+
+	writeTestFile(t, r.cfg.KeyFile, second.keyPEM)
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if name := servedName(t, r); name != "second" {
+		t.Errorf("serving %q once the pair is valid, want the new certificate", name)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port   string
+		host   string
+		target string
+		want   string
+	}{
+		{"8443", "vault.example.com", "/api/files?path=%2Fa", "https://vault.example.com:8443/api/files?path=%2Fa"},
+		{"8443", "vault.example.com:8080", "/", "https://vault.example.com:8443/"},
+		{"443", "vault.example.com:80", "/dav/a%20b", "https://vault.example.com/dav/a%20b"},
+		{"443", "[2001:db8::1]:80", "/", "https://[2001:db8::1]/"},
+		{"8443", "[2001:db8::1]", "/", "https://[2001:db8::1]:8443/"},
+		{"8443", "192.0.2.1:80", "/x", "https://192.0.2.1:8443/x"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		redirectHandler(tt.port).ServeHTTP(w, req)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s%s: status = %d, want 308", tt.host, tt.target, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("%s%s to port %s: Location = %q, want %q", tt.host, tt.target, tt.port, got, tt.want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = ""
+	w := httptest.NewRecorder()
+	redirectHandler("8443").ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("request without a host: status = %d, want 400", w.Code)
+	}
+}
+
+func TestClientCertHandshake(t *testing.T) {
+	serverCert := newTestCert(t, "localhost", nil)
+	clientCA := newTestCert(t, "client CA", nil)
+	clientCert := newTestCert(t, "laptop", clientCA)
+	strangerCert := newTestCert(t, "stranger", newTestCert(t, "other CA", nil))
+
+	caFile := filepath.Join(t.TempDir(), "clients.pem")
+	writeTestFile(t, caFile, clientCA.certPEM)
+
+	modes := []struct {
+		name        string
+		optional    bool
+		clientCerts bool
+	}{
+		{"require", false, true},
+		{"optional", true, true},
+		// The S3 gateway's listener
+		{"without client certificates", false, false},
+	}
+	tests := []struct {
+		name string
+		cert *testCert
+		// want is the number of verified certificates the server sees in each mode, -1 if
+		// the handshake must fail
+		want []int
+	}{
+		{"signed by the CA", clientCert, []int{1, 1, 0}},
+		{"signed by another CA", strangerCert, []int{-1, -1, 0}},
+		{"without a certificate", nil, []int{-1, 0, 0}},
+	}
+
+	for i, mode := range modes {
+		r := newTestReloader(t, serverCert, config.TLSConfig{ClientCAFile: caFile, ClientCertOptional: mode.optional, MinVersion: tls.VersionTLS12})
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			io.WriteString(w, strconv.Itoa(len(req.TLS.VerifiedChains)))
+		}))
+		server.TLS = r.tlsConfig(mode.clientCerts)
+		server.Config.ErrorLog = log.New(io.Discard, "", 0)
+		server.StartTLS()
+
+		for _, tt := range tests {
+			tlsConfig := &tls.Config{RootCAs: serverCert.pool()}
+			if tt.cert != nil {
+				// Sent even when the server doesn't list its CA as acceptable
+				cert := tt.cert.tlsCertificate(t)
+				tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+					return &cert, nil
+				}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+			resp, err := client.Get(server.URL)
+			if tt.want[i] < 0 {
+				if err == nil {
+					resp.Body.Close()
+					t.Errorf("%s, client %s: handshake succeeded, want it refused", mode.name, tt.name)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s, client %s: %v", mode.name, tt.name, err)
+				continue
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != strconv.Itoa(tt.want[i]) {
+				t.Errorf("%s, client %s: server verified %s certificates, want %d", mode.name, tt.name, body, tt.want[i])
+			}
+		}
+		server.Close()
+	}
+}
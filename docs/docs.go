@@ -24,38 +24,99 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/auth/login": {
-            "post": {
-                "description": "Authenticate user and return access and refresh tokens",
+        "/api/admin/audit": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Query the audit trail of file operations and logins. Use format=csv to export all matching entries as CSV.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/csv"
                 ],
                 "tags": [
-                    "Authentication"
+                    "Admin"
                 ],
-                "summary": "User login",
+                "summary": "List audit logs",
                 "parameters": [
                     {
-                        "description": "Login credentials",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/entities.LoginRequest"
-                        }
+                        "type": "string",
+                        "description": "Filter by user ID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by username",
+                        "name": "username",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by action (list, download, preview, stream, upload, create-folder, delete, move, copy, login, version-download, version-preview, version-restore, version-delete, version-prune, encrypt-folder, disable-encryption, unlock-keyring, fetch, photo-index)",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by path prefix",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by outcome (success or failure)",
+                        "name": "outcome",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by client IP",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only entries at or after this RFC3339 time",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only entries before this RFC3339 time",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format (json or csv)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (max 500)",
+                        "name": "page_size",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Login successful",
+                        "description": "Audit logs",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "additionalProperties": true
                         }
                     },
                     "400": {
@@ -67,17 +128,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "401": {
-                        "description": "Invalid credentials",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    },
                     "403": {
-                        "description": "User account inactive",
+                        "description": "Admin access required",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -97,33 +149,39 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/register": {
-            "post": {
-                "description": "Register a new user account",
-                "consumes": [
-                    "application/json"
+        "/api/admin/users/{id}/transfer-limits": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Get the bandwidth limits and daily transfer quota of a user, the server defaults if none were set.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication"
+                    "Admin"
                 ],
-                "summary": "User registration",
+                "summary": "Get a user's transfer limits",
                 "parameters": [
                     {
-                        "description": "User registration data",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/entities.RegisterRequest"
-                        }
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "User registered successfully",
+                    "200": {
+                        "description": "Transfer limits",
+                        "schema": {
+                            "$ref": "#/definitions/entities.TransferLimit"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -131,8 +189,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -150,11 +217,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/drive/create-folder": {
-            "post": {
-                "description": "Create a new folder at a given path",
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Set the download and upload rates (KiB per second) and daily transfer quota (MiB) of a user in place of the server defaults; 0 means no limit. The rates apply at once, including to transfers in progress.",
                 "consumes": [
                     "application/json"
                 ],
@@ -162,23 +232,36 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Drive"
+                    "Admin"
                 ],
-                "summary": "Create folder",
+                "summary": "Set a user's transfer limits",
                 "parameters": [
                     {
-                        "description": "Create folder request",
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Transfer limits",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entities.CreateFolderRequest"
+                            "$ref": "#/definitions/entities.TransferLimitRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Folder created successfully",
+                        "description": "Transfer limits",
+                        "schema": {
+                            "$ref": "#/definitions/entities.TransferLimit"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -186,8 +269,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -205,35 +297,33 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/drive/download": {
-            "post": {
-                "description": "Download a file from a given path",
-                "consumes": [
-                    "application/json"
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Put a user back on the server's default bandwidth limits and daily transfer quota.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Drive"
+                    "Admin"
                 ],
-                "summary": "Download file",
+                "summary": "Reset a user's transfer limits",
                 "parameters": [
                     {
-                        "description": "Download request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/entities.DownloadRequest"
-                        }
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "File downloaded successfully",
+                        "description": "Transfer limits reset",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -242,7 +332,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request",
+                        "description": "Invalid user ID",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -250,50 +340,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "403": {
+                        "description": "Admin access required",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
                                 "type": "string"
                             }
                         }
-                    }
-                }
-            }
-        },
-        "/drive/list": {
-            "get": {
-                "description": "List all files and directories in a given path",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Drive"
-                ],
-                "summary": "List path contents",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Path to list contents",
-                        "name": "path",
-                        "in": "query",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "Path contents",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "404": {
+                        "description": "User not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -313,36 +370,76 @@ const docTemplate = `{
                 }
             }
         },
-        "/drive/preview": {
+        "/api/drivers/activity": {
             "get": {
-                "description": "Preview a file at a given path",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "List the recent changes to the entries of a folder by any user, newest first: uploads, new folders, deletes, copies, restores, and moves into or out of it. With recursive, changes in its subfolders are listed too.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Drive"
                 ],
-                "summary": "Preview file",
+                "summary": "Folder activity",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Path to preview file",
+                        "description": "Folder",
                         "name": "path",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include changes in subfolders",
+                        "name": "recursive",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only this type of change (repeatable): uploaded, created, deleted, moved, copied, restored",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only changes at or after this time (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only changes before this time (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries per page (max 500)",
+                        "name": "page_size",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "File previewed successfully",
+                        "description": "Folder activity",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "additionalProperties": true
                         }
                     },
                     "400": {
@@ -366,22 +463,24 @@ const docTemplate = `{
                 }
             }
         },
-        "/drive/root": {
+        "/api/drivers/encrypted-folders": {
             "get": {
-                "description": "Get all root drivers",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "List the caller's encrypted folders and whether they are currently locked",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Drive"
+                    "Encryption"
                 ],
-                "summary": "Get root drivers",
+                "summary": "List encrypted folders",
                 "responses": {
                     "200": {
-                        "description": "Root drivers",
+                        "description": "Encrypted folders",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -397,11 +496,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/drive/stream": {
-            "get": {
-                "description": "Stream a file at a given path",
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Turn on at-rest encryption for an empty or new folder. Files saved into it are sealed with AES-256-GCM, and with encrypt_names their names are encrypted too. The folder key is wrapped by a key derived from the caller's password, so the folder can only be read while the caller's keyring is unlocked (after login or unlock).",
                 "consumes": [
                     "application/json"
                 ],
@@ -409,21 +511,93 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Drive"
+                    "Encryption"
                 ],
-                "summary": "Stream file",
+                "summary": "Encrypt folder",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Path to stream file",
-                        "name": "path",
-                        "in": "query",
-                        "required": true
+                        "description": "Folder to encrypt",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.EncryptFolderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Encrypted folder",
+                        "schema": {
+                            "$ref": "#/definitions/entities.EncryptedFolder"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Wrong password",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Folder is not empty or overlaps an encrypted folder",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/encrypted-folders/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stop encrypting one of the caller's folders. The folder must be empty.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Encryption"
+                ],
+                "summary": "Disable folder encryption",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Encrypted folder ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "File streamed successfully",
+                        "description": "Encryption disabled",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -440,6 +614,24 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "404": {
+                        "description": "Encrypted folder not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Folder is not empty",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -452,9 +644,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/drive/upload-files": {
+        "/api/drivers/fetch": {
             "post": {
-                "description": "Upload files to a given path",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Download a file from an HTTP(S) URL on the server and save it into a folder. The download runs as a background job; the request returns 202 with the job. Interrupted downloads are resumed with Range requests, and the file is only saved once it is complete and matches the optional checksum. Addresses that aren't publicly routable are refused unless the server allows private networks.",
                 "consumes": [
                     "application/json"
                 ],
@@ -464,36 +661,93 @@ const docTemplate = `{
                 "tags": [
                     "Drive"
                 ],
-                "summary": "Upload files",
+                "summary": "Fetch a URL into the vault",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Path to upload files",
-                        "name": "path",
-                        "in": "query",
-                        "required": true
+                        "description": "URL, destination folder, and optional name, checksum (sha256:\u003chex\u003e) and overwrite mode",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.FetchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Fetch job started",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Job"
+                        }
                     },
-                    {
-                        "type": "string",
-                        "description": "Upload type (files or folder)",
-                        "name": "upload_type",
-                        "in": "formData",
-                        "required": true
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Destination folder not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Destination folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     },
+                    "503": {
+                        "description": "Server is shutting down",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/metadata": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the caller's tags, favorite flag, color label and notes of a file or folder. Files without any have empty metadata.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metadata"
+                ],
+                "summary": "Get file metadata",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "Overwrite files (true or false)",
-                        "name": "overwrite",
-                        "in": "formData",
+                        "description": "Path of the file or folder",
+                        "name": "path",
+                        "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Upload results",
+                        "description": "File metadata",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/entities.FileMeta"
                         }
                     },
                     "400": {
@@ -505,8 +759,17 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -515,11 +778,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/user/details/{user_id}": {
-            "get": {
-                "description": "Get user details by user ID",
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Set the caller's tags, favorite flag, color label and notes of a file or folder. Fields left out are kept; tags and notes replace the previous ones, so an empty list or object clears them. Tags are lowercased. Colors are red, orange, yellow, green, blue, purple and gray, or empty for none. The metadata follows the file when it is renamed or moved.",
                 "consumes": [
                     "application/json"
                 ],
@@ -527,27 +793,91 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Metadata"
                 ],
-                "summary": "Get user details",
+                "summary": "Update file metadata",
+                "parameters": [
+                    {
+                        "description": "Metadata to set",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.FileMetadataRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File metadata",
+                        "schema": {
+                            "$ref": "#/definitions/entities.FileMeta"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove all of the caller's tags, favorite flag, color label and notes of a file or folder",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metadata"
+                ],
+                "summary": "Delete file metadata",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User ID",
-                        "name": "user_id",
-                        "in": "path",
+                        "description": "Path of the file or folder",
+                        "name": "path",
+                        "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "User details",
+                        "description": "File metadata deleted",
                         "schema": {
-                            "$ref": "#/definitions/entities.User"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
-                    "401": {
-                        "description": "User not found in context",
+                    "400": {
+                        "description": "Invalid request",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -556,7 +886,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "File not found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -564,8 +894,8 @@ const docTemplate = `{
                             }
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "423": {
+                        "description": "Encrypted folder is locked",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
@@ -575,47 +905,3478 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "entities.CreateFolderRequest": {
-            "type": "object",
-            "required": [
-                "path"
-            ],
-            "properties": {
-                "path": {
-                    "type": "string",
-                    "example": "/documents/new_folder"
-                }
-            }
         },
-        "entities.DownloadRequest": {
-            "type": "object",
-            "required": [
-                "path"
-            ],
-            "properties": {
-                "path": {
-                    "type": "string",
-                    "example": "/documents/file.pdf"
+        "/api/drivers/metadata/search": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the caller's files and folders with all of the given tags, ordered by path. Favorites, a color label and a folder narrow the search further; without filters every file with metadata is listed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metadata"
+                ],
+                "summary": "Search files by metadata",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Tag the files must have (repeatable)",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only favorites",
+                        "name": "favorite",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only files with this color label",
+                        "name": "color",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only files in this folder or below it",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Files per page (max 1000)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Matching files",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/move": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Move files and folders into a folder, keeping their names. The move runs as a background job; the request returns 202 with the job, which reports each path as it is moved and can be cancelled.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Move files",
+                "parameters": [
+                    {
+                        "description": "Paths to move and the destination folder",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.MoveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Move job started",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Server is shutting down",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/tags": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the caller's tags with how many files have each, most used first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Metadata"
+                ],
+                "summary": "List tags",
+                "responses": {
+                    "200": {
+                        "description": "Tags",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/entities.TagCount"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/text": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the first or last lines of a text file, or a range of lines, decoded to UTF-8. The encoding is detected from the byte order mark (UTF-8, UTF-16), else the file is read as UTF-8 or, if it isn't valid UTF-8, Latin-1. Only the lines asked for are read, so files of any size can be previewed; a tail's line numbers aren't known. Very long lines are cut and flagged as truncated.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Preview text file lines",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "head (default), tail or range",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of lines of a head or tail (default 100)",
+                        "name": "lines",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "First line of a range",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Last line of a range",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File lines",
+                        "schema": {
+                            "$ref": "#/definitions/entities.TextPreview"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "File is not text",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/text/follow": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Server-Sent Events stream of the lines appended to a text file, like tail -f. A ready event with the last lines of the file (an entities.TextPreview) is sent first, then a lines event for each batch of complete lines written to it. If the file is truncated or replaced by a smaller one, a reset event is sent and the file is followed from its start. Browsers' EventSource can't set headers, so the token may be passed as access_token instead.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Follow a text file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of last lines to send first (default 100)",
+                        "name": "lines",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer token, for clients that can't send the Authorization header",
+                        "name": "access_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "$ref": "#/definitions/entities.TextFollowEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "File is not text",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Server is shutting down",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/text/structured": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Parse a Markdown, JSON or CSV file for display. Markdown is split into its top-level blocks, JSON is returned as a document with exact numbers, and CSV files are returned a page of rows at a time, with the delimiter detected from the first line and the first row as column names unless no_header is set. The format defaults to the one of the file's extension (.md, .json, .csv, .tsv). Markdown and JSON files larger than PREVIEW_MAX_STRUCTURED_MB are refused.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Structured file preview",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "markdown, json or csv",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "The first CSV row is data, not column names",
+                        "name": "no_header",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page of CSV rows",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "CSV rows per page (default 100, max 1000)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Parsed file",
+                        "schema": {
+                            "$ref": "#/definitions/entities.StructuredPreview"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "File is too large",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "415": {
+                        "description": "File is not text or has no structured preview",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "File could not be parsed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/usage": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the size, file count and folder count of a folder and everything below it, like du, with its largest subfolders and the largest files anywhere below it. Sizes are the sizes on disk, so encrypted files count with their encryption overhead; hidden files aren't counted, and folders that can't be read are counted as unreadable. Folder listings are cached and only listed again when they change, so asking again, or about a subfolder, is quick; refresh lists every folder again. Closing the connection stops the scan, and the folders scanned so far stay cached.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Folder disk usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Folder",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of largest subfolders and files (default 20, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "List every folder again instead of using the cache",
+                        "name": "refresh",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Disk usage",
+                        "schema": {
+                            "$ref": "#/definitions/entities.DiskUsage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Path is not allowed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Folder not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/versions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the previous versions of a file, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Versions"
+                ],
+                "summary": "List file versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Versions",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/versions/prune": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Apply the caller's retention policy to the versions of a file",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Versions"
+                ],
+                "summary": "Prune file versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Number of pruned versions",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/versions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Permanently delete a previous version",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Versions"
+                ],
+                "summary": "Delete file version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Version ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Version deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Version not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/versions/{id}/download": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Download the content of a previous version as an attachment",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Versions"
+                ],
+                "summary": "Download file version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Version ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Version content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Version not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/versions/{id}/preview": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Serve the content of a previous version inline, with Range support",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "Versions"
+                ],
+                "summary": "Preview file version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Version ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Version content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial version content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Version not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/drivers/versions/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Make a previous version the current content of the file. The replaced content is kept as a new version.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Versions"
+                ],
+                "summary": "Restore file version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Version ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Restored version",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Version not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/events": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Server-Sent Events stream of changes to the given folders and their direct entries, whether made through the vault or directly on disk. Each change is sent as an event named after its type (create, modify, delete, rename) with an entities.ChangeEvent as data. Changes are merged over a short window, so a burst such as a folder upload arrives as one event per path; a resync event means changes were dropped and the folder should be listed again. A ready event listing the watched folders is sent first. Browsers' EventSource can't set headers, so the token may be passed as access_token instead.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Events"
+                ],
+                "summary": "Stream change events",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Folder to watch (repeatable)",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer token, for clients that can't send the Authorization header",
+                        "name": "access_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "$ref": "#/definitions/entities.ChangeEvent"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Folder not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Server is shutting down",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the caller's running jobs and the ones that finished recently, newest first and without their files",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "List jobs",
+                "responses": {
+                    "200": {
+                        "description": "Jobs",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/events": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Server-Sent Events stream of the caller's jobs: a job event with the job's totals and the files that changed is sent at most twice a second while a job progresses, and once more when it finishes. Browsers' EventSource can't set headers, so the token may be passed as access_token instead.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Stream job progress",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer token, for clients that can't send the Authorization header",
+                        "name": "access_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Job"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a job's status and the progress of each of its files",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Get job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/jobs/{id}/cancel": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Cancel a running job. Work already done is kept; the file being written is discarded.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Cancel job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job cancelled",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Job has already finished",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/photos/index": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Index the EXIF metadata of the images in a folder and its subfolders as a background job. Only new and changed images are read, and images that are gone are removed from the index. The request returns 202 with the job.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Photos"
+                ],
+                "summary": "Index photos",
+                "parameters": [
+                    {
+                        "description": "Folder to index",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.PhotoIndexRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Index job started",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Server is shutting down",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/photos/metadata": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the EXIF metadata indexed for an image: capture time, camera, orientation and GPS position",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Photos"
+                ],
+                "summary": "Photo metadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the image",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Photo metadata",
+                        "schema": {
+                            "$ref": "#/definitions/entities.PhotoMetadata"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Image is not indexed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/photos/timeline": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List indexed photos across all folders by the day they were taken, newest first. Dates are the camera's local date; photos without a capture date are left out.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Photos"
+                ],
+                "summary": "Photo timeline",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only photos in this folder or below it",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only photos taken on or after this date (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only photos taken on or before this date (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Photos per page (max 1000)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Photos grouped by day",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sync/changes": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the changes below a folder after a cursor, oldest first: files and folders created, modified, deleted or renamed (old_path is set; a rename out of the folder is a removal for the client), and removals and renames of the folders above it. Each change that didn't remove its path has the path's current state as entry, with its hash. The cursor of the response is the one to ask with next; with has_more, ask again right away. With reset, the changes since the cursor aren't known (the journal was trimmed, or changes were lost) and the client must take a new snapshot. Changes made directly on disk are only journaled for folders the file watcher is watching.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Sync changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Folder",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Cursor of the snapshot or of the last changes received",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Most changes to return (default 1000, max 10000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out the content hashes",
+                        "name": "no_hashes",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Changes",
+                        "schema": {
+                            "$ref": "#/definitions/entities.SyncDelta"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sync/delta": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace a file by the one rebuilt from it and a delta, computed against the signature from GET /api/sync/signature. If-Match must be the hash of that signature: if the file has changed since, the upload is refused with 412 and current, as for PUT /api/sync/file. The body starts with \"PVD1\" and is a sequence of operations, integers being big-endian: 0x01 followed by an offset (uint64) and a length (uint32) copies that range of the file; 0x02 followed by a length (uint32) and as many bytes appends them; 0x00 followed by the 32-byte SHA-256 of the new file ends the delta. The new file is written like any upload and only replaces the old one, which is kept as a version, if its SHA-256 is the one at the end of the delta.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Delta upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hash of the file the delta was computed against",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File written",
+                        "schema": {
+                            "$ref": "#/definitions/entities.SyncEntry"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or delta",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "412": {
+                        "description": "File was changed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sync/file": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Write the request body to a file, creating the folders above it. With If-Match, the file is only replaced if its hash is the one given (or, with *, if it exists); with If-None-Match: *, it is only created if nothing is there yet. Otherwise the write is refused with 412 and current, the file or folder there now (null if nothing is): the client can download it, retry with its hash to overwrite it, or upload its own version under another name. The response has the new hash, also sent as ETag.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Conditional upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hash the file must have, or *",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "* to only create the file",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File written",
+                        "schema": {
+                            "$ref": "#/definitions/entities.SyncEntry"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "412": {
+                        "description": "File was changed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a file, or a folder with everything in it. With If-Match, a file is only deleted if its hash is the one given (or, with *, if anything is there); otherwise the delete is refused with 412 and current, what is there now.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Conditional delete",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file or folder",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hash the file must have, or *",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "412": {
+                        "description": "File was changed",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sync/signature": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the checksums of every block of a file, for a delta upload: the rsync rolling checksum (weak) and the first 16 bytes of the SHA-256 (strong) of each block of block_size bytes, the last one being shorter. The client finds these blocks anywhere in its own version of the file and sends a delta made of copies of them and of the data that changed to PUT /api/sync/delta, with hash as If-Match. Blocks are about the square root of the file's size unless block_size is given; smaller blocks make a smaller delta but a larger signature. Computing a signature reads the whole file.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "File signature",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Block size in bytes (1024 to 1048576)",
+                        "name": "block_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Signature",
+                        "schema": {
+                            "$ref": "#/definitions/entities.SyncSignature"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "File has too many blocks",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/sync/snapshot": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every file and folder below a folder with its size, modification time and content hash (the hex SHA-256 of the file as clients see it), along with the cursor of the sync journal to ask for the changes made since. Hashes are cached while a file's size and modification time are unchanged, so the first snapshot of a folder may read every file; no_hashes leaves them out. Folders with more than 100000 entries must be synced as several subfolders.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sync"
+                ],
+                "summary": "Sync snapshot",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Folder",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Leave out the content hashes",
+                        "name": "no_hashes",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Snapshot",
+                        "schema": {
+                            "$ref": "#/definitions/entities.SyncSnapshot"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Folder not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "413": {
+                        "description": "Folder has too many entries",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/access-keys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the caller's S3 gateway access keys, without their secrets",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Access Keys"
+                ],
+                "summary": "List S3 access keys",
+                "responses": {
+                    "200": {
+                        "description": "Access keys",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create an access key for the S3 gateway. The secret key is only returned by this call; store it right away.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Access Keys"
+                ],
+                "summary": "Create S3 access key",
+                "parameters": [
+                    {
+                        "description": "Key name",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.CreateAccessKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Access key with its secret",
+                        "schema": {
+                            "$ref": "#/definitions/entities.NewAccessKey"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/access-keys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revoke one of the caller's S3 gateway access keys",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Access Keys"
+                ],
+                "summary": "Delete S3 access key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Access key record ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Access key deleted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Access key not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/keyring/lock": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Forget the caller's keys, so their encrypted folders can't be read until they unlock them again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Encryption"
+                ],
+                "summary": "Lock encrypted folders",
+                "responses": {
+                    "200": {
+                        "description": "Keyring locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/keyring/unlock": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Unlock the caller's encrypted folders with their password. Logging in does this too; unlocking is needed again after the server restarts or the unlock expires.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Encryption"
+                ],
+                "summary": "Unlock encrypted folders",
+                "parameters": [
+                    {
+                        "description": "Password",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.UnlockKeyringRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Keyring unlocked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Wrong password",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "No encrypted folders set up",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/recent": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the files and folders the caller recently opened, previewed, uploaded, created, deleted, moved, copied or restored, through the API, WebDAV or the S3 gateway, newest first. Opening or previewing the same file again within 10 minutes isn't listed again. Activity is kept for ACTIVITY_RETENTION_DAYS.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Recent activity",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only this type of activity (repeatable): opened, previewed, uploaded, created, deleted, moved, copied, restored",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only activity at or after this time (RFC 3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only activity before this time (RFC 3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Entries per page (max 500)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Recent activity",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/transfers": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the caller's bandwidth limits (KiB per second, 0 for none), daily transfer quota (MiB, 0 for none) and what they downloaded and uploaded today, in bytes. remaining is what is left of the quota, absent without one; the quota is renewed at resets_at.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Get transfer limits and usage",
+                "responses": {
+                    "200": {
+                        "description": "Transfer limits and usage",
+                        "schema": {
+                            "$ref": "#/definitions/entities.TransferStatus"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/users/me/version-retention": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the caller's version retention policy (the server default if never set)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Versions"
+                ],
+                "summary": "Get version retention",
+                "responses": {
+                    "200": {
+                        "description": "Retention policy",
+                        "schema": {
+                            "$ref": "#/definitions/entities.VersionRetention"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Set how many previous versions to keep: the last keep_last versions plus the newest version of each of the last keep_daily_days days. Zero in both keeps everything.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Versions"
+                ],
+                "summary": "Set version retention",
+                "parameters": [
+                    {
+                        "description": "Retention policy",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.VersionRetentionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retention policy",
+                        "schema": {
+                            "$ref": "#/definitions/entities.VersionRetention"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/login": {
+            "post": {
+                "description": "Authenticate user and return access and refresh tokens",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "User login",
+                "parameters": [
+                    {
+                        "description": "Login credentials",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Login successful",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid credentials",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "User account inactive",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/register": {
+            "post": {
+                "description": "Register a new user account",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "User registration",
+                "parameters": [
+                    {
+                        "description": "User registration data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.RegisterRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "User registered successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/drive/create-folder": {
+            "post": {
+                "description": "Create a new folder at a given path",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Create folder",
+                "parameters": [
+                    {
+                        "description": "Create folder request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.CreateFolderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Folder created successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/drive/download": {
+            "post": {
+                "description": "Download a file from a given path. With strip_gps the GPS data of JPEG and TIFF-based images is removed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Download file",
+                "parameters": [
+                    {
+                        "description": "Download request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.DownloadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File downloaded successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/drive/list": {
+            "get": {
+                "description": "List all files and directories in a given path, with the caller's tags, favorite flags and color labels",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "List path contents",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path to list contents",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Path contents",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/drive/preview": {
+            "get": {
+                "description": "Preview a file at a given path. With strip_gps the GPS data of JPEG and TIFF-based images is removed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Preview file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path to preview file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove GPS data from images",
+                        "name": "strip_gps",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File previewed successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/drive/root": {
+            "get": {
+                "description": "Get all root drivers",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Get root drivers",
+                "responses": {
+                    "200": {
+                        "description": "Root drivers",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/drive/stream": {
+            "get": {
+                "description": "Stream a file at a given path. With strip_gps the GPS data of JPEG and TIFF-based images is removed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Stream file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path to stream file",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove GPS data from images",
+                        "name": "strip_gps",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File streamed successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "423": {
+                        "description": "Encrypted folder is locked",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/drive/upload-files": {
+            "post": {
+                "description": "Upload files to a given path. With async=true the request returns 202 with a job as soon as the files are received, and they are written in the background; follow the job at /api/jobs/{id} or /api/jobs/events.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Drive"
+                ],
+                "summary": "Upload files",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path to upload files",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Upload type (files or folder)",
+                        "name": "upload_type",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "What to do when a file exists: false (fail), true (replace) or keep_both (save as \\",
+                        "name": "overwrite",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Write the files in a background job",
+                        "name": "async",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Upload results",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "202": {
+                        "description": "Upload job started",
+                        "schema": {
+                            "$ref": "#/definitions/entities.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Server is shutting down",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Report that the process is up and serving HTTP",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "Server is alive",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Check database connectivity and that every vault root is accessible",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Server is ready",
+                        "schema": {
+                            "$ref": "#/definitions/entities.ReadinessReport"
+                        }
+                    },
+                    "503": {
+                        "description": "Server is not ready",
+                        "schema": {
+                            "$ref": "#/definitions/entities.ReadinessReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/details/{user_id}": {
+            "get": {
+                "description": "Get user details by user ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Get user details",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User details",
+                        "schema": {
+                            "$ref": "#/definitions/entities.User"
+                        }
+                    },
+                    "401": {
+                        "description": "User not found in context",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "entities.ChangeEvent": {
+            "type": "object",
+            "properties": {
+                "is_dir": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "old_path": {
+                    "type": "string",
+                    "example": "/home/john/photos/IMG_0001.jpg"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/photos/beach.jpg"
+                },
+                "time": {
+                    "type": "string",
+                    "example": "2025-01-01T00:00:00Z"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "create"
+                }
+            }
+        },
+        "entities.CreateAccessKeyRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 100,
+                    "example": "restic backups"
+                }
+            }
+        },
+        "entities.CreateFolderRequest": {
+            "type": "object",
+            "required": [
+                "path"
+            ],
+            "properties": {
+                "path": {
+                    "type": "string",
+                    "example": "/documents/new_folder"
+                }
+            }
+        },
+        "entities.DiskUsage": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "type": "integer",
+                    "example": 120345
+                },
+                "folders": {
+                    "type": "integer",
+                    "example": 8211
+                },
+                "largest_files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.DiskUsageEntry"
+                    }
+                },
+                "largest_folders": {
+                    "description": "LargestFolders are the largest direct subfolders, LargestFiles the largest files\nanywhere below the folder",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.DiskUsageEntry"
+                    }
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john"
+                },
+                "scanned": {
+                    "description": "Scanned is how many folders were listed for this answer; the others were cached",
+                    "type": "integer",
+                    "example": 12
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 53687091200
+                },
+                "unreadable": {
+                    "type": "integer",
+                    "example": 0
+                }
+            }
+        },
+        "entities.DiskUsageEntry": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "type": "integer",
+                    "example": 311
+                },
+                "folders": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "modified": {
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/videos"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 42949672960
+                }
+            }
+        },
+        "entities.DownloadRequest": {
+            "type": "object",
+            "required": [
+                "path"
+            ],
+            "properties": {
+                "path": {
+                    "type": "string",
+                    "example": "/documents/file.pdf"
+                },
+                "strip_gps": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "entities.EncryptFolderRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "path"
+            ],
+            "properties": {
+                "encrypt_names": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "password": {
+                    "type": "string",
+                    "example": "securePassword123"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/media/usb/private"
+                }
+            }
+        },
+        "entities.EncryptedFolder": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-01-01T00:00:00Z"
+                },
+                "encrypt_names": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "locked": {
+                    "description": "Locked is set when the owner's keyring isn't unlocked, so the folder can't be read",
+                    "type": "boolean",
+                    "example": false
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/media/usb/private"
+                }
+            }
+        },
+        "entities.FetchRequest": {
+            "type": "object",
+            "required": [
+                "path",
+                "url"
+            ],
+            "properties": {
+                "checksum": {
+                    "type": "string",
+                    "example": "sha256:81fae9cc21e2b1e3a9a4526c7dad3131b668e346c580702235ad4d02645d9455"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "ubuntu.iso"
+                },
+                "overwrite": {
+                    "type": "string",
+                    "example": "false"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/isos"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://releases.ubuntu.com/24.04/ubuntu-24.04-desktop-amd64.iso"
+                }
+            }
+        },
+        "entities.FileMeta": {
+            "type": "object",
+            "properties": {
+                "color": {
+                    "type": "string",
+                    "example": "blue"
+                },
+                "favorite": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "notes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/projects/apollo/plan.md"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "apollo",
+                        "planning"
+                    ]
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2024-06-01T18:30:00Z"
+                }
+            }
+        },
+        "entities.FileMetadataRequest": {
+            "type": "object",
+            "required": [
+                "path"
+            ],
+            "properties": {
+                "color": {
+                    "type": "string",
+                    "example": "blue"
+                },
+                "favorite": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "notes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/projects/apollo/plan.md"
+                },
+                "tags": {
+                    "type": "array",
+                    "maxItems": 50,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "apollo",
+                        "planning"
+                    ]
+                }
+            }
+        },
+        "entities.HealthCheck": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "connection refused"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "database"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "entities.Job": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-01-01T00:00:00Z"
+                },
+                "done_bytes": {
+                    "type": "integer",
+                    "example": 104857600
+                },
+                "done_files": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "error": {
+                    "type": "string",
+                    "example": "all uploads failed"
+                },
+                "failed_files": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.JobFile"
+                    }
+                },
+                "finished_at": {
+                    "type": "string",
+                    "example": "2025-01-01T00:05:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "kind": {
+                    "type": "string",
+                    "example": "upload"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "running"
+                },
+                "target": {
+                    "type": "string",
+                    "example": "/home/john/photos"
+                },
+                "total_bytes": {
+                    "type": "integer",
+                    "example": 524288000
+                },
+                "total_files": {
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "entities.JobFile": {
+            "type": "object",
+            "properties": {
+                "done": {
+                    "type": "integer",
+                    "example": 1048576
+                },
+                "error": {
+                    "type": "string",
+                    "example": "file already exists"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "trip/beach.jpg"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/photos/trip/beach.jpg"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 4194304
+                },
+                "status": {
+                    "type": "string",
+                    "example": "running"
+                }
+            }
+        },
+        "entities.LoginRequest": {
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "securePassword123"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "john_doe"
+                }
+            }
+        },
+        "entities.MarkdownBlock": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "language": {
+                    "type": "string",
+                    "example": "go"
+                },
+                "level": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "ordered": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "text": {
+                    "type": "string",
+                    "example": "Installation"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "heading"
+                }
+            }
+        },
+        "entities.MoveRequest": {
+            "type": "object",
+            "required": [
+                "destination",
+                "paths"
+            ],
+            "properties": {
+                "destination": {
+                    "type": "string",
+                    "example": "/home/john/archive"
+                },
+                "paths": {
+                    "type": "array",
+                    "maxItems": 10000,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "/home/john/a.txt",
+                        "/home/john/b.txt"
+                    ]
                 }
             }
         },
-        "entities.LoginRequest": {
+        "entities.NewAccessKey": {
+            "type": "object",
+            "properties": {
+                "access_key_id": {
+                    "type": "string",
+                    "example": "PVK3M5QZ7XW2LJ4N6RT8"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-01-01T00:00:00Z"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "last_used_at": {
+                    "type": "string",
+                    "example": "2025-01-02T00:00:00Z"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "restic backups"
+                },
+                "secret_key": {
+                    "type": "string",
+                    "example": "q8N0yQ3o9mQ1l2y7nJ4kVQ6f7Xo0Zp1sWc9aE3bR"
+                }
+            }
+        },
+        "entities.PhotoIndexRequest": {
             "type": "object",
             "required": [
-                "password",
-                "username"
+                "path"
             ],
             "properties": {
-                "password": {
+                "path": {
                     "type": "string",
-                    "example": "securePassword123"
+                    "example": "/home/john/Pictures"
+                }
+            }
+        },
+        "entities.PhotoMetadata": {
+            "type": "object",
+            "properties": {
+                "altitude": {
+                    "type": "number",
+                    "example": 34.5
                 },
-                "username": {
+                "camera_make": {
                     "type": "string",
-                    "example": "john_doe"
+                    "example": "Canon"
+                },
+                "camera_model": {
+                    "type": "string",
+                    "example": "Canon EOS R6"
+                },
+                "captured_at": {
+                    "type": "string",
+                    "example": "2024-06-01T18:30:00Z"
+                },
+                "captured_offset": {
+                    "type": "string",
+                    "example": "+02:00"
+                },
+                "indexed_at": {
+                    "type": "string",
+                    "example": "2024-06-02T08:00:00Z"
+                },
+                "latitude": {
+                    "type": "number",
+                    "example": 52.520008
+                },
+                "longitude": {
+                    "type": "number",
+                    "example": 13.404954
+                },
+                "modified": {
+                    "type": "string",
+                    "example": "2024-06-01T18:30:00Z"
+                },
+                "orientation": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/Pictures/IMG_0001.jpg"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 3145728
+                }
+            }
+        },
+        "entities.ReadinessReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.HealthCheck"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
                 }
             }
         },
@@ -650,6 +4411,382 @@ const docTemplate = `{
                 }
             }
         },
+        "entities.StructuredPreview": {
+            "type": "object",
+            "properties": {
+                "encoding": {
+                    "type": "string",
+                    "example": "utf-8"
+                },
+                "format": {
+                    "type": "string",
+                    "example": "csv"
+                },
+                "has_more": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "json": {},
+                "markdown": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.MarkdownBlock"
+                    }
+                },
+                "page": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "page_size": {
+                    "type": "integer",
+                    "example": 100
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/report.csv"
+                },
+                "table": {
+                    "$ref": "#/definitions/entities.TablePreview"
+                }
+            }
+        },
+        "entities.SyncBlock": {
+            "type": "object",
+            "properties": {
+                "strong": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015"
+                },
+                "weak": {
+                    "type": "integer",
+                    "example": 2868643201
+                }
+            }
+        },
+        "entities.SyncChange": {
+            "type": "object",
+            "properties": {
+                "cursor": {
+                    "type": "integer",
+                    "example": 1042
+                },
+                "entry": {
+                    "description": "Entry is the current state of Path, unless it has since been removed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/entities.SyncEntry"
+                        }
+                    ]
+                },
+                "is_dir": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "old_path": {
+                    "type": "string",
+                    "example": "/home/john/docs/draft.md"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/docs/plan.md"
+                },
+                "time": {
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "modify"
+                }
+            }
+        },
+        "entities.SyncDelta": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.SyncChange"
+                    }
+                },
+                "cursor": {
+                    "type": "integer",
+                    "example": 1050
+                },
+                "has_more": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/docs"
+                },
+                "reset": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "entities.SyncEntry": {
+            "type": "object",
+            "properties": {
+                "hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "modified": {
+                    "type": "string",
+                    "example": "2024-01-15T10:30:00Z"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/docs/plan.md"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 2048
+                },
+                "type": {
+                    "type": "string",
+                    "example": "file"
+                }
+            }
+        },
+        "entities.SyncSignature": {
+            "type": "object",
+            "properties": {
+                "block_size": {
+                    "type": "integer",
+                    "example": 65536
+                },
+                "blocks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.SyncBlock"
+                    }
+                },
+                "hash": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/vm/disk.img"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 4294967296
+                }
+            }
+        },
+        "entities.SyncSnapshot": {
+            "type": "object",
+            "properties": {
+                "cursor": {
+                    "type": "integer",
+                    "example": 1042
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.SyncEntry"
+                    }
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/home/john/docs"
+                }
+            }
+        },
+        "entities.TablePreview": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "date",
+                        "amount"
+                    ]
+                },
+                "delimiter": {
+                    "type": "string",
+                    "example": ","
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "entities.TagCount": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "tag": {
+                    "type": "string",
+                    "example": "apollo"
+                }
+            }
+        },
+        "entities.TextFollowEvent": {
+            "type": "object",
+            "properties": {
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "truncated": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "type": {
+                    "type": "string",
+                    "example": "lines"
+                }
+            }
+        },
+        "entities.TextPreview": {
+            "type": "object",
+            "properties": {
+                "encoding": {
+                    "type": "string",
+                    "example": "utf-8"
+                },
+                "first_line": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "has_more": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "path": {
+                    "type": "string",
+                    "example": "/var/log/app.log"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 2147483648
+                },
+                "truncated": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "view": {
+                    "type": "string",
+                    "example": "head"
+                }
+            }
+        },
+        "entities.TransferLimit": {
+            "type": "object",
+            "properties": {
+                "daily_quota_mb": {
+                    "type": "integer",
+                    "example": 20480
+                },
+                "download_rate_kb": {
+                    "type": "integer",
+                    "example": 5120
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-01-01T00:00:00Z"
+                },
+                "upload_rate_kb": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "entities.TransferLimitRequest": {
+            "type": "object",
+            "properties": {
+                "daily_quota_mb": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 20480
+                },
+                "download_rate_kb": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 5120
+                },
+                "upload_rate_kb": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 0
+                }
+            }
+        },
+        "entities.TransferStatus": {
+            "type": "object",
+            "properties": {
+                "daily_quota_mb": {
+                    "type": "integer",
+                    "example": 20480
+                },
+                "download_rate_kb": {
+                    "type": "integer",
+                    "example": 5120
+                },
+                "downloaded": {
+                    "type": "integer",
+                    "example": 1073741824
+                },
+                "remaining": {
+                    "type": "integer",
+                    "example": 20349714432
+                },
+                "resets_at": {
+                    "type": "string",
+                    "example": "2025-01-02T00:00:00+01:00"
+                },
+                "upload_rate_kb": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "uploaded": {
+                    "type": "integer",
+                    "example": 52428800
+                }
+            }
+        },
+        "entities.UnlockKeyringRequest": {
+            "type": "object",
+            "required": [
+                "password"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "securePassword123"
+                }
+            }
+        },
         "entities.User": {
             "type": "object",
             "properties": {
@@ -661,6 +4798,10 @@ const docTemplate = `{
                     "type": "string",
                     "example": "550e8400-e29b-41d4-a716-446655440000"
                 },
+                "is_admin": {
+                    "type": "boolean",
+                    "example": false
+                },
                 "last_name": {
                     "type": "string",
                     "example": "Doe"
@@ -670,6 +4811,44 @@ const docTemplate = `{
                     "example": "john_doe"
                 }
             }
+        },
+        "entities.VersionRetention": {
+            "type": "object",
+            "properties": {
+                "keep_daily_days": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "keep_last": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-01-01T00:00:00Z"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "entities.VersionRetentionRequest": {
+            "type": "object",
+            "properties": {
+                "keep_daily_days": {
+                    "type": "integer",
+                    "maximum": 3650,
+                    "minimum": 0,
+                    "example": 30
+                },
+                "keep_last": {
+                    "type": "integer",
+                    "maximum": 10000,
+                    "minimum": 0,
+                    "example": 20
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of activity
+const (
+	ActivityOpened    = "opened"
+	ActivityPreviewed = "previewed"
+	ActivityUploaded  = "uploaded"
+	ActivityCreated   = "created"
+	ActivityDeleted   = "deleted"
+	ActivityMoved     = "moved"
+	ActivityCopied    = "copied"
+	ActivityRestored  = "restored"
+)
+
+// ActivityChangeTypes are the types of activity that change a folder
+var ActivityChangeTypes = []string{ActivityUploaded, ActivityCreated, ActivityDeleted, ActivityMoved, ActivityCopied, ActivityRestored}
+
+// Activity represents something a user did with a file or folder. Folder and OldFolder
+// are the folders Path and OldPath are in, so a folder's feed can find them.
+type Activity struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index:idx_activity_user_created,priority:1" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Username  string    `json:"username" example:"john_doe"`
+	Type      string    `json:"type" gorm:"not null" example:"uploaded"`
+	Path      string    `json:"path" gorm:"not null" example:"/home/john/projects/plan.md"`
+	OldPath   string    `json:"old_path,omitempty" example:"/home/john/plan.md"`
+	Folder    string    `json:"-" gorm:"not null;index"`
+	OldFolder string    `json:"-" gorm:"index"`
+	Bytes     int64     `json:"bytes,omitempty" example:"1024000"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null;index;index:idx_activity_user_created,priority:2" example:"2024-01-15T10:30:00Z"`
+}
@@ -0,0 +1,54 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditActionList         = "list"
+	AuditActionDownload     = "download"
+	AuditActionPreview      = "preview"
+	AuditActionStream       = "stream"
+	AuditActionUpload       = "upload"
+	AuditActionCreateFolder = "create-folder"
+	AuditActionLogin        = "login"
+	AuditActionDelete       = "delete"
+	AuditActionMove         = "move"
+	AuditActionCopy         = "copy"
+	AuditActionFetch        = "fetch"
+	AuditActionPhotoIndex   = "photo-index"
+
+	AuditActionVersionDownload = "version-download"
+	AuditActionVersionPreview  = "version-preview"
+	AuditActionVersionRestore  = "version-restore"
+	AuditActionVersionDelete   = "version-delete"
+	AuditActionVersionPrune    = "version-prune"
+
+	AuditActionEncryptFolder     = "encrypt-folder"
+	AuditActionDisableEncryption = "disable-encryption"
+	AuditActionUnlockKeyring     = "unlock-keyring"
+)
+
+// Outcomes recorded in the audit log
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditLog represents a single entry of the append-only audit trail
+type AuditLog struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" gorm:"type:uuid;index" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Username  string     `json:"username" gorm:"index" example:"john_doe"`
+	IP        string     `json:"ip" example:"192.168.1.20"`
+	UserAgent string     `json:"user_agent" example:"Mozilla/5.0"`
+	Action    string     `json:"action" gorm:"index;not null" example:"download"`
+	Path      string     `json:"path" example:"/documents/document.pdf"`
+	Bytes     int64      `json:"bytes" example:"1024000"`
+	Outcome   string     `json:"outcome" gorm:"not null" example:"success"`
+	Error     string     `json:"error,omitempty" example:"file already exists"`
+	CreatedAt time.Time  `json:"created_at" gorm:"index;not null" example:"2024-01-15T10:30:00Z"`
+}
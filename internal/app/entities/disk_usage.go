@@ -0,0 +1,31 @@
+package entities
+
+import "time"
+
+// DiskUsage represents the space taken by a folder and everything below it, like du.
+// Sizes are the sizes of the files on disk, so encrypted files count with their
+// encryption overhead; hidden files aren't counted. Folders that couldn't be read are
+// counted in Unreadable and left out of the totals.
+type DiskUsage struct {
+	Path       string `json:"path" example:"/home/john"`
+	Size       int64  `json:"size" example:"53687091200"`
+	Files      int64  `json:"files" example:"120345"`
+	Folders    int64  `json:"folders" example:"8211"`
+	Unreadable int64  `json:"unreadable" example:"0"`
+	// Scanned is how many folders were listed for this answer; the others were cached
+	Scanned int64 `json:"scanned" example:"12"`
+	// LargestFolders are the largest direct subfolders, LargestFiles the largest files
+	// anywhere below the folder
+	LargestFolders []DiskUsageEntry `json:"largest_folders"`
+	LargestFiles   []DiskUsageEntry `json:"largest_files"`
+}
+
+// DiskUsageEntry represents a folder or file of a disk usage report. Files and Folders
+// are only set for folders.
+type DiskUsageEntry struct {
+	Path     string    `json:"path" example:"/home/john/videos"`
+	Size     int64     `json:"size" example:"42949672960"`
+	Files    int64     `json:"files,omitempty" example:"311"`
+	Folders  int64     `json:"folders,omitempty" example:"12"`
+	Modified time.Time `json:"modified" example:"2024-01-15T10:30:00Z"`
+}
@@ -0,0 +1,33 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Keyring holds a user's master key, wrapped by a key derived from their password with
+// Argon2id. The master key in turn wraps the keys of the user's encrypted folders, so
+// nothing on disk or in the database can decrypt a file without the password.
+type Keyring struct {
+	UserID        uuid.UUID `gorm:"type:uuid;primary_key"`
+	Salt          []byte    `gorm:"not null"`
+	Argon2Time    uint32    `gorm:"not null"`
+	Argon2Memory  uint32    `gorm:"not null"`
+	Argon2Threads uint8     `gorm:"not null"`
+	WrappedKey    []byte    `gorm:"not null"`
+	CreatedAt     time.Time
+}
+
+// EncryptedFolder represents a folder whose files are stored sealed with AES-256-GCM, and
+// optionally under encrypted names. Its key is wrapped by the owner's master key.
+type EncryptedFolder struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UserID       uuid.UUID `json:"-" gorm:"type:uuid;not null;index"`
+	Path         string    `json:"path" gorm:"not null;uniqueIndex" example:"/media/usb/private"`
+	EncryptNames bool      `json:"encrypt_names" gorm:"not null;default:false" example:"true"`
+	WrappedKey   []byte    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at" example:"2025-01-01T00:00:00Z"`
+	// Locked is set when the owner's keyring isn't unlocked, so the folder can't be read
+	Locked bool `json:"locked" gorm:"-" example:"false"`
+}
@@ -0,0 +1,24 @@
+package entities
+
+import "time"
+
+// Kinds of change events
+const (
+	ChangeCreate = "create"
+	ChangeModify = "modify"
+	ChangeDelete = "delete"
+	ChangeRename = "rename"
+	// ChangeResync tells the client that changes below Path were dropped (too many at once,
+	// or the watcher fell behind) and it should list the folder again
+	ChangeResync = "resync"
+)
+
+// ChangeEvent represents a change to a file or folder, made through the API or directly on
+// disk
+type ChangeEvent struct {
+	Type    string    `json:"type" example:"create"`
+	Path    string    `json:"path" example:"/home/john/photos/beach.jpg"`
+	OldPath string    `json:"old_path,omitempty" example:"/home/john/photos/IMG_0001.jpg"`
+	IsDir   bool      `json:"is_dir" example:"false"`
+	Time    time.Time `json:"time" example:"2025-01-01T00:00:00Z"`
+}
@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of background jobs
+const (
+	JobKindUpload     = "upload"
+	JobKindMove       = "move"
+	JobKindFetch      = "fetch"
+	JobKindPhotoIndex = "photo-index"
+)
+
+// States of a job and of the files it works on
+const (
+	JobStatusPending   = "pending" // files only
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// Job represents a long operation running in the background
+type Job struct {
+	ID          uuid.UUID  `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UserID      uuid.UUID  `json:"-"`
+	Kind        string     `json:"kind" example:"upload"`
+	Target      string     `json:"target" example:"/home/john/photos"`
+	Status      string     `json:"status" example:"running"`
+	TotalFiles  int        `json:"total_files" example:"120"`
+	DoneFiles   int        `json:"done_files" example:"42"`
+	FailedFiles int        `json:"failed_files" example:"1"`
+	TotalBytes  int64      `json:"total_bytes" example:"524288000"`
+	DoneBytes   int64      `json:"done_bytes" example:"104857600"`
+	Error       string     `json:"error,omitempty" example:"all uploads failed"`
+	Files       []JobFile  `json:"files,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" example:"2025-01-01T00:00:00Z"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty" example:"2025-01-01T00:05:00Z"`
+}
+
+// JobFile represents the progress of one file of a job
+type JobFile struct {
+	Name   string `json:"name" example:"trip/beach.jpg"`
+	Path   string `json:"path,omitempty" example:"/home/john/photos/trip/beach.jpg"`
+	Size   int64  `json:"size" example:"4194304"`
+	Done   int64  `json:"done" example:"1048576"`
+	Status string `json:"status" example:"running"`
+	Error  string `json:"error,omitempty" example:"file already exists"`
+}
+
+// Finished reports whether the job has stopped running
+func (j *Job) Finished() bool {
+	return j.Status != JobStatusRunning
+}
@@ -0,0 +1,54 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Color labels a file can be given
+var FileColors = []string{"red", "orange", "yellow", "green", "blue", "purple", "gray"}
+
+// FileMetadata represents what a user has recorded about a file or folder. It is keyed by
+// FileID, the device and inode of the file where the storage has them, so it follows the
+// file when it is renamed; otherwise FileID is derived from the path.
+type FileMetadata struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_file_metadata_user_file"`
+	FileID    string     `gorm:"not null;uniqueIndex:idx_file_metadata_user_file;index"`
+	Path      string     `gorm:"not null;index"`
+	Favorite  bool       `gorm:"not null;default:false"`
+	Color     string     `gorm:"not null;default:''"`
+	Tags      []FileTag  `gorm:"foreignKey:MetadataID;constraint:OnDelete:CASCADE"`
+	Notes     []FileNote `gorm:"foreignKey:MetadataID;constraint:OnDelete:CASCADE"`
+	UpdatedAt time.Time  `gorm:"not null"`
+}
+
+// FileTag represents a tag on a file
+type FileTag struct {
+	MetadataID uuid.UUID `gorm:"type:uuid;primary_key"`
+	Tag        string    `gorm:"primary_key;index"`
+}
+
+// FileNote represents a key/value note on a file
+type FileNote struct {
+	MetadataID uuid.UUID `gorm:"type:uuid;primary_key"`
+	Key        string    `gorm:"primary_key"`
+	Value      string    `gorm:"not null"`
+}
+
+// FileMeta represents the tags, favorite flag, color label and notes of a file
+type FileMeta struct {
+	Path      string            `json:"path" example:"/home/john/projects/apollo/plan.md"`
+	Tags      []string          `json:"tags" example:"apollo,planning"`
+	Favorite  bool              `json:"favorite" example:"true"`
+	Color     string            `json:"color,omitempty" example:"blue"`
+	Notes     map[string]string `json:"notes"`
+	UpdatedAt *time.Time        `json:"updated_at,omitempty" example:"2024-06-01T18:30:00Z"`
+}
+
+// TagCount represents a tag and how many files have it
+type TagCount struct {
+	Tag   string `json:"tag" example:"apollo"`
+	Files int64  `json:"files" example:"42"`
+}
@@ -0,0 +1,35 @@
+package entities
+
+import "time"
+
+// PhotoMetadata represents the EXIF metadata of an image, extracted when it is indexed.
+// CapturedAt is the time shown on the camera's clock; CapturedOffset is its offset from
+// UTC when the camera recorded one.
+type PhotoMetadata struct {
+	Path           string     `json:"path" gorm:"primary_key" example:"/home/john/Pictures/IMG_0001.jpg"`
+	Size           int64      `json:"size" gorm:"not null" example:"3145728"`
+	Modified       time.Time  `json:"modified" gorm:"not null" example:"2024-06-01T18:30:00Z"`
+	CapturedAt     *time.Time `json:"captured_at,omitempty" gorm:"type:timestamp;index" example:"2024-06-01T18:30:00Z"`
+	CapturedOffset string     `json:"captured_offset,omitempty" example:"+02:00"`
+	CameraMake     string     `json:"camera_make,omitempty" example:"Canon"`
+	CameraModel    string     `json:"camera_model,omitempty" example:"Canon EOS R6"`
+	Orientation    int        `json:"orientation,omitempty" example:"1"`
+	Latitude       *float64   `json:"latitude,omitempty" example:"52.520008"`
+	Longitude      *float64   `json:"longitude,omitempty" example:"13.404954"`
+	Altitude       *float64   `json:"altitude,omitempty" example:"34.5"`
+	IndexedAt      time.Time  `json:"indexed_at" gorm:"not null" example:"2024-06-02T08:00:00Z"`
+}
+
+// PhotoDay represents the photos taken on one day of the timeline
+type PhotoDay struct {
+	Date   string          `json:"date" example:"2024-06-01"`
+	Photos []PhotoMetadata `json:"photos"`
+}
+
+// PhotoIndexResult represents the outcome of indexing a folder's photos
+type PhotoIndexResult struct {
+	Scanned int `json:"scanned" example:"1200"`
+	Indexed int `json:"indexed" example:"35"`
+	Removed int `json:"removed" example:"2"`
+	Failed  int `json:"failed" example:"0"`
+}
@@ -1,5 +1,7 @@
 package entities
 
+import "time"
+
 // LoginRequest represents user login credentials
 type LoginRequest struct {
 	Username string `json:"username" binding:"required,username" example:"john_doe"`
@@ -16,10 +18,180 @@ type RegisterRequest struct {
 
 // DownloadRequest represents file download request
 type DownloadRequest struct {
-	Path string `json:"path" binding:"required" example:"/documents/file.pdf"`
+	Path     string `json:"path" binding:"required" example:"/documents/file.pdf"`
+	StripGPS bool   `json:"strip_gps" example:"true"`
 }
 
 // CreateFolderRequest represents folder creation request
 type CreateFolderRequest struct {
 	Path string `json:"path" binding:"required" example:"/documents/new_folder"`
 }
+
+// VersionRetentionRequest represents an update of the caller's version retention policy
+type VersionRetentionRequest struct {
+	KeepLast      int `json:"keep_last" binding:"min=0,max=10000" example:"20"`
+	KeepDailyDays int `json:"keep_daily_days" binding:"min=0,max=3650" example:"30"`
+}
+
+// AuditQueryRequest represents the filters accepted by the audit log endpoint
+type AuditQueryRequest struct {
+	UserID   string    `form:"user_id" binding:"omitempty,uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Username string    `form:"username" example:"john_doe"`
+	Action   string    `form:"action" example:"download"`
+	Path     string    `form:"path" example:"/documents"`
+	Outcome  string    `form:"outcome" binding:"omitempty,oneof=success failure" example:"success"`
+	IP       string    `form:"ip" example:"192.168.1.20"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00" example:"2024-01-01T00:00:00Z"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00" example:"2024-02-01T00:00:00Z"`
+	Format   string    `form:"format" binding:"omitempty,oneof=json csv" example:"csv"`
+	Page     int       `form:"page" binding:"omitempty,min=1" example:"1"`
+	PageSize int       `form:"page_size" binding:"omitempty,min=1,max=500" example:"50"`
+}
+
+// CreateAccessKeyRequest represents the creation of an S3 access key
+type CreateAccessKeyRequest struct {
+	Name string `json:"name" binding:"max=100" example:"restic backups"`
+}
+
+// EncryptFolderRequest represents turning on encryption for an empty (or new) folder. The
+// password creates or unlocks the caller's keyring.
+type EncryptFolderRequest struct {
+	Path         string `json:"path" binding:"required" example:"/media/usb/private"`
+	EncryptNames bool   `json:"encrypt_names" example:"true"`
+	Password     string `json:"password" binding:"required" example:"securePassword123"`
+}
+
+// UnlockKeyringRequest represents unlocking the caller's encrypted folders
+type UnlockKeyringRequest struct {
+	Password string `json:"password" binding:"required" example:"securePassword123"`
+}
+
+// MoveRequest represents moving several files or folders into one folder
+type MoveRequest struct {
+	Paths       []string `json:"paths" binding:"required,min=1,max=10000" example:"/home/john/a.txt,/home/john/b.txt"`
+	Destination string   `json:"destination" binding:"required" example:"/home/john/archive"`
+}
+
+// FetchRequest represents downloading a URL into a vault folder on the server
+type FetchRequest struct {
+	URL       string `json:"url" binding:"required,url" example:"https://releases.ubuntu.com/24.04/ubuntu-24.04-desktop-amd64.iso"`
+	Path      string `json:"path" binding:"required" example:"/home/john/isos"`
+	Name      string `json:"name" example:"ubuntu.iso"`
+	Checksum  string `json:"checksum" example:"sha256:81fae9cc21e2b1e3a9a4526c7dad3131b668e346c580702235ad4d02645d9455"`
+	Overwrite string `json:"overwrite" example:"false"`
+}
+
+// PhotoIndexRequest represents a request to index the photos of a folder
+type PhotoIndexRequest struct {
+	Path string `json:"path" binding:"required" example:"/home/john/Pictures"`
+}
+
+// PhotoTimelineRequest represents the filters accepted by the photo timeline endpoint
+type PhotoTimelineRequest struct {
+	Path     string    `form:"path" example:"/home/john/Pictures"`
+	From     time.Time `form:"from" time_format:"2006-01-02" example:"2024-01-01"`
+	To       time.Time `form:"to" time_format:"2006-01-02" example:"2024-12-31"`
+	Page     int       `form:"page" binding:"omitempty,min=1" example:"1"`
+	PageSize int       `form:"page_size" binding:"omitempty,min=1,max=1000" example:"200"`
+}
+
+// TextPreviewRequest represents a request for some lines of a text file. Lines is the
+// number of lines of a head or tail; From and To are the first and last line of a range.
+type TextPreviewRequest struct {
+	Path  string `form:"path" binding:"required" example:"/var/log/app.log"`
+	View  string `form:"view" binding:"omitempty,oneof=head tail range" example:"tail"`
+	Lines int    `form:"lines" binding:"omitempty,min=1" example:"100"`
+	From  int    `form:"from" binding:"omitempty,min=1" example:"1000"`
+	To    int    `form:"to" binding:"omitempty,min=1" example:"1100"`
+}
+
+// StructuredPreviewRequest represents a request for a parsed preview of a Markdown, JSON
+// or CSV file. The format defaults to the one of the file's extension.
+type StructuredPreviewRequest struct {
+	Path     string `form:"path" binding:"required" example:"/home/john/report.csv"`
+	Format   string `form:"format" binding:"omitempty,oneof=markdown json csv" example:"csv"`
+	NoHeader bool   `form:"no_header" example:"false"`
+	Page     int    `form:"page" binding:"omitempty,min=1" example:"1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=1000" example:"100"`
+}
+
+// FileMetadataRequest represents an update of a file's tags, favorite flag, color label
+// and notes. Fields left out are kept; given tags and notes replace the previous ones.
+type FileMetadataRequest struct {
+	Path     string            `json:"path" binding:"required" example:"/home/john/projects/apollo/plan.md"`
+	Tags     *[]string         `json:"tags" binding:"omitempty,max=50,dive,min=1,max=64" example:"apollo,planning"`
+	Favorite *bool             `json:"favorite" example:"true"`
+	Color    *string           `json:"color" example:"blue"`
+	Notes    map[string]string `json:"notes" binding:"omitempty,max=50,dive,keys,min=1,max=64,endkeys,max=4096"`
+}
+
+// FileMetadataQuery represents the filters of a search of the caller's file metadata.
+// A file must have every tag given.
+type FileMetadataQuery struct {
+	Tags     []string `form:"tag" example:"apollo"`
+	Favorite bool     `form:"favorite" example:"true"`
+	Color    string   `form:"color" example:"blue"`
+	Path     string   `form:"path" example:"/home/john/projects"`
+	Page     int      `form:"page" binding:"omitempty,min=1" example:"1"`
+	PageSize int      `form:"page_size" binding:"omitempty,min=1,max=1000" example:"100"`
+}
+
+// RecentActivityRequest represents the filters of the caller's recent activity
+type RecentActivityRequest struct {
+	Types    []string  `form:"type" binding:"omitempty,dive,oneof=opened previewed uploaded created deleted moved copied restored" example:"uploaded"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00" example:"2024-01-01T00:00:00Z"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00" example:"2024-02-01T00:00:00Z"`
+	Page     int       `form:"page" binding:"omitempty,min=1" example:"1"`
+	PageSize int       `form:"page_size" binding:"omitempty,min=1,max=500" example:"50"`
+}
+
+// FolderActivityRequest represents the filters of the recent changes of a folder
+type FolderActivityRequest struct {
+	Path      string    `form:"path" binding:"required" example:"/home/john/projects"`
+	Recursive bool      `form:"recursive" example:"true"`
+	Types     []string  `form:"type" binding:"omitempty,dive,oneof=uploaded created deleted moved copied restored" example:"uploaded"`
+	From      time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00" example:"2024-01-01T00:00:00Z"`
+	To        time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00" example:"2024-02-01T00:00:00Z"`
+	Page      int       `form:"page" binding:"omitempty,min=1" example:"1"`
+	PageSize  int       `form:"page_size" binding:"omitempty,min=1,max=500" example:"50"`
+}
+
+// DiskUsageRequest represents a request for the disk usage of a folder tree. Limit is how
+// many of the largest subfolders and files are listed; Refresh lists every folder again
+// instead of trusting the cache.
+type DiskUsageRequest struct {
+	Path    string `form:"path" binding:"required" example:"/home/john"`
+	Limit   int    `form:"limit" binding:"omitempty,min=1,max=100" example:"20"`
+	Refresh bool   `form:"refresh" example:"false"`
+}
+
+// SyncSnapshotRequest represents a request for everything below a folder. NoHashes
+// leaves out the content hashes, which may need every file to be read.
+type SyncSnapshotRequest struct {
+	Path     string `form:"path" binding:"required" example:"/home/john/docs"`
+	NoHashes bool   `form:"no_hashes" example:"false"`
+}
+
+// SyncChangesRequest represents a request for the changes below a folder after Cursor,
+// the cursor of a snapshot or of the last changes received
+type SyncChangesRequest struct {
+	Path     string `form:"path" binding:"required" example:"/home/john/docs"`
+	Cursor   int64  `form:"cursor" binding:"min=0" example:"1042"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=10000" example:"1000"`
+	NoHashes bool   `form:"no_hashes" example:"false"`
+}
+
+// SyncSignatureRequest represents a request for the block checksums of a file. Without
+// BlockSize, blocks are about the square root of the file's size.
+type SyncSignatureRequest struct {
+	Path      string `form:"path" binding:"required" example:"/home/john/vm/disk.img"`
+	BlockSize int    `form:"block_size" binding:"omitempty,min=1024,max=1048576" example:"65536"`
+}
+
+// TransferLimitRequest represents the bandwidth limits an admin sets for a user: rates in
+// KiB per second and a daily quota in MiB, 0 for no limit
+type TransferLimitRequest struct {
+	DownloadRateKB int64 `json:"download_rate_kb" binding:"min=0" example:"5120"`
+	UploadRateKB   int64 `json:"upload_rate_kb" binding:"min=0" example:"0"`
+	DailyQuotaMB   int64 `json:"daily_quota_mb" binding:"min=0" example:"20480"`
+}
@@ -1,6 +1,7 @@
 package entities
 
 import (
+	"io"
 	"os"
 	"time"
 )
@@ -21,6 +22,10 @@ type FileInfo struct {
 	Type     string    `json:"type" example:"file"`
 	Size     int64     `json:"size" example:"1024000"`
 	Modified time.Time `json:"modified" example:"2024-01-15T10:30:00Z"`
+	Tags     []string  `json:"tags,omitempty" example:"apollo,planning"`
+	Favorite bool      `json:"favorite,omitempty" example:"true"`
+	Color    string    `json:"color,omitempty" example:"blue"`
+	FileID   string    `json:"-"`
 }
 
 // UploadResult represents the result of a file upload
@@ -31,11 +36,31 @@ type UploadResult struct {
 	Error string `json:"error,omitempty" example:"File already exists"`
 }
 
+// MoveResult represents the outcome of moving one path of a bulk move
+type MoveResult struct {
+	Src   string `json:"src" example:"/home/john/a.txt"`
+	Dst   string `json:"dst,omitempty" example:"/home/john/archive/a.txt"`
+	Error string `json:"error,omitempty" example:"file already exists"`
+}
+
 // PreviewInfo represents the information needed for file preview
 type PreviewInfo struct {
-	File           *os.File    `json:"file" example:"*os.File"`
-	Info           os.FileInfo `json:"info" example:"os.FileInfo"`
-	AbsPath        string      `json:"abs_path" example:"/documents/document.pdf"`
-	MimeType       string      `json:"mime_type" example:"application/pdf"`
-	ShouldUseRange bool        `json:"should_use_range" example:"true"`
+	File           io.ReadSeekCloser `json:"file" example:"io.ReadSeekCloser"`
+	Info           os.FileInfo       `json:"info" example:"os.FileInfo"`
+	AbsPath        string            `json:"abs_path" example:"/documents/document.pdf"`
+	MimeType       string            `json:"mime_type" example:"application/pdf"`
+	ShouldUseRange bool              `json:"should_use_range" example:"true"`
+}
+
+// HealthCheck represents the result of a single readiness check
+type HealthCheck struct {
+	Name   string `json:"name" example:"database"`
+	Status string `json:"status" example:"ok"`
+	Error  string `json:"error,omitempty" example:"connection refused"`
+}
+
+// ReadinessReport represents the overall readiness of the server
+type ReadinessReport struct {
+	Status string        `json:"status" example:"ok"`
+	Checks []HealthCheck `json:"checks"`
 }
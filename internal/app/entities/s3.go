@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccessKey lets S3 clients sign requests on behalf of a user. SigV4 signatures are HMACs
+// keyed with the secret, so unlike a password the secret must be stored as is; it is only
+// shown to the user when the key is created.
+type AccessKey struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()" example:"550e8400-e29b-41d4-a716-446655440000"`
+	UserID      uuid.UUID  `json:"-" gorm:"type:uuid;not null;index"`
+	AccessKeyID string     `json:"access_key_id" gorm:"size:32;not null;uniqueIndex" example:"PVK3M5QZ7XW2LJ4N6RT8"`
+	SecretKey   string     `json:"-" gorm:"not null"`
+	Name        string     `json:"name" example:"restic backups"`
+	CreatedAt   time.Time  `json:"created_at" example:"2025-01-01T00:00:00Z"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" example:"2025-01-02T00:00:00Z"`
+}
+
+// NewAccessKey represents a freshly created access key together with its secret
+type NewAccessKey struct {
+	AccessKey
+	SecretKey string `json:"secret_key" example:"q8N0yQ3o9mQ1l2y7nJ4kVQ6f7Xo0Zp1sWc9aE3bR"`
+}
+
+// MultipartUpload represents an S3 multipart upload in progress. Parts are kept in the data
+// dir until the upload is completed or aborted.
+type MultipartUpload struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Bucket    string    `json:"bucket" gorm:"not null"`
+	Key       string    `json:"key" gorm:"not null"`
+	Path      string    `json:"path" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null;index"`
+}
+
+// UploadPart represents one stored part of a multipart upload
+type UploadPart struct {
+	Number int
+	ETag   string
+	Size   int64
+}
+
+// S3Object identifies an object addressed through the S3 gateway and the vault path
+// it maps to
+type S3Object struct {
+	Bucket string
+	Key    string
+	Path   string
+}
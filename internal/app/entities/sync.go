@@ -0,0 +1,75 @@
+package entities
+
+import "time"
+
+// SyncChange represents an entry of the sync journal: a change to a file or folder, made
+// through the vault or seen by the file watcher. ID is the cursor of the change and only
+// grows. A ChangeResync with an empty path means changes may have been lost (the server
+// restarted or fell behind) and every client must take a new snapshot.
+type SyncChange struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"cursor" example:"1042"`
+	Type      string    `gorm:"size:16;not null" json:"type" example:"modify"`
+	Path      string    `gorm:"not null" json:"path" example:"/home/john/docs/plan.md"`
+	OldPath   string    `json:"old_path,omitempty" example:"/home/john/docs/draft.md"`
+	IsDir     bool      `gorm:"not null;default:false" json:"is_dir" example:"false"`
+	CreatedAt time.Time `gorm:"index" json:"time" example:"2024-01-15T10:30:00Z"`
+	// Entry is the current state of Path, unless it has since been removed
+	Entry *SyncEntry `gorm:"-" json:"entry,omitempty"`
+}
+
+// SyncEntry represents a file or folder as the sync API reports it. Hash is the
+// hex-encoded SHA-256 of a file's content, as clients see it.
+type SyncEntry struct {
+	Path     string    `json:"path" example:"/home/john/docs/plan.md"`
+	Type     string    `json:"type" example:"file"`
+	Size     int64     `json:"size" example:"2048"`
+	Modified time.Time `json:"modified" example:"2024-01-15T10:30:00Z"`
+	Hash     string    `json:"hash,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+}
+
+// SyncSnapshot represents every file and folder below a folder. Changes after Cursor are
+// not in it.
+type SyncSnapshot struct {
+	Path    string      `json:"path" example:"/home/john/docs"`
+	Cursor  int64       `json:"cursor" example:"1042"`
+	Entries []SyncEntry `json:"entries"`
+}
+
+// SyncDelta represents the changes below a folder since a cursor, oldest first. With
+// Reset, the changes since the cursor aren't known and the client must take a new
+// snapshot.
+type SyncDelta struct {
+	Path    string       `json:"path" example:"/home/john/docs"`
+	Cursor  int64        `json:"cursor" example:"1050"`
+	HasMore bool         `json:"has_more" example:"false"`
+	Reset   bool         `json:"reset" example:"false"`
+	Changes []SyncChange `json:"changes"`
+}
+
+// SyncSignature represents the checksums of every block of a file, from which a client
+// computes the delta that turns it into its own version. Hash is the file's hash, to
+// send as If-Match with the delta.
+type SyncSignature struct {
+	Path      string      `json:"path" example:"/home/john/vm/disk.img"`
+	Size      int64       `json:"size" example:"4294967296"`
+	Hash      string      `json:"hash" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	BlockSize int         `json:"block_size" example:"65536"`
+	Blocks    []SyncBlock `json:"blocks"`
+}
+
+// SyncBlock represents the checksums of a block: Weak is the rsync rolling checksum and
+// Strong the hex-encoded first 16 bytes of its SHA-256
+type SyncBlock struct {
+	Weak   uint32 `json:"weak" example:"2868643201"`
+	Strong string `json:"strong" example:"9f86d081884c7d659a2feaa0c55ad015"`
+}
+
+// FileHash caches the content hash of a file for as long as its size and modification
+// time (in nanoseconds) are unchanged
+type FileHash struct {
+	Path      string `gorm:"primaryKey"`
+	Size      int64  `gorm:"not null"`
+	ModTime   int64  `gorm:"not null"`
+	Hash      string `gorm:"size:64;not null"`
+	UpdatedAt time.Time
+}
@@ -0,0 +1,68 @@
+package entities
+
+// Views of a text preview
+const (
+	TextViewHead  = "head"
+	TextViewTail  = "tail"
+	TextViewRange = "range"
+)
+
+// Types of follow events
+const (
+	TextFollowLines = "lines"
+	TextFollowReset = "reset"
+)
+
+// TextPreview represents some lines of a text file, decoded to UTF-8. FirstLine is the
+// number of the first line, or 0 for a tail, whose line numbers aren't known.
+type TextPreview struct {
+	Path      string   `json:"path" example:"/var/log/app.log"`
+	Encoding  string   `json:"encoding" example:"utf-8"`
+	Size      int64    `json:"size" example:"2147483648"`
+	View      string   `json:"view" example:"head"`
+	FirstLine int      `json:"first_line,omitempty" example:"1"`
+	Lines     []string `json:"lines"`
+	HasMore   bool     `json:"has_more" example:"true"`
+	Truncated bool     `json:"truncated,omitempty" example:"false"`
+}
+
+// TextFollowEvent represents lines appended to a followed file, or the file being
+// truncated, after which it is followed from its start again
+type TextFollowEvent struct {
+	Type      string   `json:"type" example:"lines"`
+	Lines     []string `json:"lines,omitempty"`
+	Truncated bool     `json:"truncated,omitempty" example:"false"`
+}
+
+// StructuredPreview represents a Markdown, JSON or CSV file parsed for display. Only the
+// field of its format is set; for CSV files Page and PageSize select the rows.
+type StructuredPreview struct {
+	Path     string          `json:"path" example:"/home/john/report.csv"`
+	Encoding string          `json:"encoding" example:"utf-8"`
+	Format   string          `json:"format" example:"csv"`
+	Markdown []MarkdownBlock `json:"markdown,omitempty"`
+	JSON     any             `json:"json,omitempty"`
+	Table    *TablePreview   `json:"table,omitempty"`
+	Page     int             `json:"page,omitempty" example:"1"`
+	PageSize int             `json:"page_size,omitempty" example:"100"`
+	HasMore  bool            `json:"has_more" example:"true"`
+}
+
+// MarkdownBlock represents a top-level block of a Markdown document: a heading,
+// paragraph, code, list, quote, table or rule. Inline markup is left in the text.
+type MarkdownBlock struct {
+	Type     string     `json:"type" example:"heading"`
+	Level    int        `json:"level,omitempty" example:"2"`
+	Text     string     `json:"text,omitempty" example:"Installation"`
+	Language string     `json:"language,omitempty" example:"go"`
+	Ordered  bool       `json:"ordered,omitempty" example:"false"`
+	Items    []string   `json:"items,omitempty"`
+	Rows     [][]string `json:"rows,omitempty"`
+}
+
+// TablePreview represents a page of the rows of a CSV file
+type TablePreview struct {
+	Delimiter string     `json:"delimiter" example:","`
+	Columns   []string   `json:"columns,omitempty" example:"date,amount"`
+	Rows      [][]string `json:"rows"`
+}
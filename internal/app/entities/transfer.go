@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransferLimit represents the bandwidth limits an admin set for a user, in place of the
+// server defaults. Rates are in KiB per second and the quota in MiB a day; 0 means no
+// limit.
+type TransferLimit struct {
+	UserID         uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key" example:"550e8400-e29b-41d4-a716-446655440000"`
+	DownloadRateKB int64     `json:"download_rate_kb" gorm:"not null" example:"5120"`
+	UploadRateKB   int64     `json:"upload_rate_kb" gorm:"not null" example:"0"`
+	DailyQuotaMB   int64     `json:"daily_quota_mb" gorm:"not null" example:"20480"`
+	UpdatedAt      time.Time `json:"updated_at" example:"2025-01-01T00:00:00Z"`
+}
+
+// TransferUsage represents how many bytes a user downloaded and uploaded on a day
+// (YYYY-MM-DD, in the server's time zone)
+type TransferUsage struct {
+	UserID     uuid.UUID `gorm:"type:uuid;primary_key"`
+	Day        string    `gorm:"size:10;primary_key"`
+	Downloaded int64     `gorm:"not null"`
+	Uploaded   int64     `gorm:"not null"`
+}
+
+// TransferStatus represents a user's bandwidth limits and what they transferred today.
+// Remaining is what is left of the daily quota, absent if there is none; the quota is
+// renewed at ResetsAt.
+type TransferStatus struct {
+	DownloadRateKB int64     `json:"download_rate_kb" example:"5120"`
+	UploadRateKB   int64     `json:"upload_rate_kb" example:"0"`
+	DailyQuotaMB   int64     `json:"daily_quota_mb" example:"20480"`
+	Downloaded     int64     `json:"downloaded" example:"1073741824"`
+	Uploaded       int64     `json:"uploaded" example:"52428800"`
+	Remaining      *int64    `json:"remaining,omitempty" example:"20349714432"`
+	ResetsAt       time.Time `json:"resets_at" example:"2025-01-02T00:00:00+01:00"`
+}
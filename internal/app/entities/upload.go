@@ -0,0 +1,34 @@
+package entities
+
+import "fmt"
+
+// OverwriteMode decides what an upload does when the destination file already exists
+type OverwriteMode string
+
+const (
+	// OverwriteNever fails the upload with "file already exists"
+	OverwriteNever OverwriteMode = "false"
+	// OverwriteReplace atomically replaces the existing file
+	OverwriteReplace OverwriteMode = "true"
+	// OverwriteKeepBoth keeps the existing file and saves the upload as "name (1).ext"
+	OverwriteKeepBoth OverwriteMode = "keep_both"
+)
+
+// ParseOverwriteMode parses the "overwrite" form field. An empty value means OverwriteNever.
+func ParseOverwriteMode(s string) (OverwriteMode, error) {
+	switch OverwriteMode(s) {
+	case "", OverwriteNever:
+		return OverwriteNever, nil
+	case OverwriteReplace, OverwriteKeepBoth:
+		return OverwriteMode(s), nil
+	}
+	return "", fmt.Errorf("invalid overwrite mode %q: must be true, false or keep_both", s)
+}
+
+// StoredFile describes a file after it has been written to disk
+type StoredFile struct {
+	Path string
+	Size int64
+	// Hash is the hex-encoded SHA-256 of the content
+	Hash string
+}
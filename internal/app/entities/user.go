@@ -8,4 +8,5 @@ type User struct {
 	Password  string    `json:"-" gorm:"not null"`
 	FirstName string    `json:"first_name" gorm:"not null" example:"John"`
 	LastName  string    `json:"last_name" gorm:"not null" example:"Doe"`
+	IsAdmin   bool      `json:"is_admin" gorm:"not null;default:false" example:"false"`
 }
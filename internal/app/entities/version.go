@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileVersion represents one revision of a file. The live revision has no ArchivedAt; once it
+// is overwritten its content is copied into the version store and ArchivedAt is set.
+type FileVersion struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:uuid_generate_v4()" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Path       string     `json:"path" gorm:"not null;index" example:"/documents/report.pdf"`
+	Size       int64      `json:"size" gorm:"not null" example:"1024000"`
+	Hash       string     `json:"hash" gorm:"size:64;not null" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	AuthorID   *uuid.UUID `json:"author_id,omitempty" gorm:"type:uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	AuthorName string     `json:"author_name,omitempty" example:"john_doe"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"not null" example:"2025-01-01T00:00:00Z"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty" gorm:"index" example:"2025-01-02T00:00:00Z"`
+	BlobPath   string     `json:"-"`
+}
+
+// VersionRetention represents how many previous versions a user keeps. Zero in both fields
+// means versions are never pruned automatically.
+type VersionRetention struct {
+	UserID        uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key" example:"550e8400-e29b-41d4-a716-446655440000"`
+	KeepLast      int       `json:"keep_last" gorm:"not null" example:"20"`
+	KeepDailyDays int       `json:"keep_daily_days" gorm:"not null" example:"30"`
+	UpdatedAt     time.Time `json:"updated_at" example:"2025-01-01T00:00:00Z"`
+}
+
+// Actor identifies the user performing an operation
+type Actor struct {
+	UserID   uuid.UUID
+	Username string
+}
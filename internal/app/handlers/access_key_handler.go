@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AccessKeyHandler struct {
+	AccessKeyService services.AccessKeyService
+	logger           *slog.Logger
+}
+
+func NewAccessKeyHandler(accessKeys services.AccessKeyService, logger *slog.Logger) *AccessKeyHandler {
+	return &AccessKeyHandler{
+		AccessKeyService: accessKeys,
+		logger:           logger.With("component", "handler.access_key"),
+	}
+}
+
+// CreateAccessKey godoc
+// @Summary      Create S3 access key
+// @Description  Create an access key for the S3 gateway. The secret key is only returned by this call; store it right away.
+// @Tags         Access Keys
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body entities.CreateAccessKeyRequest true "Key name"
+// @Success      201 {object} entities.NewAccessKey "Access key with its secret"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/users/me/access-keys [post]
+func (h *AccessKeyHandler) CreateAccessKey(c *gin.Context) {
+	var req entities.CreateAccessKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+
+	key, err := h.AccessKeyService.Create(c.Request.Context(), actorFromContext(c).UserID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"Data":    key,
+		"message": "access key created successfully",
+	})
+}
+
+// ListAccessKeys godoc
+// @Summary      List S3 access keys
+// @Description  List the caller's S3 gateway access keys, without their secrets
+// @Tags         Access Keys
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]any "Access keys"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/users/me/access-keys [get]
+func (h *AccessKeyHandler) ListAccessKeys(c *gin.Context) {
+	keys, err := h.AccessKeyService.List(c.Request.Context(), actorFromContext(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    keys,
+		"message": "fetch access keys successfully",
+	})
+}
+
+// DeleteAccessKey godoc
+// @Summary      Delete S3 access key
+// @Description  Revoke one of the caller's S3 gateway access keys
+// @Tags         Access Keys
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Access key record ID"
+// @Success      200 {object} map[string]string "Access key deleted"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Access key not found"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/users/me/access-keys/{id} [delete]
+func (h *AccessKeyHandler) DeleteAccessKey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid access key id"})
+		return
+	}
+
+	if err := h.AccessKeyService.Delete(c.Request.Context(), actorFromContext(c).UserID, id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrAccessKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "access key deleted successfully"})
+}
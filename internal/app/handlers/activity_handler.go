@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ActivityHandler struct {
+	ActivityService services.ActivityService
+	logger          *slog.Logger
+}
+
+func NewActivityHandler(activity services.ActivityService, logger *slog.Logger) *ActivityHandler {
+	return &ActivityHandler{
+		ActivityService: activity,
+		logger:          logger.With("component", "handler.activity"),
+	}
+}
+
+// GetRecent godoc
+// @Summary      Recent activity
+// @Description  List the files and folders the caller recently opened, previewed, uploaded, created, deleted, moved, copied or restored, through the API, WebDAV or the S3 gateway, newest first. Opening or previewing the same file again within 10 minutes isn't listed again. Activity is kept for ACTIVITY_RETENTION_DAYS.
+// @Tags         User
+// @Produce      json
+// @Security     BearerAuth
+// @Param        type query []string false "Only this type of activity (repeatable): opened, previewed, uploaded, created, deleted, moved, copied, restored" collectionFormat(multi)
+// @Param        from query string false "Only activity at or after this time (RFC 3339)"
+// @Param        to query string false "Only activity before this time (RFC 3339)"
+// @Param        page query int false "Page number"
+// @Param        page_size query int false "Entries per page (max 500)"
+// @Success      200 {object} map[string]any "Recent activity"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/users/me/recent [get]
+func (h *ActivityHandler) GetRecent(c *gin.Context) {
+	var req entities.RecentActivityRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	activities, total, err := h.ActivityService.Recent(c.Request.Context(), actorFromContext(c).UserID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":      activities,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+		"message":   "fetch recent activity successfully",
+	})
+}
+
+// GetFolderActivity godoc
+// @Summary      Folder activity
+// @Description  List the recent changes to the entries of a folder by any user, newest first: uploads, new folders, deletes, copies, restores, and moves into or out of it. With recursive, changes in its subfolders are listed too.
+// @Tags         Drive
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Folder"
+// @Param        recursive query bool false "Include changes in subfolders"
+// @Param        type query []string false "Only this type of change (repeatable): uploaded, created, deleted, moved, copied, restored" collectionFormat(multi)
+// @Param        from query string false "Only changes at or after this time (RFC 3339)"
+// @Param        to query string false "Only changes before this time (RFC 3339)"
+// @Param        page query int false "Page number"
+// @Param        page_size query int false "Entries per page (max 500)"
+// @Success      200 {object} map[string]any "Folder activity"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/activity [get]
+func (h *ActivityHandler) GetFolderActivity(c *gin.Context) {
+	var req entities.FolderActivityRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	activities, total, err := h.ActivityService.Folder(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":      activities,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+		"message":   "fetch folder activity successfully",
+	})
+}
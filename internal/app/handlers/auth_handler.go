@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
@@ -11,12 +11,16 @@ import (
 )
 
 type AuthHandler struct {
-	authService services.AuthService
+	authService  services.AuthService
+	auditService services.AuditService
+	logger       *slog.Logger
 }
 
-func NewAuthHandler(authService services.AuthService) *AuthHandler {
+func NewAuthHandler(authService services.AuthService, auditService services.AuditService, logger *slog.Logger) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:  authService,
+		auditService: auditService,
+		logger:       logger.With("component", "handler.auth"),
 	}
 }
 
@@ -34,27 +38,32 @@ func NewAuthHandler(authService services.AuthService) *AuthHandler {
 // @Failure      500 {object} map[string]string "Internal server error"
 // @Router       /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
+	ctx := c.Request.Context()
+
 	//Read the Raw body
 	body, _ := c.GetRawData()
-	fmt.Println("Raw login request body:", string(body))
 
 	var jsonData map[string]any
 	err := json.Unmarshal(body, &jsonData)
 	if err != nil {
-		fmt.Printf("JSON parsing error: %v\n", err)
+		h.logger.WarnContext(ctx, "invalid login request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid JSON format",
 		})
+		return
 	}
 
-	fmt.Printf("Parsed JSON: %+v\n", jsonData)
-
 	req := entities.LoginRequest{
 		Username: getString(jsonData, "username"),
 		Password: getString(jsonData, "password"),
 	}
 
-	accessToken, refreshToken, err := h.authService.Login(req.Username, req.Password)
+	accessToken, refreshToken, err := h.authService.Login(ctx, req.Username, req.Password)
+
+	entry := newAuditEntry(c, entities.AuditActionLogin, "", 0, err)
+	entry.Username = req.Username
+	h.auditService.Record(entry)
+
 	if err != nil {
 		if err == services.ErrInvalidCredentials {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
@@ -86,22 +95,20 @@ func (h *AuthHandler) Login(c *gin.Context) {
 // @Failure      500 {object} map[string]string "Internal server error"
 // @Router       /auth/register [post]
 func (h *AuthHandler) Register(c *gin.Context) {
+	ctx := c.Request.Context()
 
 	body, _ := c.GetRawData()
-	fmt.Println("Raw request body: ", string(body))
 
 	var jsonData map[string]any
 	err := json.Unmarshal(body, &jsonData)
 	if err != nil {
-		fmt.Printf("JSON parsing error %v\n", err)
+		h.logger.WarnContext(ctx, "invalid register request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "invalid JSON format",
 		})
 		return
 	}
 
-	fmt.Printf("Parsed JSON %+v\n", jsonData)
-
 	req := entities.RegisterRequest{
 		Username:  getString(jsonData, "username"),
 		FirstName: getString(jsonData, "first_name"),
@@ -109,11 +116,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Password:  getString(jsonData, "password"),
 	}
 
-	if err := h.authService.Register(&req); err != nil {
-		fmt.Printf("Registration error: %v\n", err)
+	if err := h.authService.Register(ctx, &req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to register the user",
 		})
+		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "user registered successfully"})
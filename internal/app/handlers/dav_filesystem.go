@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"golang.org/x/net/webdav"
+)
+
+var (
+	errIsDirectory  = errors.New("is a directory")
+	errNotDirectory = errors.New("not a directory")
+)
+
+type davActorKey struct{}
+type davBodyKey struct{}
+
+// davFileSystem exposes the vault to golang.org/x/net/webdav. Every operation goes through
+// DriverService, so path restrictions, atomic writes and versioning apply exactly as they
+// do for the REST API.
+//
+// WebDAV paths map onto absolute paths: "/home/john/a.txt" on unix, "/C:/Users/a.txt" on
+// windows. On windows "/" is a virtual folder listing the drives.
+type davFileSystem struct {
+	drivers services.DriverService
+}
+
+// resolve turns a WebDAV path into a file system path. The second result is true for the
+// virtual root on windows.
+func (d *davFileSystem) resolve(name string) (string, bool) {
+	name = path.Clean("/" + name)
+	if runtime.GOOS != "windows" {
+		return name, false
+	}
+
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		return "", true
+	}
+	if len(name) == 2 && name[1] == ':' {
+		name += "/"
+	}
+	return name, false
+}
+
+func (d *davFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	dir, virtual := d.resolve(name)
+	if virtual {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+
+	if _, err := d.drivers.Stat(ctx, dir); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	// MKCOL must not create missing parents
+	if _, err := d.drivers.Stat(ctx, filepath.Dir(dir)); err != nil {
+		return davError("mkdir", name, err)
+	}
+
+	return davError("mkdir", name, d.drivers.CreateFolder(ctx, dir))
+}
+
+func (d *davFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	p, virtual := d.resolve(name)
+
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if virtual {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDirectory}
+		}
+		return d.openForWrite(ctx, name, p, flag)
+	}
+
+	if virtual {
+		return &davDir{info: davRootInfo(), list: func() ([]os.FileInfo, error) { return d.listRoots(ctx) }}, nil
+	}
+
+	info, err := d.drivers.Stat(ctx, p)
+	if err != nil {
+		return nil, davError("open", name, err)
+	}
+
+	if info.IsDir() {
+		return &davDir{info: davFileInfo{info}, list: func() ([]os.FileInfo, error) { return d.listDir(ctx, p) }}, nil
+	}
+
+	stream, err := d.drivers.StreamFile(ctx, p)
+	if err != nil {
+		return nil, davError("open", name, err)
+	}
+	return &davReadFile{ReadSeekCloser: stream.File, info: davFileInfo{stream.Info}}, nil
+}
+
+// openForWrite streams everything written to the returned file into DriverService.SaveFile
+// through a pipe, so the content lands atomically (and the old content is versioned) when
+// the file is closed.
+func (d *davFileSystem) openForWrite(ctx context.Context, name, p string, flag int) (webdav.File, error) {
+	if info, err := d.drivers.Stat(ctx, p); err == nil && info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errIsDirectory}
+	}
+	if _, err := d.drivers.Stat(ctx, filepath.Dir(p)); err != nil {
+		return nil, davError("open", name, err)
+	}
+
+	mode := entities.OverwriteReplace
+	if flag&os.O_EXCL != 0 {
+		mode = entities.OverwriteNever
+	}
+
+	actor, _ := ctx.Value(davActorKey{}).(entities.Actor)
+	body, _ := ctx.Value(davBodyKey{}).(*davBody)
+
+	pr, pw := io.Pipe()
+	f := &davWriteFile{name: path.Base(name), pw: pw, body: body, done: make(chan error, 1)}
+
+	go func() {
+		_, err := d.drivers.SaveFile(ctx, actor, p, pr, mode)
+		// Unblocks a writer still waiting on the pipe if saving failed early
+		pr.CloseWithError(err)
+		f.done <- davError("write", name, err)
+	}()
+
+	return f, nil
+}
+
+func (d *davFileSystem) RemoveAll(ctx context.Context, name string) error {
+	p, virtual := d.resolve(name)
+	if virtual {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	return davError("remove", name, d.drivers.Delete(ctx, p))
+}
+
+func (d *davFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	src, srcVirtual := d.resolve(oldName)
+	dst, dstVirtual := d.resolve(newName)
+	if srcVirtual || dstVirtual {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrPermission}
+	}
+	return davError("rename", oldName, d.drivers.Move(ctx, src, dst))
+}
+
+func (d *davFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	p, virtual := d.resolve(name)
+	if virtual {
+		return davRootInfo(), nil
+	}
+
+	info, err := d.drivers.Stat(ctx, p)
+	if err != nil {
+		return nil, davError("stat", name, err)
+	}
+	return davFileInfo{info}, nil
+}
+
+// listDir lists a folder the same way the REST API does, so hidden and system entries
+// stay hidden.
+func (d *davFileSystem) listDir(ctx context.Context, dir string) ([]os.FileInfo, error) {
+	files, err := d.drivers.ListPath(ctx, dir)
+	if err != nil {
+		return nil, davError("readdir", dir, err)
+	}
+
+	infos := make([]os.FileInfo, 0, len(files))
+	for _, f := range files {
+		infos = append(infos, davEntryInfo{f})
+	}
+	return infos, nil
+}
+
+func (d *davFileSystem) listRoots(ctx context.Context) ([]os.FileInfo, error) {
+	roots, err := d.drivers.GetRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(roots))
+	for _, root := range roots {
+		infos = append(infos, davEntryInfo{entities.FileInfo{
+			Name:     strings.TrimSuffix(root.Path, "/"),
+			Path:     root.Path,
+			Type:     "folder",
+			Modified: root.Modified,
+		}})
+	}
+	return infos, nil
+}
+
+// davError translates service errors into the os errors the webdav package checks for
+// (os.IsNotExist and friends don't look through %w wrapping).
+func davError(op, name string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case errors.Is(err, services.ErrPathNotAllowed), errors.Is(err, fs.ErrPermission),
+		errors.Is(err, services.ErrFolderLocked), errors.Is(err, services.ErrEncryptionBoundary):
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	case errors.Is(err, services.ErrFileExists), errors.Is(err, fs.ErrExist):
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	return err
+}
+
+// davFileInfo answers the content type from the file extension, so PROPFIND doesn't open
+// and sniff every file of a folder.
+type davFileInfo struct {
+	os.FileInfo
+}
+
+func (fi davFileInfo) ContentType(ctx context.Context) (string, error) {
+	return contentTypeByName(fi.Name())
+}
+
+// davEntryInfo adapts a listing entry to os.FileInfo.
+type davEntryInfo struct {
+	f entities.FileInfo
+}
+
+func (fi davEntryInfo) Name() string       { return fi.f.Name }
+func (fi davEntryInfo) Size() int64        { return fi.f.Size }
+func (fi davEntryInfo) ModTime() time.Time { return fi.f.Modified }
+func (fi davEntryInfo) IsDir() bool        { return fi.f.Type == "folder" }
+func (fi davEntryInfo) Sys() any           { return nil }
+
+func (fi davEntryInfo) Mode() os.FileMode {
+	if fi.IsDir() {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (fi davEntryInfo) ContentType(ctx context.Context) (string, error) {
+	return contentTypeByName(fi.f.Name)
+}
+
+func contentTypeByName(name string) (string, error) {
+	if ct := mime.TypeByExtension(filepath.Ext(name)); ct != "" {
+		return ct, nil
+	}
+	return "", webdav.ErrNotImplemented
+}
+
+func davRootInfo() os.FileInfo {
+	return davEntryInfo{entities.FileInfo{Name: "/", Type: "folder", Modified: time.Now()}}
+}
+
+// davReadFile is a file opened for reading.
+type davReadFile struct {
+	io.ReadSeekCloser
+	info os.FileInfo
+}
+
+func (f *davReadFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *davReadFile) Write([]byte) (int, error)          { return 0, fs.ErrPermission }
+func (f *davReadFile) Readdir(int) ([]os.FileInfo, error) { return nil, errNotDirectory }
+
+// davDir is a folder opened for PROPFIND. Its entries are listed on the first Readdir.
+type davDir struct {
+	info os.FileInfo
+	list func() ([]os.FileInfo, error)
+
+	entries []os.FileInfo
+	loaded  bool
+	pos     int
+}
+
+func (d *davDir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.loaded {
+		entries, err := d.list()
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.loaded = entries, true
+	}
+
+	rest := d.entries[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(rest))
+	d.pos += n
+	return rest[:n], nil
+}
+
+func (d *davDir) Stat() (os.FileInfo, error)                   { return d.info, nil }
+func (d *davDir) Close() error                                 { return nil }
+func (d *davDir) Read([]byte) (int, error)                     { return 0, errIsDirectory }
+func (d *davDir) Write([]byte) (int, error)                    { return 0, errIsDirectory }
+func (d *davDir) Seek(offset int64, whence int) (int64, error) { return 0, errIsDirectory }
+
+// davWriteFile feeds a PUT body into the pipe read by DriverService.SaveFile.
+type davWriteFile struct {
+	name string
+	pw   *io.PipeWriter
+	body *davBody
+	done chan error
+
+	mu      sync.Mutex
+	written int64
+}
+
+func (f *davWriteFile) Write(p []byte) (int, error) {
+	n, err := f.pw.Write(p)
+	f.mu.Lock()
+	f.written += int64(n)
+	f.mu.Unlock()
+	return n, err
+}
+
+// Close waits until the file is saved. If reading the request body failed, the pipe is
+// closed with that error so the partial upload is discarded instead of committed.
+func (f *davWriteFile) Close() error {
+	if err := f.body.err(); err != nil {
+		f.pw.CloseWithError(err)
+	} else {
+		f.pw.Close()
+	}
+	return <-f.done
+}
+
+func (f *davWriteFile) Stat() (os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return davEntryInfo{entities.FileInfo{Name: f.name, Type: "file", Size: f.written, Modified: time.Now()}}, nil
+}
+
+func (f *davWriteFile) Read([]byte) (int, error)                     { return 0, fs.ErrInvalid }
+func (f *davWriteFile) Seek(offset int64, whence int) (int64, error) { return 0, fs.ErrInvalid }
+func (f *davWriteFile) Readdir(int) ([]os.FileInfo, error)           { return nil, fs.ErrInvalid }
+
+// davBody wraps the request body to count the bytes read and remember a read error other
+// than EOF, i.e. a client that went away mid-upload.
+type davBody struct {
+	io.ReadCloser
+
+	mu      sync.Mutex
+	n       int64
+	readErr error
+}
+
+func (b *davBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	b.n += int64(n)
+	if err != nil && err != io.EOF {
+		b.readErr = err
+	}
+	b.mu.Unlock()
+	return n, err
+}
+
+func (b *davBody) err() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.readErr
+}
+
+func (b *davBody) bytesRead() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.n
+}
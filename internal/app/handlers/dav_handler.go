@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+)
+
+// DAVPrefix is where the WebDAV server is mounted
+const DAVPrefix = "/dav"
+
+// DAVMethods are the HTTP methods a class 1 and 2 WebDAV server has to answer
+var DAVMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+type DAVHandler struct {
+	AuditService services.AuditService
+	fs           *davFileSystem
+	dav          *webdav.Handler
+	logger       *slog.Logger
+}
+
+func NewDAVHandler(drivers services.DriverService, audit services.AuditService, logger *slog.Logger) *DAVHandler {
+	h := &DAVHandler{
+		AuditService: audit,
+		fs:           &davFileSystem{drivers: drivers},
+		logger:       logger.With("component", "handler.dav"),
+	}
+
+	h.dav = &webdav.Handler{
+		Prefix:     DAVPrefix,
+		FileSystem: h.fs,
+		// Locks only need to outlive a client's editing session, not a restart
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				h.logger.DebugContext(r.Context(), "webdav request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+			}
+		},
+	}
+	return h
+}
+
+// ServeDAV hands the request to the WebDAV server with the authenticated user attached,
+// then records it in the audit log.
+func (h *DAVHandler) ServeDAV(c *gin.Context) {
+	body := &davBody{ReadCloser: c.Request.Body}
+	c.Request.Body = body
+
+	ctx := context.WithValue(c.Request.Context(), davActorKey{}, actorFromContext(c))
+	ctx = context.WithValue(ctx, davBodyKey{}, body)
+
+	h.dav.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+
+	h.recordDAV(c, body.bytesRead())
+}
+
+// recordDAV audits the requests that read or change data. OPTIONS, HEAD, LOCK and
+// PROPFIND of a single resource are left out: clients send lots of them while browsing.
+func (h *DAVHandler) recordDAV(c *gin.Context, bodyBytes int64) {
+	var err error
+	if status := c.Writer.Status(); status >= http.StatusBadRequest {
+		err = errors.New(http.StatusText(status))
+	}
+
+	var action string
+	var bytes int64
+
+	switch c.Request.Method {
+	case http.MethodGet:
+		action = entities.AuditActionDownload
+		if err == nil {
+			bytes = bytesWritten(c)
+			monitoring.BytesDownloaded.WithLabelValues(action).Add(float64(bytes))
+		}
+	case http.MethodPut:
+		action, bytes = entities.AuditActionUpload, bodyBytes
+	case http.MethodDelete:
+		action = entities.AuditActionDelete
+	case "MKCOL":
+		action = entities.AuditActionCreateFolder
+	case "MOVE":
+		action = entities.AuditActionMove
+	case "COPY":
+		action = entities.AuditActionCopy
+	case "PROPFIND":
+		if c.GetHeader("Depth") == "0" {
+			return
+		}
+		action = entities.AuditActionList
+	default:
+		return
+	}
+
+	path := h.davPath(c.Request.URL.Path)
+	if action == entities.AuditActionMove || action == entities.AuditActionCopy {
+		if dst := c.GetHeader("Destination"); dst != "" {
+			if i := strings.Index(dst, DAVPrefix+"/"); i >= 0 {
+				dst = dst[i:]
+			}
+			path += " -> " + h.davPath(dst)
+		}
+	}
+
+	h.AuditService.Record(newAuditEntry(c, action, path, bytes, err))
+}
+
+func (h *DAVHandler) davPath(urlPath string) string {
+	p, virtual := h.fs.resolve(strings.TrimPrefix(urlPath, DAVPrefix))
+	if virtual {
+		return "/"
+	}
+	return p
+}
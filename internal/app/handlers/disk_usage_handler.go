@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+type DiskUsageHandler struct {
+	DiskUsageService services.DiskUsageService
+	logger           *slog.Logger
+}
+
+func NewDiskUsageHandler(diskUsage services.DiskUsageService, logger *slog.Logger) *DiskUsageHandler {
+	return &DiskUsageHandler{
+		DiskUsageService: diskUsage,
+		logger:           logger.With("component", "handler.disk_usage"),
+	}
+}
+
+// GetDiskUsage godoc
+// @Summary      Folder disk usage
+// @Description  Get the size, file count and folder count of a folder and everything below it, like du, with its largest subfolders and the largest files anywhere below it. Sizes are the sizes on disk, so encrypted files count with their encryption overhead; hidden files aren't counted, and folders that can't be read are counted as unreadable. Folder listings are cached and only listed again when they change, so asking again, or about a subfolder, is quick; refresh lists every folder again. Closing the connection stops the scan, and the folders scanned so far stay cached.
+// @Tags         Drive
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Folder"
+// @Param        limit query int false "Number of largest subfolders and files (default 20, max 100)"
+// @Param        refresh query bool false "List every folder again instead of using the cache"
+// @Success      200 {object} entities.DiskUsage "Disk usage"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      403 {object} map[string]string "Path is not allowed"
+// @Failure      404 {object} map[string]string "Folder not found"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/drivers/usage [get]
+func (h *DiskUsageHandler) GetDiskUsage(c *gin.Context) {
+	var req entities.DiskUsageRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	// Scanning a large tree for the first time outlives SERVER_WRITE_TIMEOUT
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.DebugContext(c.Request.Context(), "failed to clear write deadline", "error", err)
+	}
+
+	usage, err := h.DiskUsageService.Usage(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(diskUsageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    usage,
+		"message": "fetch disk usage successfully",
+	})
+}
+
+func diskUsageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrPathNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrFolderLocked):
+		return http.StatusLocked
+	}
+	return http.StatusBadRequest
+}
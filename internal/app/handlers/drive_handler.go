@@ -2,28 +2,42 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
 	"github.com/gin-gonic/gin"
 )
 
 type DriveHandler struct {
-	DriverService services.DriverService
+	DriverService   services.DriverService
+	JobService      services.JobService
+	PhotoService    services.PhotoService
+	MetadataService services.MetadataService
+	AuditService    services.AuditService
+	logger          *slog.Logger
 }
 
-func NewDriverHandler(srvc services.DriverService) *DriveHandler {
+func NewDriverHandler(srvc services.DriverService, jobs services.JobService, photos services.PhotoService, metadata services.MetadataService, audit services.AuditService, logger *slog.Logger) *DriveHandler {
 	return &DriveHandler{
-		DriverService: srvc,
+		DriverService:   srvc,
+		JobService:      jobs,
+		PhotoService:    photos,
+		MetadataService: metadata,
+		AuditService:    audit,
+		logger:          logger.With("component", "handler.driver"),
 	}
 }
 
@@ -37,7 +51,7 @@ func NewDriverHandler(srvc services.DriverService) *DriveHandler {
 // @Failure      500 {object} map[string]string "Internal server error"
 // @Router       /drive/root [get]
 func (h *DriveHandler) GetRootDrivers(c *gin.Context) {
-	roots, err := h.DriverService.GetRoot()
+	roots, err := h.DriverService.GetRoot(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list roots " + err.Error()})
 		return
@@ -60,7 +74,7 @@ func (h *DriveHandler) GetRootDrivers(c *gin.Context) {
 
 // ListPath godoc
 // @Summary      List path contents
-// @Description  List all files and directories in a given path
+// @Description  List all files and directories in a given path, with the caller's tags, favorite flags and color labels
 // @Tags         Drive
 // @Accept       json
 // @Produce      json
@@ -80,12 +94,13 @@ func (h *DriveHandler) ListPath(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Handler: Listing contents of path: %s", path)
 	files, err := h.DriverService.ListPath(ctx, path)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionList, path, 0, err))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list path " + err.Error()})
 		return
 	}
+	h.MetadataService.Annotate(ctx, actorFromContext(c).UserID, files)
 
 	c.Header("Content-Type", "application/json; charset=utf-8")
 	c.JSON(http.StatusOK, gin.H{
@@ -96,13 +111,14 @@ func (h *DriveHandler) ListPath(c *gin.Context) {
 
 // Downloadfile godoc
 // @Summary      Download file
-// @Description  Download a file from a given path
+// @Description  Download a file from a given path. With strip_gps the GPS data of JPEG and TIFF-based images is removed.
 // @Tags         Drive
 // @Accept       json
 // @Produce      json
 // @Param        request body entities.DownloadRequest true "Download request"
 // @Success      200 {object} map[string]string "File downloaded successfully"
 // @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
 // @Failure      500 {object} map[string]string "Internal server error"
 // @Router       /drive/download [post]
 func (h *DriveHandler) Downloadfile(c *gin.Context) {
@@ -118,14 +134,27 @@ func (h *DriveHandler) Downloadfile(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Handler: Downloading file from path: %s", req.Path)
-	absPath, filename, err := h.DriverService.Downloadfile(req.Path)
+	fileInfo, filename, err := h.DriverService.Downloadfile(c.Request.Context(), req.Path)
 	if err != nil {
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionDownload, req.Path, 0, err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrFolderLocked) {
+			status = http.StatusLocked
+		}
+		c.JSON(status, gin.H{"error": "Failed to download file " + err.Error()})
+		return
+	}
+	defer fileInfo.File.Close()
+	if err := h.PhotoService.StripGPS(fileInfo, req.StripGPS); err != nil {
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionDownload, req.Path, 0, err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to download file " + err.Error()})
 		return
 	}
 
-	c.FileAttachment(absPath, filename)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	http.ServeContent(c.Writer, c.Request, filename, fileInfo.Info.ModTime(), fileInfo.File)
+	monitoring.BytesDownloaded.WithLabelValues(entities.AuditActionDownload).Add(float64(bytesWritten(c)))
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionDownload, req.Path, bytesWritten(c), nil))
 }
 
 // CreateFolder godoc
@@ -151,8 +180,8 @@ func (h *DriveHandler) CreateFolder(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Handler: Creating folder at path: %s", req.Path)
-	err := h.DriverService.CreateFolder(req.Path)
+	err := h.DriverService.CreateFolder(c.Request.Context(), req.Path)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionCreateFolder, req.Path, 0, err))
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create folder " + err.Error()})
@@ -164,13 +193,15 @@ func (h *DriveHandler) CreateFolder(c *gin.Context) {
 
 // PreviewFile godoc
 // @Summary      Preview file
-// @Description  Preview a file at a given path
+// @Description  Preview a file at a given path. With strip_gps the GPS data of JPEG and TIFF-based images is removed.
 // @Tags         Drive
 // @Accept       json
 // @Produce      json
 // @Param        path query string true "Path to preview file"
+// @Param        strip_gps query bool false "Remove GPS data from images"
 // @Success      200 {object} map[string]string "File previewed successfully"
 // @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
 // @Failure      500 {object} map[string]string "Internal server error"
 // @Router       /drive/preview [get]
 func (h *DriveHandler) PreviewFile(c *gin.Context) {
@@ -180,14 +211,23 @@ func (h *DriveHandler) PreviewFile(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Handler: Previewing file at path: %s", path)
-	previewInfo, err := h.DriverService.PreviewFile(path)
+	previewInfo, err := h.DriverService.PreviewFile(c.Request.Context(), path)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionPreview, path, 0, err))
+		c.JSON(readErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	defer previewInfo.File.Close()
+	if err := h.PhotoService.StripGPS(previewInfo, c.Query("strip_gps") == "true"); err != nil {
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionPreview, path, 0, err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer func() {
+		monitoring.BytesDownloaded.WithLabelValues(entities.AuditActionPreview).Add(float64(bytesWritten(c)))
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionPreview, path, bytesWritten(c), nil))
+	}()
 
 	c.Header("Content-Type", previewInfo.MimeType)
 	c.Header("Cache-Control", "public, max-age=3600")
@@ -202,13 +242,15 @@ func (h *DriveHandler) PreviewFile(c *gin.Context) {
 
 // StreamFile godoc
 // @Summary      Stream file
-// @Description  Stream a file at a given path
+// @Description  Stream a file at a given path. With strip_gps the GPS data of JPEG and TIFF-based images is removed.
 // @Tags         Drive
 // @Accept       json
 // @Produce      json
 // @Param        path query string true "Path to stream file"
+// @Param        strip_gps query bool false "Remove GPS data from images"
 // @Success      200 {object} map[string]string "File streamed successfully"
 // @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
 // @Failure      500 {object} map[string]string "Internal server error"
 // @Router       /drive/stream [get]
 func (h *DriveHandler) StreamFile(c *gin.Context) {
@@ -218,13 +260,25 @@ func (h *DriveHandler) StreamFile(c *gin.Context) {
 		return
 	}
 
-	streamInfo, err := h.DriverService.StreamFile(path)
+	streamInfo, err := h.DriverService.StreamFile(c.Request.Context(), path)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionStream, path, 0, err))
+		c.JSON(readErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	defer streamInfo.File.Close()
+	if err := h.PhotoService.StripGPS(streamInfo, c.Query("strip_gps") == "true"); err != nil {
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionStream, path, 0, err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	monitoring.ActiveStreams.Inc()
+	defer func() {
+		monitoring.ActiveStreams.Dec()
+		monitoring.BytesDownloaded.WithLabelValues(entities.AuditActionStream).Add(float64(bytesWritten(c)))
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionStream, path, bytesWritten(c), nil))
+	}()
 
 	c.Header("Content-Type", streamInfo.MimeType)
 	c.Header("Accept-Ranges", "bytes")
@@ -233,7 +287,47 @@ func (h *DriveHandler) StreamFile(c *gin.Context) {
 	h.serveWithRange(c, streamInfo.File, streamInfo.Info)
 }
 
-func (h *DriveHandler) serveWithRange(c *gin.Context, file *os.File, info os.FileInfo) {
+// readErrorStatus returns the status for a file that couldn't be opened for preview or
+// streaming.
+func readErrorStatus(err error) int {
+	if errors.Is(err, services.ErrFolderLocked) {
+		return http.StatusLocked
+	}
+	return http.StatusBadRequest
+}
+
+// recordUploads writes one audit entry per uploaded file, or a single failed
+// entry for the destination when the upload was rejected before any file was
+// processed.
+func (h *DriveHandler) recordUploads(audit entities.AuditLog, dstPath string, results []entities.UploadResult, err error) {
+	if len(results) == 0 {
+		h.AuditService.Record(withAuditResult(audit, dstPath, 0, err))
+		return
+	}
+
+	for _, res := range results {
+		var uploadErr error
+		if res.Error != "" {
+			uploadErr = errors.New(res.Error)
+		}
+
+		path := res.Path
+		if path == "" {
+			path = filepath.Join(dstPath, res.Name)
+		}
+		h.AuditService.Record(withAuditResult(audit, path, res.Size, uploadErr))
+	}
+}
+
+// bytesWritten returns the number of body bytes written to the response so far.
+func bytesWritten(c *gin.Context) int64 {
+	if size := c.Writer.Size(); size > 0 {
+		return int64(size)
+	}
+	return 0
+}
+
+func (h *DriveHandler) serveWithRange(c *gin.Context, file io.ReadSeeker, info os.FileInfo) {
 	fileSize := info.Size()
 	rangeHeader := c.GetHeader("Range")
 
@@ -255,21 +349,21 @@ func (h *DriveHandler) serveWithRange(c *gin.Context, file *os.File, info os.Fil
 	buffer := make([]byte, 32*1024)
 	_, err = io.CopyBuffer(c.Writer, file, buffer)
 	if err != nil {
-		log.Printf("Error serving file: %v", err)
+		h.logger.WarnContext(c.Request.Context(), "error serving file", "error", err)
 		return
 	}
 }
 
-func (h *DriveHandler) returnWholeFile(c *gin.Context, file *os.File, fileSize int64) {
+func (h *DriveHandler) returnWholeFile(c *gin.Context, file io.Reader, fileSize int64) {
 	c.Header("Content-Length", strconv.FormatInt(fileSize, 10))
 	_, err := io.Copy(c.Writer, file)
 	if err != nil {
-		log.Printf("Error serving file: %v", err)
+		h.logger.WarnContext(c.Request.Context(), "error serving file", "error", err)
 		return
 	}
 }
 
-func (h *DriveHandler) parseRangeHeader(c *gin.Context, rangeHeader string, file *os.File, fileSize int64) (int64, int64, error) {
+func (h *DriveHandler) parseRangeHeader(c *gin.Context, rangeHeader string, file io.Seeker, fileSize int64) (int64, int64, error) {
 	if !strings.HasPrefix(rangeHeader, "bytes=") {
 		c.Status(http.StatusRequestedRangeNotSatisfiable)
 		return 0, 0, fmt.Errorf("invalid range header")
@@ -321,14 +415,11 @@ func extractFilenameFromHeader(header map[string][]string) string {
 	if contentDisposition, exists := header["Content-Disposition"]; exists && len(contentDisposition) > 0 {
 		// Parse Content-Disposition header like: form-data; name="files"; filename="Handle/handle.exe"
 		disposition := contentDisposition[0]
-		log.Printf("Handler: Parsing disposition: %s", disposition)
 		if strings.Contains(disposition, "filename=") {
 			// Extract filename from the header
 			parts := strings.Split(disposition, "filename=")
-			log.Printf("Handler: Split parts: %v", parts)
 			if len(parts) > 1 {
 				filename := strings.Trim(parts[1], `"`)
-				log.Printf("Handler: Extracted filename: %s", filename)
 				return filename
 			}
 		}
@@ -338,16 +429,19 @@ func extractFilenameFromHeader(header map[string][]string) string {
 
 // UploadFiles godoc
 // @Summary      Upload files
-// @Description  Upload files to a given path
+// @Description  Upload files to a given path. With async=true the request returns 202 with a job as soon as the files are received, and they are written in the background; follow the job at /api/jobs/{id} or /api/jobs/events.
 // @Tags         Drive
 // @Accept       json
 // @Produce      json
 // @Param        path query string true "Path to upload files"
 // @Param        upload_type formData string true "Upload type (files or folder)"
-// @Param        overwrite formData string true "Overwrite files (true or false)"
+// @Param        overwrite formData string false "What to do when a file exists: false (fail), true (replace) or keep_both (save as \"name (1).ext\")"
+// @Param        async formData bool false "Write the files in a background job"
 // @Success      200 {object} map[string]any "Upload results"
+// @Success      202 {object} entities.Job "Upload job started"
 // @Failure      400 {object} map[string]string "Invalid request"
 // @Failure      500 {object} map[string]string "Internal server error"
+// @Failure      503 {object} map[string]string "Server is shutting down"
 // @Router       /drive/upload-files [post]
 func (h *DriveHandler) UploadFiles(c *gin.Context) {
 	dstPath := c.Query("path")
@@ -357,7 +451,16 @@ func (h *DriveHandler) UploadFiles(c *gin.Context) {
 	}
 
 	uploadType := c.DefaultPostForm("upload_type", "files")
-	overwrite := strings.ToLower(c.DefaultPostForm("overwrite", "false")) == "true"
+	overwrite, err := entities.ParseOverwriteMode(strings.ToLower(c.DefaultPostForm("overwrite", "false")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	async, err := strconv.ParseBool(c.DefaultPostForm("async", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid async value"})
+		return
+	}
 
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -365,14 +468,37 @@ func (h *DriveHandler) UploadFiles(c *gin.Context) {
 		return
 	}
 
-	var results []entities.UploadResult
+	actor := actorFromContext(c)
+	audit := newAuditEntry(c, entities.AuditActionUpload, dstPath, 0, nil)
 
+	var files []*multipart.FileHeader
+	var upload func(ctx context.Context, progress *services.JobProgress) ([]entities.UploadResult, error)
 	if uploadType == "folder" {
-		results, err = h.handleFolderUpload(dstPath, form, overwrite)
+		files, err = h.folderUploadFiles(c, form)
+		upload = func(ctx context.Context, progress *services.JobProgress) ([]entities.UploadResult, error) {
+			return h.DriverService.UploadFolder(ctx, actor, dstPath, files, overwrite, progress)
+		}
 	} else {
-		results, err = h.handleFileUpload(c, dstPath, form, overwrite)
+		files, err = h.fileUploadFiles(c, form)
+		upload = func(ctx context.Context, progress *services.JobProgress) ([]entities.UploadResult, error) {
+			return h.DriverService.UploadFiles(ctx, actor, dstPath, files, overwrite, progress)
+		}
 	}
 
+	if err != nil {
+		h.recordUploads(audit, dstPath, nil, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if async {
+		h.startUploadJob(c, form, dstPath, audit, upload)
+		return
+	}
+
+	results, err := upload(c.Request.Context(), nil)
+	h.recordUploads(audit, dstPath, results, err)
+
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload files: " + err.Error()})
 		return
@@ -385,7 +511,31 @@ func (h *DriveHandler) UploadFiles(c *gin.Context) {
 
 }
 
-func (h *DriveHandler) handleFileUpload(c *gin.Context, destPath string, form *multipart.Form, overwrite bool) ([]entities.UploadResult, error) {
+// startUploadJob writes the files of form in a job. The job takes over the form's temp
+// files, which the server would otherwise delete as soon as the request returns.
+func (h *DriveHandler) startUploadJob(c *gin.Context, form *multipart.Form, dstPath string, audit entities.AuditLog, upload func(ctx context.Context, progress *services.JobProgress) ([]entities.UploadResult, error)) {
+	c.Request.MultipartForm = nil
+
+	job, err := h.JobService.Start(actorFromContext(c), entities.JobKindUpload, dstPath, func(ctx context.Context, progress *services.JobProgress) error {
+		defer form.RemoveAll()
+
+		results, err := upload(ctx, progress)
+		h.recordUploads(audit, dstPath, results, err)
+		return err
+	})
+	if err != nil {
+		form.RemoveAll()
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"Data":    job,
+		"message": "upload started",
+	})
+}
+
+func (h *DriveHandler) fileUploadFiles(c *gin.Context, form *multipart.Form) ([]*multipart.FileHeader, error) {
 	files := form.File["files"]
 	if len(files) == 0 {
 		if f, err := c.FormFile("file"); err == nil {
@@ -401,38 +551,86 @@ func (h *DriveHandler) handleFileUpload(c *gin.Context, destPath string, form *m
 		if file.Header != nil {
 			extractedFilename := extractFilenameFromHeader(file.Header)
 			if extractedFilename != "" {
-				log.Printf("Handler: File upload %d - Extracted filename: %s", i, extractedFilename)
+				h.logger.DebugContext(c.Request.Context(), "extracted upload filename", "index", i, "filename", extractedFilename)
 				file.Filename = extractedFilename
 			}
 		}
 	}
 
-	return h.DriverService.UploadFiles(destPath, files, overwrite)
+	return files, checkUploadNames(files)
 }
 
-func (h *DriveHandler) handleFolderUpload(destPath string, form *multipart.Form, overwrite bool) ([]entities.UploadResult, error) {
+func (h *DriveHandler) folderUploadFiles(c *gin.Context, form *multipart.Form) ([]*multipart.FileHeader, error) {
 	files := form.File["files"]
 	if len(files) == 0 {
 		return nil, fmt.Errorf("no files provided")
 	}
 
-	// Debug: log the raw filename from multipart form
 	for i, file := range files {
-		log.Printf("Handler: Raw file %d - Filename: %s, Size: %d", i, file.Filename, file.Size)
-		// Log the header to see what's in the Content-Disposition
 		if file.Header != nil {
 			// Extract filename from Content-Disposition header
 			extractedFilename := extractFilenameFromHeader(file.Header)
 			if extractedFilename != "" {
-				log.Printf("Handler: Extracted filename from header: %s", extractedFilename)
 				// Update the filename to preserve the folder structure
 				file.Filename = extractedFilename
-				log.Printf("Handler: Updated filename to: %s", file.Filename)
 			} else {
-				log.Printf("Handler: No filename extracted for file %d", i)
+				h.logger.DebugContext(c.Request.Context(), "no filename in content disposition", "index", i, "filename", file.Filename)
 			}
 		}
 	}
 
-	return h.DriverService.UploadFolder(destPath, files, overwrite)
+	return files, checkUploadNames(files)
+}
+
+// checkUploadNames rejects the files whose names, which are chosen by the client, would
+// be written outside the destination folder, such as "../x" or "/etc/x".
+func checkUploadNames(files []*multipart.FileHeader) error {
+	for _, file := range files {
+		if !filepath.IsLocal(filepath.FromSlash(file.Filename)) {
+			return fmt.Errorf("invalid file name %q: must be a relative path inside the destination folder", file.Filename)
+		}
+	}
+	return nil
+}
+
+// MoveFiles godoc
+// @Summary      Move files
+// @Description  Move files and folders into a folder, keeping their names. The move runs as a background job; the request returns 202 with the job, which reports each path as it is moved and can be cancelled.
+// @Tags         Drive
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body entities.MoveRequest true "Paths to move and the destination folder"
+// @Success      202 {object} entities.Job "Move job started"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      503 {object} map[string]string "Server is shutting down"
+// @Router       /api/drivers/move [post]
+func (h *DriveHandler) MoveFiles(c *gin.Context) {
+	var req entities.MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+
+	audit := newAuditEntry(c, entities.AuditActionMove, "", 0, nil)
+	job, err := h.JobService.Start(actorFromContext(c), entities.JobKindMove, req.Destination, func(ctx context.Context, progress *services.JobProgress) error {
+		results, err := h.DriverService.MoveAll(ctx, req.Paths, req.Destination, progress)
+		for _, res := range results {
+			var moveErr error
+			if res.Error != "" {
+				moveErr = errors.New(res.Error)
+			}
+			h.AuditService.Record(withAuditResult(audit, res.Src+" -> "+filepath.Join(req.Destination, filepath.Base(res.Src)), 0, moveErr))
+		}
+		return err
+	})
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"Data":    job,
+		"message": "move started",
+	})
 }
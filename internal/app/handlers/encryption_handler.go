@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type EncryptionHandler struct {
+	EncryptionService services.EncryptionService
+	AuditService      services.AuditService
+	logger            *slog.Logger
+}
+
+func NewEncryptionHandler(encryption services.EncryptionService, audit services.AuditService, logger *slog.Logger) *EncryptionHandler {
+	return &EncryptionHandler{
+		EncryptionService: encryption,
+		AuditService:      audit,
+		logger:            logger.With("component", "handler.encryption"),
+	}
+}
+
+// ListEncryptedFolders godoc
+// @Summary      List encrypted folders
+// @Description  List the caller's encrypted folders and whether they are currently locked
+// @Tags         Encryption
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]any "Encrypted folders"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/encrypted-folders [get]
+func (h *EncryptionHandler) ListEncryptedFolders(c *gin.Context) {
+	folders, err := h.EncryptionService.ListFolders(c.Request.Context(), actorFromContext(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    folders,
+		"message": "fetch encrypted folders successfully",
+	})
+}
+
+// EncryptFolder godoc
+// @Summary      Encrypt folder
+// @Description  Turn on at-rest encryption for an empty or new folder. Files saved into it are sealed with AES-256-GCM, and with encrypt_names their names are encrypted too. The folder key is wrapped by a key derived from the caller's password, so the folder can only be read while the caller's keyring is unlocked (after login or unlock).
+// @Tags         Encryption
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body entities.EncryptFolderRequest true "Folder to encrypt"
+// @Success      201 {object} entities.EncryptedFolder "Encrypted folder"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      401 {object} map[string]string "Wrong password"
+// @Failure      409 {object} map[string]string "Folder is not empty or overlaps an encrypted folder"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/encrypted-folders [post]
+func (h *EncryptionHandler) EncryptFolder(c *gin.Context) {
+	var req entities.EncryptFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+
+	folder, err := h.EncryptionService.EncryptFolder(c.Request.Context(), actorFromContext(c), &req)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionEncryptFolder, req.Path, 0, err))
+	if err != nil {
+		c.JSON(encryptionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"Data":    folder,
+		"message": "folder encrypted successfully",
+	})
+}
+
+// DisableFolderEncryption godoc
+// @Summary      Disable folder encryption
+// @Description  Stop encrypting one of the caller's folders. The folder must be empty.
+// @Tags         Encryption
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Encrypted folder ID"
+// @Success      200 {object} map[string]string "Encryption disabled"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Encrypted folder not found"
+// @Failure      409 {object} map[string]string "Folder is not empty"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/encrypted-folders/{id} [delete]
+func (h *EncryptionHandler) DisableFolderEncryption(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid encrypted folder id"})
+		return
+	}
+
+	err = h.EncryptionService.DisableFolder(c.Request.Context(), actorFromContext(c).UserID, id)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionDisableEncryption, id.String(), 0, err))
+	if err != nil {
+		c.JSON(encryptionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "folder encryption disabled successfully"})
+}
+
+// UnlockKeyring godoc
+// @Summary      Unlock encrypted folders
+// @Description  Unlock the caller's encrypted folders with their password. Logging in does this too; unlocking is needed again after the server restarts or the unlock expires.
+// @Tags         Encryption
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body entities.UnlockKeyringRequest true "Password"
+// @Success      200 {object} map[string]string "Keyring unlocked"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      401 {object} map[string]string "Wrong password"
+// @Failure      404 {object} map[string]string "No encrypted folders set up"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/users/me/keyring/unlock [post]
+func (h *EncryptionHandler) UnlockKeyring(c *gin.Context) {
+	var req entities.UnlockKeyringRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+
+	err := h.EncryptionService.Unlock(c.Request.Context(), actorFromContext(c).UserID, req.Password)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionUnlockKeyring, "", 0, err))
+	if err != nil {
+		c.JSON(encryptionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "keyring unlocked successfully"})
+}
+
+// LockKeyring godoc
+// @Summary      Lock encrypted folders
+// @Description  Forget the caller's keys, so their encrypted folders can't be read until they unlock them again
+// @Tags         Encryption
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]string "Keyring locked"
+// @Router       /api/users/me/keyring/lock [post]
+func (h *EncryptionHandler) LockKeyring(c *gin.Context) {
+	h.EncryptionService.Lock(actorFromContext(c).UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "keyring locked successfully"})
+}
+
+func encryptionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrWrongPassword):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrEncryptedFolderNotFound), errors.Is(err, services.ErrNoKeyring):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrDirNotEmpty), errors.Is(err, services.ErrAlreadyEncrypted):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrPathNotAllowed), errors.Is(err, services.ErrNotDirectory):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeatInterval keeps idle streams alive through proxies that close silent
+// connections
+const eventHeartbeatInterval = 25 * time.Second
+
+type EventHandler struct {
+	EventService services.EventService
+	logger       *slog.Logger
+}
+
+func NewEventHandler(events services.EventService, logger *slog.Logger) *EventHandler {
+	return &EventHandler{
+		EventService: events,
+		logger:       logger.With("component", "handler.event"),
+	}
+}
+
+// StreamEvents godoc
+// @Summary      Stream change events
+// @Description  Server-Sent Events stream of changes to the given folders and their direct entries, whether made through the vault or directly on disk. Each change is sent as an event named after its type (create, modify, delete, rename) with an entities.ChangeEvent as data. Changes are merged over a short window, so a burst such as a folder upload arrives as one event per path; a resync event means changes were dropped and the folder should be listed again. A ready event listing the watched folders is sent first. Browsers' EventSource can't set headers, so the token may be passed as access_token instead.
+// @Tags         Events
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        path query []string true "Folder to watch (repeatable)" collectionFormat(multi)
+// @Param        access_token query string false "Bearer token, for clients that can't send the Authorization header"
+// @Success      200 {object} entities.ChangeEvent "Event stream"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Folder not found"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Failure      503 {object} map[string]string "Server is shutting down"
+// @Router       /api/events [get]
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	sub, err := h.EventService.Subscribe(c.Request.Context(), c.QueryArray("path"))
+	if err != nil {
+		c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Streams outlive SERVER_WRITE_TIMEOUT
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.DebugContext(c.Request.Context(), "failed to clear write deadline", "error", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.SSEvent("ready", gin.H{"paths": sub.Paths()})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			c.SSEvent(event.Type, event)
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func eventErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrFolderLocked):
+		return http.StatusLocked
+	case errors.Is(err, services.ErrEventsClosed):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
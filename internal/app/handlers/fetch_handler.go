@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+type FetchHandler struct {
+	FetchService services.FetchService
+	JobService   services.JobService
+	AuditService services.AuditService
+	logger       *slog.Logger
+}
+
+func NewFetchHandler(fetch services.FetchService, jobs services.JobService, audit services.AuditService, logger *slog.Logger) *FetchHandler {
+	return &FetchHandler{
+		FetchService: fetch,
+		JobService:   jobs,
+		AuditService: audit,
+		logger:       logger.With("component", "handler.fetch"),
+	}
+}
+
+// FetchURL godoc
+// @Summary      Fetch a URL into the vault
+// @Description  Download a file from an HTTP(S) URL on the server and save it into a folder. The download runs as a background job; the request returns 202 with the job. Interrupted downloads are resumed with Range requests, and the file is only saved once it is complete and matches the optional checksum. Addresses that aren't publicly routable are refused unless the server allows private networks.
+// @Tags         Drive
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body entities.FetchRequest true "URL, destination folder, and optional name, checksum (sha256:<hex>) and overwrite mode"
+// @Success      202 {object} entities.Job "Fetch job started"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Destination folder not found"
+// @Failure      423 {object} map[string]string "Destination folder is locked"
+// @Failure      503 {object} map[string]string "Server is shutting down"
+// @Router       /api/drivers/fetch [post]
+func (h *FetchHandler) FetchURL(c *gin.Context) {
+	var req entities.FetchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+
+	if err := h.FetchService.Check(c.Request.Context(), &req); err != nil {
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionFetch, req.Path, 0, err))
+		c.JSON(fetchErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	actor := actorFromContext(c)
+	audit := newAuditEntry(c, entities.AuditActionFetch, "", 0, nil)
+	job, err := h.JobService.Start(actor, entities.JobKindFetch, req.URL, func(ctx context.Context, progress *services.JobProgress) error {
+		stored, err := h.FetchService.Fetch(ctx, actor, &req, progress)
+		path := stored.Path
+		if path == "" {
+			path = req.Path
+		}
+		h.AuditService.Record(withAuditResult(audit, path, stored.Size, err))
+		return err
+	})
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"Data":    job,
+		"message": "fetch started",
+	})
+}
+
+func fetchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrFolderLocked):
+		return http.StatusLocked
+	}
+	return http.StatusBadRequest
+}
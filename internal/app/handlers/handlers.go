@@ -1,17 +1,55 @@
 package handlers
 
-import "github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+import (
+	"log/slog"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+)
 
 type Handlers struct {
 	UserHandler *UserHandler
 	Auth        *AuthHandler
 	Driver      *DriveHandler
+	Audit       *AuditHandler
+	Health      *HealthHandler
+	Version     *VersionHandler
+	DAV         *DAVHandler
+	AccessKey   *AccessKeyHandler
+	S3          *S3Handler
+	Encryption  *EncryptionHandler
+	Events      *EventHandler
+	Jobs        *JobHandler
+	Fetch       *FetchHandler
+	Photos      *PhotoHandler
+	Text        *TextHandler
+	Metadata    *MetadataHandler
+	Activity    *ActivityHandler
+	DiskUsage   *DiskUsageHandler
+	Sync        *SyncHandler
+	Transfer    *TransferHandler
 }
 
-func NewHandlers(srvc *services.Services) *Handlers {
+func NewHandlers(srvc *services.Services, logger *slog.Logger) *Handlers {
 	return &Handlers{
 		UserHandler: NewUserhandler(srvc.User),
-		Auth:        NewAuthHandler(srvc.Auth),
-		Driver:      NewDriverHandler(srvc.Driver),
+		Auth:        NewAuthHandler(srvc.Auth, srvc.Audit, logger),
+		Driver:      NewDriverHandler(srvc.Driver, srvc.Jobs, srvc.Photos, srvc.Metadata, srvc.Audit, logger),
+		Audit:       NewAuditHandler(srvc.Audit, logger),
+		Health:      NewHealthHandler(srvc.Health),
+		Version:     NewVersionHandler(srvc.Version, srvc.Audit, logger),
+		DAV:         NewDAVHandler(srvc.Driver, srvc.Audit, logger),
+		AccessKey:   NewAccessKeyHandler(srvc.AccessKey, logger),
+		S3:          NewS3Handler(srvc.S3Gateway, srvc.Audit, logger),
+		Encryption:  NewEncryptionHandler(srvc.Encryption, srvc.Audit, logger),
+		Events:      NewEventHandler(srvc.Events, logger),
+		Jobs:        NewJobHandler(srvc.Jobs, logger),
+		Fetch:       NewFetchHandler(srvc.Fetch, srvc.Jobs, srvc.Audit, logger),
+		Photos:      NewPhotoHandler(srvc.Photos, srvc.Jobs, srvc.Audit, logger),
+		Text:        NewTextHandler(srvc.Text, srvc.Audit, logger),
+		Metadata:    NewMetadataHandler(srvc.Metadata, logger),
+		Activity:    NewActivityHandler(srvc.Activity, logger),
+		DiskUsage:   NewDiskUsageHandler(srvc.DiskUsage, logger),
+		Sync:        NewSyncHandler(srvc.Sync, srvc.Audit, logger),
+		Transfer:    NewTransferHandler(srvc.Transfer, logger),
 	}
 }
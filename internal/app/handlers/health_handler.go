@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+const readinessTimeout = 5 * time.Second
+
+type HealthHandler struct {
+	healthService services.HealthService
+}
+
+func NewHealthHandler(healthService services.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// Healthz godoc
+// @Summary      Liveness probe
+// @Description  Report that the process is up and serving HTTP
+// @Tags         Health
+// @Produce      json
+// @Success      200 {object} map[string]string "Server is alive"
+// @Router       /healthz [get]
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": services.HealthStatusOK})
+}
+
+// Readyz godoc
+// @Summary      Readiness probe
+// @Description  Check database connectivity and that every vault root is accessible
+// @Tags         Health
+// @Produce      json
+// @Success      200 {object} entities.ReadinessReport "Server is ready"
+// @Failure      503 {object} entities.ReadinessReport "Server is not ready"
+// @Router       /readyz [get]
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	report := h.healthService.Ready(ctx)
+
+	status := http.StatusOK
+	if report.Status != services.HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, report)
+}
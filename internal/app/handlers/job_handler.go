@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type JobHandler struct {
+	JobService services.JobService
+	logger     *slog.Logger
+}
+
+func NewJobHandler(jobs services.JobService, logger *slog.Logger) *JobHandler {
+	return &JobHandler{
+		JobService: jobs,
+		logger:     logger.With("component", "handler.job"),
+	}
+}
+
+// ListJobs godoc
+// @Summary      List jobs
+// @Description  List the caller's running jobs and the ones that finished recently, newest first and without their files
+// @Tags         Jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]any "Jobs"
+// @Router       /api/jobs [get]
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    h.JobService.List(actorFromContext(c).UserID),
+		"message": "fetch jobs successfully",
+	})
+}
+
+// GetJob godoc
+// @Summary      Get job
+// @Description  Get a job's status and the progress of each of its files
+// @Tags         Jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Job ID"
+// @Success      200 {object} entities.Job "Job"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Job not found"
+// @Router       /api/jobs/{id} [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	job, err := h.JobService.Get(actorFromContext(c).UserID, id)
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    job,
+		"message": "fetch job successfully",
+	})
+}
+
+// CancelJob godoc
+// @Summary      Cancel job
+// @Description  Cancel a running job. Work already done is kept; the file being written is discarded.
+// @Tags         Jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Job ID"
+// @Success      200 {object} map[string]string "Job cancelled"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Job not found"
+// @Failure      409 {object} map[string]string "Job has already finished"
+// @Router       /api/jobs/{id}/cancel [post]
+func (h *JobHandler) CancelJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	if err := h.JobService.Cancel(actorFromContext(c).UserID, id); err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "job cancelled successfully"})
+}
+
+// StreamJobEvents godoc
+// @Summary      Stream job progress
+// @Description  Server-Sent Events stream of the caller's jobs: a job event with the job's totals and the files that changed is sent at most twice a second while a job progresses, and once more when it finishes. Browsers' EventSource can't set headers, so the token may be passed as access_token instead.
+// @Tags         Jobs
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        access_token query string false "Bearer token, for clients that can't send the Authorization header"
+// @Success      200 {object} entities.Job "Event stream"
+// @Router       /api/jobs/events [get]
+func (h *JobHandler) StreamJobEvents(c *gin.Context) {
+	jobs := h.JobService.Subscribe(c.Request.Context(), actorFromContext(c).UserID)
+
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.DebugContext(c.Request.Context(), "failed to clear write deadline", "error", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case job, ok := <-jobs:
+			if !ok {
+				return
+			}
+			c.SSEvent("job", job)
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrJobFinished):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrJobsShutdown):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
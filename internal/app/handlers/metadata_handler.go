@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+type MetadataHandler struct {
+	MetadataService services.MetadataService
+	logger          *slog.Logger
+}
+
+func NewMetadataHandler(metadata services.MetadataService, logger *slog.Logger) *MetadataHandler {
+	return &MetadataHandler{
+		MetadataService: metadata,
+		logger:          logger.With("component", "handler.metadata"),
+	}
+}
+
+// GetMetadata godoc
+// @Summary      Get file metadata
+// @Description  Get the caller's tags, favorite flag, color label and notes of a file or folder. Files without any have empty metadata.
+// @Tags         Metadata
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file or folder"
+// @Success      200 {object} entities.FileMeta "File metadata"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "File not found"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/drivers/metadata [get]
+func (h *MetadataHandler) GetMetadata(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+
+	meta, err := h.MetadataService.Get(c.Request.Context(), actorFromContext(c).UserID, path)
+	if err != nil {
+		c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    meta,
+		"message": "fetch file metadata successfully",
+	})
+}
+
+// UpdateMetadata godoc
+// @Summary      Update file metadata
+// @Description  Set the caller's tags, favorite flag, color label and notes of a file or folder. Fields left out are kept; tags and notes replace the previous ones, so an empty list or object clears them. Tags are lowercased. Colors are red, orange, yellow, green, blue, purple and gray, or empty for none. The metadata follows the file when it is renamed or moved.
+// @Tags         Metadata
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body entities.FileMetadataRequest true "Metadata to set"
+// @Success      200 {object} entities.FileMeta "File metadata"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "File not found"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/drivers/metadata [put]
+func (h *MetadataHandler) UpdateMetadata(c *gin.Context) {
+	var req entities.FileMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+
+	meta, err := h.MetadataService.Update(c.Request.Context(), actorFromContext(c).UserID, &req)
+	if err != nil {
+		c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    meta,
+		"message": "file metadata updated successfully",
+	})
+}
+
+// DeleteMetadata godoc
+// @Summary      Delete file metadata
+// @Description  Remove all of the caller's tags, favorite flag, color label and notes of a file or folder
+// @Tags         Metadata
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file or folder"
+// @Success      200 {object} map[string]string "File metadata deleted"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "File not found"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/drivers/metadata [delete]
+func (h *MetadataHandler) DeleteMetadata(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+
+	if err := h.MetadataService.Delete(c.Request.Context(), actorFromContext(c).UserID, path); err != nil {
+		c.JSON(metadataErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "file metadata deleted successfully"})
+}
+
+// SearchMetadata godoc
+// @Summary      Search files by metadata
+// @Description  List the caller's files and folders with all of the given tags, ordered by path. Favorites, a color label and a folder narrow the search further; without filters every file with metadata is listed.
+// @Tags         Metadata
+// @Produce      json
+// @Security     BearerAuth
+// @Param        tag query []string false "Tag the files must have (repeatable)" collectionFormat(multi)
+// @Param        favorite query bool false "Only favorites"
+// @Param        color query string false "Only files with this color label"
+// @Param        path query string false "Only files in this folder or below it"
+// @Param        page query int false "Page number"
+// @Param        page_size query int false "Files per page (max 1000)"
+// @Success      200 {object} map[string]any "Matching files"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/metadata/search [get]
+func (h *MetadataHandler) SearchMetadata(c *gin.Context) {
+	var query entities.FileMetadataQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	files, total, err := h.MetadataService.Search(c.Request.Context(), actorFromContext(c).UserID, &query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":      files,
+		"total":     total,
+		"page":      query.Page,
+		"page_size": query.PageSize,
+		"message":   "search file metadata successfully",
+	})
+}
+
+// ListTags godoc
+// @Summary      List tags
+// @Description  List the caller's tags with how many files have each, most used first
+// @Tags         Metadata
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {array} entities.TagCount "Tags"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/tags [get]
+func (h *MetadataHandler) ListTags(c *gin.Context) {
+	tags, err := h.MetadataService.Tags(c.Request.Context(), actorFromContext(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    tags,
+		"message": "fetch tags successfully",
+	})
+}
+
+func metadataErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrFolderLocked):
+		return http.StatusLocked
+	}
+	return http.StatusBadRequest
+}
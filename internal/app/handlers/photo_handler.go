@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PhotoHandler struct {
+	PhotoService services.PhotoService
+	JobService   services.JobService
+	AuditService services.AuditService
+	logger       *slog.Logger
+}
+
+func NewPhotoHandler(photos services.PhotoService, jobs services.JobService, audit services.AuditService, logger *slog.Logger) *PhotoHandler {
+	return &PhotoHandler{
+		PhotoService: photos,
+		JobService:   jobs,
+		AuditService: audit,
+		logger:       logger.With("component", "handler.photo"),
+	}
+}
+
+// GetTimeline godoc
+// @Summary      Photo timeline
+// @Description  List indexed photos across all folders by the day they were taken, newest first. Dates are the camera's local date; photos without a capture date are left out.
+// @Tags         Photos
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string false "Only photos in this folder or below it"
+// @Param        from query string false "Only photos taken on or after this date (YYYY-MM-DD)"
+// @Param        to query string false "Only photos taken on or before this date (YYYY-MM-DD)"
+// @Param        page query int false "Page number"
+// @Param        page_size query int false "Photos per page (max 1000)"
+// @Success      200 {object} map[string]any "Photos grouped by day"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/photos/timeline [get]
+func (h *PhotoHandler) GetTimeline(c *gin.Context) {
+	var req entities.PhotoTimelineRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	days, total, err := h.PhotoService.Timeline(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":      days,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+		"message":   "fetch photo timeline successfully",
+	})
+}
+
+// GetMetadata godoc
+// @Summary      Photo metadata
+// @Description  Get the EXIF metadata indexed for an image: capture time, camera, orientation and GPS position
+// @Tags         Photos
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the image"
+// @Success      200 {object} entities.PhotoMetadata "Photo metadata"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Image is not indexed"
+// @Router       /api/photos/metadata [get]
+func (h *PhotoHandler) GetMetadata(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+
+	photo, err := h.PhotoService.Get(c.Request.Context(), path)
+	if err != nil {
+		c.JSON(photoErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    photo,
+		"message": "fetch photo metadata successfully",
+	})
+}
+
+// IndexPhotos godoc
+// @Summary      Index photos
+// @Description  Index the EXIF metadata of the images in a folder and its subfolders as a background job. Only new and changed images are read, and images that are gone are removed from the index. The request returns 202 with the job.
+// @Tags         Photos
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body entities.PhotoIndexRequest true "Folder to index"
+// @Success      202 {object} entities.Job "Index job started"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      503 {object} map[string]string "Server is shutting down"
+// @Router       /api/photos/index [post]
+func (h *PhotoHandler) IndexPhotos(c *gin.Context) {
+	var req entities.PhotoIndexRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+
+	audit := newAuditEntry(c, entities.AuditActionPhotoIndex, "", 0, nil)
+	job, err := h.JobService.Start(actorFromContext(c), entities.JobKindPhotoIndex, req.Path, func(ctx context.Context, progress *services.JobProgress) error {
+		_, err := h.PhotoService.Index(ctx, req.Path, progress)
+		h.AuditService.Record(withAuditResult(audit, req.Path, 0, err))
+		return err
+	})
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"Data":    job,
+		"message": "photo index started",
+	})
+}
+
+func photoErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrPhotoNotFound), errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
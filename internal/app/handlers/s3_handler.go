@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/s3"
+	"github.com/gin-gonic/gin"
+)
+
+// s3SubResources are the query parameters that select another operation on the same path.
+// A request naming one the gateway doesn't implement is refused rather than mistaken for a
+// plain GET or PUT; other parameters (x-id, response-content-type, ...) are ignored.
+var s3SubResources = []string{
+	"uploads", "uploadId", "location", "acl", "tagging", "versions", "versionId", "versioning",
+	"policy", "cors", "lifecycle", "delete", "retention", "legal-hold", "object-lock",
+	"attributes", "select", "restore", "notification", "encryption", "website", "logging",
+	"replication",
+}
+
+// maxCompleteBody bounds the part list of CompleteMultipartUpload (10000 parts fit easily)
+const maxCompleteBody = 4 << 20
+
+var errBadDigest = errors.New("content does not match Content-MD5")
+
+// S3Handler serves the S3-compatible API. S3 routes by method and query string rather than
+// by path, so every request comes in through ServeS3 and is dispatched from there.
+type S3Handler struct {
+	S3Service    services.S3GatewayService
+	AuditService services.AuditService
+	logger       *slog.Logger
+}
+
+func NewS3Handler(s3Service services.S3GatewayService, audit services.AuditService, logger *slog.Logger) *S3Handler {
+	return &S3Handler{
+		S3Service:    s3Service,
+		AuditService: audit,
+		logger:       logger.With("component", "handler.s3"),
+	}
+}
+
+// ServeS3 handles the supported subset of the S3 API:
+//
+//	GET    /                                ListBuckets
+//	GET    /bucket?list-type=2              ListObjectsV2 (and V1 without list-type)
+//	GET    /bucket?location                 GetBucketLocation
+//	HEAD   /bucket                          HeadBucket
+//	HEAD   /bucket/key                      HeadObject
+//	GET    /bucket/key                      GetObject, with Range
+//	PUT    /bucket/key                      PutObject
+//	DELETE /bucket/key                      DeleteObject
+//	POST   /bucket/key?uploads              CreateMultipartUpload
+//	PUT    /bucket/key?partNumber&uploadId  UploadPart
+//	POST   /bucket/key?uploadId             CompleteMultipartUpload
+//	DELETE /bucket/key?uploadId             AbortMultipartUpload
+func (h *S3Handler) ServeS3(c *gin.Context) {
+	c.Header("X-Amz-Request-Id", c.GetString("request_id"))
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(c.Param("path"), "/"), "/")
+	query := c.Request.URL.Query()
+	method, sub := c.Request.Method, subResource(query)
+
+	switch {
+	case bucket == "":
+		if method == http.MethodGet {
+			h.listBuckets(c)
+			return
+		}
+
+	case key == "":
+		switch {
+		case method == http.MethodGet && sub == "":
+			h.listObjects(c, bucket)
+			return
+		case method == http.MethodGet && sub == "location":
+			h.getBucketLocation(c, bucket)
+			return
+		case method == http.MethodHead:
+			h.headBucket(c, bucket)
+			return
+		case method == http.MethodPut && sub == "":
+			h.createBucket(c, bucket)
+			return
+		}
+
+	default:
+		switch {
+		case method == http.MethodHead && sub == "":
+			h.getObject(c, bucket, key, false)
+			return
+		case method == http.MethodGet && sub == "":
+			h.getObject(c, bucket, key, true)
+			return
+		case method == http.MethodPut && sub == "uploadId":
+			h.uploadPart(c, query.Get("uploadId"), query.Get("partNumber"))
+			return
+		case method == http.MethodPut && sub == "" && c.GetHeader("X-Amz-Copy-Source") == "":
+			h.putObject(c, bucket, key)
+			return
+		case method == http.MethodPost && sub == "uploads":
+			h.createMultipartUpload(c, bucket, key)
+			return
+		case method == http.MethodPost && sub == "uploadId":
+			h.completeMultipartUpload(c, query.Get("uploadId"))
+			return
+		case method == http.MethodDelete && sub == "uploadId":
+			h.abortMultipartUpload(c, query.Get("uploadId"))
+			return
+		case method == http.MethodDelete && sub == "":
+			h.deleteObject(c, bucket, key)
+			return
+		}
+	}
+
+	s3.WriteError(c.Writer, http.StatusNotImplemented, "NotImplemented", "this operation is not supported by PersonalVault", c.Request.URL.Path, c.GetString("request_id"))
+}
+
+func (h *S3Handler) listBuckets(c *gin.Context) {
+	buckets, err := h.S3Service.ListBuckets(c.Request.Context())
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	h.writeXML(c, http.StatusOK, &s3.ListAllMyBucketsResult{
+		Xmlns:   s3.Namespace,
+		Owner:   s3.Owner{ID: actorFromContext(c).UserID.String(), DisplayName: c.GetString("username")},
+		Buckets: buckets,
+	})
+}
+
+func (h *S3Handler) getBucketLocation(c *gin.Context, bucket string) {
+	if _, err := h.S3Service.Resolve(c.Request.Context(), bucket, ""); err != nil {
+		h.fail(c, err)
+		return
+	}
+	// An empty constraint means us-east-1; signatures for any region are accepted anyway
+	h.writeXML(c, http.StatusOK, &s3.LocationConstraint{Xmlns: s3.Namespace})
+}
+
+func (h *S3Handler) headBucket(c *gin.Context, bucket string) {
+	if _, err := h.S3Service.Resolve(c.Request.Context(), bucket, ""); err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// createBucket can't create anything since buckets are the vault roots, but answers like S3
+// does for a bucket that exists so clients that always try to create theirs carry on.
+func (h *S3Handler) createBucket(c *gin.Context, bucket string) {
+	if _, err := h.S3Service.Resolve(c.Request.Context(), bucket, ""); err != nil {
+		if errors.Is(err, services.ErrNoSuchBucket) {
+			s3.WriteError(c.Writer, http.StatusForbidden, "AccessDenied", "buckets are the vault roots and can't be created", c.Request.URL.Path, c.GetString("request_id"))
+			return
+		}
+		h.fail(c, err)
+		return
+	}
+	s3.WriteError(c.Writer, http.StatusConflict, "BucketAlreadyOwnedByYou", "bucket already exists", c.Request.URL.Path, c.GetString("request_id"))
+}
+
+// listObjects serves both ListObjects versions. V2 pages with an opaque continuation token,
+// V1 with the last key as marker; both come down to listing the keys after a given one.
+func (h *S3Handler) listObjects(c *gin.Context, bucket string) {
+	query := c.Request.URL.Query()
+	v2 := query.Get("list-type") == "2"
+
+	maxKeys := 1000
+	if value := query.Get("max-keys"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			h.writeError(c, http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer")
+			return
+		}
+		maxKeys = n
+	}
+
+	startAfter := query.Get("marker")
+	if v2 {
+		startAfter = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				h.writeError(c, http.StatusBadRequest, "InvalidArgument", "invalid continuation token")
+				return
+			}
+			startAfter = string(decoded)
+		}
+	}
+
+	result, err := h.S3Service.ListObjects(c.Request.Context(), bucket, query.Get("prefix"), query.Get("delimiter"), startAfter, maxKeys)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	result.Xmlns = s3.Namespace
+	if v2 {
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = query.Get("start-after")
+		if result.IsTruncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(result.NextMarker))
+		}
+		result.NextMarker = ""
+	} else {
+		result.Marker = query.Get("marker")
+	}
+
+	h.writeXML(c, http.StatusOK, result)
+}
+
+// getObject answers GET and HEAD. Ranges and conditional requests are handled by
+// http.ServeContent.
+func (h *S3Handler) getObject(c *gin.Context, bucket, key string, withBody bool) {
+	ctx := c.Request.Context()
+
+	obj, err := h.S3Service.Resolve(ctx, bucket, key)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	info, err := h.S3Service.HeadObject(ctx, obj)
+	if err != nil {
+		if withBody {
+			h.AuditService.Record(newAuditEntry(c, entities.AuditActionDownload, obj.Path, 0, err))
+		}
+		h.fail(c, err)
+		return
+	}
+
+	c.Header("ETag", services.ObjectETag(info))
+	c.Header("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	c.Header("Accept-Ranges", "bytes")
+
+	// Folder markers are empty objects
+	if info.IsDir() {
+		c.Header("Content-Type", "application/x-directory")
+		c.Header("Content-Length", "0")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	if !withBody {
+		c.Header("Content-Type", contentTypeOf(obj.Key))
+		c.Header("Content-Length", strconv.FormatInt(info.Size(), 10))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	previewInfo, err := h.S3Service.GetObject(ctx, obj)
+	if err != nil {
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionDownload, obj.Path, 0, err))
+		h.fail(c, err)
+		return
+	}
+	defer previewInfo.File.Close()
+
+	c.Header("Content-Type", previewInfo.MimeType)
+	http.ServeContent(c.Writer, c.Request, "", previewInfo.Info.ModTime(), previewInfo.File)
+
+	bytes := bytesWritten(c)
+	monitoring.BytesDownloaded.WithLabelValues(entities.AuditActionDownload).Add(float64(bytes))
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionDownload, obj.Path, bytes, nil))
+}
+
+func (h *S3Handler) putObject(c *gin.Context, bucket, key string) {
+	ctx := c.Request.Context()
+
+	obj, err := h.S3Service.Resolve(ctx, bucket, key)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	body, err := verifyContentMD5(c)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	action := entities.AuditActionUpload
+	if strings.HasSuffix(key, "/") {
+		action = entities.AuditActionCreateFolder
+	}
+
+	info, err := h.S3Service.PutObject(ctx, actorFromContext(c), obj, body)
+	if err != nil {
+		h.AuditService.Record(newAuditEntry(c, action, obj.Path, 0, err))
+		h.fail(c, err)
+		return
+	}
+
+	var bytes int64
+	if !info.IsDir() {
+		bytes = info.Size()
+	}
+	h.AuditService.Record(newAuditEntry(c, action, obj.Path, bytes, nil))
+
+	c.Header("ETag", services.ObjectETag(info))
+	c.Status(http.StatusOK)
+}
+
+func (h *S3Handler) deleteObject(c *gin.Context, bucket, key string) {
+	ctx := c.Request.Context()
+
+	obj, err := h.S3Service.Resolve(ctx, bucket, key)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	err = h.S3Service.DeleteObject(ctx, obj)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionDelete, obj.Path, 0, err))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *S3Handler) createMultipartUpload(c *gin.Context, bucket, key string) {
+	ctx := c.Request.Context()
+
+	obj, err := h.S3Service.Resolve(ctx, bucket, key)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	upload, err := h.S3Service.CreateMultipartUpload(ctx, actorFromContext(c), obj)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	h.writeXML(c, http.StatusOK, &s3.InitiateMultipartUploadResult{
+		Xmlns:    s3.Namespace,
+		Bucket:   upload.Bucket,
+		Key:      upload.Key,
+		UploadID: upload.ID.String(),
+	})
+}
+
+func (h *S3Handler) uploadPart(c *gin.Context, uploadID, partNumber string) {
+	number, err := strconv.Atoi(partNumber)
+	if err != nil {
+		h.writeError(c, http.StatusBadRequest, "InvalidArgument", "partNumber must be an integer")
+		return
+	}
+
+	body, err := verifyContentMD5(c)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	part, err := h.S3Service.UploadPart(c.Request.Context(), actorFromContext(c), uploadID, number, body)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+
+	c.Header("ETag", `"`+part.ETag+`"`)
+	c.Status(http.StatusOK)
+}
+
+func (h *S3Handler) completeMultipartUpload(c *gin.Context, uploadID string) {
+	var req s3.CompleteMultipartUpload
+	if err := xml.NewDecoder(io.LimitReader(c.Request.Body, maxCompleteBody)).Decode(&req); err != nil {
+		h.writeError(c, http.StatusBadRequest, "MalformedXML", "invalid part list")
+		return
+	}
+
+	upload, info, err := h.S3Service.CompleteMultipartUpload(c.Request.Context(), actorFromContext(c), uploadID, req.Parts)
+	if err != nil {
+		if upload != nil {
+			h.AuditService.Record(newAuditEntry(c, entities.AuditActionUpload, upload.Path, 0, err))
+		}
+		h.fail(c, err)
+		return
+	}
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionUpload, upload.Path, info.Size(), nil))
+
+	h.writeXML(c, http.StatusOK, &s3.CompleteMultipartUploadResult{
+		Xmlns:  s3.Namespace,
+		Bucket: upload.Bucket,
+		Key:    upload.Key,
+		ETag:   services.ObjectETag(info),
+	})
+}
+
+func (h *S3Handler) abortMultipartUpload(c *gin.Context, uploadID string) {
+	if _, err := h.S3Service.AbortMultipartUpload(c.Request.Context(), actorFromContext(c), uploadID); err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *S3Handler) writeXML(c *gin.Context, status int, v any) {
+	if err := s3.WriteXML(c.Writer, status, v); err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to write s3 response", "error", err)
+	}
+}
+
+func (h *S3Handler) writeError(c *gin.Context, status int, code, message string) {
+	s3.WriteError(c.Writer, status, code, message, c.Request.URL.Path, c.GetString("request_id"))
+}
+
+// fail answers with the S3 error code clients expect for err.
+func (h *S3Handler) fail(c *gin.Context, err error) {
+	status, code := http.StatusInternalServerError, "InternalError"
+	message := err.Error()
+
+	switch {
+	case errors.Is(err, services.ErrNoSuchBucket):
+		status, code = http.StatusNotFound, "NoSuchBucket"
+	case errors.Is(err, services.ErrNoSuchKey), errors.Is(err, fs.ErrNotExist):
+		status, code = http.StatusNotFound, "NoSuchKey"
+	case errors.Is(err, services.ErrNoSuchUpload):
+		status, code = http.StatusNotFound, "NoSuchUpload"
+	case errors.Is(err, services.ErrInvalidKey):
+		status, code = http.StatusBadRequest, "InvalidArgument"
+	case errors.Is(err, services.ErrInvalidPart):
+		status, code = http.StatusBadRequest, "InvalidPart"
+	case errors.Is(err, services.ErrPathNotAllowed), errors.Is(err, fs.ErrPermission),
+		errors.Is(err, services.ErrFolderLocked):
+		status, code = http.StatusForbidden, "AccessDenied"
+	case errors.Is(err, errBadDigest):
+		status, code = http.StatusBadRequest, "BadDigest"
+	case errors.Is(err, s3.ErrContentSHA256):
+		status, code = http.StatusBadRequest, "XAmzContentSHA256Mismatch"
+	case errors.Is(err, s3.ErrSignatureMismatch):
+		status, code = http.StatusForbidden, "SignatureDoesNotMatch"
+	case errors.Is(err, s3.ErrIncompleteBody):
+		status, code = http.StatusBadRequest, "IncompleteBody"
+	case errors.Is(err, context.Canceled):
+		status, code = http.StatusBadRequest, "RequestTimeout"
+	default:
+		h.logger.ErrorContext(c.Request.Context(), "s3 request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+		message = "We encountered an internal error. Please try again."
+	}
+
+	h.writeError(c, status, code, message)
+}
+
+// verifyContentMD5 returns the request body, checked against the Content-MD5 header if the
+// client sent one.
+func verifyContentMD5(c *gin.Context) (io.Reader, error) {
+	header := c.GetHeader("Content-MD5")
+	if header == "" {
+		return c.Request.Body, nil
+	}
+	expected, err := base64.StdEncoding.DecodeString(header)
+	if err != nil || len(expected) != md5.Size {
+		return nil, errBadDigest
+	}
+	return &md5VerifyingReader{r: c.Request.Body, hash: md5.New(), expected: string(expected)}, nil
+}
+
+type md5VerifyingReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected string
+}
+
+func (r *md5VerifyingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && string(r.hash.Sum(nil)) != r.expected {
+		return n, errBadDigest
+	}
+	return n, err
+}
+
+func subResource(query url.Values) string {
+	for _, name := range s3SubResources {
+		if query.Has(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// contentTypeOf guesses the type of an object from its key, as GET would report it.
+func contentTypeOf(key string) string {
+	if ct := mime.TypeByExtension(strings.ToLower(path.Ext(key))); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
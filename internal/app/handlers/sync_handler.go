@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SyncHandler struct {
+	SyncService  services.SyncService
+	AuditService services.AuditService
+	logger       *slog.Logger
+}
+
+func NewSyncHandler(sync services.SyncService, audit services.AuditService, logger *slog.Logger) *SyncHandler {
+	return &SyncHandler{
+		SyncService:  sync,
+		AuditService: audit,
+		logger:       logger.With("component", "handler.sync"),
+	}
+}
+
+// GetSnapshot godoc
+// @Summary      Sync snapshot
+// @Description  List every file and folder below a folder with its size, modification time and content hash (the hex SHA-256 of the file as clients see it), along with the cursor of the sync journal to ask for the changes made since. Hashes are cached while a file's size and modification time are unchanged, so the first snapshot of a folder may read every file; no_hashes leaves them out. Folders with more than 100000 entries must be synced as several subfolders.
+// @Tags         Sync
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Folder"
+// @Param        no_hashes query bool false "Leave out the content hashes"
+// @Success      200 {object} entities.SyncSnapshot "Snapshot"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Folder not found"
+// @Failure      413 {object} map[string]string "Folder has too many entries"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/sync/snapshot [get]
+func (h *SyncHandler) GetSnapshot(c *gin.Context) {
+	var req entities.SyncSnapshotRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	// Hashing a large folder for the first time outlives SERVER_WRITE_TIMEOUT
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.DebugContext(c.Request.Context(), "failed to clear write deadline", "error", err)
+	}
+
+	snapshot, err := h.SyncService.Snapshot(c.Request.Context(), &req)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionList, req.Path, 0, err))
+	if err != nil {
+		c.JSON(syncErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    snapshot,
+		"message": "fetch sync snapshot successfully",
+	})
+}
+
+// GetChanges godoc
+// @Summary      Sync changes
+// @Description  List the changes below a folder after a cursor, oldest first: files and folders created, modified, deleted or renamed (old_path is set; a rename out of the folder is a removal for the client), and removals and renames of the folders above it. Each change that didn't remove its path has the path's current state as entry, with its hash. The cursor of the response is the one to ask with next; with has_more, ask again right away. With reset, the changes since the cursor aren't known (the journal was trimmed, or changes were lost) and the client must take a new snapshot. Changes made directly on disk are only journaled for folders the file watcher is watching.
+// @Tags         Sync
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Folder"
+// @Param        cursor query int false "Cursor of the snapshot or of the last changes received"
+// @Param        limit query int false "Most changes to return (default 1000, max 10000)"
+// @Param        no_hashes query bool false "Leave out the content hashes"
+// @Success      200 {object} entities.SyncDelta "Changes"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Router       /api/sync/changes [get]
+func (h *SyncHandler) GetChanges(c *gin.Context) {
+	var req entities.SyncChangesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	delta, err := h.SyncService.Changes(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(syncErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    delta,
+		"message": "fetch sync changes successfully",
+	})
+}
+
+// UploadFile godoc
+// @Summary      Conditional upload
+// @Description  Write the request body to a file, creating the folders above it. With If-Match, the file is only replaced if its hash is the one given (or, with *, if it exists); with If-None-Match: *, it is only created if nothing is there yet. Otherwise the write is refused with 412 and current, the file or folder there now (null if nothing is): the client can download it, retry with its hash to overwrite it, or upload its own version under another name. The response has the new hash, also sent as ETag.
+// @Tags         Sync
+// @Accept       application/octet-stream
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file"
+// @Param        If-Match header string false "Hash the file must have, or *"
+// @Param        If-None-Match header string false "* to only create the file"
+// @Success      200 {object} entities.SyncEntry "File written"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      412 {object} map[string]any "File was changed"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/sync/file [put]
+func (h *SyncHandler) UploadFile(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+	cond, err := syncCondition(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := h.SyncService.Upload(c.Request.Context(), actorFromContext(c), path, c.Request.Body, cond)
+	var size int64
+	if entry != nil {
+		size = entry.Size
+	}
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionUpload, path, size, err))
+	if err != nil {
+		h.conflictOrError(c, err)
+		return
+	}
+
+	c.Header("ETag", `"`+entry.Hash+`"`)
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    entry,
+		"message": "file uploaded successfully",
+	})
+}
+
+// DeleteFile godoc
+// @Summary      Conditional delete
+// @Description  Delete a file, or a folder with everything in it. With If-Match, a file is only deleted if its hash is the one given (or, with *, if anything is there); otherwise the delete is refused with 412 and current, what is there now.
+// @Tags         Sync
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file or folder"
+// @Param        If-Match header string false "Hash the file must have, or *"
+// @Success      200 {object} map[string]string "Deleted"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Not found"
+// @Failure      412 {object} map[string]any "File was changed"
+// @Router       /api/sync/file [delete]
+func (h *SyncHandler) DeleteFile(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+	cond, err := syncCondition(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cond.IfNoneMatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-None-Match can't be used to delete"})
+		return
+	}
+
+	err = h.SyncService.Delete(c.Request.Context(), path, cond)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionDelete, path, 0, err))
+	if err != nil {
+		h.conflictOrError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "deleted successfully"})
+}
+
+// GetSignature godoc
+// @Summary      File signature
+// @Description  Get the checksums of every block of a file, for a delta upload: the rsync rolling checksum (weak) and the first 16 bytes of the SHA-256 (strong) of each block of block_size bytes, the last one being shorter. The client finds these blocks anywhere in its own version of the file and sends a delta made of copies of them and of the data that changed to PUT /api/sync/delta, with hash as If-Match. Blocks are about the square root of the file's size unless block_size is given; smaller blocks make a smaller delta but a larger signature. Computing a signature reads the whole file.
+// @Tags         Sync
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file"
+// @Param        block_size query int false "Block size in bytes (1024 to 1048576)"
+// @Success      200 {object} entities.SyncSignature "Signature"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "File not found"
+// @Failure      413 {object} map[string]string "File has too many blocks"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/sync/signature [get]
+func (h *SyncHandler) GetSignature(c *gin.Context) {
+	var req entities.SyncSignatureRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	// Reading a file of several gigabytes outlives SERVER_WRITE_TIMEOUT
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.DebugContext(c.Request.Context(), "failed to clear write deadline", "error", err)
+	}
+
+	signature, err := h.SyncService.Signature(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(syncErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    signature,
+		"message": "fetch file signature successfully",
+	})
+}
+
+// UploadDelta godoc
+// @Summary      Delta upload
+// @Description  Replace a file by the one rebuilt from it and a delta, computed against the signature from GET /api/sync/signature. If-Match must be the hash of that signature: if the file has changed since, the upload is refused with 412 and current, as for PUT /api/sync/file. The body starts with "PVD1" and is a sequence of operations, integers being big-endian: 0x01 followed by an offset (uint64) and a length (uint32) copies that range of the file; 0x02 followed by a length (uint32) and as many bytes appends them; 0x00 followed by the 32-byte SHA-256 of the new file ends the delta. The new file is written like any upload and only replaces the old one, which is kept as a version, if its SHA-256 is the one at the end of the delta.
+// @Tags         Sync
+// @Accept       application/octet-stream
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file"
+// @Param        If-Match header string true "Hash of the file the delta was computed against"
+// @Success      200 {object} entities.SyncEntry "File written"
+// @Failure      400 {object} map[string]string "Invalid request or delta"
+// @Failure      404 {object} map[string]string "File not found"
+// @Failure      412 {object} map[string]any "File was changed"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/sync/delta [put]
+func (h *SyncHandler) UploadDelta(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+	cond, err := syncCondition(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cond.IfMatch == "" || cond.IfMatch == "*" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match must be the hash of the file the delta was computed against"})
+		return
+	}
+
+	// The body is read as the file is rebuilt, which for a large file outlives both
+	// SERVER_READ_TIMEOUT and SERVER_WRITE_TIMEOUT however small the delta is
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		h.logger.DebugContext(c.Request.Context(), "failed to clear read deadline", "error", err)
+	}
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.DebugContext(c.Request.Context(), "failed to clear write deadline", "error", err)
+	}
+
+	entry, err := h.SyncService.UploadDelta(c.Request.Context(), actorFromContext(c), path, c.Request.Body, cond.IfMatch)
+	var size int64
+	if entry != nil {
+		size = entry.Size
+	}
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionUpload, path, size, err))
+	if err != nil {
+		h.conflictOrError(c, err)
+		return
+	}
+
+	c.Header("ETag", `"`+entry.Hash+`"`)
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    entry,
+		"message": "file uploaded successfully",
+	})
+}
+
+// syncCondition reads the If-Match and If-None-Match headers of a conditional write.
+func syncCondition(c *gin.Context) (services.SyncCondition, error) {
+	var cond services.SyncCondition
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	ifNoneMatch := strings.TrimSpace(c.GetHeader("If-None-Match"))
+
+	if ifMatch != "" && ifNoneMatch != "" {
+		return cond, errors.New("If-Match and If-None-Match can't be used together")
+	}
+	if ifNoneMatch != "" {
+		if ifNoneMatch != "*" {
+			return cond, errors.New("If-None-Match must be *")
+		}
+		cond.IfNoneMatch = true
+	}
+	if strings.HasPrefix(ifMatch, "W/") {
+		return cond, errors.New("If-Match must be a hash, not a weak ETag")
+	}
+	cond.IfMatch = strings.Trim(ifMatch, `"`)
+	return cond, nil
+}
+
+// conflictOrError answers a failed write, with what is at the path now for a conflict.
+func (h *SyncHandler) conflictOrError(c *gin.Context, err error) {
+	var conflict *services.SyncConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error(), "current": conflict.Current})
+		return
+	}
+	c.JSON(syncErrorStatus(err), gin.H{"error": err.Error()})
+}
+
+func syncErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrPathNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrSnapshotTooLarge), errors.Is(err, services.ErrTooManyBlocks):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrFolderLocked):
+		return http.StatusLocked
+	}
+	return http.StatusBadRequest
+}
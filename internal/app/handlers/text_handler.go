@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/gin-gonic/gin"
+)
+
+type TextHandler struct {
+	TextService  services.TextService
+	AuditService services.AuditService
+	logger       *slog.Logger
+}
+
+func NewTextHandler(text services.TextService, audit services.AuditService, logger *slog.Logger) *TextHandler {
+	return &TextHandler{
+		TextService:  text,
+		AuditService: audit,
+		logger:       logger.With("component", "handler.text"),
+	}
+}
+
+// PreviewText godoc
+// @Summary      Preview text file lines
+// @Description  Get the first or last lines of a text file, or a range of lines, decoded to UTF-8. The encoding is detected from the byte order mark (UTF-8, UTF-16), else the file is read as UTF-8 or, if it isn't valid UTF-8, Latin-1. Only the lines asked for are read, so files of any size can be previewed; a tail's line numbers aren't known. Very long lines are cut and flagged as truncated.
+// @Tags         Drive
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file"
+// @Param        view query string false "head (default), tail or range"
+// @Param        lines query int false "Number of lines of a head or tail (default 100)"
+// @Param        from query int false "First line of a range"
+// @Param        to query int false "Last line of a range"
+// @Success      200 {object} entities.TextPreview "File lines"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "File not found"
+// @Failure      415 {object} map[string]string "File is not text"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/drivers/text [get]
+func (h *TextHandler) PreviewText(c *gin.Context) {
+	var req entities.TextPreviewRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	preview, err := h.TextService.Lines(c.Request.Context(), &req)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionPreview, req.Path, 0, err))
+	if err != nil {
+		c.JSON(textErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    preview,
+		"message": "fetch text preview successfully",
+	})
+}
+
+// PreviewStructured godoc
+// @Summary      Structured file preview
+// @Description  Parse a Markdown, JSON or CSV file for display. Markdown is split into its top-level blocks, JSON is returned as a document with exact numbers, and CSV files are returned a page of rows at a time, with the delimiter detected from the first line and the first row as column names unless no_header is set. The format defaults to the one of the file's extension (.md, .json, .csv, .tsv). Markdown and JSON files larger than PREVIEW_MAX_STRUCTURED_MB are refused.
+// @Tags         Drive
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file"
+// @Param        format query string false "markdown, json or csv"
+// @Param        no_header query bool false "The first CSV row is data, not column names"
+// @Param        page query int false "Page of CSV rows"
+// @Param        page_size query int false "CSV rows per page (default 100, max 1000)"
+// @Success      200 {object} entities.StructuredPreview "Parsed file"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "File not found"
+// @Failure      413 {object} map[string]string "File is too large"
+// @Failure      415 {object} map[string]string "File is not text or has no structured preview"
+// @Failure      422 {object} map[string]string "File could not be parsed"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Router       /api/drivers/text/structured [get]
+func (h *TextHandler) PreviewStructured(c *gin.Context) {
+	var req entities.StructuredPreviewRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query " + err.Error()})
+		return
+	}
+
+	preview, err := h.TextService.Structured(c.Request.Context(), &req)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionPreview, req.Path, 0, err))
+	if err != nil {
+		c.JSON(textErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    preview,
+		"message": "fetch structured preview successfully",
+	})
+}
+
+// FollowText godoc
+// @Summary      Follow a text file
+// @Description  Server-Sent Events stream of the lines appended to a text file, like tail -f. A ready event with the last lines of the file (an entities.TextPreview) is sent first, then a lines event for each batch of complete lines written to it. If the file is truncated or replaced by a smaller one, a reset event is sent and the file is followed from its start. Browsers' EventSource can't set headers, so the token may be passed as access_token instead.
+// @Tags         Drive
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file"
+// @Param        lines query int false "Number of last lines to send first (default 100)"
+// @Param        access_token query string false "Bearer token, for clients that can't send the Authorization header"
+// @Success      200 {object} entities.TextFollowEvent "Event stream"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "File not found"
+// @Failure      415 {object} map[string]string "File is not text"
+// @Failure      423 {object} map[string]string "Encrypted folder is locked"
+// @Failure      503 {object} map[string]string "Server is shutting down"
+// @Router       /api/drivers/text/follow [get]
+func (h *TextHandler) FollowText(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+	lines := 0
+	if value := c.Query("lines"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lines must be a positive number"})
+			return
+		}
+		lines = n
+	}
+
+	preview, events, err := h.TextService.Follow(c.Request.Context(), path, lines)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionPreview, path, 0, err))
+	if err != nil {
+		c.JSON(textErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Streams outlive SERVER_WRITE_TIMEOUT
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.DebugContext(c.Request.Context(), "failed to clear write deadline", "error", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.SSEvent("ready", preview)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.SSEvent(event.Type, event)
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func textErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNotText), errors.Is(err, services.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, services.ErrPreviewTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrInvalidDocument):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrFolderLocked):
+		return http.StatusLocked
+	case errors.Is(err, services.ErrPreviewsClosed):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
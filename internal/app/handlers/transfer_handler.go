@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/throttle"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type TransferHandler struct {
+	TransferService services.TransferService
+	logger          *slog.Logger
+}
+
+func NewTransferHandler(transfers services.TransferService, logger *slog.Logger) *TransferHandler {
+	return &TransferHandler{
+		TransferService: transfers,
+		logger:          logger.With("component", "handler.transfer"),
+	}
+}
+
+// Throttle is the middleware of the routes that download or upload files. It limits the
+// bandwidth of the request body and of the response to the caller's and the server's
+// rates, and counts them against the caller's daily quota. When the caller has a quota,
+// the response has the quota (X-Transfer-Quota-Limit, in bytes), what was left of it when
+// the request started (X-Transfer-Quota-Remaining) and the seconds until it is renewed
+// (X-Transfer-Quota-Reset). Once the quota is used up, requests are refused with 429 and
+// transfers in progress are cut off.
+func (h *TransferHandler) Throttle(c *gin.Context) {
+	ctx := c.Request.Context()
+	transfer, err := h.TransferService.Begin(ctx, actorFromContext(c).UserID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to start transfer", "error", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer transfer.End()
+
+	status := transfer.Status()
+	if status.Remaining != nil {
+		reset := strconv.FormatInt(int64(time.Until(status.ResetsAt).Seconds())+1, 10)
+		c.Header("X-Transfer-Quota-Limit", strconv.FormatInt(status.DailyQuotaMB<<20, 10))
+		c.Header("X-Transfer-Quota-Remaining", strconv.FormatInt(*status.Remaining, 10))
+		c.Header("X-Transfer-Quota-Reset", reset)
+		if *status.Remaining == 0 {
+			c.Header("Retry-After", reset)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": services.ErrTransferQuotaExceeded.Error()})
+			return
+		}
+	}
+
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		c.Request.Body = &throttledBody{ReadCloser: c.Request.Body, ctx: ctx, transfer: transfer}
+	}
+	c.Writer = &throttledWriter{ResponseWriter: c.Writer, ctx: ctx, transfer: transfer}
+	c.Next()
+}
+
+// throttledBody reads a request body within the limits of its transfer.
+type throttledBody struct {
+	io.ReadCloser
+	ctx      context.Context
+	transfer *services.Transfer
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > throttle.ChunkSize {
+		p = p[:throttle.ChunkSize]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if limitErr := b.transfer.Upload(b.ctx, n); limitErr != nil {
+			return n, limitErr
+		}
+	}
+	return n, err
+}
+
+// throttledWriter writes a response body within the limits of its transfer.
+type throttledWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	transfer *services.Transfer
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), throttle.ChunkSize)]
+		if err := w.transfer.Download(w.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (w *throttledWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Unwrap lets http.ResponseController reach the connection, to change its deadlines.
+func (w *throttledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// GetStatus godoc
+// @Summary      Get transfer limits and usage
+// @Description  Get the caller's bandwidth limits (KiB per second, 0 for none), daily transfer quota (MiB, 0 for none) and what they downloaded and uploaded today, in bytes. remaining is what is left of the quota, absent without one; the quota is renewed at resets_at.
+// @Tags         User
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} entities.TransferStatus "Transfer limits and usage"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/users/me/transfers [get]
+func (h *TransferHandler) GetStatus(c *gin.Context) {
+	status, err := h.TransferService.Status(c.Request.Context(), actorFromContext(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    status,
+		"message": "fetch transfer status successfully",
+	})
+}
+
+// GetLimit godoc
+// @Summary      Get a user's transfer limits
+// @Description  Get the bandwidth limits and daily transfer quota of a user, the server defaults if none were set.
+// @Tags         Admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "User ID"
+// @Success      200 {object} entities.TransferLimit "Transfer limits"
+// @Failure      400 {object} map[string]string "Invalid user ID"
+// @Failure      403 {object} map[string]string "Admin access required"
+// @Failure      404 {object} map[string]string "User not found"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/admin/users/{id}/transfer-limits [get]
+func (h *TransferHandler) GetLimit(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	limit, err := h.TransferService.GetLimit(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(transferErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    limit,
+		"message": "fetch transfer limits successfully",
+	})
+}
+
+// SetLimit godoc
+// @Summary      Set a user's transfer limits
+// @Description  Set the download and upload rates (KiB per second) and daily transfer quota (MiB) of a user in place of the server defaults; 0 means no limit. The rates apply at once, including to transfers in progress.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "User ID"
+// @Param        request body entities.TransferLimitRequest true "Transfer limits"
+// @Success      200 {object} entities.TransferLimit "Transfer limits"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      403 {object} map[string]string "Admin access required"
+// @Failure      404 {object} map[string]string "User not found"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/admin/users/{id}/transfer-limits [put]
+func (h *TransferHandler) SetLimit(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	var req entities.TransferLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+
+	limit, err := h.TransferService.SetLimit(c.Request.Context(), id, &req)
+	if err != nil {
+		c.JSON(transferErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    limit,
+		"message": "transfer limits updated successfully",
+	})
+}
+
+// ResetLimit godoc
+// @Summary      Reset a user's transfer limits
+// @Description  Put a user back on the server's default bandwidth limits and daily transfer quota.
+// @Tags         Admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "User ID"
+// @Success      200 {object} map[string]string "Transfer limits reset"
+// @Failure      400 {object} map[string]string "Invalid user ID"
+// @Failure      403 {object} map[string]string "Admin access required"
+// @Failure      404 {object} map[string]string "User not found"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/admin/users/{id}/transfer-limits [delete]
+func (h *TransferHandler) ResetLimit(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	if err := h.TransferService.ResetLimit(c.Request.Context(), id); err != nil {
+		c.JSON(transferErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "transfer limits reset successfully"})
+}
+
+func transferErrorStatus(err error) int {
+	if errors.Is(err, services.ErrUserNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// transferRepo holds the limits of the one user of a test and what they transferred
+// before it.
+type transferRepo struct {
+	repositories.TransferRepository
+
+	mu    sync.Mutex
+	limit entities.TransferLimit
+	used  int64
+}
+
+func (r *transferRepo) GetLimit(ctx context.Context, userID uuid.UUID) (*entities.TransferLimit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	limit := r.limit
+	return &limit, nil
+}
+
+func (r *transferRepo) SaveLimit(ctx context.Context, limit *entities.TransferLimit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limit = *limit
+	return nil
+}
+
+func (r *transferRepo) GetUsage(ctx context.Context, userID uuid.UUID, day string) (*entities.TransferUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &entities.TransferUsage{UserID: userID, Day: day, Downloaded: r.used}, nil
+}
+
+func (r *transferRepo) AddUsage(ctx context.Context, usages []entities.TransferUsage) error {
+	return nil
+}
+
+func (r *transferRepo) DeleteUsageBefore(ctx context.Context, day string) (int64, error) {
+	return 0, nil
+}
+
+// userRepo finds every user.
+type userRepo struct {
+	repositories.UserRepository
+}
+
+func (userRepo) GetByID(id uuid.UUID) (*entities.User, error) {
+	return &entities.User{}, nil
+}
+
+// throttledRouter serves handler behind Throttle, for a user with limit who already
+// transferred used bytes today.
+func throttledRouter(t *testing.T, limit entities.TransferLimit, used int64, handler gin.HandlerFunc) (*gin.Engine, services.TransferService, uuid.UUID) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	userID := uuid.New()
+	limit.UserID = userID
+	transfers := services.NewTransferService(&transferRepo{limit: limit, used: used}, userRepo{}, config.TransferConfig{}, logger)
+	t.Cleanup(transfers.Close)
+	h := NewTransferHandler(transfers, logger)
+
+	router := gin.New()
+	router.Any("/", func(c *gin.Context) {
+		c.Set("user_id", userID)
+	}, h.Throttle, handler)
+	return router, transfers, userID
+}
+
+func TestThrottleKeepsRateAcrossWrites(t *testing.T) {
+	// A second's worth goes out at once, the next quarter of a second at the rate
+	size := 1<<20 + 256<<10
+	router, _, _ := throttledRouter(t, entities.TransferLimit{DownloadRateKB: 1024}, 0, func(c *gin.Context) {
+		for range size / 4096 {
+			if _, err := c.Writer.Write(make([]byte, 4096)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	elapsed := time.Since(start)
+
+	if w.Body.Len() != size {
+		t.Fatalf("sent %d bytes, want %d", w.Body.Len(), size)
+	}
+	if elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("sent in %v, want 250ms", elapsed)
+	}
+	if w.Header().Get("X-Transfer-Quota-Limit") != "" {
+		t.Error("quota headers without a quota")
+	}
+}
+
+func TestThrottleRateChangeMidTransfer(t *testing.T) {
+	started, changed := make(chan struct{}), make(chan struct{})
+	var elapsed time.Duration
+	router, transfers, userID := throttledRouter(t, entities.TransferLimit{DownloadRateKB: 1024}, 0, func(c *gin.Context) {
+		// The burst of the first rate
+		c.Writer.Write(make([]byte, 1<<20))
+		close(started)
+		<-changed
+
+		start := time.Now()
+		c.Writer.Write(make([]byte, 128<<10))
+		elapsed = time.Since(start)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-started
+	if _, err := transfers.SetLimit(context.Background(), userID, &entities.TransferLimitRequest{DownloadRateKB: 64}); err != nil {
+		t.Error(err)
+	}
+	close(changed)
+	<-done
+
+	// 128 KiB takes 125ms at the first rate. At the new one, the bucket holds at most a
+	// second's worth, so the rest waits at least another second
+	if elapsed < 900*time.Millisecond || elapsed > 4*time.Second {
+		t.Errorf("sent the rest in %v after slowing down, want 1 to 2s", elapsed)
+	}
+}
+
+func TestThrottleQuotaCutsOffTransfer(t *testing.T) {
+	quota := entities.TransferLimit{DailyQuotaMB: 1}
+
+	var written int
+	var writeErr error
+	router, _, _ := throttledRouter(t, quota, 0, func(c *gin.Context) {
+		written, writeErr = c.Writer.Write(make([]byte, 2<<20))
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !errors.Is(writeErr, services.ErrTransferQuotaExceeded) {
+		t.Errorf("write error = %v, want ErrTransferQuotaExceeded", writeErr)
+	}
+	if written != 1<<20 || w.Body.Len() != 1<<20 {
+		t.Errorf("wrote %d bytes (%d sent), want the 1 MiB of the quota", written, w.Body.Len())
+	}
+	if got := w.Header().Get("X-Transfer-Quota-Limit"); got != strconv.Itoa(1<<20) {
+		t.Errorf("X-Transfer-Quota-Limit = %s, want %d", got, 1<<20)
+	}
+	if got := w.Header().Get("X-Transfer-Quota-Remaining"); got != strconv.Itoa(1<<20) {
+		t.Errorf("X-Transfer-Quota-Remaining = %s, want %d", got, 1<<20)
+	}
+
+	var readErr error
+	router, _, _ = throttledRouter(t, quota, 512<<10, func(c *gin.Context) {
+		_, readErr = io.ReadAll(c.Request.Body)
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(make([]byte, 2<<20))))
+	if !errors.Is(readErr, services.ErrTransferQuotaExceeded) {
+		t.Errorf("read error = %v, want ErrTransferQuotaExceeded", readErr)
+	}
+}
+
+func TestThrottleRefusesWithoutQuotaLeft(t *testing.T) {
+	called := false
+	router, _, _ := throttledRouter(t, entities.TransferLimit{DailyQuotaMB: 1}, 1<<20, func(c *gin.Context) {
+		called = true
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if called {
+		t.Error("handler called without quota left")
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 24*60*60+1 {
+		t.Errorf("Retry-After = %q, want the seconds until midnight", w.Header().Get("Retry-After"))
+	}
+	if got := w.Header().Get("X-Transfer-Quota-Reset"); got != w.Header().Get("Retry-After") {
+		t.Errorf("X-Transfer-Quota-Reset = %s, want it equal to Retry-After", got)
+	}
+	if got := w.Header().Get("X-Transfer-Quota-Remaining"); got != "0" {
+		t.Errorf("X-Transfer-Quota-Remaining = %s, want 0", got)
+	}
+}
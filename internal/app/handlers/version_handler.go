@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/services"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type VersionHandler struct {
+	VersionService services.VersionService
+	AuditService   services.AuditService
+	logger         *slog.Logger
+}
+
+func NewVersionHandler(versions services.VersionService, audit services.AuditService, logger *slog.Logger) *VersionHandler {
+	return &VersionHandler{
+		VersionService: versions,
+		AuditService:   audit,
+		logger:         logger.With("component", "handler.version"),
+	}
+}
+
+// ListVersions godoc
+// @Summary      List file versions
+// @Description  List the previous versions of a file, newest first
+// @Tags         Versions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file"
+// @Success      200 {object} map[string]any "Versions"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/versions [get]
+func (h *VersionHandler) ListVersions(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+
+	versions, err := h.VersionService.List(c.Request.Context(), path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    versions,
+		"message": "fetch versions successfully",
+	})
+}
+
+// DownloadVersion godoc
+// @Summary      Download file version
+// @Description  Download the content of a previous version as an attachment
+// @Tags         Versions
+// @Produce      octet-stream
+// @Security     BearerAuth
+// @Param        id path string true "Version ID"
+// @Success      200 {file} file "Version content"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Version not found"
+// @Router       /api/drivers/versions/{id}/download [get]
+func (h *VersionHandler) DownloadVersion(c *gin.Context) {
+	h.serveVersion(c, entities.AuditActionVersionDownload, true)
+}
+
+// PreviewVersion godoc
+// @Summary      Preview file version
+// @Description  Serve the content of a previous version inline, with Range support
+// @Tags         Versions
+// @Produce      octet-stream
+// @Security     BearerAuth
+// @Param        id path string true "Version ID"
+// @Success      200 {file} file "Version content"
+// @Success      206 {file} file "Partial version content"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Version not found"
+// @Router       /api/drivers/versions/{id}/preview [get]
+func (h *VersionHandler) PreviewVersion(c *gin.Context) {
+	h.serveVersion(c, entities.AuditActionVersionPreview, false)
+}
+
+func (h *VersionHandler) serveVersion(c *gin.Context, action string, attachment bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version id"})
+		return
+	}
+
+	version, info, err := h.VersionService.Open(c.Request.Context(), id)
+	if err != nil {
+		h.AuditService.Record(newAuditEntry(c, action, c.Param("id"), 0, err))
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer info.File.Close()
+
+	name := filepath.Base(version.Path)
+	disposition := "inline"
+	if attachment {
+		disposition = "attachment"
+	}
+
+	c.Header("Content-Type", info.MimeType)
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	http.ServeContent(c.Writer, c.Request, name, info.Info.ModTime(), info.File)
+
+	monitoring.BytesDownloaded.WithLabelValues(action).Add(float64(bytesWritten(c)))
+	h.AuditService.Record(newAuditEntry(c, action, version.Path, bytesWritten(c), nil))
+}
+
+// RestoreVersion godoc
+// @Summary      Restore file version
+// @Description  Make a previous version the current content of the file. The replaced content is kept as a new version.
+// @Tags         Versions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Version ID"
+// @Success      200 {object} map[string]any "Restored version"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Version not found"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/versions/{id}/restore [post]
+func (h *VersionHandler) RestoreVersion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version id"})
+		return
+	}
+
+	current, err := h.VersionService.Restore(c.Request.Context(), actorFromContext(c), id)
+	if err != nil {
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionVersionRestore, c.Param("id"), 0, err))
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var path string
+	var size int64
+	if current != nil {
+		path, size = current.Path, current.Size
+	}
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionVersionRestore, path, size, nil))
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    current,
+		"message": "version restored successfully",
+	})
+}
+
+// DeleteVersion godoc
+// @Summary      Delete file version
+// @Description  Permanently delete a previous version
+// @Tags         Versions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Version ID"
+// @Success      200 {object} map[string]string "Version deleted"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      404 {object} map[string]string "Version not found"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/versions/{id} [delete]
+func (h *VersionHandler) DeleteVersion(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version id"})
+		return
+	}
+
+	version, err := h.VersionService.Delete(c.Request.Context(), id)
+	if err != nil {
+		h.AuditService.Record(newAuditEntry(c, entities.AuditActionVersionDelete, c.Param("id"), 0, err))
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionVersionDelete, version.Path, version.Size, nil))
+
+	c.JSON(http.StatusOK, gin.H{"message": "version deleted successfully"})
+}
+
+// PruneVersions godoc
+// @Summary      Prune file versions
+// @Description  Apply the caller's retention policy to the versions of a file
+// @Tags         Versions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        path query string true "Path of the file"
+// @Success      200 {object} map[string]any "Number of pruned versions"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/drivers/versions/prune [post]
+func (h *VersionHandler) PruneVersions(c *gin.Context) {
+	path := c.Query("path")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+
+	pruned, err := h.VersionService.Prune(c.Request.Context(), actorFromContext(c), path)
+	h.AuditService.Record(newAuditEntry(c, entities.AuditActionVersionPrune, path, 0, err))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    gin.H{"pruned": pruned},
+		"message": "versions pruned successfully",
+	})
+}
+
+// GetRetention godoc
+// @Summary      Get version retention
+// @Description  Get the caller's version retention policy (the server default if never set)
+// @Tags         Versions
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} entities.VersionRetention "Retention policy"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/users/me/version-retention [get]
+func (h *VersionHandler) GetRetention(c *gin.Context) {
+	retention, err := h.VersionService.GetRetention(c.Request.Context(), actorFromContext(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    retention,
+		"message": "fetch retention policy successfully",
+	})
+}
+
+// SetRetention godoc
+// @Summary      Set version retention
+// @Description  Set how many previous versions to keep: the last keep_last versions plus the newest version of each of the last keep_daily_days days. Zero in both keeps everything.
+// @Tags         Versions
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body entities.VersionRetentionRequest true "Retention policy"
+// @Success      200 {object} entities.VersionRetention "Retention policy"
+// @Failure      400 {object} map[string]string "Invalid request"
+// @Failure      500 {object} map[string]string "Internal server error"
+// @Router       /api/users/me/version-retention [put]
+func (h *VersionHandler) SetRetention(c *gin.Context) {
+	var req entities.VersionRetentionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body " + err.Error()})
+		return
+	}
+
+	retention, err := h.VersionService.SetRetention(c.Request.Context(), actorFromContext(c).UserID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"Data":    retention,
+		"message": "retention policy updated successfully",
+	})
+}
+
+func versionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrVersionIsCurrent):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrAccessKeyNotFound = errors.New("access key not found")
+
+type AccessKeyRepository interface {
+	Create(ctx context.Context, key *entities.AccessKey) error
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]entities.AccessKey, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+}
+
+type AccessKeyRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewAccessKeyRepository(db *gorm.DB) AccessKeyRepository {
+	return &AccessKeyRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *AccessKeyRepositoryImpl) Create(ctx context.Context, key *entities.AccessKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *AccessKeyRepositoryImpl) ListByUser(ctx context.Context, userID uuid.UUID) ([]entities.AccessKey, error) {
+	var keys []entities.AccessKey
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// Delete removes one of the user's keys; keys of other users are reported as not found.
+func (r *AccessKeyRepositoryImpl) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&entities.AccessKey{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAccessKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ActivityFilter selects activity. Zero fields don't filter; with Folder, activity in the
+// folder (or below it if Recursive) is selected, including moves out of it.
+type ActivityFilter struct {
+	UserID    uuid.UUID
+	Types     []string
+	Folder    string
+	Recursive bool
+	From      time.Time
+	To        time.Time
+	Offset    int
+	Limit     int
+}
+
+type ActivityRepository interface {
+	CreateBatch(ctx context.Context, activities []entities.Activity) error
+	// List returns activity newest first, along with how much there is in total.
+	List(ctx context.Context, filter ActivityFilter) ([]entities.Activity, int64, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type ActivityRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewActivityRepository(db *gorm.DB) ActivityRepository {
+	return &ActivityRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *ActivityRepositoryImpl) CreateBatch(ctx context.Context, activities []entities.Activity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(activities, len(activities)).Error
+}
+
+func (r *ActivityRepositoryImpl) List(ctx context.Context, filter ActivityFilter) ([]entities.Activity, int64, error) {
+	query := r.db.WithContext(ctx).Model(&entities.Activity{})
+	if filter.UserID != uuid.Nil {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if filter.Folder != "" {
+		if filter.Recursive {
+			prefix := escapeLike(childPrefix(filter.Folder)) + "%"
+			query = query.Where("folder = ? OR folder LIKE ? ESCAPE '\\' OR old_folder = ? OR old_folder LIKE ? ESCAPE '\\'",
+				filter.Folder, prefix, filter.Folder, prefix)
+		} else {
+			query = query.Where("folder = ? OR old_folder = ?", filter.Folder, filter.Folder)
+		}
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var activities []entities.Activity
+	err := query.Order("created_at DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&activities).Error
+	return activities, total, err
+}
+
+func (r *ActivityRepositoryImpl) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("created_at < ?", before).Delete(&entities.Activity{})
+	return result.RowsAffected, result.Error
+}
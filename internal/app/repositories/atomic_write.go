@@ -0,0 +1,329 @@
+package repositories
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+)
+
+// Uploads are written to a hidden temp file next to the destination and renamed into place
+// once complete, so a crash or cancelled request never leaves a truncated file under the
+// real name. The leading dot keeps temp files out of listings (see shouldSkipFile).
+const (
+	tempFilePrefix = ".pv-upload-"
+	tempFileSuffix = ".tmp"
+
+	// tempDirsJournal lists every directory a temp file was ever created in, so the
+	// startup cleanup only scans those instead of walking whole drives.
+	tempDirsJournal = "upload-dirs.log"
+)
+
+// MaxKeepBothAttempts is how many numbered names OverwriteKeepBoth tries before giving up
+const MaxKeepBothAttempts = 10000
+
+// commitTempFile moves the fully written and synced tmp file to dst according to mode and
+// returns the path the file ended up at.
+func commitTempFile(tmp, dst string, mode entities.OverwriteMode) (string, error) {
+	switch mode {
+	case entities.OverwriteReplace:
+		// os.Rename replaces an existing file atomically on both unix and windows
+		if err := os.Rename(tmp, dst); err != nil {
+			return "", err
+		}
+		return dst, nil
+
+	case entities.OverwriteKeepBoth:
+		for n := 0; n < MaxKeepBothAttempts; n++ {
+			candidate := dst
+			if n > 0 {
+				candidate = NumberedName(dst, n)
+			}
+			err := renameNoReplace(tmp, candidate)
+			if err == nil {
+				return candidate, nil
+			}
+			if !errors.Is(err, ErrFileExists) {
+				return "", err
+			}
+		}
+		return "", fmt.Errorf("%w: no free name for %s", ErrFileExists, filepath.Base(dst))
+
+	default:
+		if err := renameNoReplace(tmp, dst); err != nil {
+			return "", err
+		}
+		return dst, nil
+	}
+}
+
+// replacedFileMode is the mode of the file written to dst: the mode of the file it
+// replaces, so a replaced file keeps its permissions, or the mode regular files always
+// had. CreateTemp uses 0600.
+func replacedFileMode(dst string, mode entities.OverwriteMode) fs.FileMode {
+	if mode == entities.OverwriteReplace {
+		if info, err := os.Stat(dst); err == nil && info.Mode().IsRegular() {
+			return info.Mode().Perm()
+		}
+	}
+	return 0644
+}
+
+// renameNoReplace moves tmp to dst only if dst doesn't exist yet. A hard link fails
+// atomically when dst exists; file systems without hard links (FAT/exFAT USB drives) fall
+// back to a check followed by a rename.
+func renameNoReplace(tmp, dst string) error {
+	err := os.Link(tmp, dst)
+	if err == nil {
+		return os.Remove(tmp)
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(dst))
+	}
+
+	if _, statErr := os.Lstat(dst); statErr == nil {
+		return fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(dst))
+	}
+	return os.Rename(tmp, dst)
+}
+
+// NumberedName turns "dir/report.pdf" into "dir/report (n).pdf".
+func NumberedName(path string, n int) string {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	return filepath.Join(dir, fmt.Sprintf("%s (%d)%s", name, n, ext))
+}
+
+func isTempFileName(name string) bool {
+	return strings.HasPrefix(name, tempFilePrefix) && strings.HasSuffix(name, tempFileSuffix)
+}
+
+// AtomicWriter writes files through a temp file and rename. It remembers which directories
+// received temp files so the ones a crash left behind can be removed at startup.
+type AtomicWriter struct {
+	journal string
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	tracked map[string]bool
+}
+
+func NewAtomicWriter(dataDir string, logger *slog.Logger) *AtomicWriter {
+	return &AtomicWriter{
+		journal: filepath.Join(dataDir, tempDirsJournal),
+		logger:  logger.With("component", "repository.atomic_writer"),
+		tracked: make(map[string]bool),
+	}
+}
+
+// WriteFile copies r into a temp file next to dst, syncs it, and renames it into place
+// according to mode. The SHA-256 of the content is computed on the way. Cancelling ctx
+// stops the copy; the temp file is removed on every failure.
+func (w *AtomicWriter) WriteFile(ctx context.Context, dst string, r io.Reader, mode entities.OverwriteMode) (entities.StoredFile, error) {
+	parentDir := filepath.Dir(dst)
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return entities.StoredFile{}, err
+	}
+
+	w.track(ctx, parentDir)
+	tmp, err := os.CreateTemp(parentDir, tempFilePrefix+"*"+tempFileSuffix)
+	if err != nil {
+		return entities.StoredFile{}, err
+	}
+	tmpPath := tmp.Name()
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), &contextReader{ctx: ctx, r: r})
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Chmod(replacedFileMode(dst, mode))
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	savedPath := ""
+	if err == nil {
+		savedPath, err = commitTempFile(tmpPath, dst, mode)
+	}
+	if err != nil {
+		if removeErr := os.Remove(tmpPath); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			w.logger.ErrorContext(ctx, "failed to remove temp file", "path", tmpPath, "error", removeErr)
+		}
+		return entities.StoredFile{}, err
+	}
+
+	// Make the rename itself durable
+	if err := syncDir(parentDir); err != nil {
+		w.logger.WarnContext(ctx, "failed to sync directory", "dir", parentDir, "error", err)
+	}
+
+	return entities.StoredFile{
+		Path: savedPath,
+		Size: written,
+		Hash: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// track records dir in the journal the first time a temp file is created there.
+func (w *AtomicWriter) track(ctx context.Context, dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.tracked[dir] {
+		return
+	}
+	if err := appendLine(w.journal, dir); err != nil {
+		// Not fatal: the upload itself is still atomic, only crash cleanup is affected
+		w.logger.WarnContext(ctx, "failed to record upload directory", "dir", dir, "error", err)
+		return
+	}
+	w.tracked[dir] = true
+}
+
+// RemoveStaleTempFiles removes every temp file in the journaled directories. It must run
+// before the server accepts uploads, since it can't tell a stale temp file from one being
+// written.
+func (w *AtomicWriter) RemoveStaleTempFiles(ctx context.Context) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dirs, err := readLines(w.journal)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read upload journal: %w", err)
+	}
+
+	removed := 0
+	var keep []string
+	for _, dir := range dirs {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				w.logger.WarnContext(ctx, "failed to scan upload directory", "dir", dir, "error", err)
+				keep = append(keep, dir)
+			}
+			continue
+		}
+
+		failed := false
+		for _, entry := range entries {
+			if entry.IsDir() || !isTempFileName(entry.Name()) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				w.logger.WarnContext(ctx, "failed to remove stale temp file", "path", path, "error", err)
+				failed = true
+				continue
+			}
+			w.logger.DebugContext(ctx, "removed stale temp file", "path", path)
+			removed++
+		}
+		if failed {
+			keep = append(keep, dir)
+		}
+	}
+
+	// Only directories that still need attention stay in the journal
+	if err := writeLines(w.journal, keep); err != nil {
+		return removed, fmt.Errorf("failed to rewrite upload journal: %w", err)
+	}
+	w.tracked = make(map[string]bool, len(keep))
+	for _, dir := range keep {
+		w.tracked[dir] = true
+	}
+
+	return removed, nil
+}
+
+func appendLine(path, line string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func writeLines(path string, lines []string) error {
+	if len(lines) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// contextReader fails reads once ctx is done, which lets io.Copy stop in the
+// middle of a large file.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// NewContextReader returns a reader of r that fails once ctx is done.
+func NewContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"gorm.io/gorm"
+)
+
+const auditExportBatchSize = 500
+
+type AuditRepository interface {
+	CreateBatch(ctx context.Context, logs []entities.AuditLog) error
+	List(ctx context.Context, filter *entities.AuditQueryRequest) ([]entities.AuditLog, int64, error)
+	Each(ctx context.Context, filter *entities.AuditQueryRequest, fn func(logs []entities.AuditLog) error) error
+}
+
+type AuditRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &AuditRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *AuditRepositoryImpl) CreateBatch(ctx context.Context, logs []entities.AuditLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(logs, len(logs)).Error
+}
+
+func (r *AuditRepositoryImpl) List(ctx context.Context, filter *entities.AuditQueryRequest) ([]entities.AuditLog, int64, error) {
+	var logs []entities.AuditLog
+	var total int64
+
+	query := applyAuditFilter(r.db.WithContext(ctx).Model(&entities.AuditLog{}), filter)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (filter.Page - 1) * filter.PageSize
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(filter.PageSize).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+// Each walks every entry matching the filter in batches, oldest first, so
+// exports don't have to hold the whole result set in memory.
+func (r *AuditRepositoryImpl) Each(ctx context.Context, filter *entities.AuditQueryRequest, fn func(logs []entities.AuditLog) error) error {
+	query := applyAuditFilter(r.db.WithContext(ctx).Model(&entities.AuditLog{}), filter)
+
+	rows, err := query.Order("created_at ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	batch := make([]entities.AuditLog, 0, auditExportBatchSize)
+	for rows.Next() {
+		var log entities.AuditLog
+		if err := r.db.ScanRows(rows, &log); err != nil {
+			return err
+		}
+		batch = append(batch, log)
+
+		if len(batch) == auditExportBatchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+func applyAuditFilter(query *gorm.DB, filter *entities.AuditQueryRequest) *gorm.DB {
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Path != "" {
+		query = query.Where("path LIKE ? ESCAPE '\\'", escapeLike(filter.Path)+"%")
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	return query
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
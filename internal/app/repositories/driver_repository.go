@@ -2,46 +2,87 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"mime/multipart"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
-	"golang.org/x/sys/windows"
+)
+
+var (
+	ErrPathNotAllowed = errors.New("access to path is not allowed")
+	ErrFileExists     = errors.New("file already exists")
+	ErrNotDirectory   = errors.New("path is not a directory")
+	ErrDirNotEmpty    = errors.New("directory is not empty")
 )
 
 type DriverRepository interface {
-	GetRoots() ([]string, error)
+	GetRoots(ctx context.Context) ([]string, error)
 	ListPath(ctx context.Context, path string) ([]entities.FileInfo, error)
-	Downloadfile(path string) (string, error)
-	CreateFolder(path string) error
-	OpenFile(path string) (*os.File, os.FileInfo, string /*absPath*/, error)
-	EnsureDirExists(path string) error
-	SaveUploadedFile(fh *multipart.FileHeader, dst string, overwrite bool) (int64, error)
+	CreateFolder(ctx context.Context, path string) error
+	OpenFile(ctx context.Context, path string) (io.ReadSeekCloser, os.FileInfo, string /*absPath*/, error)
+	EnsureDirExists(ctx context.Context, path string) error
+	SaveFile(ctx context.Context, dst string, src io.Reader, mode entities.OverwriteMode) (entities.StoredFile, error)
+	RemoveStaleTempFiles(ctx context.Context) (int, error)
+	Stat(ctx context.Context, path string) (os.FileInfo, error)
+	Remove(ctx context.Context, path string) error
+	RemoveEmptyDir(ctx context.Context, path string) error
+	Rename(ctx context.Context, src, dst string) error
+	CheckReadable(ctx context.Context, path string) error
 }
 
+// DriverRepositoryImpl applies the vault's path rules and hands the actual file operations
+// to the storage backend holding each path.
 type DriverRepositoryImpl struct {
+	storage *Storage
+	writer  *AtomicWriter
+	logger  *slog.Logger
 }
 
-func NewDriverRepository() DriverRepository {
-	return &DriverRepositoryImpl{}
+func NewDriverRepository(storage *Storage, writer *AtomicWriter, logger *slog.Logger) DriverRepository {
+	return &DriverRepositoryImpl{
+		storage: storage,
+		writer:  writer,
+		logger:  logger.With("component", "repository.driver"),
+	}
 }
 
-func (r *DriverRepositoryImpl) GetRoots() ([]string, error) {
+// resolve checks path against the vault's rules and returns its absolute form along with
+// the backend holding it.
+func (r *DriverRepositoryImpl) resolve(path string) (string, StorageBackend, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if !isSafePath(absPath) {
+		return "", nil, fmt.Errorf("%w: %s", ErrPathNotAllowed, path)
+	}
+	return absPath, r.storage.Backend(absPath), nil
+}
+
+// isRoot reports whether absPath is a file system root or the mount point of an object
+// store, neither of which may be removed or moved.
+func (r *DriverRepositoryImpl) isRoot(absPath string) bool {
+	return filepath.Dir(absPath) == absPath || r.storage.IsMountPoint(absPath)
+}
+
+func (r *DriverRepositoryImpl) GetRoots(ctx context.Context) ([]string, error) {
 	var roots []string
-	log.Printf("Getting root directories for OS: %s", runtime.GOOS)
+	r.logger.DebugContext(ctx, "getting root directories", "os", runtime.GOOS)
 
 	if runtime.GOOS == "windows" {
 		Drivers, err := getWindowsDrivers()
 		if err != nil {
-			log.Printf("Error getting Windows drivers: %v", err)
+			r.logger.ErrorContext(ctx, "failed to get windows drivers", "error", err)
 			return nil, err
 		}
+		r.logger.DebugContext(ctx, "windows drivers found", "drivers", Drivers)
 
 		for _, d := range Drivers {
 			absPath, err := filepath.Abs(filepath.Clean(d))
@@ -73,6 +114,12 @@ func (r *DriverRepositoryImpl) GetRoots() ([]string, error) {
 		}
 	}
 
+	for _, mountPoint := range r.storage.MountPoints() {
+		if isSafePath(mountPoint) {
+			roots = append(roots, mountPoint)
+		}
+	}
+
 	uniqueRoots := make(map[string]bool)
 	var result []string
 	for _, root := range roots {
@@ -82,53 +129,12 @@ func (r *DriverRepositoryImpl) GetRoots() ([]string, error) {
 		}
 	}
 
-	log.Printf("Found %d unique root directories", len(result))
+	r.logger.DebugContext(ctx, "found root directories", "count", len(result))
 	return result, nil
 }
 
-func getWindowsDrivers() ([]string, error) {
-	var drivers []string
-
-	// Each drive string is like "C:\\" and strings are concatenated with \0 separators, ending with \0\0.
-	// The API expects the buffer size in UTF-16 code units (not bytes).
-	buf := make([]uint16, 256)
-	n, err := windows.GetLogicalDriveStrings(uint32(len(buf)), &buf[0])
-	if err != nil {
-		return nil, err
-	}
-
-	if n == 0 {
-		// Fallback to C:/ if API returns nothing
-		return []string{"C:/"}, nil
-	}
-
-	// Ensure we only parse up to n code units returned by the API
-	u := buf[:n]
-
-	// Parse sequences separated by 0 (NUL). There is a trailing double NUL; ignore empties.
-	start := 0
-	for i, v := range u {
-		if v == 0 {
-			if i > start {
-				s := windows.UTF16ToString(u[start:i])
-				if s != "" {
-					s = strings.ReplaceAll(s, "\\", "/")
-					drivers = append(drivers, s)
-				}
-			}
-			start = i + 1
-		}
-	}
-
-	log.Printf("Drivers found (parsed): %v", drivers)
-	if len(drivers) == 0 {
-		return []string{"C:/"}, nil
-	}
-	return drivers, nil
-}
-
 func (r *DriverRepositoryImpl) ListPath(ctx context.Context, path string) ([]entities.FileInfo, error) {
-	log.Printf("Repository: Listing contents of path: %s", path)
+	r.logger.DebugContext(ctx, "listing path", "path", path)
 
 	// Check if context is cancelled before proceeding
 	select {
@@ -137,70 +143,60 @@ func (r *DriverRepositoryImpl) ListPath(ctx context.Context, path string) ([]ent
 	default:
 	}
 
-	if !isSafePath(path) {
-		return nil, fmt.Errorf("access to path %s is not allowed", path)
+	absPath, backend, err := r.resolve(path)
+	if err != nil {
+		return nil, err
 	}
 
-	info, err := os.Stat(path)
+	info, err := backend.Stat(ctx, absPath)
 	if err != nil {
 		return nil, fmt.Errorf("path does not exist or is not accessible: %w", err)
 	}
 
 	if !info.IsDir() {
-		return nil, fmt.Errorf("path %s is not a directory", path)
+		return nil, fmt.Errorf("%w: %s", ErrNotDirectory, path)
 	}
 
-	entries, err := os.ReadDir(path)
+	entries, err := backend.ReadDir(ctx, absPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
 
 	var fileinfos []entities.FileInfo
 
-	for _, entry := range entries {
+	for _, info := range entries {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
 		}
-		if shouldSkipFile(entry) {
+		if shouldSkipFile(info.Name()) {
 			continue
 		}
 
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
-
-		info, err := entry.Info()
-		if err != nil {
-			return nil, fmt.Errorf("repository: failed to get file info: %w", err)
-		}
 		fileType := "file"
 		if info.IsDir() {
 			fileType = "folder"
 		}
 
-		fullPath := filepath.Join(path, entry.Name())
+		fullPath := filepath.Join(path, info.Name())
 		fileinfo := entities.FileInfo{
-			Name:     entry.Name(),
+			Name:     info.Name(),
 			Path:     fullPath,
 			Type:     fileType,
 			Size:     info.Size(),
 			Modified: info.ModTime(),
+			FileID:   FileID(info),
 		}
 		fileinfos = append(fileinfos, fileinfo)
 	}
 
-	log.Printf("Repository: Successfully processed %d files in directory %s", len(fileinfos), path)
+	r.logger.DebugContext(ctx, "listed path", "path", path, "count", len(fileinfos))
 	return fileinfos, nil
 }
 
 // isSafePath ensures the path is safe (e.g., not accessing sensitive system dirs)
 func isSafePath(path string) bool {
-	log.Printf("Repository: Checking if path is safe: %s", path)
-
 	// Block sensitive paths (customize as needed)
 	sensitivePaths := []string{
 		"/etc",
@@ -216,18 +212,20 @@ func isSafePath(path string) bool {
 			"C:/Program Files (x86)",
 		}
 	}
+	// ".." is resolved first, so "/home/../etc" is /etc
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
 	for _, sp := range sensitivePaths {
-		if strings.HasPrefix(strings.ToLower(path), strings.ToLower(sp)) {
-			log.Printf("Repository: Path %s is blocked by sensitive path %s", path, sp)
+		if strings.HasPrefix(strings.ToLower(filepath.ToSlash(absPath)), strings.ToLower(sp)) {
 			return false
 		}
 	}
-	log.Printf("Repository: Path %s is considered safe", path)
 	return true
 }
 
-func shouldSkipFile(entry os.DirEntry) bool {
-	filename := entry.Name()
+func shouldSkipFile(filename string) bool {
 
 	if strings.HasPrefix(filename, ".") {
 		return true
@@ -257,50 +255,21 @@ func shouldSkipFile(entry os.DirEntry) bool {
 	return false
 }
 
-func (r *DriverRepositoryImpl) Downloadfile(path string) (string, error) {
-	if !isSafePath(path) {
-		return "", fmt.Errorf("access to path %s is not allowed", path)
-	}
-	info, err := os.Stat(path)
-
-	if err != nil {
-		return "", err
-	}
-
-	if info.IsDir() {
-		return "", fmt.Errorf("folder or directory is not downloadable")
-	}
-
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return "", err
-	}
-
-	return absPath, nil
-}
-
-func (r *DriverRepositoryImpl) CreateFolder(path string) error {
-	if !isSafePath(path) {
-		return fmt.Errorf("access to path %s is not allowed", path)
-	}
-	err := os.MkdirAll(path, 0755)
+func (r *DriverRepositoryImpl) CreateFolder(ctx context.Context, path string) error {
+	absPath, backend, err := r.resolve(path)
 	if err != nil {
 		return err
 	}
-	return nil
+	return backend.MkdirAll(ctx, absPath)
 }
 
-func (r *DriverRepositoryImpl) OpenFile(path string) (*os.File, os.FileInfo, string /*absPath*/, error) {
-
-	if !isSafePath(path) {
-		return nil, nil, "", fmt.Errorf("access to path %s is not allowed", path)
-	}
-	absPath, err := filepath.Abs(path)
+func (r *DriverRepositoryImpl) OpenFile(ctx context.Context, path string) (io.ReadSeekCloser, os.FileInfo, string /*absPath*/, error) {
+	absPath, backend, err := r.resolve(path)
 	if err != nil {
 		return nil, nil, "", err
 	}
 
-	fileInfo, err := os.Stat(absPath)
+	fileInfo, err := backend.Stat(ctx, absPath)
 	if err != nil {
 		return nil, nil, "", err
 	}
@@ -309,105 +278,148 @@ func (r *DriverRepositoryImpl) OpenFile(path string) (*os.File, os.FileInfo, str
 		return nil, nil, "", fmt.Errorf("path %s is a directory", path)
 	}
 
-	file, err := os.Open(absPath)
+	file, fileInfo, err := backend.Open(ctx, absPath)
 	if err != nil {
 		return nil, nil, "", err
 	}
 	return file, fileInfo, absPath, nil
 }
-func (r *DriverRepositoryImpl) EnsureDirExists(path string) error {
-	log.Printf("Repository: EnsureDirExists called for path: %s", path)
-
-	if !isSafePath(path) {
-		log.Printf("Repository: Path %s is not considered safe", path)
-		return fmt.Errorf("access to path %s is not allowed", path)
+func (r *DriverRepositoryImpl) EnsureDirExists(ctx context.Context, path string) error {
+	absPath, backend, err := r.resolve(path)
+	if err != nil {
+		r.logger.WarnContext(ctx, "path is not allowed", "path", path)
+		return err
 	}
 
-	info, err := os.Stat(path)
+	info, err := backend.Stat(ctx, absPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			log.Printf("Repository: Directory does not exist, creating: %s", path)
-			err := os.MkdirAll(path, 0755)
+		if errors.Is(err, fs.ErrNotExist) {
+			r.logger.DebugContext(ctx, "creating directory", "path", path)
+			err := backend.MkdirAll(ctx, absPath)
 			if err != nil {
-				log.Printf("Repository: Error creating directory %s: %v", path, err)
 				return err
 			}
-			log.Printf("Repository: Successfully created directory: %s", path)
 			return nil
 		}
-		log.Printf("Repository: Error checking directory %s: %v", path, err)
 		return err
 	}
 
 	if !info.IsDir() {
-		log.Printf("Repository: Path %s exists but is not a directory", path)
-		return fmt.Errorf("path %s is not a directory", path)
+		return fmt.Errorf("%w: %s", ErrNotDirectory, path)
 	}
 
-	log.Printf("Repository: Directory %s already exists", path)
 	return nil
 
 }
-func (r *DriverRepositoryImpl) SaveUploadedFile(fh *multipart.FileHeader, dst string, overwrite bool) (int64, error) {
-	log.Printf("Repository: Attempting to save file %s to path: %s", fh.Filename, dst)
 
-	if !isSafePath(dst) {
-		log.Printf("Repository: Path %s is not considered safe", dst)
-		return 0, fmt.Errorf("access to path %s is not allowed", dst)
-	}
+// SaveFile writes src to dst so that readers and crashes never see a half-written file:
+// through a synced temp file and rename on local disk, as a single (multipart) upload on
+// object stores. With OverwriteKeepBoth the file may be saved under a numbered name; the
+// returned Path is where it actually ended up.
+func (r *DriverRepositoryImpl) SaveFile(ctx context.Context, dst string, src io.Reader, mode entities.OverwriteMode) (entities.StoredFile, error) {
+	r.logger.DebugContext(ctx, "saving file", "dst", dst, "overwrite", mode)
 
-	absDst, err := filepath.Abs(dst)
+	absDst, backend, err := r.resolve(dst)
 	if err != nil {
-		log.Printf("Repository: Error getting absolute path for %s: %v", dst, err)
-		return 0, err
+		r.logger.WarnContext(ctx, "path is not allowed", "path", dst)
+		return entities.StoredFile{}, err
 	}
 
-	log.Printf("Repository: Absolute destination path: %s", absDst)
-
-	if !overwrite {
-		if _, err := os.Stat(absDst); err == nil {
-			log.Printf("Repository: File already exists and overwrite is disabled: %s", absDst)
-			return 0, fmt.Errorf("file already exists: %s", fh.Filename)
+	// Fail early instead of copying the whole body first; the backend checks again
+	if mode == entities.OverwriteNever {
+		if _, err := backend.Stat(ctx, absDst); err == nil {
+			return entities.StoredFile{}, fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(absDst))
 		}
 	}
 
-	src, err := fh.Open()
+	stored, err := backend.WriteFile(ctx, absDst, src, mode)
 	if err != nil {
-		return 0, err
+		return entities.StoredFile{}, err
 	}
 
-	defer src.Close()
+	r.logger.DebugContext(ctx, "saved file", "path", stored.Path, "bytes", stored.Size)
+	return stored, nil
+}
+
+// RemoveStaleTempFiles deletes temp files left behind by uploads interrupted by a crash.
+func (r *DriverRepositoryImpl) RemoveStaleTempFiles(ctx context.Context) (int, error) {
+	return r.writer.RemoveStaleTempFiles(ctx)
+}
 
-	parentDir := filepath.Dir(absDst)
-	log.Printf("Repository: Creating parent directory: %s", parentDir)
-	if err := os.MkdirAll(parentDir, 0755); err != nil {
-		log.Printf("Repository: Error creating parent directory %s: %v", parentDir, err)
-		return 0, err
+func (r *DriverRepositoryImpl) Stat(ctx context.Context, path string) (os.FileInfo, error) {
+	absPath, backend, err := r.resolve(path)
+	if err != nil {
+		return nil, err
 	}
+	return backend.Stat(ctx, absPath)
+}
 
-	flags := os.O_CREATE | os.O_WRONLY
+// Remove deletes a file or a whole directory tree. Roots (like "/", "C:/" or the mount
+// point of an object store) are never removed.
+func (r *DriverRepositoryImpl) Remove(ctx context.Context, path string) error {
+	absPath, backend, err := r.resolve(path)
+	if err != nil {
+		return err
+	}
+	if r.isRoot(absPath) {
+		return fmt.Errorf("%w: %s", ErrPathNotAllowed, path)
+	}
 
-	if overwrite {
-		flags |= os.O_TRUNC
-	} else {
-		flags |= os.O_EXCL
+	r.logger.DebugContext(ctx, "removing path", "path", absPath)
+	return backend.RemoveAll(ctx, absPath)
+}
+
+// RemoveEmptyDir deletes the folder at path only if it has no entries at all, including
+// hidden ones that listings leave out.
+func (r *DriverRepositoryImpl) RemoveEmptyDir(ctx context.Context, path string) error {
+	absPath, backend, err := r.resolve(path)
+	if err != nil {
+		return err
+	}
+	if r.isRoot(absPath) {
+		return fmt.Errorf("%w: %s", ErrPathNotAllowed, path)
 	}
 
-	log.Printf("Repository: Opening file %s with flags %d", absDst, flags)
-	out, err := os.OpenFile(absDst, flags, 0644)
+	entries, err := backend.ReadDir(ctx, absPath)
 	if err != nil {
-		log.Printf("Repository: Error opening file %s: %v", absDst, err)
-		return 0, err
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%w: %s", ErrDirNotEmpty, path)
 	}
 
-	defer out.Close()
-	log.Printf("Repository: Starting to copy file content")
-	written, err := io.Copy(out, src)
+	r.logger.DebugContext(ctx, "removing empty directory", "path", absPath)
+	return backend.RemoveAll(ctx, absPath)
+}
+
+// Rename moves src to dst. It never replaces an existing dst; callers that want to
+// overwrite must remove it first. Moving between storage backends (local disk and an
+// object store) is not supported.
+func (r *DriverRepositoryImpl) Rename(ctx context.Context, src, dst string) error {
+	absSrc, srcBackend, err := r.resolve(src)
+	if err != nil {
+		return err
+	}
+	absDst, dstBackend, err := r.resolve(dst)
 	if err != nil {
-		log.Printf("Repository: Error copying file content: %v", err)
-		return 0, err
+		return err
+	}
+	if r.isRoot(absSrc) {
+		return fmt.Errorf("%w: %s", ErrPathNotAllowed, src)
 	}
+	if srcBackend != dstBackend {
+		return fmt.Errorf("%w: %s -> %s", ErrCrossBackend, src, dst)
+	}
+
+	r.logger.DebugContext(ctx, "renaming path", "src", absSrc, "dst", absDst)
+	return srcBackend.Rename(ctx, absSrc, absDst)
+}
 
-	log.Printf("Repository: Successfully saved file %s, written %d bytes", absDst, written)
-	return written, nil
+// CheckReadable fails if the folder at path can't be listed.
+func (r *DriverRepositoryImpl) CheckReadable(ctx context.Context, path string) error {
+	absPath, backend, err := r.resolve(path)
+	if err != nil {
+		return err
+	}
+	return backend.CheckReadable(ctx, absPath)
 }
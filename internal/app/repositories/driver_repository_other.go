@@ -0,0 +1,22 @@
+//go:build !windows
+
+package repositories
+
+import (
+	"errors"
+	"os"
+)
+
+func getWindowsDrivers() ([]string, error) {
+	return nil, errors.New("drive letters are only available on windows")
+}
+
+// syncDir flushes the directory entry itself, so a rename into dir survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
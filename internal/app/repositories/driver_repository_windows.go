@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+func getWindowsDrivers() ([]string, error) {
+	var drivers []string
+
+	// Each drive string is like "C:\\" and strings are concatenated with \0 separators, ending with \0\0.
+	// The API expects the buffer size in UTF-16 code units (not bytes).
+	buf := make([]uint16, 256)
+	n, err := windows.GetLogicalDriveStrings(uint32(len(buf)), &buf[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		// Fallback to C:/ if API returns nothing
+		return []string{"C:/"}, nil
+	}
+
+	// Ensure we only parse up to n code units returned by the API
+	u := buf[:n]
+
+	// Parse sequences separated by 0 (NUL). There is a trailing double NUL; ignore empties.
+	start := 0
+	for i, v := range u {
+		if v == 0 {
+			if i > start {
+				s := windows.UTF16ToString(u[start:i])
+				if s != "" {
+					s = strings.ReplaceAll(s, "\\", "/")
+					drivers = append(drivers, s)
+				}
+			}
+			start = i + 1
+		}
+	}
+
+	if len(drivers) == 0 {
+		return []string{"C:/"}, nil
+	}
+	return drivers, nil
+}
+
+// syncDir is a no-op on Windows: directories can't be opened for FlushFileBuffers, and
+// NTFS journals the rename itself.
+func syncDir(dir string) error {
+	return nil
+}
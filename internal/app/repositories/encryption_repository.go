@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrKeyringNotFound         = errors.New("keyring not found")
+	ErrEncryptedFolderNotFound = errors.New("encrypted folder not found")
+)
+
+type EncryptionRepository interface {
+	GetKeyring(ctx context.Context, userID uuid.UUID) (*entities.Keyring, error)
+	CreateKeyring(ctx context.Context, keyring *entities.Keyring) error
+	ListFolders(ctx context.Context) ([]entities.EncryptedFolder, error)
+	CreateFolder(ctx context.Context, folder *entities.EncryptedFolder) error
+	DeleteFolder(ctx context.Context, userID, id uuid.UUID) error
+}
+
+type EncryptionRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewEncryptionRepository(db *gorm.DB) EncryptionRepository {
+	return &EncryptionRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *EncryptionRepositoryImpl) GetKeyring(ctx context.Context, userID uuid.UUID) (*entities.Keyring, error) {
+	var keyring entities.Keyring
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&keyring).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrKeyringNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &keyring, nil
+}
+
+func (r *EncryptionRepositoryImpl) CreateKeyring(ctx context.Context, keyring *entities.Keyring) error {
+	return r.db.WithContext(ctx).Create(keyring).Error
+}
+
+// ListFolders returns the encrypted folders of all users; there are few enough of them to
+// keep in memory.
+func (r *EncryptionRepositoryImpl) ListFolders(ctx context.Context) ([]entities.EncryptedFolder, error) {
+	var folders []entities.EncryptedFolder
+	err := r.db.WithContext(ctx).Order("path").Find(&folders).Error
+	return folders, err
+}
+
+func (r *EncryptionRepositoryImpl) CreateFolder(ctx context.Context, folder *entities.EncryptedFolder) error {
+	return r.db.WithContext(ctx).Create(folder).Error
+}
+
+// DeleteFolder removes one of the user's encrypted folders; folders of other users are
+// reported as not found.
+func (r *EncryptionRepositoryImpl) DeleteFolder(ctx context.Context, userID, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Delete(&entities.EncryptedFolder{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEncryptedFolderNotFound
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// fetchStoreDir is the directory under the data dir holding URL fetches while they are
+// downloaded, one "<id>.part" file per fetch.
+const fetchStoreDir = "fetch"
+
+type FetchRepository interface {
+	// OpenPartial opens the partial download of a fetch for reading and writing, creating
+	// it if needed
+	OpenPartial(id uuid.UUID) (*os.File, error)
+	RemovePartial(id uuid.UUID) error
+	// RemoveAllPartials deletes every partial download. It must only run while no fetch
+	// is in progress, i.e. at startup.
+	RemoveAllPartials() (int, error)
+}
+
+type FetchRepositoryImpl struct {
+	storeDir string
+}
+
+func NewFetchRepository(dataDir string) FetchRepository {
+	return &FetchRepositoryImpl{storeDir: filepath.Join(dataDir, fetchStoreDir)}
+}
+
+func (r *FetchRepositoryImpl) OpenPartial(id uuid.UUID) (*os.File, error) {
+	if err := os.MkdirAll(r.storeDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create fetch directory: %w", err)
+	}
+	return os.OpenFile(r.partialPath(id), os.O_RDWR|os.O_CREATE, 0600)
+}
+
+func (r *FetchRepositoryImpl) RemovePartial(id uuid.UUID) error {
+	if err := os.Remove(r.partialPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *FetchRepositoryImpl) RemoveAllPartials() (int, error) {
+	matches, err := filepath.Glob(filepath.Join(r.storeDir, "*.part"))
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, path := range matches {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (r *FetchRepositoryImpl) partialPath(id uuid.UUID) string {
+	return filepath.Join(r.storeDir, id.String()+".part")
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package repositories
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// FileID returns the device and inode of a file on local storage, which stay the same when
+// it is renamed within its file system, or "" if info doesn't carry them.
+func FileID(info os.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(st.Dev), 10) + ":" + strconv.FormatUint(uint64(st.Ino), 10)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package repositories
+
+import "os"
+
+// FileID returns "": file identities are only read on Linux, elsewhere files are known
+// by their path.
+func FileID(info os.FileInfo) string {
+	return ""
+}
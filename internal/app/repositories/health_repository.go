@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+}
+
+type HealthRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewHealthRepository(db *gorm.DB) HealthRepository {
+	return &HealthRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *HealthRepositoryImpl) Ping(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrMetadataNotFound = errors.New("file has no metadata")
+
+// FileMetadataFilter selects a user's file metadata. Zero fields don't filter; a file
+// must have all of Tags.
+type FileMetadataFilter struct {
+	UserID   uuid.UUID
+	Tags     []string
+	Favorite bool
+	Color    string
+	Path     string
+	Offset   int
+	Limit    int
+}
+
+type MetadataRepository interface {
+	Get(ctx context.Context, userID uuid.UUID, fileID string) (*entities.FileMetadata, error)
+	// AtPath returns every user's metadata of the file at path.
+	AtPath(ctx context.Context, path string) ([]entities.FileMetadata, error)
+	// ForFiles returns the user's metadata of the files with the given IDs, with their
+	// tags but not their notes.
+	ForFiles(ctx context.Context, userID uuid.UUID, fileIDs []string) ([]entities.FileMetadata, error)
+	// Save creates or updates metadata along with its tags and notes, which replace the
+	// ones saved before.
+	Save(ctx context.Context, metadata *entities.FileMetadata) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Relocate records that the file of metadata now has the given ID and path.
+	Relocate(ctx context.Context, id uuid.UUID, fileID, path string) error
+	// Remove deletes every user's metadata of path, or of every path below it if it is
+	// a folder.
+	Remove(ctx context.Context, path string) error
+	// Move changes the path of the metadata of src, or of every path below it if it is a
+	// folder, to the same place under dst, and returns the metadata that was moved.
+	Move(ctx context.Context, src, dst string) ([]entities.FileMetadata, error)
+	Search(ctx context.Context, filter FileMetadataFilter) ([]entities.FileMetadata, int64, error)
+	// Tags returns the user's tags with how many files have each, most used first.
+	Tags(ctx context.Context, userID uuid.UUID) ([]entities.TagCount, error)
+}
+
+type MetadataRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewMetadataRepository(db *gorm.DB) MetadataRepository {
+	return &MetadataRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *MetadataRepositoryImpl) Get(ctx context.Context, userID uuid.UUID, fileID string) (*entities.FileMetadata, error) {
+	var metadata entities.FileMetadata
+	err := r.db.WithContext(ctx).
+		Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("tag") }).
+		Preload("Notes", func(db *gorm.DB) *gorm.DB { return db.Order("key") }).
+		First(&metadata, "user_id = ? AND file_id = ?", userID, fileID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMetadataNotFound
+		}
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+func (r *MetadataRepositoryImpl) AtPath(ctx context.Context, path string) ([]entities.FileMetadata, error) {
+	var metadata []entities.FileMetadata
+	err := r.db.WithContext(ctx).Where("path = ?", path).Find(&metadata).Error
+	return metadata, err
+}
+
+func (r *MetadataRepositoryImpl) ForFiles(ctx context.Context, userID uuid.UUID, fileIDs []string) ([]entities.FileMetadata, error) {
+	var metadata []entities.FileMetadata
+	if len(fileIDs) == 0 {
+		return metadata, nil
+	}
+	err := r.db.WithContext(ctx).
+		Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("tag") }).
+		Where("user_id = ? AND file_id IN ?", userID, fileIDs).
+		Find(&metadata).Error
+	return metadata, err
+}
+
+func (r *MetadataRepositoryImpl) Save(ctx context.Context, metadata *entities.FileMetadata) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(metadata).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("metadata_id = ?", metadata.ID).Delete(&entities.FileTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("metadata_id = ?", metadata.ID).Delete(&entities.FileNote{}).Error; err != nil {
+			return err
+		}
+		for i := range metadata.Tags {
+			metadata.Tags[i].MetadataID = metadata.ID
+		}
+		for i := range metadata.Notes {
+			metadata.Notes[i].MetadataID = metadata.ID
+		}
+		if len(metadata.Tags) > 0 {
+			if err := tx.Create(&metadata.Tags).Error; err != nil {
+				return err
+			}
+		}
+		if len(metadata.Notes) > 0 {
+			if err := tx.Create(&metadata.Notes).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *MetadataRepositoryImpl) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&entities.FileMetadata{}, "id = ?", id).Error
+}
+
+func (r *MetadataRepositoryImpl) Relocate(ctx context.Context, id uuid.UUID, fileID, path string) error {
+	return r.db.WithContext(ctx).Model(&entities.FileMetadata{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"file_id": fileID, "path": path}).Error
+}
+
+func (r *MetadataRepositoryImpl) Remove(ctx context.Context, path string) error {
+	return r.db.WithContext(ctx).
+		Where("path = ? OR path LIKE ? ESCAPE '\\'", path, escapeLike(childPrefix(path))+"%").
+		Delete(&entities.FileMetadata{}).Error
+}
+
+func (r *MetadataRepositoryImpl) Move(ctx context.Context, src, dst string) ([]entities.FileMetadata, error) {
+	var moved []entities.FileMetadata
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Files that were at the destination have been replaced
+		if err := tx.Where("path = ? OR path LIKE ? ESCAPE '\\'", dst, escapeLike(childPrefix(dst))+"%").
+			Delete(&entities.FileMetadata{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&moved).
+			Clauses(clause.Returning{}).
+			Where("path = ? OR path LIKE ? ESCAPE '\\'", src, escapeLike(childPrefix(src))+"%").
+			Update("path", gorm.Expr("? || substr(path, ?)", dst, utf8.RuneCountInString(src)+1)).Error
+	})
+	return moved, err
+}
+
+func (r *MetadataRepositoryImpl) Search(ctx context.Context, filter FileMetadataFilter) ([]entities.FileMetadata, int64, error) {
+	query := r.db.WithContext(ctx).Model(&entities.FileMetadata{}).Where("user_id = ?", filter.UserID)
+	for _, tag := range filter.Tags {
+		query = query.Where("id IN (?)", r.db.Model(&entities.FileTag{}).Select("metadata_id").Where("tag = ?", tag))
+	}
+	if filter.Favorite {
+		query = query.Where("favorite")
+	}
+	if filter.Color != "" {
+		query = query.Where("color = ?", filter.Color)
+	}
+	if filter.Path != "" {
+		query = query.Where("path LIKE ? ESCAPE '\\'", escapeLike(childPrefix(filter.Path))+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var metadata []entities.FileMetadata
+	err := query.
+		Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("tag") }).
+		Preload("Notes", func(db *gorm.DB) *gorm.DB { return db.Order("key") }).
+		Order("path").Offset(filter.Offset).Limit(filter.Limit).
+		Find(&metadata).Error
+	return metadata, total, err
+}
+
+func (r *MetadataRepositoryImpl) Tags(ctx context.Context, userID uuid.UUID) ([]entities.TagCount, error) {
+	var tags []entities.TagCount
+	err := r.db.WithContext(ctx).Model(&entities.FileTag{}).
+		Select("tag, count(*) AS files").
+		Where("metadata_id IN (?)", r.db.Model(&entities.FileMetadata{}).Select("id").Where("user_id = ?", userID)).
+		Group("tag").
+		Order("files DESC, tag").
+		Scan(&tags).Error
+	return tags, err
+}
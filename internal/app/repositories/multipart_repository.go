@@ -0,0 +1,209 @@
+package repositories
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrUploadNotFound = errors.New("multipart upload not found")
+
+// multipartStoreDir is the directory under the data dir holding the parts of S3 multipart
+// uploads, one subdirectory per upload. Parts are named "<number>-<md5>" so listing the
+// directory is enough to know their ETags.
+const multipartStoreDir = "multipart"
+
+type MultipartRepository interface {
+	Create(ctx context.Context, upload *entities.MultipartUpload) error
+	Get(ctx context.Context, id uuid.UUID) (*entities.MultipartUpload, error)
+	// Delete removes the upload and every part stored for it
+	Delete(ctx context.Context, upload *entities.MultipartUpload) error
+	ListStale(ctx context.Context, before time.Time) ([]entities.MultipartUpload, error)
+
+	SavePart(ctx context.Context, upload *entities.MultipartUpload, number int, r io.Reader) (entities.UploadPart, error)
+	ListParts(ctx context.Context, upload *entities.MultipartUpload) ([]entities.UploadPart, error)
+	// OpenParts returns the content of the given parts one after the other
+	OpenParts(ctx context.Context, upload *entities.MultipartUpload, parts []entities.UploadPart) (io.ReadCloser, error)
+}
+
+type MultipartRepositoryImpl struct {
+	db       *gorm.DB
+	storeDir string
+	writer   *AtomicWriter
+}
+
+func NewMultipartRepository(db *gorm.DB, dataDir string, writer *AtomicWriter) MultipartRepository {
+	return &MultipartRepositoryImpl{
+		db:       db,
+		storeDir: filepath.Join(dataDir, multipartStoreDir),
+		writer:   writer,
+	}
+}
+
+func (r *MultipartRepositoryImpl) Create(ctx context.Context, upload *entities.MultipartUpload) error {
+	if err := r.db.WithContext(ctx).Create(upload).Error; err != nil {
+		return err
+	}
+	return os.MkdirAll(r.uploadDir(upload), 0700)
+}
+
+func (r *MultipartRepositoryImpl) Get(ctx context.Context, id uuid.UUID) (*entities.MultipartUpload, error) {
+	var upload entities.MultipartUpload
+	if err := r.db.WithContext(ctx).First(&upload, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *MultipartRepositoryImpl) Delete(ctx context.Context, upload *entities.MultipartUpload) error {
+	if err := r.db.WithContext(ctx).Delete(&entities.MultipartUpload{}, "id = ?", upload.ID).Error; err != nil {
+		return err
+	}
+	return os.RemoveAll(r.uploadDir(upload))
+}
+
+func (r *MultipartRepositoryImpl) ListStale(ctx context.Context, before time.Time) ([]entities.MultipartUpload, error) {
+	var uploads []entities.MultipartUpload
+	err := r.db.WithContext(ctx).Where("created_at < ?", before).Find(&uploads).Error
+	return uploads, err
+}
+
+// SavePart stores part number of upload, replacing an earlier upload of the same part.
+func (r *MultipartRepositoryImpl) SavePart(ctx context.Context, upload *entities.MultipartUpload, number int, body io.Reader) (entities.UploadPart, error) {
+	dir := r.uploadDir(upload)
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return entities.UploadPart{}, ErrUploadNotFound
+		}
+		return entities.UploadPart{}, err
+	}
+
+	hash := md5.New()
+	stored, err := r.writer.WriteFile(ctx, filepath.Join(dir, fmt.Sprintf("%05d.tmp", number)), io.TeeReader(body, hash), entities.OverwriteReplace)
+	if err != nil {
+		return entities.UploadPart{}, err
+	}
+	part := entities.UploadPart{Number: number, ETag: hex.EncodeToString(hash.Sum(nil)), Size: stored.Size}
+
+	previous, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%05d-*", number)))
+	if err != nil {
+		return entities.UploadPart{}, err
+	}
+	for _, path := range previous {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return entities.UploadPart{}, err
+		}
+	}
+
+	if err := os.Rename(stored.Path, r.partPath(upload, part)); err != nil {
+		return entities.UploadPart{}, err
+	}
+	return part, nil
+}
+
+// ListParts returns the stored parts of upload ordered by part number.
+func (r *MultipartRepositoryImpl) ListParts(ctx context.Context, upload *entities.MultipartUpload) ([]entities.UploadPart, error) {
+	entries, err := os.ReadDir(r.uploadDir(upload))
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []entities.UploadPart
+	for _, entry := range entries {
+		numberField, etag, ok := strings.Cut(entry.Name(), "-")
+		if !ok {
+			// Parts still being written
+			continue
+		}
+		number, err := strconv.Atoi(numberField)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, entities.UploadPart{Number: number, ETag: etag, Size: info.Size()})
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+func (r *MultipartRepositoryImpl) OpenParts(ctx context.Context, upload *entities.MultipartUpload, parts []entities.UploadPart) (io.ReadCloser, error) {
+	paths := make([]string, 0, len(parts))
+	for _, part := range parts {
+		path := r.partPath(upload, part)
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return &partsReader{paths: paths}, nil
+}
+
+func (r *MultipartRepositoryImpl) uploadDir(upload *entities.MultipartUpload) string {
+	return filepath.Join(r.storeDir, upload.ID.String())
+}
+
+func (r *MultipartRepositoryImpl) partPath(upload *entities.MultipartUpload, part entities.UploadPart) string {
+	return filepath.Join(r.uploadDir(upload), fmt.Sprintf("%05d-%s", part.Number, part.ETag))
+}
+
+// partsReader reads files one after the other, opening each only when it is reached so an
+// upload of thousands of parts doesn't hold thousands of descriptors.
+type partsReader struct {
+	paths   []string
+	current *os.File
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.paths) == 0 {
+				return 0, io.EOF
+			}
+			file, err := os.Open(r.paths[0])
+			if err != nil {
+				return 0, err
+			}
+			r.current, r.paths = file, r.paths[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
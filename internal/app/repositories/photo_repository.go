@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrPhotoNotFound = errors.New("photo not found")
+
+// PhotoTimelineFilter selects the photos of the timeline. Zero fields don't filter.
+type PhotoTimelineFilter struct {
+	Path   string
+	From   time.Time
+	To     time.Time
+	Offset int
+	Limit  int
+}
+
+type PhotoRepository interface {
+	Get(ctx context.Context, path string) (*entities.PhotoMetadata, error)
+	// ListUnder returns the path, size and modification time of every photo in dir or
+	// below it.
+	ListUnder(ctx context.Context, dir string) ([]entities.PhotoMetadata, error)
+	Save(ctx context.Context, photo *entities.PhotoMetadata) error
+	// Delete removes the photo at path, or every photo below it if it is a folder.
+	Delete(ctx context.Context, paths ...string) (int64, error)
+	// Move changes the path of the photo at src, or of every photo below it if it is a
+	// folder, to the same place under dst.
+	Move(ctx context.Context, src, dst string) error
+	Timeline(ctx context.Context, filter PhotoTimelineFilter) ([]entities.PhotoMetadata, int64, error)
+}
+
+type PhotoRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewPhotoRepository(db *gorm.DB) PhotoRepository {
+	return &PhotoRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *PhotoRepositoryImpl) Get(ctx context.Context, path string) (*entities.PhotoMetadata, error) {
+	var photo entities.PhotoMetadata
+	if err := r.db.WithContext(ctx).First(&photo, "path = ?", path).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPhotoNotFound
+		}
+		return nil, err
+	}
+	return &photo, nil
+}
+
+func (r *PhotoRepositoryImpl) ListUnder(ctx context.Context, dir string) ([]entities.PhotoMetadata, error) {
+	var photos []entities.PhotoMetadata
+	err := r.db.WithContext(ctx).
+		Select("path", "size", "modified").
+		Where("path LIKE ? ESCAPE '\\'", escapeLike(childPrefix(dir))+"%").
+		Find(&photos).Error
+	return photos, err
+}
+
+func (r *PhotoRepositoryImpl) Save(ctx context.Context, photo *entities.PhotoMetadata) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(photo).Error
+}
+
+func (r *PhotoRepositoryImpl) Delete(ctx context.Context, paths ...string) (int64, error) {
+	var removed int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, path := range paths {
+			result := tx.Where("path = ? OR path LIKE ? ESCAPE '\\'", path, escapeLike(childPrefix(path))+"%").
+				Delete(&entities.PhotoMetadata{})
+			if result.Error != nil {
+				return result.Error
+			}
+			removed += result.RowsAffected
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func (r *PhotoRepositoryImpl) Move(ctx context.Context, src, dst string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Photos that were at the destination have been replaced
+		if err := tx.Where("path = ? OR path LIKE ? ESCAPE '\\'", dst, escapeLike(childPrefix(dst))+"%").
+			Delete(&entities.PhotoMetadata{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&entities.PhotoMetadata{}).
+			Where("path = ? OR path LIKE ? ESCAPE '\\'", src, escapeLike(childPrefix(src))+"%").
+			Update("path", gorm.Expr("? || substr(path, ?)", dst, utf8.RuneCountInString(src)+1)).Error
+	})
+}
+
+// Timeline returns the photos with a capture date, newest first, along with how many
+// there are in total.
+func (r *PhotoRepositoryImpl) Timeline(ctx context.Context, filter PhotoTimelineFilter) ([]entities.PhotoMetadata, int64, error) {
+	query := r.db.WithContext(ctx).Model(&entities.PhotoMetadata{}).Where("captured_at IS NOT NULL")
+	if filter.Path != "" {
+		query = query.Where("path LIKE ? ESCAPE '\\'", escapeLike(childPrefix(filter.Path))+"%")
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("captured_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("captured_at < ?", filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var photos []entities.PhotoMetadata
+	err := query.Order("captured_at DESC, path").Offset(filter.Offset).Limit(filter.Limit).Find(&photos).Error
+	return photos, total, err
+}
+
+// childPrefix returns the prefix shared by the paths inside dir.
+func childPrefix(dir string) string {
+	if dir == "" || dir[len(dir)-1] == filepath.Separator {
+		return dir
+	}
+	return dir + string(filepath.Separator)
+}
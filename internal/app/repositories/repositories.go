@@ -1,17 +1,60 @@
 package repositories
 
-import "gorm.io/gorm"
+import (
+	"log/slog"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"gorm.io/gorm"
+)
 
 type Repositories struct {
-	User   UserRepository
-	Auth   AuthRepository
-	Driver DriverRepository
+	User       UserRepository
+	Auth       AuthRepository
+	Driver     DriverRepository
+	Audit      AuditRepository
+	Health     HealthRepository
+	Version    VersionRepository
+	AccessKey  AccessKeyRepository
+	Multipart  MultipartRepository
+	Encryption EncryptionRepository
+	Watcher    FileWatcher
+	Fetch      FetchRepository
+	Photo      PhotoRepository
+	Metadata   MetadataRepository
+	Activity   ActivityRepository
+	Sync       SyncRepository
+	Transfer   TransferRepository
 }
 
-func NewRepositories(db *gorm.DB) *Repositories {
-	return &Repositories{
-		User:   NewUserRepository(db),
-		Auth:   NewAuthReporsitory(db),
-		Driver: NewDriverRepository(),
+func NewRepositories(db *gorm.DB, cfg *config.Config, logger *slog.Logger) (*Repositories, error) {
+	writer := NewAtomicWriter(cfg.Storage.DataDir, logger)
+
+	storage, err := NewStorage(cfg.Storage, writer, logger)
+	if err != nil {
+		return nil, err
 	}
+
+	watcher, err := NewFileWatcher(logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repositories{
+		User:       NewUserRepository(db),
+		Auth:       NewAuthReporsitory(db),
+		Driver:     NewDriverRepository(storage, writer, logger),
+		Audit:      NewAuditRepository(db),
+		Health:     NewHealthRepository(db),
+		Version:    NewVersionRepository(db, storage, cfg.Storage.DataDir, writer),
+		AccessKey:  NewAccessKeyRepository(db),
+		Multipart:  NewMultipartRepository(db, cfg.Storage.DataDir, writer),
+		Encryption: NewEncryptionRepository(db),
+		Watcher:    watcher,
+		Fetch:      NewFetchRepository(cfg.Storage.DataDir),
+		Photo:      NewPhotoRepository(db),
+		Metadata:   NewMetadataRepository(db),
+		Activity:   NewActivityRepository(db),
+		Sync:       NewSyncRepository(db),
+		Transfer:   NewTransferRepository(db),
+	}, nil
 }
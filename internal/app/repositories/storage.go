@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+)
+
+var ErrCrossBackend = errors.New("cannot move between storage backends")
+
+// StorageBackend is where file content actually lives. Paths are absolute vault paths; a
+// backend mounted below a vault path maps them onto its own namespace.
+//
+// Backends report missing paths with errors matching fs.ErrNotExist.
+type StorageBackend interface {
+	Stat(ctx context.Context, path string) (os.FileInfo, error)
+	// ReadDir returns the direct children of the folder at path
+	ReadDir(ctx context.Context, path string) ([]os.FileInfo, error)
+	// Open returns the content of a file, seekable so it can be served in ranges
+	Open(ctx context.Context, path string) (io.ReadSeekCloser, os.FileInfo, error)
+	// WriteFile stores r at path so the new content appears all at once or not at all
+	WriteFile(ctx context.Context, path string, r io.Reader, mode entities.OverwriteMode) (entities.StoredFile, error)
+	MkdirAll(ctx context.Context, path string) error
+	// RemoveAll removes a file or a folder with everything below it
+	RemoveAll(ctx context.Context, path string) error
+	// Rename moves a file or folder; dst must not exist
+	Rename(ctx context.Context, src, dst string) error
+	// CheckReadable fails if the folder at path can't be listed
+	CheckReadable(ctx context.Context, path string) error
+}
+
+type storageMount struct {
+	path    string
+	backend StorageBackend
+}
+
+// Storage routes every vault path to the backend holding it: the object-store mounts
+// configured in STORAGE_MOUNTS, and the local file system for everything else.
+type Storage struct {
+	local  StorageBackend
+	mounts []storageMount // longest path first, so nested mounts win
+}
+
+func NewStorage(cfg config.StorageConfig, writer *AtomicWriter, logger *slog.Logger) (*Storage, error) {
+	s := &Storage{local: NewLocalBackend(writer)}
+
+	for _, mountCfg := range cfg.Mounts {
+		backend, err := NewS3Backend(mountCfg, cfg.PartSize, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to mount %s: %w", mountCfg.Path, err)
+		}
+		s.mounts = append(s.mounts, storageMount{path: filepath.Clean(mountCfg.Path), backend: backend})
+	}
+	sort.Slice(s.mounts, func(i, j int) bool { return len(s.mounts[i].path) > len(s.mounts[j].path) })
+
+	return s, nil
+}
+
+// Backend returns the backend holding path, which must be absolute.
+func (s *Storage) Backend(path string) StorageBackend {
+	if mount := s.mountOf(path); mount != nil {
+		return mount.backend
+	}
+	return s.local
+}
+
+// MountPoints returns the vault paths object stores are mounted at.
+func (s *Storage) MountPoints() []string {
+	points := make([]string, 0, len(s.mounts))
+	for _, mount := range s.mounts {
+		points = append(points, mount.path)
+	}
+	return points
+}
+
+// IsMountPoint reports whether path is the root of a mounted object store.
+func (s *Storage) IsMountPoint(path string) bool {
+	mount := s.mountOf(path)
+	return mount != nil && mount.path == filepath.Clean(path)
+}
+
+func (s *Storage) mountOf(path string) *storageMount {
+	path = filepath.Clean(path)
+	for i := range s.mounts {
+		if isWithin(s.mounts[i].path, path) {
+			return &s.mounts[i]
+		}
+	}
+	return nil
+}
+
+// isWithin reports whether path is root or below it.
+func isWithin(root, path string) bool {
+	if path == root {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+)
+
+// LocalBackend stores files on the server's own file systems.
+type LocalBackend struct {
+	writer *AtomicWriter
+}
+
+func NewLocalBackend(writer *AtomicWriter) StorageBackend {
+	return &LocalBackend{writer: writer}
+}
+
+func (b *LocalBackend) Stat(ctx context.Context, path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (b *LocalBackend) ReadDir(ctx context.Context, path string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get file info: %w", err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (b *LocalBackend) Open(ctx context.Context, path string) (io.ReadSeekCloser, os.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// WriteFile writes through a temp file and rename, see AtomicWriter.
+func (b *LocalBackend) WriteFile(ctx context.Context, path string, r io.Reader, mode entities.OverwriteMode) (entities.StoredFile, error) {
+	return b.writer.WriteFile(ctx, path, r, mode)
+}
+
+func (b *LocalBackend) MkdirAll(ctx context.Context, path string) error {
+	return os.MkdirAll(path, 0755)
+}
+
+func (b *LocalBackend) RemoveAll(ctx context.Context, path string) error {
+	if _, err := os.Lstat(path); err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+func (b *LocalBackend) Rename(ctx context.Context, src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(dst))
+	}
+	return os.Rename(src, dst)
+}
+
+func (b *LocalBackend) CheckReadable(ctx context.Context, path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	if _, err := dir.Readdirnames(1); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,501 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/s3"
+)
+
+// maxUploadParts is the most parts S3 accepts in one multipart upload
+const maxUploadParts = 10000
+
+// S3Backend stores files as objects in an S3-compatible bucket mounted at a vault path.
+//
+// Object stores have no folders: a folder is every key sharing a "name/" prefix, and an
+// empty folder is kept as a zero-byte "name/" marker object, the convention the S3 console
+// and MinIO use as well. Uploads become visible only once complete, so writes are atomic
+// like on local disk; renames, however, copy and delete object by object and a folder
+// rename interrupted halfway leaves both folders partially filled.
+type S3Backend struct {
+	client    *s3.Client
+	mountPath string
+	prefix    string // key prefix inside the bucket, empty or ending with "/"
+	partSize  int64
+	logger    *slog.Logger
+}
+
+func NewS3Backend(cfg config.S3MountConfig, partSize int64, logger *slog.Logger) (StorageBackend, error) {
+	client, err := s3.NewClient(s3.Config{
+		Endpoint: cfg.Endpoint,
+		Region:   cfg.Region,
+		Bucket:   cfg.Bucket,
+		Credentials: s3.Credentials{
+			AccessKey: cfg.AccessKey,
+			SecretKey: cfg.SecretKey,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3Backend{
+		client:    client,
+		mountPath: filepath.Clean(cfg.Path),
+		prefix:    prefix,
+		partSize:  partSize,
+		logger:    logger.With("component", "repository.s3", "bucket", cfg.Bucket),
+	}, nil
+}
+
+// key maps a vault path below the mount point to its object key. The mount point itself
+// maps to "".
+func (b *S3Backend) key(path string) string {
+	rel, err := filepath.Rel(b.mountPath, filepath.Clean(path))
+	if err != nil || rel == "." {
+		return ""
+	}
+	return b.prefix + filepath.ToSlash(rel)
+}
+
+// dirPrefix is the prefix shared by the keys inside the folder with the given key.
+func (b *S3Backend) dirPrefix(key string) string {
+	if key == "" {
+		return b.prefix
+	}
+	return key + "/"
+}
+
+func (b *S3Backend) Stat(ctx context.Context, path string) (os.FileInfo, error) {
+	key := b.key(path)
+	if key == "" {
+		return &objectInfo{name: filepath.Base(b.mountPath), dir: true}, nil
+	}
+
+	obj, err := b.client.HeadObject(ctx, key)
+	if err == nil {
+		return &objectInfo{name: filepath.Base(path), size: obj.Size, modified: obj.LastModified}, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Not an object, but it is a folder if any key lives below it
+	page, err := b.client.ListObjects(ctx, key+"/", "", "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Contents) == 0 && len(page.CommonPrefixes) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+	}
+
+	info := &objectInfo{name: filepath.Base(path), dir: true}
+	if len(page.Contents) > 0 && page.Contents[0].Key == key+"/" {
+		info.modified = page.Contents[0].LastModified
+	}
+	return info, nil
+}
+
+func (b *S3Backend) ReadDir(ctx context.Context, path string) ([]os.FileInfo, error) {
+	prefix := b.dirPrefix(b.key(path))
+
+	var infos []os.FileInfo
+	token := ""
+	for {
+		page, err := b.client.ListObjects(ctx, prefix, "/", token, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range page.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(p.Prefix, prefix), "/")
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			infos = append(infos, &objectInfo{name: name, dir: true})
+		}
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(obj.Key, prefix)
+			// An empty name is the folder's own marker
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			infos = append(infos, &objectInfo{name: name, size: obj.Size, modified: obj.LastModified})
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return infos, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (b *S3Backend) Open(ctx context.Context, path string) (io.ReadSeekCloser, os.FileInfo, error) {
+	key := b.key(path)
+	if key == "" {
+		return nil, nil, fmt.Errorf("path %s is a directory", path)
+	}
+
+	obj, err := b.client.HeadObject(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := &objectInfo{name: filepath.Base(path), size: obj.Size, modified: obj.LastModified}
+	return &s3ObjectReader{ctx: ctx, client: b.client, key: key, etag: obj.ETag, size: obj.Size}, info, nil
+}
+
+// WriteFile uploads r in a single request if it is smaller than the part size, and as a
+// multipart upload otherwise, so memory use stays at one part per upload. With
+// OverwriteNever and OverwriteKeepBoth the name is checked before uploading; S3 has no
+// create-if-absent, so a concurrent writer can still win the race.
+func (b *S3Backend) WriteFile(ctx context.Context, path string, r io.Reader, mode entities.OverwriteMode) (entities.StoredFile, error) {
+	target := path
+	switch mode {
+	case entities.OverwriteNever:
+		exists, err := b.exists(ctx, b.key(path))
+		if err != nil {
+			return entities.StoredFile{}, err
+		}
+		if exists {
+			return entities.StoredFile{}, fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(path))
+		}
+
+	case entities.OverwriteKeepBoth:
+		found := false
+		for n := 0; n < MaxKeepBothAttempts && !found; n++ {
+			if n > 0 {
+				target = NumberedName(path, n)
+			}
+			exists, err := b.exists(ctx, b.key(target))
+			if err != nil {
+				return entities.StoredFile{}, err
+			}
+			found = !exists
+		}
+		if !found {
+			return entities.StoredFile{}, fmt.Errorf("%w: no free name for %s", ErrFileExists, filepath.Base(path))
+		}
+	}
+
+	key := b.key(target)
+	if key == "" {
+		return entities.StoredFile{}, fmt.Errorf("path %s is a directory", path)
+	}
+
+	hash := sha256.New()
+	src := io.TeeReader(&contextReader{ctx: ctx, r: r}, hash)
+
+	buf := make([]byte, b.partSize)
+	n, err := io.ReadFull(src, buf)
+
+	var size int64
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		if _, err := b.client.PutObject(ctx, key, buf[:n]); err != nil {
+			return entities.StoredFile{}, err
+		}
+		size = int64(n)
+	case err != nil:
+		return entities.StoredFile{}, err
+	default:
+		size, err = b.multipartUpload(ctx, key, src, buf)
+		if err != nil {
+			return entities.StoredFile{}, err
+		}
+	}
+
+	return entities.StoredFile{
+		Path: target,
+		Size: size,
+		Hash: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// multipartUpload uploads buf (already filled) and then the rest of src part by part. The
+// upload is aborted on any failure so the store doesn't keep the orphaned parts.
+func (b *S3Backend) multipartUpload(ctx context.Context, key string, src io.Reader, buf []byte) (int64, error) {
+	uploadID, err := b.client.CreateMultipartUpload(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	abort := func(cause error) (int64, error) {
+		// The request context may be what failed; aborting must still happen
+		if err := b.client.AbortMultipartUpload(context.WithoutCancel(ctx), key, uploadID); err != nil {
+			b.logger.WarnContext(ctx, "failed to abort multipart upload", "key", key, "error", err)
+		}
+		return 0, cause
+	}
+
+	var parts []s3.CompletedPart
+	var size int64
+	n := len(buf)
+	for {
+		if len(parts) == maxUploadParts {
+			return abort(fmt.Errorf("file is larger than %d parts of %d bytes", maxUploadParts, len(buf)))
+		}
+
+		part, err := b.client.UploadPart(ctx, key, uploadID, len(parts)+1, buf[:n])
+		if err != nil {
+			return abort(err)
+		}
+		parts = append(parts, part)
+		size += int64(n)
+
+		n, err = io.ReadFull(src, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return abort(err)
+		}
+	}
+
+	if _, err := b.client.CompleteMultipartUpload(ctx, key, uploadID, parts); err != nil {
+		return abort(err)
+	}
+	b.logger.DebugContext(ctx, "completed multipart upload", "key", key, "parts", len(parts), "bytes", size)
+	return size, nil
+}
+
+func (b *S3Backend) MkdirAll(ctx context.Context, path string) error {
+	key := b.key(path)
+	if key == "" {
+		return nil
+	}
+
+	exists, err := b.exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrNotDirectory, path)
+	}
+
+	// Parents need no markers: they exist as soon as a key below them does
+	_, err = b.client.PutObject(ctx, key+"/", nil)
+	return err
+}
+
+func (b *S3Backend) RemoveAll(ctx context.Context, path string) error {
+	key := b.key(path)
+	if key == "" {
+		return fmt.Errorf("%w: %s", ErrPathNotAllowed, path)
+	}
+
+	found, err := b.exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if found {
+		if err := b.client.DeleteObject(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	objects, err := b.listAll(ctx, key+"/")
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := b.client.DeleteObject(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+
+	if !found && len(objects) == 0 {
+		return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrNotExist}
+	}
+	return nil
+}
+
+// Rename copies every object to its new key and then deletes the originals. Objects are
+// copied on the server side, which S3 limits to 5 GiB per object.
+func (b *S3Backend) Rename(ctx context.Context, src, dst string) error {
+	srcKey, dstKey := b.key(src), b.key(dst)
+	if srcKey == "" || dstKey == "" || isWithin(filepath.Clean(src), filepath.Clean(dst)) {
+		return fmt.Errorf("%w: %s", ErrPathNotAllowed, dst)
+	}
+
+	if _, err := b.Stat(ctx, dst); err == nil {
+		return fmt.Errorf("%w: %s", ErrFileExists, filepath.Base(dst))
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	isFile, err := b.exists(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	if isFile {
+		if err := b.client.CopyObject(ctx, srcKey, dstKey); err != nil {
+			return err
+		}
+		return b.client.DeleteObject(ctx, srcKey)
+	}
+
+	objects, err := b.listAll(ctx, srcKey+"/")
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return &fs.PathError{Op: "rename", Path: src, Err: fs.ErrNotExist}
+	}
+
+	for _, obj := range objects {
+		if err := b.client.CopyObject(ctx, obj.Key, dstKey+"/"+strings.TrimPrefix(obj.Key, srcKey+"/")); err != nil {
+			return err
+		}
+	}
+	for _, obj := range objects {
+		if err := b.client.DeleteObject(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *S3Backend) CheckReadable(ctx context.Context, path string) error {
+	_, err := b.client.ListObjects(ctx, b.dirPrefix(b.key(path)), "/", "", 1)
+	return err
+}
+
+func (b *S3Backend) exists(ctx context.Context, key string) (bool, error) {
+	if key == "" {
+		return false, nil
+	}
+	_, err := b.client.HeadObject(ctx, key)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+// listAll returns every object below prefix, across all pages.
+func (b *S3Backend) listAll(ctx context.Context, prefix string) ([]s3.Object, error) {
+	var objects []s3.Object
+	token := ""
+	for {
+		page, err := b.client.ListObjects(ctx, prefix, "", token, 0)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, page.Contents...)
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// objectInfo describes an object or a folder prefix as an os.FileInfo.
+type objectInfo struct {
+	name     string
+	size     int64
+	modified time.Time
+	dir      bool
+}
+
+func (fi *objectInfo) Name() string       { return fi.name }
+func (fi *objectInfo) Size() int64        { return fi.size }
+func (fi *objectInfo) ModTime() time.Time { return fi.modified }
+func (fi *objectInfo) IsDir() bool        { return fi.dir }
+func (fi *objectInfo) Sys() any           { return nil }
+
+func (fi *objectInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// s3ObjectReader reads an object with ranged GETs. Reading sequentially uses a single
+// request; seeking closes it and the next Read starts a new one at the new offset, which is
+// what serving a Range request or a video seek needs. Every request is pinned to the ETag
+// seen when the file was opened, so a concurrent overwrite fails the read instead of
+// mixing two versions.
+type s3ObjectReader struct {
+	ctx    context.Context
+	client *s3.Client
+	key    string
+	etag   string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *s3ObjectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		body, err := r.client.GetObject(r.ctx, r.key, r.offset, r.etag)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *s3ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errors.New("s3: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("s3: negative position")
+	}
+
+	if abs != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = abs
+	return abs, nil
+}
+
+func (r *s3ObjectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,638 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/s3"
+)
+
+const (
+	testBucket    = "vault"
+	testAccessKey = "test-access-key"
+	testSecretKey = "test-secret-key"
+	testPartSize  = 1024
+)
+
+// fakeS3 is an in-memory S3 server for one bucket. It checks the SigV4 signature of every
+// request and implements the calls S3Backend makes: HEAD, GET (with Range and If-Match),
+// PUT (with copy), DELETE, ListObjectsV2 and multipart uploads.
+type fakeS3 struct {
+	// pageSize is the most keys a list returns, small to exercise pagination
+	pageSize int
+	// failPart makes UploadPart of this part number fail
+	failPart int
+
+	mu         sync.Mutex
+	objects    map[string]fakeObject
+	uploads    map[string]map[int][]byte
+	nextUpload int
+	// requests are the calls made, as "PutObject", "UploadPart" etc.
+	requests []string
+	// ranges are the Range headers of the GET requests
+	ranges []string
+}
+
+type fakeObject struct {
+	data     []byte
+	etag     string
+	modified time.Time
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		pageSize: 2,
+		objects:  make(map[string]fakeObject),
+		uploads:  make(map[string]map[int][]byte),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth, err := s3.ParseAuthorization(r.Header.Get("Authorization"))
+	if err != nil || auth.AccessKey != testAccessKey {
+		s3.WriteError(w, http.StatusForbidden, "AccessDenied", "unknown access key", r.URL.Path, "")
+		return
+	}
+	if err := s3.VerifyRequest(r, auth, testSecretKey, time.Now()); err != nil {
+		s3.WriteError(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error(), r.URL.Path, "")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s3.WriteError(w, http.StatusBadRequest, "BadDigest", err.Error(), r.URL.Path, "")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != testBucket {
+		s3.WriteError(w, http.StatusNotFound, "NoSuchBucket", "no such bucket", bucket, "")
+		return
+	}
+	query := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.record("ListObjects")
+		f.list(w, query)
+	case r.Method == http.MethodHead, r.Method == http.MethodGet:
+		f.record(map[string]string{http.MethodHead: "HeadObject", http.MethodGet: "GetObject"}[r.Method])
+		f.get(w, r, key)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.record("CreateMultipartUpload")
+		f.nextUpload++
+		uploadID := strconv.Itoa(f.nextUpload)
+		f.uploads[uploadID] = make(map[int][]byte)
+		s3.WriteXML(w, http.StatusOK, &s3.InitiateMultipartUploadResult{Bucket: bucket, Key: key, UploadID: uploadID})
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.record("CompleteMultipartUpload")
+		f.complete(w, key, query.Get("uploadId"), body)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		f.record("UploadPart")
+		parts, ok := f.uploads[query.Get("uploadId")]
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		if !ok {
+			s3.WriteError(w, http.StatusNotFound, "NoSuchUpload", "no such upload", key, "")
+			return
+		}
+		if partNumber == f.failPart {
+			s3.WriteError(w, http.StatusInternalServerError, "InternalError", "part failed", key, "")
+			return
+		}
+		parts[partNumber] = body
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.record("CopyObject")
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		src, ok := f.objects[strings.TrimPrefix(source, "/"+testBucket+"/")]
+		if !ok {
+			s3.WriteError(w, http.StatusNotFound, "NoSuchKey", "no such key", source, "")
+			return
+		}
+		f.put(key, src.data)
+		s3.WriteXML(w, http.StatusOK, &s3.CopyObjectResult{LastModified: time.Now(), ETag: src.etag})
+	case r.Method == http.MethodPut:
+		f.record("PutObject")
+		w.Header().Set("ETag", f.put(key, body))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		f.record("AbortMultipartUpload")
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		f.record("DeleteObject")
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3.WriteError(w, http.StatusNotImplemented, "NotImplemented", r.Method+" is not implemented", key, "")
+	}
+}
+
+func (f *fakeS3) record(call string) {
+	f.requests = append(f.requests, call)
+}
+
+func (f *fakeS3) put(key string, data []byte) string {
+	obj := fakeObject{data: bytes.Clone(data), etag: etag(data), modified: time.Now().UTC().Truncate(time.Second)}
+	f.objects[key] = obj
+	return obj.etag
+}
+
+func (f *fakeS3) get(w http.ResponseWriter, r *http.Request, key string) {
+	obj, ok := f.objects[key]
+	if !ok {
+		s3.WriteError(w, http.StatusNotFound, "NoSuchKey", "no such key", key, "")
+		return
+	}
+	if match := r.Header.Get("If-Match"); match != "" && match != obj.etag {
+		s3.WriteError(w, http.StatusPreconditionFailed, "PreconditionFailed", "etag changed", key, "")
+		return
+	}
+
+	data, status := obj.data, http.StatusOK
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		f.ranges = append(f.ranges, rangeHeader)
+		start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rangeHeader, "bytes="), "-"))
+		if err != nil || start >= len(data) {
+			s3.WriteError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", rangeHeader, key, "")
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+		data, status = data[start:], http.StatusPartialContent
+	}
+
+	w.Header().Set("ETag", obj.etag)
+	w.Header().Set("Last-Modified", obj.modified.Format(http.TimeFormat))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	prefix, delimiter, token := query.Get("prefix"), query.Get("delimiter"), query.Get("continuation-token")
+	maxKeys := f.pageSize
+	if n, err := strconv.Atoi(query.Get("max-keys")); err == nil && n < maxKeys {
+		maxKeys = n
+	}
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := &s3.ListBucketResult{Name: testBucket, Prefix: prefix, Delimiter: delimiter, MaxKeys: maxKeys, ContinuationToken: token}
+	last := ""
+	for _, key := range keys {
+		// The token is the last key or common prefix of the previous page
+		if token != "" && (key <= token || strings.HasSuffix(token, "/") && strings.HasPrefix(key, token)) {
+			continue
+		}
+		commonPrefix := ""
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				commonPrefix = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if commonPrefix != "" && commonPrefix == last {
+			continue
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = last
+			break
+		}
+
+		if commonPrefix != "" {
+			result.CommonPrefixes = append(result.CommonPrefixes, s3.CommonPrefix{Prefix: commonPrefix})
+			last = commonPrefix
+		} else {
+			obj := f.objects[key]
+			result.Contents = append(result.Contents, s3.Object{Key: key, LastModified: obj.modified, ETag: obj.etag, Size: int64(len(obj.data))})
+			last = key
+		}
+		result.KeyCount++
+	}
+	s3.WriteXML(w, http.StatusOK, result)
+}
+
+func (f *fakeS3) complete(w http.ResponseWriter, key, uploadID string, body []byte) {
+	parts, ok := f.uploads[uploadID]
+	if !ok {
+		s3.WriteError(w, http.StatusNotFound, "NoSuchUpload", "no such upload", key, "")
+		return
+	}
+	var request s3.CompleteMultipartUpload
+	if err := xml.Unmarshal(body, &request); err != nil {
+		s3.WriteError(w, http.StatusBadRequest, "MalformedXML", err.Error(), key, "")
+		return
+	}
+
+	var data []byte
+	for i, part := range request.Parts {
+		content, ok := parts[part.PartNumber]
+		if !ok || part.PartNumber != i+1 || part.ETag != etag(content) {
+			s3.WriteError(w, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("part %d", part.PartNumber), key, "")
+			return
+		}
+		data = append(data, content...)
+	}
+	delete(f.uploads, uploadID)
+	s3.WriteXML(w, http.StatusOK, &s3.CompleteMultipartUploadResult{Bucket: testBucket, Key: key, ETag: f.put(key, data)})
+}
+
+// count returns how many times call was made and forgets the calls made so far.
+func (f *fakeS3) count(call string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, c := range f.requests {
+		if c == call {
+			n++
+		}
+	}
+	f.requests = nil
+	return n
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// newS3Repository returns a driver repository with the bucket of a fake S3 server mounted
+// at the returned path, below the "data/" prefix.
+func newS3Repository(t *testing.T) (DriverRepository, *fakeS3, string) {
+	t.Helper()
+
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	mountPath := filepath.Join(dir, "bucket")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	writer := NewAtomicWriter(filepath.Join(dir, "data"), logger)
+	storage, err := NewStorage(config.StorageConfig{
+		Mounts: []config.S3MountConfig{{
+			Path:      mountPath,
+			Endpoint:  server.URL,
+			Bucket:    testBucket,
+			Prefix:    "data",
+			Region:    "us-east-1",
+			AccessKey: testAccessKey,
+			SecretKey: testSecretKey,
+		}},
+		PartSize: testPartSize,
+	}, writer, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewDriverRepository(storage, writer, logger), fake, mountPath
+}
+
+func randomBytes(t *testing.T, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func saveFile(t *testing.T, repo DriverRepository, path string, data []byte) entities.StoredFile {
+	t.Helper()
+	stored, err := repo.SaveFile(context.Background(), path, bytes.NewReader(data), entities.OverwriteNever)
+	if err != nil {
+		t.Fatalf("SaveFile(%s): %v", path, err)
+	}
+	return stored
+}
+
+func readFile(t *testing.T, repo DriverRepository, path string) []byte {
+	t.Helper()
+	file, _, _, err := repo.OpenFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("OpenFile(%s): %v", path, err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return data
+}
+
+func listNames(t *testing.T, repo DriverRepository, path string) []string {
+	t.Helper()
+	infos, err := repo.ListPath(context.Background(), path)
+	if err != nil {
+		t.Fatalf("ListPath(%s): %v", path, err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name+":"+info.Type)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestS3BackendListByPrefix(t *testing.T) {
+	repo, fake, mount := newS3Repository(t)
+	ctx := context.Background()
+
+	saveFile(t, repo, filepath.Join(mount, "a.txt"), []byte("a"))
+	saveFile(t, repo, filepath.Join(mount, "b.txt"), []byte("b"))
+	saveFile(t, repo, filepath.Join(mount, "docs", "my report.txt"), []byte("report"))
+	saveFile(t, repo, filepath.Join(mount, "docs", "sub", "c.txt"), []byte("c"))
+	if err := repo.CreateFolder(ctx, filepath.Join(mount, "empty")); err != nil {
+		t.Fatal(err)
+	}
+	// Keys outside the mount's prefix don't show up
+	fake.mu.Lock()
+	fake.put("other/hidden.txt", []byte("x"))
+	fake.put("data-other.txt", []byte("x"))
+	fake.mu.Unlock()
+
+	// More entries than the fake's page size, so the listing spans pages
+	if got, want := listNames(t, repo, mount), []string{"a.txt:file", "b.txt:file", "docs:folder", "empty:folder"}; !slices.Equal(got, want) {
+		t.Errorf("ListPath(mount) = %v, want %v", got, want)
+	}
+	if got, want := listNames(t, repo, filepath.Join(mount, "docs")), []string{"my report.txt:file", "sub:folder"}; !slices.Equal(got, want) {
+		t.Errorf("ListPath(docs) = %v, want %v", got, want)
+	}
+	if got := listNames(t, repo, filepath.Join(mount, "empty")); len(got) != 0 {
+		t.Errorf("ListPath(empty) = %v, want nothing", got)
+	}
+
+	info, err := repo.Stat(ctx, filepath.Join(mount, "docs"))
+	if err != nil || !info.IsDir() {
+		t.Errorf("Stat(docs) = %v, %v; want a folder", info, err)
+	}
+	if _, err := repo.ListPath(ctx, filepath.Join(mount, "a.txt")); !errors.Is(err, ErrNotDirectory) {
+		t.Errorf("ListPath(a.txt) error = %v, want ErrNotDirectory", err)
+	}
+	if _, err := repo.Stat(ctx, filepath.Join(mount, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(missing) error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestS3BackendMultipartUpload(t *testing.T) {
+	tests := []struct {
+		size      int
+		wantParts int // 0 for a single PutObject
+	}{
+		{size: 0},
+		{size: testPartSize - 1},
+		{size: testPartSize, wantParts: 1},
+		{size: testPartSize + 1, wantParts: 2},
+		{size: 3*testPartSize + 7, wantParts: 4},
+	}
+
+	repo, fake, mount := newS3Repository(t)
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.size), func(t *testing.T) {
+			path := filepath.Join(mount, fmt.Sprintf("file-%d.bin", tt.size))
+			data := randomBytes(t, tt.size)
+			fake.count("")
+
+			stored := saveFile(t, repo, path, data)
+			if got := fake.count("UploadPart"); got != tt.wantParts {
+				t.Errorf("uploaded %d parts, want %d", got, tt.wantParts)
+			}
+
+			sum := sha256.Sum256(data)
+			if stored.Size != int64(tt.size) || stored.Hash != hex.EncodeToString(sum[:]) || stored.Path != path {
+				t.Errorf("SaveFile = %+v, want %d bytes with hash %x at %s", stored, tt.size, sum, path)
+			}
+			if got := readFile(t, repo, path); !bytes.Equal(got, data) {
+				t.Errorf("read back %d bytes, want the %d written", len(got), len(data))
+			}
+		})
+	}
+
+	if len(fake.uploads) != 0 {
+		t.Errorf("%d multipart uploads were left open", len(fake.uploads))
+	}
+}
+
+func TestS3BackendMultipartUploadFailureAborts(t *testing.T) {
+	repo, fake, mount := newS3Repository(t)
+	fake.failPart = 2
+
+	path := filepath.Join(mount, "big.bin")
+	_, err := repo.SaveFile(context.Background(), path, bytes.NewReader(randomBytes(t, 3*testPartSize)), entities.OverwriteNever)
+	if err == nil {
+		t.Fatal("SaveFile succeeded although a part failed")
+	}
+	if got := fake.count("AbortMultipartUpload"); got != 1 {
+		t.Errorf("aborted %d uploads, want 1", got)
+	}
+	if len(fake.uploads) != 0 {
+		t.Errorf("%d multipart uploads were left open", len(fake.uploads))
+	}
+	if _, err := repo.Stat(context.Background(), path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat after the failed upload: %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestS3BackendOverwriteModes(t *testing.T) {
+	repo, _, mount := newS3Repository(t)
+	ctx := context.Background()
+	path := filepath.Join(mount, "report.pdf")
+	saveFile(t, repo, path, []byte("v1"))
+
+	if _, err := repo.SaveFile(ctx, path, strings.NewReader("v2"), entities.OverwriteNever); !errors.Is(err, ErrFileExists) {
+		t.Errorf("SaveFile without overwrite error = %v, want ErrFileExists", err)
+	}
+	stored, err := repo.SaveFile(ctx, path, strings.NewReader("v2"), entities.OverwriteKeepBoth)
+	if err != nil || stored.Path != filepath.Join(mount, "report (1).pdf") {
+		t.Errorf("SaveFile keep_both = %+v, %v; want report (1).pdf", stored, err)
+	}
+	if _, err := repo.SaveFile(ctx, path, strings.NewReader("v3"), entities.OverwriteReplace); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, repo, path); string(got) != "v3" {
+		t.Errorf("content after replace = %q, want v3", got)
+	}
+}
+
+func TestS3BackendRangedRead(t *testing.T) {
+	repo, fake, mount := newS3Repository(t)
+	path := filepath.Join(mount, "video.mp4")
+	data := randomBytes(t, 5000)
+	saveFile(t, repo, path, data)
+
+	file, info, _, err := repo.OpenFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if info.Size() != int64(len(data)) {
+		t.Errorf("size = %d, want %d", info.Size(), len(data))
+	}
+
+	reads := []struct {
+		offset int64
+		whence int
+		want   int64
+	}{
+		{offset: 1234, whence: io.SeekStart, want: 1234},
+		{offset: 100, whence: io.SeekCurrent, want: 1234 + 100 + 100},
+		{offset: -300, whence: io.SeekEnd, want: 4700},
+		{offset: 0, whence: io.SeekStart, want: 0},
+	}
+	for _, r := range reads {
+		pos, err := file.Seek(r.offset, r.whence)
+		if err != nil || pos != r.want {
+			t.Fatalf("Seek(%d, %d) = %d, %v; want %d", r.offset, r.whence, pos, err, r.want)
+		}
+		buf := make([]byte, 100)
+		if _, err := io.ReadFull(file, buf); err != nil {
+			t.Fatalf("read at %d: %v", pos, err)
+		}
+		if !bytes.Equal(buf, data[pos:pos+100]) {
+			t.Errorf("read at %d returned the wrong bytes", pos)
+		}
+	}
+
+	// Only seeks to a new position start a request, the first read from 0 has no Range
+	fake.mu.Lock()
+	ranges := slices.Clone(fake.ranges)
+	fake.mu.Unlock()
+	if want := []string{"bytes=1234-", "bytes=1434-", "bytes=4700-"}; !slices.Equal(ranges, want) {
+		t.Errorf("Range headers = %v, want %v", ranges, want)
+	}
+
+	// Reading past the end
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := file.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Errorf("Read at the end = %d, %v; want 0, EOF", n, err)
+	}
+}
+
+func TestS3BackendReadFailsWhenObjectChanges(t *testing.T) {
+	repo, _, mount := newS3Repository(t)
+	ctx := context.Background()
+	path := filepath.Join(mount, "notes.txt")
+	saveFile(t, repo, path, []byte("first version"))
+
+	file, _, _, err := repo.OpenFile(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := repo.SaveFile(ctx, path, strings.NewReader("second version"), entities.OverwriteReplace); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(file); !errors.Is(err, s3.ErrPreconditionFailed) {
+		t.Errorf("reading a replaced object: %v, want ErrPreconditionFailed", err)
+	}
+}
+
+func TestS3BackendRemoveAndRename(t *testing.T) {
+	repo, fake, mount := newS3Repository(t)
+	ctx := context.Background()
+
+	saveFile(t, repo, filepath.Join(mount, "a.txt"), []byte("a"))
+	saveFile(t, repo, filepath.Join(mount, "docs", "b.txt"), []byte("b"))
+	saveFile(t, repo, filepath.Join(mount, "docs", "sub", "c.txt"), []byte("c"))
+	saveFile(t, repo, filepath.Join(mount, "docs", "sub", "d.txt"), []byte("d"))
+	if err := repo.CreateFolder(ctx, filepath.Join(mount, "docs", "empty")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file
+	if err := repo.Rename(ctx, filepath.Join(mount, "a.txt"), filepath.Join(mount, "docs", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, repo, filepath.Join(mount, "docs", "a.txt")); string(got) != "a" {
+		t.Errorf("moved file has %q, want a", got)
+	}
+
+	// A folder with everything below it, including the empty folder's marker
+	if err := repo.Rename(ctx, filepath.Join(mount, "docs"), filepath.Join(mount, "archive")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := listNames(t, repo, mount), []string{"archive:folder"}; !slices.Equal(got, want) {
+		t.Errorf("ListPath(mount) after rename = %v, want %v", got, want)
+	}
+	if got, want := listNames(t, repo, filepath.Join(mount, "archive")), []string{"a.txt:file", "b.txt:file", "empty:folder", "sub:folder"}; !slices.Equal(got, want) {
+		t.Errorf("ListPath(archive) = %v, want %v", got, want)
+	}
+	if got := readFile(t, repo, filepath.Join(mount, "archive", "sub", "d.txt")); string(got) != "d" {
+		t.Errorf("moved nested file has %q, want d", got)
+	}
+
+	// Refused moves
+	saveFile(t, repo, filepath.Join(mount, "x.txt"), []byte("x"))
+	if err := repo.Rename(ctx, filepath.Join(mount, "x.txt"), filepath.Join(mount, "archive", "a.txt")); !errors.Is(err, ErrFileExists) {
+		t.Errorf("Rename onto an existing file error = %v, want ErrFileExists", err)
+	}
+	if err := repo.Rename(ctx, filepath.Join(mount, "archive"), filepath.Join(mount, "archive", "sub", "archive")); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("Rename into itself error = %v, want ErrPathNotAllowed", err)
+	}
+	if err := repo.Rename(ctx, filepath.Join(mount, "x.txt"), filepath.Join(filepath.Dir(mount), "x.txt")); !errors.Is(err, ErrCrossBackend) {
+		t.Errorf("Rename to local disk error = %v, want ErrCrossBackend", err)
+	}
+	if err := repo.Rename(ctx, filepath.Join(mount, "missing"), filepath.Join(mount, "found")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Rename of a missing path error = %v, want fs.ErrNotExist", err)
+	}
+
+	// Removing
+	if err := repo.Remove(ctx, filepath.Join(mount, "x.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RemoveEmptyDir(ctx, filepath.Join(mount, "archive")); !errors.Is(err, ErrDirNotEmpty) {
+		t.Errorf("RemoveEmptyDir of a full folder error = %v, want ErrDirNotEmpty", err)
+	}
+	if err := repo.Remove(ctx, filepath.Join(mount, "archive")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Remove(ctx, filepath.Join(mount, "archive")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove of a removed folder error = %v, want fs.ErrNotExist", err)
+	}
+	if err := repo.Remove(ctx, mount); !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("Remove of the mount point error = %v, want ErrPathNotAllowed", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.objects) != 0 {
+		var keys []string
+		for key := range fake.objects {
+			keys = append(keys, key)
+		}
+		t.Errorf("objects left in the bucket: %v", keys)
+	}
+}
@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SyncChangeFilter selects the journal entries after Cursor, up to Head, that concern the
+// folder at Root: changes to it or below it, moves into or out of it, removals and moves
+// of the folders above it, and resyncs of everything.
+type SyncChangeFilter struct {
+	Root   string
+	Cursor int64
+	Head   int64
+	Limit  int
+}
+
+type SyncRepository interface {
+	CreateChanges(ctx context.Context, changes []entities.SyncChange) error
+	// Head returns the cursor of the latest change, 0 if there is none.
+	Head(ctx context.Context) (int64, error)
+	// First returns the cursor of the oldest change kept, 0 if there is none.
+	First(ctx context.Context) (int64, error)
+	ListChanges(ctx context.Context, filter SyncChangeFilter) ([]entities.SyncChange, error)
+	// DeleteChangesBefore deletes the changes older than before, except the latest one, so
+	// the cursors of the deleted changes can still be told apart from the latest.
+	DeleteChangesBefore(ctx context.Context, before time.Time) (int64, error)
+
+	// Hashes returns the cached hashes of the files at paths, by path.
+	Hashes(ctx context.Context, paths []string) (map[string]entities.FileHash, error)
+	SaveHash(ctx context.Context, hash *entities.FileHash) error
+	// MoveHashes moves the cached hashes of the file or folder tree at src to dst.
+	MoveHashes(ctx context.Context, src, dst string) error
+	// RemoveHashes forgets the cached hashes of the file or folder tree at path.
+	RemoveHashes(ctx context.Context, path string) error
+}
+
+type SyncRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewSyncRepository(db *gorm.DB) SyncRepository {
+	return &SyncRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *SyncRepositoryImpl) CreateChanges(ctx context.Context, changes []entities.SyncChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(changes, len(changes)).Error
+}
+
+func (r *SyncRepositoryImpl) Head(ctx context.Context) (int64, error) {
+	var head int64
+	err := r.db.WithContext(ctx).Model(&entities.SyncChange{}).Select("COALESCE(MAX(id), 0)").Scan(&head).Error
+	return head, err
+}
+
+func (r *SyncRepositoryImpl) First(ctx context.Context) (int64, error) {
+	var first int64
+	err := r.db.WithContext(ctx).Model(&entities.SyncChange{}).Select("COALESCE(MIN(id), 0)").Scan(&first).Error
+	return first, err
+}
+
+func (r *SyncRepositoryImpl) ListChanges(ctx context.Context, filter SyncChangeFilter) ([]entities.SyncChange, error) {
+	prefix := escapeLike(childPrefix(filter.Root)) + "%"
+	var ancestors []string
+	for dir := filepath.Dir(filter.Root); ; dir = filepath.Dir(dir) {
+		ancestors = append(ancestors, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	var changes []entities.SyncChange
+	err := r.db.WithContext(ctx).
+		Where("id > ? AND id <= ?", filter.Cursor, filter.Head).
+		Where(r.db.Where("path = ? OR path LIKE ? ESCAPE '\\'", filter.Root, prefix).
+			Or("old_path = ? OR old_path LIKE ? ESCAPE '\\'", filter.Root, prefix).
+			Or("type IN ? AND (path IN ? OR old_path IN ?)", []string{entities.ChangeDelete, entities.ChangeRename}, ancestors, ancestors).
+			Or("type = ? AND path = ''", entities.ChangeResync)).
+		Order("id").
+		Limit(filter.Limit).
+		Find(&changes).Error
+	return changes, err
+}
+
+func (r *SyncRepositoryImpl) DeleteChangesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("created_at < ? AND id < (SELECT MAX(id) FROM sync_changes)", before).
+		Delete(&entities.SyncChange{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *SyncRepositoryImpl) Hashes(ctx context.Context, paths []string) (map[string]entities.FileHash, error) {
+	hashes := make(map[string]entities.FileHash, len(paths))
+	if len(paths) == 0 {
+		return hashes, nil
+	}
+
+	var found []entities.FileHash
+	if err := r.db.WithContext(ctx).Where("path IN ?", paths).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for _, hash := range found {
+		hashes[hash.Path] = hash
+	}
+	return hashes, nil
+}
+
+func (r *SyncRepositoryImpl) SaveHash(ctx context.Context, hash *entities.FileHash) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(hash).Error
+}
+
+func (r *SyncRepositoryImpl) MoveHashes(ctx context.Context, src, dst string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Files that were at the destination have been replaced
+		if err := tx.Where("path = ? OR path LIKE ? ESCAPE '\\'", dst, escapeLike(childPrefix(dst))+"%").
+			Delete(&entities.FileHash{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&entities.FileHash{}).
+			Where("path = ? OR path LIKE ? ESCAPE '\\'", src, escapeLike(childPrefix(src))+"%").
+			Update("path", gorm.Expr("? || substr(path, ?)", dst, utf8.RuneCountInString(src)+1)).Error
+	})
+}
+
+func (r *SyncRepositoryImpl) RemoveHashes(ctx context.Context, path string) error {
+	return r.db.WithContext(ctx).
+		Where("path = ? OR path LIKE ? ESCAPE '\\'", path, escapeLike(childPrefix(path))+"%").
+		Delete(&entities.FileHash{}).Error
+}
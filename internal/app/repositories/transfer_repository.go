@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TransferRepository interface {
+	// GetLimit returns the limits set for the user, or nil if none were.
+	GetLimit(ctx context.Context, userID uuid.UUID) (*entities.TransferLimit, error)
+	SaveLimit(ctx context.Context, limit *entities.TransferLimit) error
+	DeleteLimit(ctx context.Context, userID uuid.UUID) error
+
+	// GetUsage returns what the user transferred on day, zero if nothing.
+	GetUsage(ctx context.Context, userID uuid.UUID, day string) (*entities.TransferUsage, error)
+	// AddUsage adds the bytes of usages to the ones already recorded for their user and day.
+	AddUsage(ctx context.Context, usages []entities.TransferUsage) error
+	DeleteUsageBefore(ctx context.Context, day string) (int64, error)
+}
+
+type TransferRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewTransferRepository(db *gorm.DB) TransferRepository {
+	return &TransferRepositoryImpl{
+		db: db,
+	}
+}
+
+func (r *TransferRepositoryImpl) GetLimit(ctx context.Context, userID uuid.UUID) (*entities.TransferLimit, error) {
+	var limit entities.TransferLimit
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&limit).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &limit, nil
+}
+
+func (r *TransferRepositoryImpl) SaveLimit(ctx context.Context, limit *entities.TransferLimit) error {
+	return r.db.WithContext(ctx).Save(limit).Error
+}
+
+func (r *TransferRepositoryImpl) DeleteLimit(ctx context.Context, userID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&entities.TransferLimit{}).Error
+}
+
+func (r *TransferRepositoryImpl) GetUsage(ctx context.Context, userID uuid.UUID, day string) (*entities.TransferUsage, error) {
+	usage := entities.TransferUsage{UserID: userID, Day: day}
+	err := r.db.WithContext(ctx).Where("user_id = ? AND day = ?", userID, day).Find(&usage).Error
+	return &usage, err
+}
+
+func (r *TransferRepositoryImpl) AddUsage(ctx context.Context, usages []entities.TransferUsage) error {
+	if len(usages) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "day"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "downloaded"}, Value: gorm.Expr("transfer_usages.downloaded + excluded.downloaded")},
+			{Column: clause.Column{Name: "uploaded"}, Value: gorm.Expr("transfer_usages.uploaded + excluded.uploaded")},
+		},
+	}).Create(&usages).Error
+}
+
+func (r *TransferRepositoryImpl) DeleteUsageBefore(ctx context.Context, day string) (int64, error) {
+	result := r.db.WithContext(ctx).Where("day < ?", day).Delete(&entities.TransferUsage{})
+	return result.RowsAffected, result.Error
+}
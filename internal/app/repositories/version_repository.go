@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrVersionNotFound = errors.New("version not found")
+
+// versionStoreDir is the directory under the data dir holding the content of previous
+// versions. Blobs are named after a random ID and spread over 256 subdirectories.
+const versionStoreDir = "versions"
+
+type VersionRepository interface {
+	Create(ctx context.Context, version *entities.FileVersion) error
+	Update(ctx context.Context, version *entities.FileVersion) error
+	Get(ctx context.Context, id uuid.UUID) (*entities.FileVersion, error)
+	Current(ctx context.Context, path string) (*entities.FileVersion, error)
+	ListArchived(ctx context.Context, path string) ([]entities.FileVersion, error)
+	DeleteCurrent(ctx context.Context, path string) error
+	Delete(ctx context.Context, version *entities.FileVersion) error
+
+	StoreBlob(ctx context.Context, path string) (entities.StoredFile, os.FileInfo, error)
+	OpenBlob(ctx context.Context, version *entities.FileVersion) (*os.File, os.FileInfo, error)
+	RemoveBlob(ctx context.Context, blobPath string) error
+	RestoreBlob(ctx context.Context, version *entities.FileVersion) (entities.StoredFile, error)
+
+	GetRetention(ctx context.Context, userID uuid.UUID) (*entities.VersionRetention, error)
+	SaveRetention(ctx context.Context, retention *entities.VersionRetention) error
+}
+
+// VersionRepositoryImpl keeps version content on local disk under the data dir, whatever
+// backend the versioned file itself lives on.
+type VersionRepositoryImpl struct {
+	db       *gorm.DB
+	storage  *Storage
+	storeDir string
+	writer   *AtomicWriter
+}
+
+func NewVersionRepository(db *gorm.DB, storage *Storage, dataDir string, writer *AtomicWriter) VersionRepository {
+	return &VersionRepositoryImpl{
+		db:       db,
+		storage:  storage,
+		storeDir: filepath.Join(dataDir, versionStoreDir),
+		writer:   writer,
+	}
+}
+
+func (r *VersionRepositoryImpl) Create(ctx context.Context, version *entities.FileVersion) error {
+	return r.db.WithContext(ctx).Create(version).Error
+}
+
+func (r *VersionRepositoryImpl) Update(ctx context.Context, version *entities.FileVersion) error {
+	return r.db.WithContext(ctx).Save(version).Error
+}
+
+func (r *VersionRepositoryImpl) Get(ctx context.Context, id uuid.UUID) (*entities.FileVersion, error) {
+	var version entities.FileVersion
+	if err := r.db.WithContext(ctx).First(&version, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// Current returns the record of the live content of path, or nil if there is none (the
+// file predates versioning or was last written outside the vault).
+func (r *VersionRepositoryImpl) Current(ctx context.Context, path string) (*entities.FileVersion, error) {
+	var versions []entities.FileVersion
+	err := r.db.WithContext(ctx).
+		Where("path = ? AND archived_at IS NULL", path).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, nil
+	}
+	return &versions[0], nil
+}
+
+// ListArchived returns the previous versions of path, newest first.
+func (r *VersionRepositoryImpl) ListArchived(ctx context.Context, path string) ([]entities.FileVersion, error) {
+	var versions []entities.FileVersion
+	err := r.db.WithContext(ctx).
+		Where("path = ? AND archived_at IS NOT NULL", path).
+		Order("created_at DESC").
+		Find(&versions).Error
+	return versions, err
+}
+
+func (r *VersionRepositoryImpl) DeleteCurrent(ctx context.Context, path string) error {
+	return r.db.WithContext(ctx).
+		Where("path = ? AND archived_at IS NULL", path).
+		Delete(&entities.FileVersion{}).Error
+}
+
+// Delete removes the version record and its content from the store.
+func (r *VersionRepositoryImpl) Delete(ctx context.Context, version *entities.FileVersion) error {
+	if err := r.db.WithContext(ctx).Delete(&entities.FileVersion{}, "id = ?", version.ID).Error; err != nil {
+		return err
+	}
+	if version.BlobPath == "" {
+		return nil
+	}
+	return r.RemoveBlob(ctx, version.BlobPath)
+}
+
+// StoreBlob copies the live file at path into the version store. It returns an error
+// matching fs.ErrNotExist when there is no file to keep.
+func (r *VersionRepositoryImpl) StoreBlob(ctx context.Context, path string) (entities.StoredFile, os.FileInfo, error) {
+	if !isSafePath(path) {
+		return entities.StoredFile{}, nil, fmt.Errorf("%w: %s", ErrPathNotAllowed, path)
+	}
+
+	backend := r.storage.Backend(path)
+	info, err := backend.Stat(ctx, path)
+	if err != nil {
+		return entities.StoredFile{}, nil, err
+	}
+	if info.IsDir() {
+		return entities.StoredFile{}, nil, fmt.Errorf("path %s is a directory", path)
+	}
+
+	src, _, err := backend.Open(ctx, path)
+	if err != nil {
+		return entities.StoredFile{}, nil, err
+	}
+	defer src.Close()
+
+	id := uuid.NewString()
+	blobPath := filepath.Join(r.storeDir, id[:2], id)
+
+	stored, err := r.writer.WriteFile(ctx, blobPath, src, entities.OverwriteNever)
+	if err != nil {
+		return entities.StoredFile{}, nil, err
+	}
+	return stored, info, nil
+}
+
+func (r *VersionRepositoryImpl) OpenBlob(ctx context.Context, version *entities.FileVersion) (*os.File, os.FileInfo, error) {
+	if version.BlobPath == "" {
+		return nil, nil, fmt.Errorf("%w: %s has no stored content", ErrVersionNotFound, version.ID)
+	}
+
+	file, err := os.Open(version.BlobPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+func (r *VersionRepositoryImpl) RemoveBlob(ctx context.Context, blobPath string) error {
+	if err := os.Remove(blobPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RestoreBlob atomically replaces the file at version.Path with the version's content.
+func (r *VersionRepositoryImpl) RestoreBlob(ctx context.Context, version *entities.FileVersion) (entities.StoredFile, error) {
+	if !isSafePath(version.Path) {
+		return entities.StoredFile{}, fmt.Errorf("%w: %s", ErrPathNotAllowed, version.Path)
+	}
+
+	src, _, err := r.OpenBlob(ctx, version)
+	if err != nil {
+		return entities.StoredFile{}, err
+	}
+	defer src.Close()
+
+	return r.storage.Backend(version.Path).WriteFile(ctx, version.Path, src, entities.OverwriteReplace)
+}
+
+// GetRetention returns the user's retention policy, or nil if they never set one.
+func (r *VersionRepositoryImpl) GetRetention(ctx context.Context, userID uuid.UUID) (*entities.VersionRetention, error) {
+	var retention entities.VersionRetention
+	if err := r.db.WithContext(ctx).First(&retention, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &retention, nil
+}
+
+func (r *VersionRepositoryImpl) SaveRetention(ctx context.Context, retention *entities.VersionRetention) error {
+	return r.db.WithContext(ctx).Save(retention).Error
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+)
+
+var ErrWatchUnsupported = errors.New("file system watching is not supported on this platform")
+
+// FileWatcher reports changes made to watched folders on local disk, by the vault or by
+// anything else. Watches are not recursive: only the direct entries of a watched folder
+// (and the folder itself) are reported. Paths in events are absolute disk paths.
+type FileWatcher interface {
+	Watch(dir string) error
+	Unwatch(dir string)
+	// Events is closed by Close. A ChangeResync event with an empty path means events
+	// were lost.
+	Events() <-chan entities.ChangeEvent
+	Close() error
+}
+
+const watcherQueueSize = 1024
+
+// IsHidden reports whether a file name is kept out of listings (dot files, system folders)
+func IsHidden(name string) bool {
+	return shouldSkipFile(name)
+}
+
+// IsAllowedPath reports whether the vault's path rules allow access to path
+func IsAllowedPath(path string) bool {
+	return isSafePath(path)
+}
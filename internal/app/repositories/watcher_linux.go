@@ -0,0 +1,230 @@
+//go:build linux
+
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE | unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR
+
+// inotifyWatcher watches folders with inotify. A MOVED_FROM/MOVED_TO pair read in the same
+// batch becomes one rename; a move into a watched folder from elsewhere is a create and a
+// move out of it is a delete.
+type inotifyWatcher struct {
+	fd     int
+	events chan entities.ChangeEvent
+	done   chan struct{}
+	wg     sync.WaitGroup
+	logger *slog.Logger
+
+	dropped bool // only touched by run
+
+	mu    sync.Mutex
+	dirs  map[int]string // watch descriptor -> folder
+	wds   map[string]int
+	close sync.Once
+}
+
+func NewFileWatcher(logger *slog.Logger) (FileWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+
+	w := &inotifyWatcher{
+		fd:     fd,
+		events: make(chan entities.ChangeEvent, watcherQueueSize),
+		done:   make(chan struct{}),
+		logger: logger.With("component", "repository.watcher"),
+		dirs:   make(map[int]string),
+		wds:    make(map[string]int),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+func (w *inotifyWatcher) Watch(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.wds[dir]; ok {
+		return nil
+	}
+	wd, err := unix.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	w.dirs[wd] = dir
+	w.wds[dir] = wd
+	return nil
+}
+
+func (w *inotifyWatcher) Unwatch(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wd, ok := w.wds[dir]
+	if !ok {
+		return
+	}
+	unix.InotifyRmWatch(w.fd, uint32(wd))
+	delete(w.dirs, wd)
+	delete(w.wds, dir)
+}
+
+func (w *inotifyWatcher) Events() <-chan entities.ChangeEvent {
+	return w.events
+}
+
+func (w *inotifyWatcher) Close() error {
+	w.close.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+		unix.Close(w.fd)
+		close(w.events)
+	})
+	return nil
+}
+
+// run polls with a timeout instead of blocking in read, so Close doesn't have to wait for
+// a file system change to stop it.
+func (w *inotifyWatcher) run() {
+	defer w.wg.Done()
+
+	buf := make([]byte, 64*1024)
+	fds := []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		n, err := unix.Poll(fds, 500)
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			w.logger.Error("failed to poll inotify", "error", err)
+			return
+		}
+		if n <= 0 {
+			continue
+		}
+
+		n, err = unix.Read(w.fd, buf)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			w.logger.Error("failed to read inotify events", "error", err)
+			return
+		}
+		w.handle(buf[:n])
+	}
+}
+
+func (w *inotifyWatcher) handle(buf []byte) {
+	type movedFrom struct {
+		path  string
+		isDir bool
+	}
+	moves := make(map[uint32]movedFrom)
+	now := time.Now()
+
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(raw.Len)]
+		offset += unix.SizeofInotifyEvent + int(raw.Len)
+
+		if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+			w.emit(entities.ChangeEvent{Type: entities.ChangeResync, Time: now})
+			continue
+		}
+
+		w.mu.Lock()
+		dir, ok := w.dirs[int(raw.Wd)]
+		if raw.Mask&unix.IN_IGNORED != 0 && ok {
+			delete(w.dirs, int(raw.Wd))
+			delete(w.wds, dir)
+		}
+		w.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		path := dir
+		if name := trimNul(nameBytes); name != "" {
+			path = filepath.Join(dir, name)
+		}
+		isDir := raw.Mask&unix.IN_ISDIR != 0
+		event := entities.ChangeEvent{Path: path, IsDir: isDir, Time: now}
+
+		switch {
+		case raw.Mask&unix.IN_CREATE != 0:
+			event.Type = entities.ChangeCreate
+		case raw.Mask&unix.IN_CLOSE_WRITE != 0:
+			event.Type = entities.ChangeModify
+		case raw.Mask&unix.IN_DELETE != 0:
+			event.Type = entities.ChangeDelete
+		case raw.Mask&unix.IN_MOVED_FROM != 0:
+			moves[raw.Cookie] = movedFrom{path: path, isDir: isDir}
+			continue
+		case raw.Mask&unix.IN_MOVED_TO != 0:
+			if from, ok := moves[raw.Cookie]; ok {
+				delete(moves, raw.Cookie)
+				event.Type = entities.ChangeRename
+				event.OldPath = from.path
+			} else {
+				event.Type = entities.ChangeCreate
+			}
+		case raw.Mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0:
+			event.Type = entities.ChangeDelete
+			event.IsDir = true
+		default:
+			continue
+		}
+		w.emit(event)
+	}
+
+	for _, from := range moves {
+		w.emit(entities.ChangeEvent{Type: entities.ChangeDelete, Path: from.path, IsDir: from.isDir, Time: now})
+	}
+}
+
+// emit never blocks the reader: if the consumer falls behind, events are dropped and a
+// resync is sent once there is room again.
+func (w *inotifyWatcher) emit(event entities.ChangeEvent) {
+	if w.dropped {
+		select {
+		case w.events <- entities.ChangeEvent{Type: entities.ChangeResync, Time: event.Time}:
+			w.dropped = false
+		default:
+			return
+		}
+	}
+	select {
+	case w.events <- event:
+	default:
+		w.dropped = true
+		w.logger.Warn("dropped file system events, consumer is too slow")
+	}
+}
+
+func trimNul(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package repositories
+
+import (
+	"log/slog"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+)
+
+// noopWatcher is used where inotify isn't available; only changes made through the API
+// are reported there.
+type noopWatcher struct {
+	events chan entities.ChangeEvent
+}
+
+func NewFileWatcher(logger *slog.Logger) (FileWatcher, error) {
+	return &noopWatcher{events: make(chan entities.ChangeEvent)}, nil
+}
+
+func (w *noopWatcher) Watch(dir string) error              { return ErrWatchUnsupported }
+func (w *noopWatcher) Unwatch(dir string)                  {}
+func (w *noopWatcher) Events() <-chan entities.ChangeEvent { return w.events }
+func (w *noopWatcher) Close() error                        { close(w.events); return nil }
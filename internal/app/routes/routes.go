@@ -2,30 +2,64 @@ package routes
 
 import (
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/handlers"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
 	"github.com/RaihanurRahman2022/PersonalVault/internal/middleware"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(router *gin.Engine, handlers *handlers.Handlers, db *gorm.DB) {
+// SetupRoutes configures the API. Each group of routes has its own rate limit from
+// cfg.RateLimit: the file routes, the sync API and the rest of the API are limited
+// separately, so a sync client can't use up the requests of the web app.
+func SetupRoutes(router *gin.Engine, handlers *handlers.Handlers, limiter *middleware.RateLimiter, cfg *config.Config, db *gorm.DB) {
+	limits := cfg.RateLimit
+	auth := middleware.AuthMiddleware(db, cfg.JWT, cfg.TLS.ClientCertLogin)
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	if cfg.Metrics.Enabled {
+		router.GET("/metrics", middleware.MetricsAuthMiddleware(cfg.Metrics.Token), gin.WrapH(monitoring.Handler()))
+	}
+	router.GET("/healthz", handlers.Health.Healthz)
+	router.GET("/readyz", handlers.Health.Readyz)
 
-	setupPublicRoutes(router, handlers.Auth)
+	setupPublicRoutes(router, handlers.Auth, limiter.Middleware("auth", limits.Auth))
+	setupDAVRoutes(router, handlers.DAV, handlers.Transfer, limiter.Middleware("dav", limits.DAV), cfg, db)
+	setupEventRoutes(router, handlers.Events, handlers.Jobs, handlers.Text, auth, limiter.Middleware("api", limits.API))
 
+	// Each group is limited before and after auth, so requests with a wrong token are
+	// counted against their IP
 	api := router.Group("/api")
-	api.Use(middleware.AuthMiddleware(db))
 	{
-		setupUserRoutes(api, handlers.UserHandler)
-		setupDriverRoutes(api, handlers.Driver)
+		rateLimit := limiter.Middleware("files", limits.Files)
+		files := api.Group("", rateLimit, auth, rateLimit)
+		setupDriverRoutes(files, handlers.Driver, handlers.Fetch, handlers.Text, handlers.Transfer)
+		setupVersionRoutes(files, handlers.Version, handlers.Transfer)
+		setupEncryptionRoutes(files, handlers.Encryption)
+		setupPhotoRoutes(files, handlers.Photos)
+		setupMetadataRoutes(files, handlers.Metadata)
+		setupActivityRoutes(files, handlers.Activity)
+		setupDiskUsageRoutes(files, handlers.DiskUsage)
+
+		rateLimit = limiter.Middleware("sync", limits.Sync)
+		sync := api.Group("", rateLimit, auth, rateLimit)
+		setupSyncRoutes(sync, handlers.Sync, handlers.Transfer)
+
+		rateLimit = limiter.Middleware("api", limits.API)
+		general := api.Group("", rateLimit, auth, rateLimit)
+		setupUserRoutes(general, handlers.UserHandler)
+		setupAccessKeyRoutes(general, handlers.AccessKey)
+		setupJobRoutes(general, handlers.Jobs)
+		setupTransferRoutes(general, handlers.Transfer)
+		setupAdminRoutes(general, handlers.Audit, handlers.Transfer, db)
 	}
 }
 
 // setupPublicRoutes configures public routes that don't require authentication
-func setupPublicRoutes(router *gin.Engine, authHandler *handlers.AuthHandler) {
-	auth := router.Group("/auth")
+func setupPublicRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, rateLimit gin.HandlerFunc) {
+	auth := router.Group("/auth", rateLimit)
 	{
 		auth.POST("/login", authHandler.Login)
 		auth.POST("/register", authHandler.Register)
@@ -39,15 +73,152 @@ func setupUserRoutes(api *gin.RouterGroup, userHandler *handlers.UserHandler) {
 	}
 }
 
-func setupDriverRoutes(api *gin.RouterGroup, driverHandler *handlers.DriveHandler) {
+// setupDriverRoutes configures the file routes. Downloads and uploads go through the
+// transfer handler's middleware, which limits their bandwidth.
+func setupDriverRoutes(api *gin.RouterGroup, driverHandler *handlers.DriveHandler, fetchHandler *handlers.FetchHandler, textHandler *handlers.TextHandler, transferHandler *handlers.TransferHandler) {
 	driver := api.Group("/drivers")
 	{
 		driver.GET("/root", driverHandler.GetRootDrivers)
 		driver.GET("/list", driverHandler.ListPath)
-		driver.POST("/download", driverHandler.Downloadfile)
+		driver.POST("/download", transferHandler.Throttle, driverHandler.Downloadfile)
 		driver.POST("/create-folder", driverHandler.CreateFolder)
-		driver.GET("/preview", driverHandler.PreviewFile)
-		driver.GET("/stream", driverHandler.StreamFile)
-		driver.POST("/upload", driverHandler.UploadFiles)
+		driver.GET("/preview", transferHandler.Throttle, driverHandler.PreviewFile)
+		driver.GET("/stream", transferHandler.Throttle, driverHandler.StreamFile)
+		driver.POST("/upload", transferHandler.Throttle, driverHandler.UploadFiles)
+		driver.POST("/move", driverHandler.MoveFiles)
+		driver.POST("/fetch", fetchHandler.FetchURL)
+		driver.GET("/text", textHandler.PreviewText)
+		driver.GET("/text/structured", textHandler.PreviewStructured)
+	}
+}
+
+func setupVersionRoutes(api *gin.RouterGroup, versionHandler *handlers.VersionHandler, transferHandler *handlers.TransferHandler) {
+	versions := api.Group("/drivers/versions")
+	{
+		versions.GET("", versionHandler.ListVersions)
+		versions.POST("/prune", versionHandler.PruneVersions)
+		versions.GET("/:id/download", transferHandler.Throttle, versionHandler.DownloadVersion)
+		versions.GET("/:id/preview", transferHandler.Throttle, versionHandler.PreviewVersion)
+		versions.POST("/:id/restore", versionHandler.RestoreVersion)
+		versions.DELETE("/:id", versionHandler.DeleteVersion)
+	}
+
+	api.GET("/users/me/version-retention", versionHandler.GetRetention)
+	api.PUT("/users/me/version-retention", versionHandler.SetRetention)
+}
+
+func setupAccessKeyRoutes(api *gin.RouterGroup, accessKeyHandler *handlers.AccessKeyHandler) {
+	keys := api.Group("/users/me/access-keys")
+	{
+		keys.POST("", accessKeyHandler.CreateAccessKey)
+		keys.GET("", accessKeyHandler.ListAccessKeys)
+		keys.DELETE("/:id", accessKeyHandler.DeleteAccessKey)
+	}
+}
+
+func setupEncryptionRoutes(api *gin.RouterGroup, encryptionHandler *handlers.EncryptionHandler) {
+	folders := api.Group("/drivers/encrypted-folders")
+	{
+		folders.GET("", encryptionHandler.ListEncryptedFolders)
+		folders.POST("", encryptionHandler.EncryptFolder)
+		folders.DELETE("/:id", encryptionHandler.DisableFolderEncryption)
+	}
+
+	api.POST("/users/me/keyring/unlock", encryptionHandler.UnlockKeyring)
+	api.POST("/users/me/keyring/lock", encryptionHandler.LockKeyring)
+}
+
+// SetupS3Routes configures the S3 gateway, which runs on its own listener: S3 clients
+// address buckets at the root of the endpoint, so it can't share the API's paths.
+// Requests are rate limited per IP before their signature is checked, and per user after.
+func SetupS3Routes(router *gin.Engine, handlers *handlers.Handlers, limiter *middleware.RateLimiter, cfg *config.Config, db *gorm.DB) {
+	rateLimit := limiter.Middleware("s3", cfg.RateLimit.S3)
+	router.Use(rateLimit, middleware.S3AuthMiddleware(db), rateLimit, handlers.Transfer.Throttle)
+	router.Any("/*path", handlers.S3.ServeS3)
+}
+
+// setupDAVRoutes mounts the WebDAV server. It has its own auth middleware because file
+// managers can only do HTTP Basic authentication. Requests are rate limited per IP before
+// their password is checked, and per user after.
+func setupDAVRoutes(router *gin.Engine, davHandler *handlers.DAVHandler, transferHandler *handlers.TransferHandler, rateLimit gin.HandlerFunc, cfg *config.Config, db *gorm.DB) {
+	dav := router.Group(handlers.DAVPrefix)
+	dav.Use(rateLimit, middleware.DAVAuthMiddleware(db, cfg.JWT, cfg.TLS.ClientCertLogin), rateLimit, transferHandler.Throttle)
+	for _, method := range handlers.DAVMethods {
+		dav.Handle(method, "/*path", davHandler.ServeDAV)
+	}
+}
+
+func setupJobRoutes(api *gin.RouterGroup, jobHandler *handlers.JobHandler) {
+	jobs := api.Group("/jobs")
+	{
+		jobs.GET("", jobHandler.ListJobs)
+		jobs.GET("/:id", jobHandler.GetJob)
+		jobs.POST("/:id/cancel", jobHandler.CancelJob)
+	}
+}
+
+// setupEventRoutes mounts the event streams outside the api group, because browsers open
+// them with EventSource, which can only pass the token in the query string. They count
+// against the API's rate limit.
+func setupEventRoutes(router *gin.Engine, eventHandler *handlers.EventHandler, jobHandler *handlers.JobHandler, textHandler *handlers.TextHandler, auth, rateLimit gin.HandlerFunc) {
+	router.GET("/api/events", middleware.QueryTokenMiddleware(), rateLimit, auth, rateLimit, eventHandler.StreamEvents)
+	router.GET("/api/jobs/events", middleware.QueryTokenMiddleware(), rateLimit, auth, rateLimit, jobHandler.StreamJobEvents)
+	router.GET("/api/drivers/text/follow", middleware.QueryTokenMiddleware(), rateLimit, auth, rateLimit, textHandler.FollowText)
+}
+
+func setupPhotoRoutes(api *gin.RouterGroup, photoHandler *handlers.PhotoHandler) {
+	photos := api.Group("/photos")
+	{
+		photos.GET("/timeline", photoHandler.GetTimeline)
+		photos.GET("/metadata", photoHandler.GetMetadata)
+		photos.POST("/index", photoHandler.IndexPhotos)
+	}
+}
+
+func setupMetadataRoutes(api *gin.RouterGroup, metadataHandler *handlers.MetadataHandler) {
+	metadata := api.Group("/drivers/metadata")
+	{
+		metadata.GET("", metadataHandler.GetMetadata)
+		metadata.PUT("", metadataHandler.UpdateMetadata)
+		metadata.DELETE("", metadataHandler.DeleteMetadata)
+		metadata.GET("/search", metadataHandler.SearchMetadata)
+	}
+
+	api.GET("/drivers/tags", metadataHandler.ListTags)
+}
+
+func setupActivityRoutes(api *gin.RouterGroup, activityHandler *handlers.ActivityHandler) {
+	api.GET("/users/me/recent", activityHandler.GetRecent)
+	api.GET("/drivers/activity", activityHandler.GetFolderActivity)
+}
+
+func setupDiskUsageRoutes(api *gin.RouterGroup, diskUsageHandler *handlers.DiskUsageHandler) {
+	api.GET("/drivers/usage", diskUsageHandler.GetDiskUsage)
+}
+
+func setupSyncRoutes(api *gin.RouterGroup, syncHandler *handlers.SyncHandler, transferHandler *handlers.TransferHandler) {
+	sync := api.Group("/sync")
+	{
+		sync.GET("/snapshot", syncHandler.GetSnapshot)
+		sync.GET("/changes", syncHandler.GetChanges)
+		sync.PUT("/file", transferHandler.Throttle, syncHandler.UploadFile)
+		sync.DELETE("/file", syncHandler.DeleteFile)
+		sync.GET("/signature", syncHandler.GetSignature)
+		sync.PUT("/delta", transferHandler.Throttle, syncHandler.UploadDelta)
+	}
+}
+
+func setupTransferRoutes(api *gin.RouterGroup, transferHandler *handlers.TransferHandler) {
+	api.GET("/users/me/transfers", transferHandler.GetStatus)
+}
+
+func setupAdminRoutes(api *gin.RouterGroup, auditHandler *handlers.AuditHandler, transferHandler *handlers.TransferHandler, db *gorm.DB) {
+	admin := api.Group("/admin")
+	admin.Use(middleware.AdminMiddleware(db))
+	{
+		admin.GET("/audit", auditHandler.ListAuditLogs)
+		admin.GET("/users/:id/transfer-limits", transferHandler.GetLimit)
+		admin.PUT("/users/:id/transfer-limits", transferHandler.SetLimit)
+		admin.DELETE("/users/:id/transfer-limits", transferHandler.ResetLimit)
 	}
 }
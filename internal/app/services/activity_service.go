@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/google/uuid"
+)
+
+const (
+	activityQueueSize     = 4096
+	activityBatchSize     = 100
+	activityFlushInterval = 2 * time.Second
+	activityPruneInterval = time.Hour
+
+	// activityRepeatWindow is how long opening or previewing the same file again isn't
+	// recorded, so a video streamed with many range requests is opened once
+	activityRepeatWindow = 10 * time.Minute
+
+	defaultActivityPageSize = 50
+)
+
+// activityTypes maps the audit actions that are recorded as activity to their type
+var activityTypes = map[string]string{
+	entities.AuditActionDownload:       entities.ActivityOpened,
+	entities.AuditActionStream:         entities.ActivityOpened,
+	entities.AuditActionPreview:        entities.ActivityPreviewed,
+	entities.AuditActionUpload:         entities.ActivityUploaded,
+	entities.AuditActionFetch:          entities.ActivityUploaded,
+	entities.AuditActionCreateFolder:   entities.ActivityCreated,
+	entities.AuditActionDelete:         entities.ActivityDeleted,
+	entities.AuditActionMove:           entities.ActivityMoved,
+	entities.AuditActionCopy:           entities.ActivityCopied,
+	entities.AuditActionVersionRestore: entities.ActivityRestored,
+}
+
+type ActivityService interface {
+	// Record adds the activity of a successful audited action of a user, if it is one
+	// that is recorded. It never blocks.
+	Record(entry entities.AuditLog)
+	Recent(ctx context.Context, userID uuid.UUID, req *entities.RecentActivityRequest) ([]entities.Activity, int64, error)
+	Folder(ctx context.Context, req *entities.FolderActivityRequest) ([]entities.Activity, int64, error)
+	Close()
+}
+
+// ActivityServiceImpl derives users' activity from the audit log, so files opened or
+// changed through the API, WebDAV or the S3 gateway are all recorded. Like the audit log,
+// activity is queued in memory and written in batches from a background goroutine, and
+// dropped if the queue is full. Activity older than ACTIVITY_RETENTION_DAYS is deleted
+// every hour.
+type ActivityServiceImpl struct {
+	activityRepo repositories.ActivityRepository
+	cfg          config.ActivityConfig
+	logger       *slog.Logger
+
+	queue     chan entities.Activity
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewActivityService(activityRepo repositories.ActivityRepository, cfg config.ActivityConfig, logger *slog.Logger) ActivityService {
+	s := &ActivityServiceImpl{
+		activityRepo: activityRepo,
+		cfg:          cfg,
+		logger:       logger.With("component", "service.activity"),
+		queue:        make(chan entities.Activity, activityQueueSize),
+		stop:         make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	if cfg.RetentionDays > 0 {
+		s.wg.Add(1)
+		go s.runRetention()
+	}
+	return s
+}
+
+func (s *ActivityServiceImpl) Record(entry entities.AuditLog) {
+	activity, ok := activityOf(entry)
+	if !ok {
+		return
+	}
+
+	select {
+	case <-s.stop:
+	case s.queue <- activity:
+	default:
+		s.logger.Warn("activity queue full, dropping activity", "type", activity.Type, "path", activity.Path)
+	}
+}
+
+// activityOf returns the activity recorded for an audit entry.
+func activityOf(entry entities.AuditLog) (entities.Activity, bool) {
+	typ, ok := activityTypes[entry.Action]
+	if !ok || entry.UserID == nil || entry.Outcome != entities.AuditOutcomeSuccess || entry.Path == "" {
+		return entities.Activity{}, false
+	}
+
+	activity := entities.Activity{
+		UserID:    *entry.UserID,
+		Username:  entry.Username,
+		Type:      typ,
+		Path:      filepath.Clean(entry.Path),
+		Bytes:     entry.Bytes,
+		CreatedAt: entry.CreatedAt,
+	}
+	// Moves and copies are audited as "src -> dst"
+	if src, dst, found := strings.Cut(entry.Path, " -> "); found {
+		activity.Path, activity.OldPath = filepath.Clean(dst), filepath.Clean(src)
+		activity.OldFolder = filepath.Dir(activity.OldPath)
+	}
+	activity.Folder = filepath.Dir(activity.Path)
+	if activity.CreatedAt.IsZero() {
+		activity.CreatedAt = time.Now()
+	}
+	return activity, true
+}
+
+// Close stops accepting activity and writes everything still queued.
+func (s *ActivityServiceImpl) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+}
+
+func (s *ActivityServiceImpl) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(activityFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entities.Activity, 0, activityBatchSize)
+	// When each user last opened or previewed a file
+	lastRead := make(map[string]time.Time)
+
+	add := func(activity entities.Activity) {
+		if activity.Type == entities.ActivityOpened || activity.Type == entities.ActivityPreviewed {
+			key := activity.UserID.String() + "\x00" + activity.Type + "\x00" + activity.Path
+			if last, ok := lastRead[key]; ok && activity.CreatedAt.Sub(last) < activityRepeatWindow {
+				return
+			}
+			lastRead[key] = activity.CreatedAt
+		}
+		batch = append(batch, activity)
+	}
+
+	for {
+		select {
+		case activity := <-s.queue:
+			add(activity)
+			if len(batch) >= activityBatchSize {
+				batch = s.flush(batch)
+			}
+		case now := <-ticker.C:
+			batch = s.flush(batch)
+			for key, last := range lastRead {
+				if now.Sub(last) >= activityRepeatWindow {
+					delete(lastRead, key)
+				}
+			}
+		case <-s.stop:
+			for {
+				select {
+				case activity := <-s.queue:
+					add(activity)
+				default:
+					s.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *ActivityServiceImpl) flush(batch []entities.Activity) []entities.Activity {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := s.activityRepo.CreateBatch(context.Background(), batch); err != nil {
+		s.logger.Error("failed to write activity", "count", len(batch), "error", err)
+	}
+
+	return batch[:0]
+}
+
+func (s *ActivityServiceImpl) runRetention() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(activityPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().AddDate(0, 0, -s.cfg.RetentionDays)
+		if deleted, err := s.activityRepo.DeleteBefore(context.Background(), before); err != nil {
+			s.logger.Error("failed to delete old activity", "error", err)
+		} else if deleted > 0 {
+			s.logger.Info("deleted old activity", "count", deleted, "before", before)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ActivityServiceImpl) Recent(ctx context.Context, userID uuid.UUID, req *entities.RecentActivityRequest) ([]entities.Activity, int64, error) {
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultActivityPageSize
+	}
+
+	activities, total, err := s.activityRepo.List(ctx, repositories.ActivityFilter{
+		UserID: userID,
+		Types:  req.Types,
+		From:   req.From,
+		To:     req.To,
+		Offset: (req.Page - 1) * req.PageSize,
+		Limit:  req.PageSize,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list recent activity: %w", err)
+	}
+	return activities, total, nil
+}
+
+func (s *ActivityServiceImpl) Folder(ctx context.Context, req *entities.FolderActivityRequest) ([]entities.Activity, int64, error) {
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultActivityPageSize
+	}
+	types := req.Types
+	if len(types) == 0 {
+		types = entities.ActivityChangeTypes
+	}
+
+	activities, total, err := s.activityRepo.List(ctx, repositories.ActivityFilter{
+		Types:     types,
+		Folder:    filepath.Clean(req.Path),
+		Recursive: req.Recursive,
+		From:      req.From,
+		To:        req.To,
+		Offset:    (req.Page - 1) * req.PageSize,
+		Limit:     req.PageSize,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list folder activity: %w", err)
+	}
+	return activities, total, nil
+}
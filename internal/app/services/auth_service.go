@@ -1,11 +1,15 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
 	"github.com/RaihanurRahman2022/PersonalVault/internal/helper"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
 )
 
 var (
@@ -15,50 +19,68 @@ var (
 )
 
 type AuthService interface {
-	Login(username, password string) (string, string, error)
-	Register(req *entities.RegisterRequest) error
-	RefreshToken(refreshToken string) (string, string, error)
+	Login(ctx context.Context, username, password string) (string, string, error)
+	Register(ctx context.Context, req *entities.RegisterRequest) error
+	RefreshToken(ctx context.Context, refreshToken string) (string, string, error)
 }
 
 type AuthServiceImpl struct {
-	authRepo repositories.AuthRepository
+	authRepo   repositories.AuthRepository
+	encryption EncryptionService
+	cfg        config.JWTConfig
+	logger     *slog.Logger
 }
 
-func NewAuthService(authRepo repositories.AuthRepository) AuthService {
+func NewAuthService(authRepo repositories.AuthRepository, encryption EncryptionService, cfg config.JWTConfig, logger *slog.Logger) AuthService {
 	return &AuthServiceImpl{
-		authRepo: authRepo,
+		authRepo:   authRepo,
+		encryption: encryption,
+		cfg:        cfg,
+		logger:     logger.With("component", "service.auth"),
 	}
 }
 
-func (r *AuthServiceImpl) Login(username, passwerod string) (string, string, error) {
+func (r *AuthServiceImpl) Login(ctx context.Context, username, passwerod string) (string, string, error) {
 	user, err := r.authRepo.GetUserByUsername(username)
 
 	if err != nil {
+		r.logger.WarnContext(ctx, "login failed: unknown user", "username", username)
+		monitoring.AuthFailures.WithLabelValues("unknown_user").Inc()
 		return "", "", ErrInvalidCredentials
 	}
 
 	if !helper.CheckPassword(passwerod, user.Password) {
+		r.logger.WarnContext(ctx, "login failed: wrong password", "username", username)
+		monitoring.AuthFailures.WithLabelValues("wrong_password").Inc()
 		return "", "", ErrInvalidCredentials
 	}
 
-	accessToken, err := helper.GenerateJWT(user.ID, username)
+	accessToken, err := helper.GenerateJWT(r.cfg, user.ID, username)
 
 	if err != nil {
 		return "", "", err
 	}
 
 	// Generate refresh token (7 days)
-	refreshToken, err := helper.GenerateRefreshToken(user.ID)
+	refreshToken, err := helper.GenerateRefreshToken(r.cfg, user.ID)
 	if err != nil {
 		return "", "", err
 	}
 
+	// Logging in is when the password is at hand, so unlock the user's encrypted folders
+	if err := r.encryption.Unlock(ctx, user.ID, passwerod); err != nil && !errors.Is(err, ErrNoKeyring) {
+		r.logger.WarnContext(ctx, "failed to unlock keyring", "username", username, "error", err)
+	}
+
+	r.logger.InfoContext(ctx, "user logged in", "username", username)
 	return accessToken, refreshToken, nil
 }
 
-func (r *AuthServiceImpl) RefreshToken(refreshToken string) (string, string, error) {
-	claims, err := helper.ValidateRefreshToken(refreshToken)
+func (r *AuthServiceImpl) RefreshToken(ctx context.Context, refreshToken string) (string, string, error) {
+	claims, err := helper.ValidateRefreshToken(r.cfg, refreshToken)
 	if err != nil {
+		r.logger.WarnContext(ctx, "refresh token rejected", "error", err)
+		monitoring.AuthFailures.WithLabelValues("invalid_refresh_token").Inc()
 		return "", "", ErrInvalidToken
 	}
 
@@ -67,14 +89,14 @@ func (r *AuthServiceImpl) RefreshToken(refreshToken string) (string, string, err
 		return "", "", ErrInvalidToken
 	}
 
-	accessToken, err := helper.GenerateJWT(user.ID, user.UserName)
+	accessToken, err := helper.GenerateJWT(r.cfg, user.ID, user.UserName)
 
 	if err != nil {
 		return "", "", err
 	}
 
 	// Generate refresh token (7 days)
-	newRefreshToken, err := helper.GenerateRefreshToken(user.ID)
+	newRefreshToken, err := helper.GenerateRefreshToken(r.cfg, user.ID)
 	if err != nil {
 		return "", "", err
 	}
@@ -82,7 +104,7 @@ func (r *AuthServiceImpl) RefreshToken(refreshToken string) (string, string, err
 	return accessToken, newRefreshToken, nil
 }
 
-func (r *AuthServiceImpl) Register(req *entities.RegisterRequest) error {
+func (r *AuthServiceImpl) Register(ctx context.Context, req *entities.RegisterRequest) error {
 	hashedPassword, err := helper.HashPassword(req.Password)
 	if err != nil {
 		return err
@@ -95,5 +117,11 @@ func (r *AuthServiceImpl) Register(req *entities.RegisterRequest) error {
 		LastName:  req.LastName,
 	}
 
-	return r.authRepo.Create(user)
+	if err := r.authRepo.Create(user); err != nil {
+		r.logger.ErrorContext(ctx, "failed to register user", "username", req.Username, "error", err)
+		return err
+	}
+
+	r.logger.InfoContext(ctx, "user registered", "username", req.Username)
+	return nil
 }
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+)
+
+const (
+	defaultDiskUsageLimit = 20
+	// maxDiskUsageLimit is the most subfolders and files a report lists, and so how many
+	// of each folder's own largest files are cached
+	maxDiskUsageLimit = 100
+
+	// maxStaleFolders is how many changed folders are remembered between scans; past it,
+	// the whole cache is dropped
+	maxStaleFolders = 10000
+)
+
+type DiskUsageService interface {
+	Usage(ctx context.Context, req *entities.DiskUsageRequest) (*entities.DiskUsage, error)
+}
+
+// DiskUsageServiceImpl computes the size of folder trees like du. Folders are listed in
+// parallel, by at most DISK_USAGE_WORKERS goroutines shared by every scan, and a scan stops
+// as soon as its context is done.
+//
+// The listing of every folder scanned is cached, so asking again (or about a subfolder)
+// only needs a stat of each folder. A cached listing is used while the folder's
+// modification time is unchanged, no change to it was published (by the vault or the file
+// watcher) and it is younger than DISK_USAGE_CACHE_TTL, which catches the changes neither
+// shows, like a file appended to in place. Folders finished before a scan is cancelled
+// stay cached, so the next scan picks up where it stopped.
+//
+// Folders are walked by their disk paths, so encrypted folders are counted while locked.
+type DiskUsageServiceImpl struct {
+	DriverRepo repositories.DriverRepository
+	Encryption EncryptionService
+	cfg        config.DiskUsageConfig
+	logger     *slog.Logger
+
+	workers chan struct{}
+
+	mu       sync.Mutex
+	nodes    map[string]*duNode  // disk path -> cached listing
+	stale    map[string]struct{} // folders changed since the last scan
+	staleAll bool
+}
+
+// duNode is the cached listing of one folder, along with the totals of the tree below it
+// as of the last scan that went through it.
+type duNode struct {
+	modTime  time.Time
+	listedAt time.Time
+	usedAt   time.Time
+
+	subfolders []string
+	fileCount  int64
+	fileBytes  int64
+	largest    []duFile // the folder's own largest files, largest first
+
+	size       int64
+	files      int64
+	folders    int64
+	unreadable int64
+}
+
+type duFile struct {
+	name     string
+	size     int64
+	modified time.Time
+}
+
+// duScan is the state of one request's walk
+type duScan struct {
+	ctx     context.Context
+	refresh bool
+	now     time.Time
+	listed  atomic.Int64
+}
+
+func NewDiskUsageService(driverRepo repositories.DriverRepository, encryption EncryptionService, events EventService, cfg config.DiskUsageConfig, logger *slog.Logger) DiskUsageService {
+	s := &DiskUsageServiceImpl{
+		DriverRepo: driverRepo,
+		Encryption: encryption,
+		cfg:        cfg,
+		logger:     logger.With("component", "service.disk_usage"),
+		workers:    make(chan struct{}, cfg.Workers),
+		nodes:      make(map[string]*duNode),
+		stale:      make(map[string]struct{}),
+	}
+
+	events.Observe(s.invalidate)
+	return s
+}
+
+// invalidate remembers the folders a change touched. Published events carry the paths
+// clients see, which are only turned into disk paths by the next scan.
+func (s *DiskUsageServiceImpl) invalidate(event entities.ChangeEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.staleAll {
+		return
+	}
+	// Nobody knows what changed
+	if event.Type == entities.ChangeResync && event.Path == "" {
+		s.stale = make(map[string]struct{})
+		s.staleAll = true
+		return
+	}
+
+	for _, path := range []string{event.Path, event.OldPath} {
+		if path == "" {
+			continue
+		}
+		s.stale[path] = struct{}{}
+		s.stale[filepath.Dir(path)] = struct{}{}
+	}
+	if len(s.stale) > maxStaleFolders {
+		s.stale = make(map[string]struct{})
+		s.staleAll = true
+	}
+}
+
+// dropStale forgets the listings of the folders changed since the last scan.
+func (s *DiskUsageServiceImpl) dropStale(ctx context.Context) {
+	s.mu.Lock()
+	stale := s.stale
+	s.stale = make(map[string]struct{})
+	if s.staleAll {
+		s.nodes = make(map[string]*duNode)
+		s.staleAll = false
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	for path := range stale {
+		// Folders of a locked encrypted folder can't be found; their modification times
+		// changed along with them anyway
+		diskPath, err := s.Encryption.DiskPath(ctx, path)
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		delete(s.nodes, diskPath)
+		s.mu.Unlock()
+	}
+}
+
+func (s *DiskUsageServiceImpl) Usage(ctx context.Context, req *entities.DiskUsageRequest) (*entities.DiskUsage, error) {
+	if req.Limit == 0 {
+		req.Limit = defaultDiskUsageLimit
+	}
+	path := filepath.Clean(req.Path)
+
+	diskPath, err := s.Encryption.DiskPath(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk usage of %s: %w", path, err)
+	}
+	info, err := s.DriverRepo.Stat(ctx, diskPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk usage of %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrNotDirectory, path)
+	}
+
+	s.dropStale(ctx)
+
+	scan := &duScan{ctx: ctx, refresh: req.Refresh, now: time.Now()}
+	root, err := s.walk(scan, diskPath, info.ModTime())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk usage of %s: %w", path, err)
+	}
+
+	usage := &entities.DiskUsage{
+		Path:           path,
+		Size:           root.size,
+		Files:          root.files,
+		Folders:        root.folders,
+		Unreadable:     root.unreadable,
+		Scanned:        scan.listed.Load(),
+		LargestFolders: s.largestFolders(ctx, diskPath, root, req.Limit),
+		LargestFiles:   s.largestFiles(ctx, diskPath, root, req.Limit),
+	}
+
+	s.evict()
+	s.logger.DebugContext(ctx, "computed disk usage", "path", path, "size", usage.Size, "scanned", usage.Scanned, "duration", time.Since(scan.now))
+	return usage, nil
+}
+
+// walk returns the node of the folder at dir with the totals of its tree, listing it only
+// if its cached listing can't be used. modTime is the folder's modification time, or zero
+// if it must be looked up.
+func (s *DiskUsageServiceImpl) walk(scan *duScan, dir string, modTime time.Time) (*duNode, error) {
+	if err := scan.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if modTime.IsZero() {
+		info, err := s.DriverRepo.Stat(scan.ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%w: %s", ErrNotDirectory, dir)
+		}
+		modTime = info.ModTime()
+	}
+
+	s.mu.Lock()
+	cached := s.nodes[dir]
+	s.mu.Unlock()
+
+	node := &duNode{modTime: modTime, usedAt: scan.now}
+	// Subfolders' modification times, known when the folder is listed
+	childTimes := make(map[string]time.Time)
+
+	if cached != nil && !scan.refresh && cached.modTime.Equal(modTime) && scan.now.Sub(cached.listedAt) < s.cfg.CacheTTL {
+		node.listedAt = cached.listedAt
+		node.subfolders = cached.subfolders
+		node.fileCount, node.fileBytes = cached.fileCount, cached.fileBytes
+		node.largest = cached.largest
+	} else {
+		files, err := s.DriverRepo.ListPath(scan.ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		scan.listed.Add(1)
+
+		node.listedAt = scan.now
+		for _, file := range files {
+			if file.Type == "folder" {
+				node.subfolders = append(node.subfolders, file.Name)
+				childTimes[file.Name] = file.Modified
+				continue
+			}
+			node.fileCount++
+			node.fileBytes += file.Size
+			node.largest = addLargest(node.largest, duFile{name: file.Name, size: file.Size, modified: file.Modified}, maxDiskUsageLimit)
+		}
+	}
+
+	children := make([]*duNode, len(node.subfolders))
+	errs := make([]error, len(node.subfolders))
+	var wg sync.WaitGroup
+	for i, name := range node.subfolders {
+		walkChild := func() {
+			children[i], errs[i] = s.walk(scan, filepath.Join(dir, name), childTimes[name])
+		}
+		// Hand the subfolder to another goroutine if a worker is free, else walk it here
+		select {
+		case s.workers <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-s.workers }()
+				walkChild()
+			}()
+		default:
+			walkChild()
+		}
+	}
+	wg.Wait()
+
+	node.size, node.files = node.fileBytes, node.fileCount
+	for i, child := range children {
+		if err := errs[i]; err != nil {
+			if ctxErr := scan.ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			childPath := filepath.Join(dir, node.subfolders[i])
+			s.mu.Lock()
+			delete(s.nodes, childPath)
+			s.mu.Unlock()
+			// Removed since the folder was listed
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			s.logger.DebugContext(scan.ctx, "failed to read folder", "path", childPath, "error", err)
+			node.unreadable++
+			continue
+		}
+		node.size += child.size
+		node.files += child.files
+		node.folders += 1 + child.folders
+		node.unreadable += child.unreadable
+	}
+
+	s.mu.Lock()
+	s.nodes[dir] = node
+	s.mu.Unlock()
+	return node, nil
+}
+
+// addLargest inserts file into largest, keeping the limit largest files, largest first.
+func addLargest(largest []duFile, file duFile, limit int) []duFile {
+	if len(largest) == limit && file.size <= largest[limit-1].size {
+		return largest
+	}
+	i := sort.Search(len(largest), func(i int) bool { return largest[i].size < file.size })
+	if len(largest) < limit {
+		largest = append(largest, duFile{})
+	}
+	copy(largest[i+1:], largest[i:])
+	largest[i] = file
+	return largest
+}
+
+func (s *DiskUsageServiceImpl) largestFolders(ctx context.Context, dir string, root *duNode, limit int) []entities.DiskUsageEntry {
+	folders := make([]entities.DiskUsageEntry, 0, len(root.subfolders))
+	s.mu.Lock()
+	for _, name := range root.subfolders {
+		diskPath := filepath.Join(dir, name)
+		// Unreadable folders have no node
+		node := s.nodes[diskPath]
+		if node == nil {
+			continue
+		}
+		folders = append(folders, entities.DiskUsageEntry{
+			Path:     diskPath,
+			Size:     node.size,
+			Files:    node.files,
+			Folders:  node.folders,
+			Modified: node.modTime,
+		})
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(folders, func(i, j int) bool { return folders[i].Size > folders[j].Size })
+	if len(folders) > limit {
+		folders = folders[:limit]
+	}
+	for i := range folders {
+		folders[i].Path = s.Encryption.PlainPath(ctx, folders[i].Path)
+	}
+	return folders
+}
+
+// largestFiles merges the largest files of every folder in the tree.
+func (s *DiskUsageServiceImpl) largestFiles(ctx context.Context, dir string, root *duNode, limit int) []entities.DiskUsageEntry {
+	var largest []duFile
+	type pending struct {
+		path string
+		node *duNode
+	}
+	queue := []pending{{path: dir, node: root}}
+
+	s.mu.Lock()
+	for len(queue) > 0 {
+		folder := queue[0]
+		queue = queue[1:]
+		for _, file := range folder.node.largest {
+			if len(largest) == limit && file.size <= largest[limit-1].size {
+				break
+			}
+			file.name = filepath.Join(folder.path, file.name)
+			largest = addLargest(largest, file, limit)
+		}
+		for _, name := range folder.node.subfolders {
+			path := filepath.Join(folder.path, name)
+			if node := s.nodes[path]; node != nil {
+				queue = append(queue, pending{path: path, node: node})
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	files := make([]entities.DiskUsageEntry, len(largest))
+	for i, file := range largest {
+		files[i] = entities.DiskUsageEntry{
+			Path:     s.Encryption.PlainPath(ctx, file.name),
+			Size:     file.size,
+			Modified: file.modified,
+		}
+	}
+	return files
+}
+
+// evict drops the least recently used listings past DISK_USAGE_CACHE_MAX_FOLDERS.
+func (s *DiskUsageServiceImpl) evict() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	excess := len(s.nodes) - s.cfg.CacheMaxFolders
+	if excess <= 0 {
+		return
+	}
+
+	paths := make([]string, 0, len(s.nodes))
+	for path := range s.nodes {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return s.nodes[paths[i]].usedAt.Before(s.nodes[paths[j]].usedAt) })
+	for _, path := range paths[:excess] {
+		delete(s.nodes, path)
+	}
+	s.logger.Debug("evicted cached folder listings", "count", excess)
+}
@@ -2,8 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
+	"log/slog"
 	"mime"
 	"mime/multipart"
 	"os"
@@ -12,49 +15,83 @@ import (
 	"sync"
 
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/crypt"
 
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
 )
 
 const previewRangeThreshold = 10 * 1024 * 1024
 
+// Errors callers outside the service layer need to tell apart
+var (
+	ErrPathNotAllowed = repositories.ErrPathNotAllowed
+	ErrFileExists     = repositories.ErrFileExists
+	ErrNotDirectory   = repositories.ErrNotDirectory
+	ErrDirNotEmpty    = repositories.ErrDirNotEmpty
+)
+
 type DriverService interface {
-	GetRoot() ([]entities.RootItems, error)
+	GetRoot(ctx context.Context) ([]entities.RootItems, error)
 	ListPath(ctx context.Context, path string) ([]entities.FileInfo, error)
-	Downloadfile(path string) (string, string, error)
-	CreateFolder(path string) error
-
-	PreviewFile(path string) (*entities.PreviewInfo, error)
-	StreamFile(path string) (*entities.PreviewInfo, error)
-	UploadFiles(destPath string, files []*multipart.FileHeader, overwrite bool) ([]entities.UploadResult, error)
-	UploadFolder(destPath string, files []*multipart.FileHeader, overwrite bool) ([]entities.UploadResult, error)
+	Downloadfile(ctx context.Context, path string) (*entities.PreviewInfo, string, error)
+	CreateFolder(ctx context.Context, path string) error
+
+	PreviewFile(ctx context.Context, path string) (*entities.PreviewInfo, error)
+	StreamFile(ctx context.Context, path string) (*entities.PreviewInfo, error)
+	UploadFiles(ctx context.Context, actor entities.Actor, destPath string, files []*multipart.FileHeader, mode entities.OverwriteMode, progress *JobProgress) ([]entities.UploadResult, error)
+	UploadFolder(ctx context.Context, actor entities.Actor, destPath string, files []*multipart.FileHeader, mode entities.OverwriteMode, progress *JobProgress) ([]entities.UploadResult, error)
+
+	SaveFile(ctx context.Context, actor entities.Actor, dst string, src io.Reader, mode entities.OverwriteMode) (entities.StoredFile, error)
+	Stat(ctx context.Context, path string) (os.FileInfo, error)
+	Delete(ctx context.Context, path string) error
+	DeleteEmptyFolder(ctx context.Context, path string) error
+	Move(ctx context.Context, src, dst string) error
+	MoveAll(ctx context.Context, paths []string, destination string, progress *JobProgress) ([]entities.MoveResult, error)
 }
 
+// DriverServiceImpl passes every path through the encryption service, so files in
+// encrypted folders are sealed on write, decrypted on read and listed under their
+// plaintext names and sizes. Every change it makes is published as a change event.
 type DriverServiceImpl struct {
-	DriverRepo repositories.DriverRepository
+	DriverRepo   repositories.DriverRepository
+	Versions     VersionService
+	Encryption   EncryptionService
+	Events       EventService
+	Photos       PhotoService
+	Metadata     MetadataService
+	uploadConfig config.UploadConfig
+	logger       *slog.Logger
 }
 
-func NewDriverService(DriverRepo repositories.DriverRepository) DriverService {
+func NewDriverService(DriverRepo repositories.DriverRepository, versions VersionService, encryption EncryptionService, events EventService, photos PhotoService, metadata MetadataService, uploadConfig config.UploadConfig, logger *slog.Logger) DriverService {
 	return &DriverServiceImpl{
-		DriverRepo: DriverRepo,
+		DriverRepo:   DriverRepo,
+		Versions:     versions,
+		Encryption:   encryption,
+		Events:       events,
+		Photos:       photos,
+		Metadata:     metadata,
+		uploadConfig: uploadConfig,
+		logger:       logger.With("component", "service.driver"),
 	}
 }
 
-func (r *DriverServiceImpl) GetRoot() ([]entities.RootItems, error) {
-	log.Println("Service: Getting root directories")
-	roots, err := r.DriverRepo.GetRoots()
+func (r *DriverServiceImpl) GetRoot(ctx context.Context) ([]entities.RootItems, error) {
+	roots, err := r.DriverRepo.GetRoots(ctx)
 	if err != nil {
-		log.Printf("Service: Error getting root directories: %v", err)
+		r.logger.ErrorContext(ctx, "failed to get root directories", "error", err)
 		return nil, fmt.Errorf("failed to get root directories: %w", err)
 	}
 	if len(roots) == 0 {
-		log.Println("Service: No root directories found")
+		r.logger.WarnContext(ctx, "no root directories found")
 		return []entities.RootItems{}, nil // Return empty slice instead of error
 	}
 
 	var rootItems []entities.RootItems
 	for _, path := range roots {
-		info, err := os.Stat(path)
+		info, err := r.DriverRepo.Stat(ctx, path)
 		if err != nil || !info.IsDir() {
 			continue
 		}
@@ -71,14 +108,12 @@ func (r *DriverServiceImpl) GetRoot() ([]entities.RootItems, error) {
 			Modified: info.ModTime(),
 		})
 	}
-	log.Printf("Service: Successfully processed %d root items", len(rootItems))
+	r.logger.DebugContext(ctx, "processed root items", "count", len(rootItems))
 	return rootItems, nil
 }
 
 // Example of using context to cancel the operation
 func (r *DriverServiceImpl) ListPath(ctx context.Context, path string) ([]entities.FileInfo, error) {
-	log.Printf("Service: Listing contents of path: %s", path)
-
 	// Check if context is cancelled before proceeding
 	select {
 	case <-ctx.Done():
@@ -86,46 +121,56 @@ func (r *DriverServiceImpl) ListPath(ctx context.Context, path string) ([]entiti
 	default:
 	}
 
-	files, err := r.DriverRepo.ListPath(ctx, path)
+	diskPath, err := r.Encryption.DiskPath(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contents of path: %w", err)
+	}
+
+	files, err := r.DriverRepo.ListPath(ctx, diskPath)
 	if err != nil {
-		log.Printf("Service: Error listing contents of path: %v", err)
+		r.logger.WarnContext(ctx, "failed to list path", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to list contents of path: %w", err)
 	}
 
-	log.Printf("Service: Successfully processed %d files in directory %s", len(files), path)
-	return files, nil
+	return r.Encryption.PlainListing(ctx, path, files), nil
 }
 
-func (r *DriverServiceImpl) Downloadfile(path string) (string, string, error) {
-	log.Printf("Service: Downloading file from path: %s", path)
-	absPath, err := r.DriverRepo.Downloadfile(path)
+func (r *DriverServiceImpl) Downloadfile(ctx context.Context, path string) (*entities.PreviewInfo, string, error) {
+	file, fileInfo, absPath, err := r.openFile(ctx, path)
 	if err != nil {
-		log.Printf("Service: Error downloading file: %v", err)
-		return "", "", fmt.Errorf("failed to download file: %w", err)
+		r.logger.WarnContext(ctx, "failed to download file", "path", path, "error", err)
+		return nil, "", fmt.Errorf("failed to download file: %w", err)
 	}
 
 	filename := filepath.Base(absPath)
-	return absPath, filename, nil
+	return &entities.PreviewInfo{
+		File:           file,
+		Info:           fileInfo,
+		AbsPath:        absPath,
+		MimeType:       detectMimeType(absPath),
+		ShouldUseRange: true,
+	}, filename, nil
 }
 
-func (r *DriverServiceImpl) CreateFolder(path string) error {
-	log.Printf("Service: Creating folder at path: %s", path)
-	err := r.DriverRepo.CreateFolder(path)
+func (r *DriverServiceImpl) CreateFolder(ctx context.Context, path string) error {
+	diskPath, err := r.Encryption.DiskPath(ctx, path)
+	if err == nil {
+		err = r.DriverRepo.CreateFolder(ctx, diskPath)
+	}
 	if err != nil {
-		log.Printf("Service: Error creating folder: %v", err)
+		r.logger.WarnContext(ctx, "failed to create folder", "path", path, "error", err)
 		return fmt.Errorf("failed to create folder: %w", err)
 	}
-	log.Printf("Service: Successfully created folder at path: %s", path)
+	r.Events.Publish(entities.ChangeEvent{Type: entities.ChangeCreate, Path: path, IsDir: true})
+	r.logger.InfoContext(ctx, "created folder", "path", path)
 	return nil
 }
 
-func (r *DriverServiceImpl) PreviewFile(path string) (*entities.PreviewInfo, error) {
-	log.Printf("Service: Previewing file at path: %s", path)
-
-	file, fileInfo, absPath, err := r.DriverRepo.OpenFile(path)
+func (r *DriverServiceImpl) PreviewFile(ctx context.Context, path string) (*entities.PreviewInfo, error) {
+	file, fileInfo, absPath, err := r.openFile(ctx, path)
 
 	if err != nil {
-		log.Printf("Service: Error opening file: %v", err)
+		r.logger.WarnContext(ctx, "failed to open file for preview", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 
@@ -142,13 +187,11 @@ func (r *DriverServiceImpl) PreviewFile(path string) (*entities.PreviewInfo, err
 	}, nil
 }
 
-func (r *DriverServiceImpl) StreamFile(path string) (*entities.PreviewInfo, error) {
-	log.Printf("Service: Streaming file at path: %s", path)
-
-	file, fileInfo, absPath, err := r.DriverRepo.OpenFile(path)
+func (r *DriverServiceImpl) StreamFile(ctx context.Context, path string) (*entities.PreviewInfo, error) {
+	file, fileInfo, absPath, err := r.openFile(ctx, path)
 
 	if err != nil {
-		log.Printf("Service: Error opening file: %v", err)
+		r.logger.WarnContext(ctx, "failed to open file for streaming", "path", path, "error", err)
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 
@@ -162,6 +205,36 @@ func (r *DriverServiceImpl) StreamFile(path string) (*entities.PreviewInfo, erro
 	}, nil
 }
 
+// openFile opens the file at path for reading, decrypting it if it is in an encrypted
+// folder. The returned info and absolute path are the plaintext ones.
+func (r *DriverServiceImpl) openFile(ctx context.Context, path string) (io.ReadSeekCloser, os.FileInfo, string, error) {
+	diskPath, err := r.Encryption.DiskPath(ctx, path)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	file, info, absPath, err := r.DriverRepo.OpenFile(ctx, diskPath)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	plain, plainInfo, err := r.Encryption.Open(ctx, path, file, info)
+	if err != nil {
+		file.Close()
+		return nil, nil, "", err
+	}
+	return plain, plainInfo, r.Encryption.PlainPath(ctx, absPath), nil
+}
+
+// ensureDir creates the folder at path if it doesn't exist yet.
+func (r *DriverServiceImpl) ensureDir(ctx context.Context, path string) error {
+	diskPath, err := r.Encryption.DiskPath(ctx, path)
+	if err != nil {
+		return err
+	}
+	return r.DriverRepo.EnsureDirExists(ctx, diskPath)
+}
+
 func detectMimeType(absPath string) string {
 	ext := strings.ToLower(filepath.Ext(absPath))
 	if ct := mime.TypeByExtension(ext); ct != "" {
@@ -170,60 +243,46 @@ func detectMimeType(absPath string) string {
 	return "applicaton/octet-stream"
 }
 
-// UploadFiles uploads a list of individual files to the destination path using concurrent goroutines.
+// UploadFiles uploads a list of individual files to the destination path.
 //
-// ⚠️ Improvements / Considerations:
-// 1. Currently it launches one goroutine per file without limit. For a large number of files,
-//    this may consume a lot of memory and CPU. Consider using a worker pool or limiting concurrency.
-// 2. No context is used. If the client cancels the request (e.g., closes the browser tab), the uploads
-//    will continue in the background. Using `context.Context` would allow graceful cancellation.
-// 3. Logging is minimal. Consider adding per-file logging for better observability in production.
-// 4. Error handling is per-file and collected in the slice. This is fine, but you could also use
-//    `errgroup` if you want to stop all uploads on first error.
+// Files are written by a bounded pool of workers (see uploadWithWorkers), so a request with
+// thousands of files doesn't spawn thousands of goroutines. Results are returned in the same
+// order as `files`. Cancelling ctx (client disconnect or server shutdown) stops the copy of
+// the files in progress, removes their partial output, and fails the files not started yet.
 //
-// Important parts for new developers:
-// - `wg.Wait()` ensures the main goroutine waits for all upload goroutines to finish.
-// - Writing results into `result[index]` is safe because each index is unique per goroutine.
-
-func (r *DriverServiceImpl) UploadFiles(destPath string, files []*multipart.FileHeader, overwrite bool) ([]entities.UploadResult, error) {
-	log.Printf("Service: Uploading %d files to path: %s", len(files), destPath)
+// Each file is written atomically (temp file + rename). mode decides what happens when a
+// file with the same name exists; with keep_both, UploadResult.Path holds the new name, and
+// with replace the old content is kept as a previous version.
+//
+// Progress of each file is reported to progress, which is nil unless the upload runs as a
+// job.
+//
+// Only returns an error if ALL files failed; partial failures are reported per file.
+func (r *DriverServiceImpl) UploadFiles(ctx context.Context, actor entities.Actor, destPath string, files []*multipart.FileHeader, mode entities.OverwriteMode, progress *JobProgress) ([]entities.UploadResult, error) {
+	r.logger.InfoContext(ctx, "uploading files", "count", len(files), "dest", destPath)
 
-	log.Printf("Service: Ensuring directory exists: %s", destPath)
-	if err := r.DriverRepo.EnsureDirExists(destPath); err != nil {
-		log.Printf("Service: Error ensuring directory exists: %v", err)
+	if err := r.ensureDir(ctx, destPath); err != nil {
+		r.logger.WarnContext(ctx, "failed to ensure upload directory exists", "dest", destPath, "error", err)
 		return nil, fmt.Errorf("failed to ensure directory exists: %w", err)
 	}
-	log.Printf("Service: Directory exists or was created successfully")
-
-	var wg sync.WaitGroup
-	result := make([]entities.UploadResult, len(files))
 
-	for i, file := range files {
-		wg.Add(1)
-		go func(index int, fh *multipart.FileHeader) {
-			defer wg.Done()
-
-			log.Printf("Service: Processing file %d: %s", index, fh.Filename)
-			res := entities.UploadResult{Name: fh.Filename}
-			dst := filepath.Join(destPath, fh.Filename)
-			log.Printf("Service: Destination path for file %d: %s", index, dst)
-
-			written, err := r.DriverRepo.SaveUploadedFile(fh, dst, overwrite)
-			if err != nil {
-				log.Printf("Service: Error saving file %d: %v", index, err)
-				res.Error = err.Error()
-			} else {
-				log.Printf("Service: Successfully saved file %d, written %d bytes", index, written)
-				res.Path = dst
-				res.Size = written
-			}
-			// Writing directly to the slice by index is safe because each goroutine has a unique index
-			result[index] = res
+	result := r.uploadWithWorkers(ctx, files, progress, func(index int, fh *multipart.FileHeader) entities.UploadResult {
+		res := entities.UploadResult{Name: fh.Filename}
+		dst := filepath.Join(destPath, fh.Filename)
 
-		}(i, file)
-	}
+		stored, err := r.saveUploadedFile(ctx, actor, fh, dst, mode, progress, index)
+		if err != nil {
+			r.logger.WarnContext(ctx, "failed to save uploaded file", "path", dst, "error", err)
+			monitoring.UploadFailures.WithLabelValues(monitoring.UploadFailureReason(err)).Inc()
+			res.Error = err.Error()
+			return res
+		}
 
-	wg.Wait() // wait until all files processed
+		r.logger.DebugContext(ctx, "saved uploaded file", "path", stored.Path, "bytes", stored.Size)
+		res.Path = stored.Path
+		res.Size = stored.Size
+		return res
+	})
 
 	// Count successful and failed uploads
 	successCount := 0
@@ -236,7 +295,7 @@ func (r *DriverServiceImpl) UploadFiles(destPath string, files []*multipart.File
 		}
 	}
 
-	log.Printf("Service: Upload completed - %d successful, %d failed", successCount, failedCount)
+	r.logger.InfoContext(ctx, "upload completed", "dest", destPath, "succeeded", successCount, "failed", failedCount)
 
 	// Return results even if some files failed
 	// Only return error if ALL files failed
@@ -247,89 +306,339 @@ func (r *DriverServiceImpl) UploadFiles(destPath string, files []*multipart.File
 	return result, nil
 }
 
-// UploadFolder uploads a folder containing multiple files using a worker pool pattern.
-//
-// ⚠️ Improvements / Considerations:
-// 1. Currently, the jobs channel is buffered with `len(files)`. For huge folders (e.g., 100k files),
-//    this may consume a lot of memory. Consider a smaller buffer and push jobs gradually.
-// 2. No context is used. If the client disconnects, the uploads continue. Adding `context.Context`
-//    allows graceful shutdown of ongoing uploads.
-// 3. Worker count is hardcoded to 5. In production, this should be configurable based on CPU/network IO.
-// 4. Results are collected in the order they complete, not necessarily the order of `files`. If order matters,
-//    additional logic is needed.
-//
-// Important parts for new developers:
-// - Worker pool: fixed number of workers consume jobs from the channel.
-// - Each worker ensures parent directories exist before saving files.
-// - Channels `jobs` and `result` are used for synchronization between main goroutine and workers.
+// UploadFolder uploads a folder containing multiple files. Filenames may contain a relative
+// path (e.g. "folder/subfolder/file.txt"), and each worker creates the parent directories
+// before saving. Concurrency, ordering and cancellation behave as in UploadFiles.
+func (r *DriverServiceImpl) UploadFolder(ctx context.Context, actor entities.Actor, destPath string, files []*multipart.FileHeader, mode entities.OverwriteMode, progress *JobProgress) ([]entities.UploadResult, error) {
+	r.logger.InfoContext(ctx, "uploading folder", "count", len(files), "dest", destPath)
 
-func (r *DriverServiceImpl) UploadFolder(destPath string, files []*multipart.FileHeader, overwrite bool) ([]entities.UploadResult, error) {
-	if err := r.DriverRepo.EnsureDirExists(destPath); err != nil {
+	if err := r.ensureDir(ctx, destPath); err != nil {
 		return nil, fmt.Errorf("invalid destination: %w", err)
 	}
 
-	const workerCount = 5
+	uploadedResult := r.uploadWithWorkers(ctx, files, progress, func(index int, fh *multipart.FileHeader) entities.UploadResult {
+		res := entities.UploadResult{Name: fh.Filename}
 
-	jobs := make(chan *multipart.FileHeader, len(files))   // ⚠️ Consider smaller buffer for huge folders
-	result := make(chan entities.UploadResult, len(files)) // ⚠️ Consider smaller buffer for huge folders
+		// For folder uploads, the filename might contain relative path
+		// e.g., "folder/subfolder/file.txt"
+		fullPath := filepath.Join(destPath, fh.Filename)
 
-	for range workerCount {
+		// Ensure parent directories exist
+		parentDir := filepath.Dir(fullPath)
+		if err := r.ensureDir(ctx, parentDir); err != nil {
+			monitoring.UploadFailures.WithLabelValues(monitoring.UploadFailureReason(err)).Inc()
+			res.Error = fmt.Sprintf("failed to create directory: %v", err)
+			return res
+		}
 
-		go func() {
-			for fh := range jobs {
-				res := entities.UploadResult{Name: fh.Filename}
+		stored, err := r.saveUploadedFile(ctx, actor, fh, fullPath, mode, progress, index)
+		if err != nil {
+			r.logger.WarnContext(ctx, "failed to save uploaded file", "path", fullPath, "error", err)
+			monitoring.UploadFailures.WithLabelValues(monitoring.UploadFailureReason(err)).Inc()
+			res.Error = err.Error()
+			return res
+		}
+
+		res.Path = stored.Path
+		res.Size = stored.Size
+		return res
+	})
+
+	// Check if all failed
+	allFailed := true
+	for _, r := range uploadedResult {
+		if r.Error == "" {
+			allFailed = false
+			break
+		}
+	}
+	if allFailed {
+		r.logger.WarnContext(ctx, "all folder uploads failed", "dest", destPath)
+		return uploadedResult, fmt.Errorf("all uploads failed")
+	}
+	return uploadedResult, nil
+}
+
+func (r *DriverServiceImpl) saveUploadedFile(ctx context.Context, actor entities.Actor, fh *multipart.FileHeader, dst string, mode entities.OverwriteMode, progress *JobProgress, index int) (entities.StoredFile, error) {
+	src, err := fh.Open()
+	if err != nil {
+		return entities.StoredFile{}, err
+	}
+	defer src.Close()
 
-				// Debug: log the filename received from frontend
-				log.Printf("Backend received file: %s", fh.Filename)
+	return r.SaveFile(ctx, actor, dst, progress.Reader(index, src), mode)
+}
 
-				// For folder uploads, the filename might contain relative path
-				// e.g., "folder/subfolder/file.txt"
-				fullPath := filepath.Join(destPath, fh.Filename)
+// SaveFile atomically writes src to dst. With OverwriteReplace the file being replaced is
+// archived as a previous version first; the write is refused if the old content can't be
+// kept, rather than losing it.
+//
+// In an encrypted folder the content is sealed on the way to disk. The returned path and
+// size are the plaintext ones, while versions record the file as stored.
+func (r *DriverServiceImpl) SaveFile(ctx context.Context, actor entities.Actor, dst string, src io.Reader, mode entities.OverwriteMode) (entities.StoredFile, error) {
+	folder := r.Encryption.FolderOf(ctx, dst)
+
+	// Numbered names would be appended to the encrypted name, so pick a free plaintext
+	// name here instead
+	if mode == entities.OverwriteKeepBoth && folder != nil && folder.EncryptNames {
+		free, err := r.freeName(ctx, dst)
+		if err != nil {
+			return entities.StoredFile{}, err
+		}
+		dst, mode = free, entities.OverwriteNever
+	}
 
-				log.Printf("Backend received file: %s", fullPath)
+	diskDst, err := r.Encryption.DiskPath(ctx, dst)
+	if err != nil {
+		return entities.StoredFile{}, err
+	}
+	sealed, err := r.Encryption.Seal(ctx, dst, src)
+	if err != nil {
+		return entities.StoredFile{}, err
+	}
 
-				// Ensure parent directories exist
-				parentDir := filepath.Dir(fullPath)
-				if err := r.DriverRepo.EnsureDirExists(parentDir); err != nil {
-					res.Error = fmt.Sprintf("failed to create directory: %v", err)
-					result <- res
-					continue
-				}
+	change := entities.ChangeCreate
+	if mode == entities.OverwriteReplace {
+		if err := r.Versions.Archive(ctx, actor, diskDst); err != nil {
+			return entities.StoredFile{}, err
+		}
+		if _, err := r.DriverRepo.Stat(ctx, diskDst); err == nil {
+			change = entities.ChangeModify
+		}
+	}
 
-				written, err := r.DriverRepo.SaveUploadedFile(fh, fullPath, overwrite)
-				if err != nil {
-					res.Error = err.Error()
-				} else {
-					res.Path = fullPath
-					res.Size = written
+	stored, err := r.DriverRepo.SaveFile(ctx, diskDst, sealed, mode)
+	if err != nil {
+		return entities.StoredFile{}, err
+	}
+
+	r.Versions.RecordWrite(ctx, actor, stored)
+
+	stored.Path = r.Encryption.PlainPath(ctx, stored.Path)
+	if folder != nil {
+		stored.Size = crypt.PlainSize(stored.Size)
+	}
+	r.Events.Publish(entities.ChangeEvent{Type: change, Path: stored.Path})
+	r.Photos.FileChanged(stored.Path)
+	if change == entities.ChangeModify {
+		r.Metadata.FileReplaced(ctx, stored.Path)
+	}
+	monitoring.BytesUploaded.Add(float64(stored.Size))
+	return stored, nil
+}
+
+// freeName returns dst, or the first of "name (1).ext", "name (2).ext"... that doesn't
+// exist yet.
+func (r *DriverServiceImpl) freeName(ctx context.Context, dst string) (string, error) {
+	for n := 0; n < repositories.MaxKeepBothAttempts; n++ {
+		candidate := dst
+		if n > 0 {
+			candidate = repositories.NumberedName(dst, n)
+		}
+		diskPath, err := r.Encryption.DiskPath(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		_, err = r.DriverRepo.Stat(ctx, diskPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%w: no free name for %s", ErrFileExists, filepath.Base(dst))
+}
+
+func (r *DriverServiceImpl) Stat(ctx context.Context, path string) (os.FileInfo, error) {
+	diskPath, err := r.Encryption.DiskPath(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat path: %w", err)
+	}
+	info, err := r.DriverRepo.Stat(ctx, diskPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat path: %w", err)
+	}
+	return r.Encryption.PlainInfo(ctx, path, info), nil
+}
+
+// Delete removes a file or folder tree.
+func (r *DriverServiceImpl) Delete(ctx context.Context, path string) error {
+	var isDir bool
+	diskPath, err := r.Encryption.DiskPath(ctx, path)
+	if err == nil {
+		if info, statErr := r.DriverRepo.Stat(ctx, diskPath); statErr == nil {
+			isDir = info.IsDir()
+		}
+		err = r.DriverRepo.Remove(ctx, diskPath)
+	}
+	if err != nil {
+		r.logger.WarnContext(ctx, "failed to delete path", "path", path, "error", err)
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+	r.Events.Publish(entities.ChangeEvent{Type: entities.ChangeDelete, Path: path, IsDir: isDir})
+	r.Photos.FileRemoved(path)
+	r.Metadata.FileRemoved(ctx, path)
+	r.logger.InfoContext(ctx, "deleted path", "path", path)
+	return nil
+}
+
+// DeleteEmptyFolder removes a folder only if nothing is left in it; otherwise it fails with
+// ErrDirNotEmpty.
+func (r *DriverServiceImpl) DeleteEmptyFolder(ctx context.Context, path string) error {
+	diskPath, err := r.Encryption.DiskPath(ctx, path)
+	if err == nil {
+		err = r.DriverRepo.RemoveEmptyDir(ctx, diskPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+	r.Events.Publish(entities.ChangeEvent{Type: entities.ChangeDelete, Path: path, IsDir: true})
+	r.Metadata.FileRemoved(ctx, path)
+	r.logger.InfoContext(ctx, "deleted folder", "path", path)
+	return nil
+}
+
+// Move renames a file or folder. It fails with ErrFileExists if dst already exists, and
+// with ErrEncryptionBoundary if the move would cross the boundary of an encrypted folder.
+func (r *DriverServiceImpl) Move(ctx context.Context, src, dst string) error {
+	err := r.Encryption.CheckMove(ctx, src, dst)
+	var diskSrc, diskDst string
+	if err == nil {
+		diskSrc, err = r.Encryption.DiskPath(ctx, src)
+	}
+	if err == nil {
+		diskDst, err = r.Encryption.DiskPath(ctx, dst)
+	}
+	if err == nil {
+		err = r.DriverRepo.Rename(ctx, diskSrc, diskDst)
+	}
+	if err != nil {
+		r.logger.WarnContext(ctx, "failed to move path", "src", src, "dst", dst, "error", err)
+		return fmt.Errorf("failed to move: %w", err)
+	}
+	info, err := r.DriverRepo.Stat(ctx, diskDst)
+	r.Events.Publish(entities.ChangeEvent{Type: entities.ChangeRename, Path: dst, OldPath: src, IsDir: err == nil && info.IsDir()})
+	r.Photos.FileMoved(src, dst)
+	r.Metadata.FileMoved(ctx, src, dst)
+	r.logger.InfoContext(ctx, "moved path", "src", src, "dst", dst)
+	return nil
+}
+
+// MoveAll moves every path into destination, keeping its name, and reports each one to
+// progress. It stops at the first path not started when ctx is cancelled; failures of
+// single paths are reported in the results, and only an error for every path fails the
+// whole move.
+func (r *DriverServiceImpl) MoveAll(ctx context.Context, paths []string, destination string, progress *JobProgress) ([]entities.MoveResult, error) {
+	first := progress.AddFiles(paths, make([]int64, len(paths)))
+
+	results := make([]entities.MoveResult, 0, len(paths))
+	failed := 0
+	for i, src := range paths {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		progress.StartFile(first + i)
+
+		dst := filepath.Join(destination, filepath.Base(src))
+		err := r.Move(ctx, src, dst)
+		res := entities.MoveResult{Src: src, Dst: dst}
+		if err != nil {
+			res.Dst, res.Error = "", err.Error()
+			failed++
+		}
+		progress.FinishFile(first+i, res.Dst, err)
+		results = append(results, res)
+	}
+
+	if failed == len(paths) {
+		return results, fmt.Errorf("all moves failed")
+	}
+	return results, nil
+}
+
+// uploadWithWorkers runs save for every file on at most UploadConfig.Workers goroutines and
+// returns the results in the order of files.
+//
+// Important parts for new developers:
+//   - The jobs channel is unbuffered, so files are handed out one at a time as workers become
+//     free instead of being queued all at once.
+//   - Each job carries its index; writing results into `results[index]` is safe because every
+//     index is written by exactly one worker.
+//   - Once ctx is cancelled, the feeder stops handing out jobs and the files that were never
+//     started are marked with the context error.
+//   - Every file is registered with progress up front, and reported as started and finished
+//     around save; save gets the file's progress index and only reports the bytes it copies.
+func (r *DriverServiceImpl) uploadWithWorkers(ctx context.Context, files []*multipart.FileHeader, progress *JobProgress, save func(index int, fh *multipart.FileHeader) entities.UploadResult) []entities.UploadResult {
+	type job struct {
+		index int
+		fh    *multipart.FileHeader
+	}
+
+	results := make([]entities.UploadResult, len(files))
+	started := make([]bool, len(files))
+
+	names := make([]string, len(files))
+	sizes := make([]int64, len(files))
+	for i, fh := range files {
+		names[i], sizes[i] = fh.Filename, fh.Size
+	}
+	first := progress.AddFiles(names, sizes)
+
+	workerCount := min(r.uploadConfig.Workers, len(files))
+	if workerCount < 1 {
+		workerCount = 1
+	}
+
+	monitoring.UploadQueueDepth.Add(float64(len(files)))
+
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for range workerCount {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				progress.StartFile(first + j.index)
+				res := save(first+j.index, j.fh)
+				var err error
+				if res.Error != "" {
+					err = errors.New(res.Error)
 				}
-				result <- res
+				progress.FinishFile(first+j.index, res.Path, err)
+				results[j.index] = res
 			}
 		}()
-
 	}
 
-	// Enqueue all jobs
-	for _, fh := range files {
-		jobs <- fh
+feed:
+	for i, fh := range files {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- job{index: i, fh: fh}:
+			started[i] = true
+			monitoring.UploadQueueDepth.Dec()
+		}
 	}
-
 	close(jobs) // Signal workers no more jobs will be sent
 
-	var uploadedResult []entities.UploadResult
-	for range files {
-		uploadedResult = append(uploadedResult, <-result)
-	}
-	// Check if all failed
-	allFailed := true
-	for _, r := range uploadedResult {
-		if r.Error == "" {
-			allFailed = false
-			break
+	wg.Wait()
+
+	skipped := 0
+	for i, fh := range files {
+		if started[i] {
+			continue
 		}
+		skipped++
+		err := ctx.Err()
+		monitoring.UploadQueueDepth.Dec()
+		monitoring.UploadFailures.WithLabelValues(monitoring.UploadFailureReason(err)).Inc()
+		results[i] = entities.UploadResult{Name: fh.Filename, Error: err.Error()}
 	}
-	if allFailed {
-		return uploadedResult, fmt.Errorf("all uploads failed")
+	if skipped > 0 {
+		r.logger.WarnContext(ctx, "upload cancelled", "skipped", skipped, "error", ctx.Err())
 	}
-	return uploadedResult, nil
+
+	return results
 }
@@ -0,0 +1,562 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/helper"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/crypt"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrFolderLocked            = errors.New("encrypted folder is locked, unlock it with your password")
+	ErrNoKeyring               = errors.New("no encrypted folders have been set up")
+	ErrWrongPassword           = errors.New("wrong password")
+	ErrAlreadyEncrypted        = errors.New("folder is inside or contains an encrypted folder")
+	ErrEncryptionBoundary      = errors.New("cannot move files into, out of or between encrypted folders")
+	ErrEncryptedFolderNotFound = repositories.ErrEncryptedFolderNotFound
+)
+
+// EncryptionService seals the content (and optionally the names) of files in encrypted
+// folders, so a stolen disk exposes nothing.
+//
+// Folder keys are wrapped by the owner's master key, which is wrapped by a key derived
+// from their password. The password is only available at login or unlock, so the master
+// key is kept in memory from then until it expires, the user locks it, or the server
+// restarts; while it is locked the folder's files can't be read or written. An unlocked
+// folder is readable by every account, like any other folder of the vault.
+//
+// The driver and version services pass every path through this service: DiskPath and
+// PlainPath translate between the names clients see and the names on disk, Seal and Open
+// encrypt and decrypt content, and PlainInfo and PlainListing report plaintext names and
+// sizes.
+type EncryptionService interface {
+	Unlock(ctx context.Context, userID uuid.UUID, password string) error
+	Lock(userID uuid.UUID)
+	ListFolders(ctx context.Context, userID uuid.UUID) ([]entities.EncryptedFolder, error)
+	EncryptFolder(ctx context.Context, actor entities.Actor, req *entities.EncryptFolderRequest) (*entities.EncryptedFolder, error)
+	DisableFolder(ctx context.Context, userID, id uuid.UUID) error
+
+	DiskPath(ctx context.Context, path string) (string, error)
+	PlainPath(ctx context.Context, diskPath string) string
+	FolderOf(ctx context.Context, path string) *entities.EncryptedFolder
+	PlainInfo(ctx context.Context, path string, info os.FileInfo) os.FileInfo
+	PlainListing(ctx context.Context, dir string, files []entities.FileInfo) []entities.FileInfo
+	Seal(ctx context.Context, path string, src io.Reader) (io.Reader, error)
+	Open(ctx context.Context, path string, file io.ReadSeekCloser, info os.FileInfo) (io.ReadSeekCloser, os.FileInfo, error)
+	CheckMove(ctx context.Context, src, dst string) error
+}
+
+type unlockedKeyring struct {
+	masterKey []byte
+	expires   time.Time
+}
+
+type EncryptionServiceImpl struct {
+	EncryptionRepo repositories.EncryptionRepository
+	AuthRepo       repositories.AuthRepository
+	DriverRepo     repositories.DriverRepository
+	cfg            config.EncryptionConfig
+	logger         *slog.Logger
+
+	mu         sync.Mutex
+	folders    []entities.EncryptedFolder // nil until loaded
+	unlocked   map[uuid.UUID]unlockedKeyring
+	folderKeys map[uuid.UUID][]byte
+}
+
+func NewEncryptionService(encryptionRepo repositories.EncryptionRepository, authRepo repositories.AuthRepository, driverRepo repositories.DriverRepository, cfg config.EncryptionConfig, logger *slog.Logger) EncryptionService {
+	return &EncryptionServiceImpl{
+		EncryptionRepo: encryptionRepo,
+		AuthRepo:       authRepo,
+		DriverRepo:     driverRepo,
+		cfg:            cfg,
+		logger:         logger.With("component", "service.encryption"),
+		unlocked:       make(map[uuid.UUID]unlockedKeyring),
+		folderKeys:     make(map[uuid.UUID][]byte),
+	}
+}
+
+// Unlock derives the user's key from password and keeps their master key in memory. It
+// fails with ErrNoKeyring if the user never encrypted a folder.
+func (s *EncryptionServiceImpl) Unlock(ctx context.Context, userID uuid.UUID, password string) error {
+	keyring, err := s.EncryptionRepo.GetKeyring(ctx, userID)
+	if errors.Is(err, repositories.ErrKeyringNotFound) {
+		return ErrNoKeyring
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get keyring: %w", err)
+	}
+
+	params := crypt.KDFParams{Time: keyring.Argon2Time, Memory: keyring.Argon2Memory, Threads: keyring.Argon2Threads}
+	masterKey, err := crypt.Unwrap(crypt.DeriveKey(password, keyring.Salt, params), keyring.WrappedKey, userID[:])
+	if err != nil {
+		return ErrWrongPassword
+	}
+
+	s.mu.Lock()
+	s.unlocked[userID] = unlockedKeyring{masterKey: masterKey, expires: time.Now().Add(s.cfg.UnlockTTL)}
+	s.mu.Unlock()
+
+	s.logger.InfoContext(ctx, "keyring unlocked", "user_id", userID)
+	return nil
+}
+
+// Lock forgets the user's master key and the folder keys it unwrapped.
+func (s *EncryptionServiceImpl) Lock(userID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockLocked(userID)
+}
+
+func (s *EncryptionServiceImpl) lockLocked(userID uuid.UUID) {
+	delete(s.unlocked, userID)
+	for _, folder := range s.folders {
+		if folder.UserID == userID {
+			delete(s.folderKeys, folder.ID)
+		}
+	}
+}
+
+func (s *EncryptionServiceImpl) ListFolders(ctx context.Context, userID uuid.UUID) ([]entities.EncryptedFolder, error) {
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	folders := []entities.EncryptedFolder{}
+	for _, folder := range s.folders {
+		if folder.UserID != userID {
+			continue
+		}
+		_, err := s.keyForLocked(&folder)
+		folder.Locked = err != nil
+		folders = append(folders, folder)
+	}
+	return folders, nil
+}
+
+// EncryptFolder turns on encryption for the folder at req.Path, creating it if needed.
+// The folder must be empty: files already in it would stay in plaintext. The caller's
+// keyring is created on first use.
+func (s *EncryptionServiceImpl) EncryptFolder(ctx context.Context, actor entities.Actor, req *entities.EncryptFolderRequest) (*entities.EncryptedFolder, error) {
+	user, err := s.AuthRepo.GetUserById(actor.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !helper.CheckPassword(req.Password, user.Password) {
+		return nil, ErrWrongPassword
+	}
+
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+
+	absPath, err := filepath.Abs(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	for _, folder := range s.folders {
+		if isWithinPath(folder.Path, absPath) || isWithinPath(absPath, folder.Path) {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyEncrypted, folder.Path)
+		}
+	}
+	s.mu.Unlock()
+
+	if err := s.DriverRepo.EnsureDirExists(ctx, absPath); err != nil {
+		return nil, fmt.Errorf("failed to create folder: %w", err)
+	}
+	files, err := s.DriverRepo.ListPath(ctx, absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folder: %w", err)
+	}
+	if len(files) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDirNotEmpty, req.Path)
+	}
+
+	masterKey, err := s.masterKey(ctx, actor.UserID, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	folderKey, err := crypt.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	folder := &entities.EncryptedFolder{
+		ID:           uuid.New(),
+		UserID:       actor.UserID,
+		Path:         absPath,
+		EncryptNames: req.EncryptNames,
+	}
+	if folder.WrappedKey, err = crypt.Wrap(masterKey, folderKey, folder.ID[:]); err != nil {
+		return nil, err
+	}
+	if err := s.EncryptionRepo.CreateFolder(ctx, folder); err != nil {
+		return nil, fmt.Errorf("failed to save encrypted folder: %w", err)
+	}
+
+	s.mu.Lock()
+	s.folders = append(s.folders, *folder)
+	s.folderKeys[folder.ID] = folderKey
+	s.mu.Unlock()
+
+	s.logger.InfoContext(ctx, "folder encrypted", "path", absPath, "encrypt_names", req.EncryptNames, "username", actor.Username)
+	return folder, nil
+}
+
+// masterKey returns the user's master key, unlocking their keyring with password or
+// creating it if they don't have one yet.
+func (s *EncryptionServiceImpl) masterKey(ctx context.Context, userID uuid.UUID, password string) ([]byte, error) {
+	err := s.Unlock(ctx, userID, password)
+	if err == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.unlocked[userID].masterKey, nil
+	}
+	if !errors.Is(err, ErrNoKeyring) {
+		return nil, err
+	}
+
+	salt, err := crypt.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	masterKey, err := crypt.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	keyring := &entities.Keyring{
+		UserID:        userID,
+		Salt:          salt,
+		Argon2Time:    s.cfg.Argon2Time,
+		Argon2Memory:  s.cfg.Argon2MemoryKB,
+		Argon2Threads: s.cfg.Argon2Threads,
+	}
+	params := crypt.KDFParams{Time: keyring.Argon2Time, Memory: keyring.Argon2Memory, Threads: keyring.Argon2Threads}
+	if keyring.WrappedKey, err = crypt.Wrap(crypt.DeriveKey(password, salt, params), masterKey, userID[:]); err != nil {
+		return nil, err
+	}
+	if err := s.EncryptionRepo.CreateKeyring(ctx, keyring); err != nil {
+		return nil, fmt.Errorf("failed to save keyring: %w", err)
+	}
+
+	s.mu.Lock()
+	s.unlocked[userID] = unlockedKeyring{masterKey: masterKey, expires: time.Now().Add(s.cfg.UnlockTTL)}
+	s.mu.Unlock()
+
+	s.logger.InfoContext(ctx, "keyring created", "user_id", userID)
+	return masterKey, nil
+}
+
+// DisableFolder stops encrypting one of the user's folders. It must be empty (or gone),
+// since files already sealed could no longer be read.
+func (s *EncryptionServiceImpl) DisableFolder(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+
+	folder := s.folderByID(userID, id)
+	if folder == nil {
+		return ErrEncryptedFolderNotFound
+	}
+
+	files, err := s.DriverRepo.ListPath(ctx, folder.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to list folder: %w", err)
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("%w: %s", ErrDirNotEmpty, folder.Path)
+	}
+
+	if err := s.EncryptionRepo.DeleteFolder(ctx, userID, id); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.folders = slices.DeleteFunc(s.folders, func(folder entities.EncryptedFolder) bool { return folder.ID == id })
+	delete(s.folderKeys, id)
+	s.mu.Unlock()
+
+	s.logger.InfoContext(ctx, "folder encryption disabled", "path", folder.Path)
+	return nil
+}
+
+func (s *EncryptionServiceImpl) folderByID(userID, id uuid.UUID) *entities.EncryptedFolder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, folder := range s.folders {
+		if folder.ID == id && folder.UserID == userID {
+			return &folder
+		}
+	}
+	return nil
+}
+
+// DiskPath returns where path is stored: path itself, unless it is below a folder with
+// encrypted names.
+func (s *EncryptionServiceImpl) DiskPath(ctx context.Context, path string) (string, error) {
+	folder, absPath := s.folderOf(ctx, path)
+	if folder == nil || !folder.EncryptNames || absPath == folder.Path {
+		return path, nil
+	}
+
+	key, err := s.keyFor(folder)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(folder.Path, absPath)
+	if err != nil {
+		return "", err
+	}
+	elems := strings.Split(rel, string(filepath.Separator))
+	for i, name := range elems {
+		if elems[i], err = crypt.EncryptName(key, name); err != nil {
+			return "", fmt.Errorf("%w: %s", err, name)
+		}
+	}
+	return filepath.Join(append([]string{folder.Path}, elems...)...), nil
+}
+
+// PlainPath reverses DiskPath. Names that can't be decrypted (files put there outside the
+// vault, or while locked) are returned as they are.
+func (s *EncryptionServiceImpl) PlainPath(ctx context.Context, diskPath string) string {
+	folder, absPath := s.folderOf(ctx, diskPath)
+	if folder == nil || !folder.EncryptNames || absPath == folder.Path {
+		return diskPath
+	}
+
+	key, err := s.keyFor(folder)
+	if err != nil {
+		return diskPath
+	}
+
+	rel, err := filepath.Rel(folder.Path, absPath)
+	if err != nil {
+		return diskPath
+	}
+	elems := strings.Split(rel, string(filepath.Separator))
+	for i, sealed := range elems {
+		if name, err := crypt.DecryptName(key, sealed); err == nil {
+			elems[i] = name
+		}
+	}
+	return filepath.Join(append([]string{folder.Path}, elems...)...)
+}
+
+// FolderOf returns the encrypted folder path is in, or nil.
+func (s *EncryptionServiceImpl) FolderOf(ctx context.Context, path string) *entities.EncryptedFolder {
+	folder, _ := s.folderOf(ctx, path)
+	return folder
+}
+
+// PlainInfo returns info of the file at path as clients see it: with its plaintext name and
+// size. The size is known from the size on disk, so this works while locked too.
+func (s *EncryptionServiceImpl) PlainInfo(ctx context.Context, path string, info os.FileInfo) os.FileInfo {
+	folder, absPath := s.folderOf(ctx, path)
+	if folder == nil || absPath == folder.Path {
+		return info
+	}
+
+	plain := &plainFileInfo{FileInfo: info, name: info.Name(), size: info.Size()}
+	if folder.EncryptNames {
+		plain.name = filepath.Base(absPath)
+	}
+	if !info.IsDir() {
+		plain.size = crypt.PlainSize(info.Size())
+	}
+	return plain
+}
+
+// PlainListing fixes the names, paths and sizes of the entries of dir, as listed from its
+// disk path.
+func (s *EncryptionServiceImpl) PlainListing(ctx context.Context, dir string, files []entities.FileInfo) []entities.FileInfo {
+	folder, _ := s.folderOf(ctx, dir)
+	if folder == nil {
+		return files
+	}
+
+	var key []byte
+	if folder.EncryptNames {
+		key, _ = s.keyFor(folder)
+	}
+
+	for i := range files {
+		if key != nil {
+			if name, err := crypt.DecryptName(key, files[i].Name); err == nil {
+				files[i].Name = name
+			}
+		}
+		files[i].Path = filepath.Join(dir, files[i].Name)
+		if files[i].Type == "file" {
+			files[i].Size = crypt.PlainSize(files[i].Size)
+		}
+	}
+	// The disk order is that of the encrypted names
+	if key != nil {
+		sort.SliceStable(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	}
+	return files
+}
+
+// Seal returns src encrypted with the key of the folder path is in, or src itself if path
+// isn't in an encrypted folder.
+func (s *EncryptionServiceImpl) Seal(ctx context.Context, path string, src io.Reader) (io.Reader, error) {
+	folder, _ := s.folderOf(ctx, path)
+	if folder == nil {
+		return src, nil
+	}
+
+	key, err := s.keyFor(folder)
+	if err != nil {
+		return nil, err
+	}
+	return crypt.Encrypt(src, key)
+}
+
+// Open wraps file, opened from path, so reads and seeks see its plaintext.
+func (s *EncryptionServiceImpl) Open(ctx context.Context, path string, file io.ReadSeekCloser, info os.FileInfo) (io.ReadSeekCloser, os.FileInfo, error) {
+	folder, _ := s.folderOf(ctx, path)
+	if folder == nil {
+		return file, info, nil
+	}
+
+	key, err := s.keyFor(folder)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := crypt.NewReader(file, info.Size(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &decryptedFile{ReadSeeker: plain, Closer: file}, s.PlainInfo(ctx, path, info), nil
+}
+
+// CheckMove fails if moving src to dst would cross the boundary of an encrypted folder,
+// which would leave sealed files outside it or plaintext files inside, or move an
+// encrypted folder itself.
+func (s *EncryptionServiceImpl) CheckMove(ctx context.Context, src, dst string) error {
+	srcFolder, absSrc := s.folderOf(ctx, src)
+	dstFolder, _ := s.folderOf(ctx, dst)
+
+	if (srcFolder == nil) != (dstFolder == nil) || (srcFolder != nil && srcFolder.ID != dstFolder.ID) {
+		return fmt.Errorf("%w: %s -> %s", ErrEncryptionBoundary, src, dst)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, folder := range s.folders {
+		if isWithinPath(absSrc, folder.Path) {
+			return fmt.Errorf("%w: %s", ErrEncryptionBoundary, folder.Path)
+		}
+	}
+	return nil
+}
+
+// folderOf returns the encrypted folder path is in, if any, along with the absolute path.
+func (s *EncryptionServiceImpl) folderOf(ctx context.Context, path string) (*entities.EncryptedFolder, string) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, path
+	}
+	if err := s.load(ctx); err != nil {
+		s.logger.ErrorContext(ctx, "failed to load encrypted folders", "error", err)
+		return nil, absPath
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, folder := range s.folders {
+		if isWithinPath(folder.Path, absPath) {
+			return &folder, absPath
+		}
+	}
+	return nil, absPath
+}
+
+// load reads the encrypted folders on first use.
+func (s *EncryptionServiceImpl) load(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.folders != nil {
+		return nil
+	}
+
+	folders, err := s.EncryptionRepo.ListFolders(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list encrypted folders: %w", err)
+	}
+	s.folders = append([]entities.EncryptedFolder{}, folders...)
+	return nil
+}
+
+func (s *EncryptionServiceImpl) keyFor(folder *entities.EncryptedFolder) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keyForLocked(folder)
+}
+
+// keyForLocked returns the folder's key if its owner's keyring is unlocked. s.mu must be
+// held.
+func (s *EncryptionServiceImpl) keyForLocked(folder *entities.EncryptedFolder) ([]byte, error) {
+	keyring, ok := s.unlocked[folder.UserID]
+	if ok && time.Now().After(keyring.expires) {
+		s.lockLocked(folder.UserID)
+		ok = false
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrFolderLocked, folder.Path)
+	}
+
+	if key, ok := s.folderKeys[folder.ID]; ok {
+		return key, nil
+	}
+	key, err := crypt.Unwrap(keyring.masterKey, folder.WrappedKey, folder.ID[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap folder key: %w", err)
+	}
+	s.folderKeys[folder.ID] = key
+	return key, nil
+}
+
+// isWithinPath reports whether path is root or below it. Both must be absolute.
+func isWithinPath(root, path string) bool {
+	if path == root {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+type plainFileInfo struct {
+	os.FileInfo
+	name string
+	size int64
+}
+
+func (fi *plainFileInfo) Name() string { return fi.name }
+func (fi *plainFileInfo) Size() int64  { return fi.size }
+
+type decryptedFile struct {
+	io.ReadSeeker
+	io.Closer
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+)
+
+const (
+	// MaxSubscriptionPaths is how many folders one subscription may watch
+	MaxSubscriptionPaths = 32
+
+	subscriptionQueueSize = 256
+	maxPendingEvents      = 512
+)
+
+var (
+	ErrNoWatchPaths = errors.New("at least one path to watch is required")
+	ErrTooManyPaths = fmt.Errorf("at most %d paths can be watched at once", MaxSubscriptionPaths)
+	ErrEventsClosed = errors.New("event service is shutting down")
+)
+
+// EventService tells subscribers about changes to the folders they watch, whether the
+// change was made through the vault (Publish) or directly on disk (the file watcher).
+//
+// Like the file watcher, subscriptions aren't recursive: a subscriber to a folder hears
+// about its direct entries and the folder itself. Events are only delivered for paths the
+// subscriber could list: not hidden, allowed by the vault's path rules, and with
+// decryptable names in encrypted folders.
+//
+// Events for one subscriber are held for the coalescing window and merged, so a burst
+// such as a folder upload (or the temp file, rename and close of every atomic write)
+// arrives as one event per path. If a subscriber can't keep up, or too many paths change
+// at once, the pending events are replaced by a resync event for each watched folder.
+type EventService interface {
+	Publish(event entities.ChangeEvent)
+	// Observe calls observer with every event published, from the vault or the file
+	// watcher, before it is filtered or coalesced for subscribers. A ChangeResync event
+	// with an empty path means the file watcher lost events. Observers are called with the
+	// service's lock held, so they must be quick and must not publish.
+	Observe(observer func(event entities.ChangeEvent))
+	// Subscribe watches paths until ctx is done or the service is closed, at which point
+	// the subscription's channel is closed.
+	Subscribe(ctx context.Context, paths []string) (*Subscription, error)
+	Close()
+}
+
+type EventServiceImpl struct {
+	DriverRepo repositories.DriverRepository
+	Encryption EncryptionService
+	Watcher    repositories.FileWatcher
+	window     time.Duration
+	logger     *slog.Logger
+
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	observers   []func(event entities.ChangeEvent)
+	watches     map[string]int // disk path -> number of subscriptions watching it
+	stop        chan struct{}
+	closeOnce   sync.Once
+}
+
+func NewEventService(driverRepo repositories.DriverRepository, encryption EncryptionService, watcher repositories.FileWatcher, cfg config.EventsConfig, logger *slog.Logger) EventService {
+	s := &EventServiceImpl{
+		DriverRepo:  driverRepo,
+		Encryption:  encryption,
+		Watcher:     watcher,
+		window:      cfg.CoalesceWindow,
+		logger:      logger.With("component", "service.event"),
+		subscribers: make(map[*Subscription]struct{}),
+		watches:     make(map[string]int),
+		stop:        make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// Publish hands an event to every interested subscriber. It never blocks.
+func (s *EventServiceImpl) Publish(event entities.ChangeEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Path = filepath.Clean(event.Path)
+	if event.OldPath != "" {
+		event.OldPath = filepath.Clean(event.OldPath)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, observer := range s.observers {
+		observer(event)
+	}
+	for sub := range s.subscribers {
+		sub.add(event)
+	}
+}
+
+func (s *EventServiceImpl) Observe(observer func(event entities.ChangeEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+func (s *EventServiceImpl) Subscribe(ctx context.Context, paths []string) (*Subscription, error) {
+	if len(paths) == 0 {
+		return nil, ErrNoWatchPaths
+	}
+	if len(paths) > MaxSubscriptionPaths {
+		return nil, ErrTooManyPaths
+	}
+
+	roots := make(map[string]string, len(paths)) // path -> disk path
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		diskPath, err := s.Encryption.DiskPath(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		info, err := s.DriverRepo.Stat(ctx, diskPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%w: %s", ErrNotDirectory, path)
+		}
+		roots[path] = diskPath
+	}
+
+	events := make(chan entities.ChangeEvent, subscriptionQueueSize)
+	sub := &Subscription{
+		Events:  events,
+		events:  events,
+		roots:   roots,
+		window:  s.window,
+		pending: make(map[string]int),
+	}
+
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+		s.mu.Unlock()
+		return nil, ErrEventsClosed
+	default:
+	}
+	s.subscribers[sub] = struct{}{}
+	for _, diskPath := range roots {
+		if s.watches[diskPath] == 0 {
+			// Folders the watcher can't see (remote storage, other platforms) still get
+			// the vault's own events
+			if err := s.Watcher.Watch(diskPath); err != nil {
+				s.logger.DebugContext(ctx, "not watching folder on disk", "path", diskPath, "error", err)
+			}
+		}
+		s.watches[diskPath]++
+	}
+	s.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.stop:
+		}
+		s.unsubscribe(sub)
+	}()
+
+	s.logger.DebugContext(ctx, "subscribed to changes", "paths", paths)
+	return sub, nil
+}
+
+func (s *EventServiceImpl) unsubscribe(sub *Subscription) {
+	s.mu.Lock()
+	delete(s.subscribers, sub)
+	for _, diskPath := range sub.roots {
+		s.watches[diskPath]--
+		if s.watches[diskPath] <= 0 {
+			delete(s.watches, diskPath)
+			s.Watcher.Unwatch(diskPath)
+		}
+	}
+	s.mu.Unlock()
+
+	sub.close()
+}
+
+// Close ends every subscription and stops the file watcher.
+func (s *EventServiceImpl) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		if err := s.Watcher.Close(); err != nil {
+			s.logger.Error("failed to close file watcher", "error", err)
+		}
+	})
+}
+
+// run turns file watcher events, which carry disk paths, into events with the paths
+// clients see.
+func (s *EventServiceImpl) run() {
+	ctx := context.Background()
+	for event := range s.Watcher.Events() {
+		if event.Type == entities.ChangeResync {
+			s.resyncAll()
+			continue
+		}
+
+		var ok bool
+		if event.Path, ok = s.plainPath(ctx, event.Path); !ok {
+			continue
+		}
+		if event.OldPath != "" {
+			if event.OldPath, ok = s.plainPath(ctx, event.OldPath); !ok {
+				event.Type, event.OldPath = entities.ChangeCreate, ""
+			}
+		}
+		s.Publish(event)
+	}
+}
+
+// plainPath translates a disk path, failing for names in an encrypted folder that can't be
+// decrypted (the folder is locked, or the file wasn't written by the vault).
+func (s *EventServiceImpl) plainPath(ctx context.Context, diskPath string) (string, bool) {
+	path := s.Encryption.PlainPath(ctx, diskPath)
+	if path != diskPath {
+		return path, true
+	}
+	folder := s.Encryption.FolderOf(ctx, diskPath)
+	if folder != nil && folder.EncryptNames && filepath.Clean(diskPath) != folder.Path {
+		return "", false
+	}
+	return path, true
+}
+
+// resyncAll is used when the file watcher lost events: nobody knows what changed.
+func (s *EventServiceImpl) resyncAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, observer := range s.observers {
+		observer(entities.ChangeEvent{Type: entities.ChangeResync, Time: time.Now()})
+	}
+	for sub := range s.subscribers {
+		sub.mu.Lock()
+		sub.overflowLocked()
+		sub.mu.Unlock()
+	}
+}
+
+// Subscription receives the events of one subscriber on Events.
+type Subscription struct {
+	Events <-chan entities.ChangeEvent
+
+	events chan entities.ChangeEvent
+	roots  map[string]string
+	window time.Duration
+
+	mu       sync.Mutex
+	queue    []entities.ChangeEvent // events waiting for the window to end
+	pending  map[string]int         // merge key -> index in queue
+	timer    *time.Timer
+	overflow bool // queue was dropped, a resync is owed
+	closed   bool
+}
+
+// Paths returns the folders the subscription watches.
+func (sub *Subscription) Paths() []string {
+	paths := make([]string, 0, len(sub.roots))
+	for path := range sub.roots {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// add queues event if it concerns a watched folder and the subscriber may see it.
+func (sub *Subscription) add(event entities.ChangeEvent) {
+	switch event.Type {
+	case entities.ChangeRename:
+		from, to := sub.visible(event.OldPath), sub.visible(event.Path)
+		switch {
+		case from && to:
+		case to:
+			// e.g. an atomic write's temp file taking its final name
+			event.Type, event.OldPath = entities.ChangeCreate, ""
+		case from:
+			event.Type, event.Path, event.OldPath = entities.ChangeDelete, event.OldPath, ""
+		default:
+			return
+		}
+	default:
+		if !sub.visible(event.Path) {
+			return
+		}
+	}
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return
+	}
+	if !sub.overflow {
+		sub.mergeLocked(event)
+	}
+	if sub.timer == nil {
+		sub.timer = time.AfterFunc(sub.window, sub.flush)
+	}
+}
+
+// visible reports whether path is a watched folder or directly in one, and whether the
+// subscriber may see it.
+func (sub *Subscription) visible(path string) bool {
+	_, isRoot := sub.roots[path]
+	if _, ok := sub.roots[filepath.Dir(path)]; !ok && !isRoot {
+		return false
+	}
+	if !repositories.IsAllowedPath(path) {
+		return false
+	}
+	for _, name := range strings.Split(filepath.ToSlash(path), "/") {
+		if name != "" && repositories.IsHidden(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeLocked folds event into the queue. The result describes the change from before the
+// window to after it: a file created and modified (or renamed) was created, a file created
+// and deleted never existed, and a file deleted and created again was modified. sub.mu
+// must be held.
+func (sub *Subscription) mergeLocked(event entities.ChangeEvent) {
+	key := event.Path
+	if event.Type == entities.ChangeRename {
+		// A file created and renamed within the window was simply created under its new name
+		if i, ok := sub.pending[event.OldPath]; ok && sub.queue[i].Type == entities.ChangeCreate {
+			sub.queue[i].Type = ""
+			delete(sub.pending, event.OldPath)
+			event.Type, event.OldPath = entities.ChangeCreate, ""
+		} else {
+			key = event.OldPath + "\x00" + event.Path
+		}
+	}
+
+	i, ok := sub.pending[key]
+	if !ok {
+		if len(sub.queue) >= maxPendingEvents {
+			sub.overflowLocked()
+			return
+		}
+		sub.pending[key] = len(sub.queue)
+		sub.queue = append(sub.queue, event)
+		return
+	}
+
+	prev := &sub.queue[i]
+	switch {
+	case event.Type == entities.ChangeRename:
+		prev.Time = event.Time
+	case prev.Type == entities.ChangeCreate && event.Type == entities.ChangeDelete:
+		// Leave a hole instead of shifting the queue; flush skips it
+		prev.Type = ""
+		delete(sub.pending, key)
+	case prev.Type == entities.ChangeCreate:
+		prev.Time = event.Time
+	case prev.Type == entities.ChangeDelete && event.Type != entities.ChangeDelete:
+		prev.Type, prev.IsDir, prev.Time = entities.ChangeModify, event.IsDir, event.Time
+	default:
+		if event.Type == entities.ChangeDelete {
+			prev.Type = entities.ChangeDelete
+		}
+		prev.IsDir, prev.Time = event.IsDir, event.Time
+	}
+}
+
+// overflowLocked drops the queue in favour of resync events. sub.mu must be held.
+func (sub *Subscription) overflowLocked() {
+	sub.queue = sub.queue[:0]
+	clear(sub.pending)
+	sub.overflow = true
+	if sub.timer == nil && !sub.closed {
+		sub.timer = time.AfterFunc(sub.window, sub.flush)
+	}
+}
+
+// flush delivers the queue at the end of the window. Delivery never blocks: what doesn't
+// fit in the channel turns into a resync, retried at the next window.
+func (sub *Subscription) flush() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	sub.timer = nil
+	if sub.closed {
+		return
+	}
+
+	if sub.overflow {
+		if cap(sub.events)-len(sub.events) < len(sub.roots) {
+			sub.timer = time.AfterFunc(sub.window, sub.flush)
+			return
+		}
+		now := time.Now()
+		for path := range sub.roots {
+			sub.events <- entities.ChangeEvent{Type: entities.ChangeResync, Path: path, IsDir: true, Time: now}
+		}
+		sub.overflow = false
+		return
+	}
+
+	for _, event := range sub.queue {
+		if event.Type == "" {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			sub.overflowLocked()
+			return
+		}
+	}
+	sub.queue = sub.queue[:0]
+	clear(sub.pending)
+}
+
+func (sub *Subscription) close() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	if sub.timer != nil {
+		sub.timer.Stop()
+	}
+	close(sub.events)
+}
@@ -0,0 +1,462 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/netguard"
+	"github.com/google/uuid"
+)
+
+const (
+	maxFetchRedirects = 10
+	maxFetchBackoff   = 30 * time.Second
+)
+
+var (
+	ErrFetchScheme      = errors.New("URL scheme is not allowed")
+	ErrFetchTooLarge    = errors.New("file is larger than the fetch size limit")
+	ErrFetchFailed      = errors.New("remote server refused the download")
+	ErrInvalidChecksum  = errors.New("checksum must be sha256:, sha512:, sha1: or md5: followed by the hex digest")
+	ErrChecksumMismatch = errors.New("downloaded file does not match the checksum")
+	ErrInvalidFileName  = errors.New("invalid file name")
+	ErrBlockedAddress   = netguard.ErrBlockedAddress
+)
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+// FetchService downloads URLs into the vault on the server, so large files don't have to
+// pass through the client.
+//
+// A download is staged under the data dir and resumed with Range requests when the
+// connection drops, as long as the server supports it and the file hasn't changed. Only
+// once it is complete (and matches the checksum, if one was given) is it saved to the vault
+// through the driver service, with the same path checks, atomic write, versioning and
+// encryption as an upload. Connections to addresses that aren't publicly routable are
+// refused unless FETCH_ALLOW_PRIVATE_NETWORKS is set.
+type FetchService interface {
+	// Check validates req without downloading anything, so a job is only started for
+	// requests that can succeed.
+	Check(ctx context.Context, req *entities.FetchRequest) error
+	Fetch(ctx context.Context, actor entities.Actor, req *entities.FetchRequest, progress *JobProgress) (entities.StoredFile, error)
+}
+
+type FetchServiceImpl struct {
+	FetchRepo repositories.FetchRepository
+	Driver    DriverService
+	cfg       config.FetchConfig
+	client    *http.Client
+	logger    *slog.Logger
+}
+
+func NewFetchService(fetchRepo repositories.FetchRepository, driver DriverService, cfg config.FetchConfig, logger *slog.Logger) FetchService {
+	s := &FetchServiceImpl{
+		FetchRepo: fetchRepo,
+		Driver:    driver,
+		cfg:       cfg,
+		logger:    logger.With("component", "service.fetch"),
+	}
+	s.client = &http.Client{
+		Transport: netguard.NewTransport(cfg.AllowPrivateNetworks),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			if !slices.Contains(cfg.AllowedSchemes, req.URL.Scheme) {
+				return fmt.Errorf("%w: redirected to %s", ErrFetchScheme, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return s
+}
+
+func (s *FetchServiceImpl) Check(ctx context.Context, req *entities.FetchRequest) error {
+	if _, err := s.parseURL(req.URL); err != nil {
+		return err
+	}
+	if _, err := entities.ParseOverwriteMode(req.Overwrite); err != nil {
+		return err
+	}
+	if _, _, err := parseChecksum(req.Checksum); err != nil {
+		return err
+	}
+	if req.Name != "" {
+		if err := checkFileName(req.Name); err != nil {
+			return err
+		}
+	}
+
+	info, err := s.Driver.Stat(ctx, req.Path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrNotDirectory, req.Path)
+	}
+	return nil
+}
+
+func (s *FetchServiceImpl) Fetch(ctx context.Context, actor entities.Actor, req *entities.FetchRequest, progress *JobProgress) (entities.StoredFile, error) {
+	if err := s.Check(ctx, req); err != nil {
+		return entities.StoredFile{}, err
+	}
+	u, _ := s.parseURL(req.URL)
+	mode, _ := entities.ParseOverwriteMode(req.Overwrite)
+
+	index := progress.AddFiles([]string{req.URL}, []int64{0})
+	progress.StartFile(index)
+
+	stored, err := s.fetch(ctx, actor, u, req, mode, progress, index)
+	progress.FinishFile(index, stored.Path, err)
+	return stored, err
+}
+
+func (s *FetchServiceImpl) fetch(ctx context.Context, actor entities.Actor, u *url.URL, req *entities.FetchRequest, mode entities.OverwriteMode, progress *JobProgress, index int) (entities.StoredFile, error) {
+	id := uuid.New()
+	file, err := s.FetchRepo.OpenPartial(id)
+	if err != nil {
+		return entities.StoredFile{}, fmt.Errorf("failed to stage download: %w", err)
+	}
+	defer func() {
+		file.Close()
+		if err := s.FetchRepo.RemovePartial(id); err != nil {
+			s.logger.Warn("failed to remove partial download", "id", id, "error", err)
+		}
+	}()
+
+	remoteName, err := s.download(ctx, u, file, progress, index)
+	if err != nil {
+		return entities.StoredFile{}, err
+	}
+
+	if req.Checksum != "" {
+		if err := verifyChecksum(file, req.Checksum); err != nil {
+			return entities.StoredFile{}, err
+		}
+	}
+
+	name := req.Name
+	if name == "" {
+		name = remoteName
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return entities.StoredFile{}, err
+	}
+	stored, err := s.Driver.SaveFile(ctx, actor, filepath.Join(req.Path, name), file, mode)
+	if err != nil {
+		return entities.StoredFile{}, err
+	}
+
+	s.logger.InfoContext(ctx, "fetched URL", "host", u.Host, "path", stored.Path, "bytes", stored.Size)
+	return stored, nil
+}
+
+// download copies the content of u into file, resuming after errors, and returns the name
+// the server suggests for it.
+func (s *FetchServiceImpl) download(ctx context.Context, u *url.URL, file *os.File, progress *JobProgress, index int) (string, error) {
+	d := &fetchDownload{
+		FetchServiceImpl: s,
+		url:              u,
+		file:             file,
+		progress:         progress,
+		index:            index,
+		total:            -1,
+		name:             nameFromURL(u),
+	}
+
+	failures := 0
+	for {
+		before := d.written
+		err := d.attempt(ctx)
+		if err == nil {
+			return d.name, nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return "", permanent.err
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		if d.written > before {
+			failures = 0
+		}
+		failures++
+		if failures > s.cfg.Retries {
+			return "", fmt.Errorf("download failed after %d attempts: %w", failures, err)
+		}
+
+		backoff := min(time.Second<<(failures-1), maxFetchBackoff)
+		s.logger.WarnContext(ctx, "download interrupted, resuming", "host", u.Host, "bytes", d.written, "retry_in", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// fetchDownload is the state of one download across its attempts.
+type fetchDownload struct {
+	*FetchServiceImpl
+	url      *url.URL
+	file     *os.File
+	progress *JobProgress
+	index    int
+
+	written   int64  // bytes in file
+	total     int64  // size of the remote file, -1 if unknown
+	validator string // ETag or Last-Modified, to make sure a resumed download is the same file
+	name      string
+}
+
+// permanentError marks errors that retrying won't fix.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (d *fetchDownload) attempt(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url.String(), nil)
+	if err != nil {
+		return &permanentError{err}
+	}
+	if d.written > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.written))
+		if d.validator != "" {
+			req.Header.Set("If-Range", d.validator)
+		}
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		if errors.Is(err, netguard.ErrBlockedAddress) || errors.Is(err, ErrFetchScheme) {
+			return &permanentError{err}
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		// The server sent the whole file: it doesn't support ranges, or the file changed
+		if err := d.restart(); err != nil {
+			return &permanentError{err}
+		}
+		d.total = resp.ContentLength
+	case resp.StatusCode == http.StatusPartialContent && d.written > 0:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != d.written {
+			// Can't append this; start over without a range
+			d.validator = ""
+			if err := d.restart(); err != nil {
+				return &permanentError{err}
+			}
+			return fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		d.total = total
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && d.written > 0:
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total == d.written {
+			return nil
+		}
+		d.validator = ""
+		if err := d.restart(); err != nil {
+			return &permanentError{err}
+		}
+		return fmt.Errorf("remote file changed: %s", resp.Status)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("%w: %s", ErrFetchFailed, resp.Status)
+	default:
+		return &permanentError{fmt.Errorf("%w: %s", ErrFetchFailed, resp.Status)}
+	}
+
+	if d.written == 0 {
+		d.validator = rangeValidator(resp.Header)
+		if name := nameFromContentDisposition(resp.Header.Get("Content-Disposition")); name != "" {
+			d.name = name
+		}
+	}
+
+	if d.total >= 0 {
+		if d.cfg.MaxSize > 0 && d.total > d.cfg.MaxSize {
+			return &permanentError{fmt.Errorf("%w: %d bytes", ErrFetchTooLarge, d.total)}
+		}
+		d.progress.SetSize(d.index, d.total)
+	}
+
+	body := io.Reader(resp.Body)
+	if d.cfg.MaxSize > 0 {
+		body = io.LimitReader(body, d.cfg.MaxSize-d.written+1)
+	}
+	n, err := io.Copy(d.file, d.progress.Reader(d.index, body))
+	d.written += n
+	if d.cfg.MaxSize > 0 && d.written > d.cfg.MaxSize {
+		return &permanentError{ErrFetchTooLarge}
+	}
+	if err != nil {
+		return err
+	}
+	if d.total >= 0 && d.written != d.total {
+		return fmt.Errorf("connection closed after %d of %d bytes", d.written, d.total)
+	}
+	return nil
+}
+
+// restart discards what was downloaded so far.
+func (d *fetchDownload) restart() error {
+	if d.written == 0 {
+		return nil
+	}
+	if err := d.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	d.progress.AddBytes(d.index, -d.written)
+	d.written = 0
+	return nil
+}
+
+func (s *FetchServiceImpl) parseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL: %s", raw)
+	}
+	if !slices.Contains(s.cfg.AllowedSchemes, u.Scheme) {
+		return nil, fmt.Errorf("%w: %s", ErrFetchScheme, u.Scheme)
+	}
+	return u, nil
+}
+
+func parseChecksum(checksum string) (func() hash.Hash, []byte, error) {
+	if checksum == "" {
+		return nil, nil, nil
+	}
+	algorithm, digest, _ := strings.Cut(checksum, ":")
+	newHash, ok := checksumAlgorithms[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, nil, ErrInvalidChecksum
+	}
+	want, err := hex.DecodeString(digest)
+	if err != nil || len(want) != newHash().Size() {
+		return nil, nil, ErrInvalidChecksum
+	}
+	return newHash, want, nil
+}
+
+func verifyChecksum(file *os.File, checksum string) error {
+	newHash, want, err := parseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	h := newHash()
+	if _, err := io.Copy(h, file); err != nil {
+		return fmt.Errorf("failed to hash download: %w", err)
+	}
+	if got := h.Sum(nil); !slices.Equal(got, want) {
+		return fmt.Errorf("%w: got %s", ErrChecksumMismatch, hex.EncodeToString(got))
+	}
+	return nil
+}
+
+// checkFileName accepts a single path element that isn't hidden.
+func checkFileName(name string) error {
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) || repositories.IsHidden(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidFileName, name)
+	}
+	return nil
+}
+
+func nameFromURL(u *url.URL) string {
+	name := path.Base(u.Path)
+	if checkFileName(name) != nil {
+		return "download"
+	}
+	return name
+}
+
+func nameFromContentDisposition(header string) string {
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+	name := filepath.Base(filepath.FromSlash(strings.ReplaceAll(params["filename"], `\`, "/")))
+	if checkFileName(name) != nil {
+		return ""
+	}
+	return name
+}
+
+// rangeValidator returns the value for If-Range: a strong ETag, or Last-Modified.
+func rangeValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// parseContentRange parses "bytes start-end/total". The total is -1 when the server
+// doesn't know it; for unsatisfied ranges ("bytes */total") start is -1.
+func parseContentRange(header string) (start, total int64, ok bool) {
+	unit, spec, found := strings.Cut(header, " ")
+	if !found || unit != "bytes" {
+		return 0, 0, false
+	}
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		var err error
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if rng == "*" {
+		return -1, total, true
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/netguard"
+)
+
+// fetchDriver stands in for the driver service: every path is a folder, and saved files
+// are kept in memory.
+type fetchDriver struct {
+	DriverService
+	dir string
+
+	mu    sync.Mutex
+	saved map[string][]byte
+}
+
+func (d *fetchDriver) Stat(ctx context.Context, path string) (os.FileInfo, error) {
+	return os.Stat(d.dir)
+}
+
+func (d *fetchDriver) SaveFile(ctx context.Context, actor entities.Actor, dst string, src io.Reader, mode entities.OverwriteMode) (entities.StoredFile, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return entities.StoredFile{}, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.saved[dst] = data
+	return entities.StoredFile{Path: dst, Size: int64(len(data))}, nil
+}
+
+// fetchServer serves content that can change between requests, and can cut the first
+// response short to make the service resume.
+type fetchServer struct {
+	mu      sync.Mutex
+	content []byte
+	etag    string
+	// cutAt is where the first response is cut off, 0 to send it whole
+	cutAt int
+	// requests are the Range and If-Range headers of each request, as "range|if-range"
+	requests []string
+}
+
+func (s *fetchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, etag, cutAt := s.content, s.etag, s.cutAt
+	s.requests = append(s.requests, r.Header.Get("Range")+"|"+r.Header.Get("If-Range"))
+	first := len(s.requests) == 1
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag)
+	if first && cutAt > 0 {
+		// A short body makes the server close the connection
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content[:cutAt])
+		return
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+func (s *fetchServer) recorded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *fetchServer) set(content []byte, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content, s.etag = content, etag
+}
+
+func newFetchService(t *testing.T, cfg config.FetchConfig) (*FetchServiceImpl, *fetchDriver, string) {
+	t.Helper()
+	dataDir := t.TempDir()
+	driver := &fetchDriver{dir: t.TempDir(), saved: make(map[string][]byte)}
+	if cfg.AllowedSchemes == nil {
+		cfg.AllowedSchemes = []string{"https", "http"}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewFetchService(repositories.NewFetchRepository(dataDir), driver, cfg, logger).(*FetchServiceImpl)
+	return s, driver, filepath.Join(dataDir, "fetch")
+}
+
+func fetchContent(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+// assertNoPartials fails if a partial download was left in the fetch directory.
+func assertNoPartials(t *testing.T, fetchDir string) {
+	t.Helper()
+	entries, err := os.ReadDir(fetchDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("%d partial downloads were left behind", len(entries))
+	}
+}
+
+func TestFetchResumesWithRange(t *testing.T) {
+	content := fetchContent(64 << 10)
+	remote := &fetchServer{content: content, etag: `"v1"`, cutAt: 20000}
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	s, driver, fetchDir := newFetchService(t, config.FetchConfig{AllowPrivateNetworks: true, Retries: 2})
+	stored, err := s.Fetch(context.Background(), entities.Actor{}, &entities.FetchRequest{URL: server.URL + "/files/data.bin", Path: "/vault"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Path != filepath.Join("/vault", "data.bin") {
+		t.Errorf("saved to %s, want /vault/data.bin", stored.Path)
+	}
+	if got := driver.saved[stored.Path]; !bytes.Equal(got, content) {
+		t.Errorf("saved %d bytes, want the %d of the remote file", len(got), len(content))
+	}
+	if got, want := remote.recorded(), []string{"|", `bytes=20000-|"v1"`}; !slices.Equal(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+	assertNoPartials(t, fetchDir)
+}
+
+func TestFetchRestartsWhenRemoteFileChanges(t *testing.T) {
+	remote := &fetchServer{content: fetchContent(64 << 10), etag: `"v1"`, cutAt: 20000}
+	server := httptest.NewServer(remote)
+	defer server.Close()
+
+	// The file is replaced after the first response is cut off
+	changed := fetchContent(50 << 10)
+	s, driver, _ := newFetchService(t, config.FetchConfig{AllowPrivateNetworks: true, Retries: 2})
+	s.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Range") != "" {
+			remote.set(changed, `"v2"`)
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	stored, err := s.Fetch(context.Background(), entities.Actor{}, &entities.FetchRequest{URL: server.URL + "/data.bin", Path: "/vault"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// If-Range didn't match, so the server sent the new file whole instead of a range
+	if got := driver.saved[stored.Path]; !bytes.Equal(got, changed) {
+		t.Errorf("saved %d bytes, want the %d of the new version", len(got), len(changed))
+	}
+	if got, want := remote.recorded(), `bytes=20000-|"v1"`; len(got) != 2 || got[1] != want {
+		t.Errorf("requests = %q, want the second to be %q", got, want)
+	}
+}
+
+func TestFetchMaxSize(t *testing.T) {
+	content := fetchContent(4096)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "content length",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write(content)
+			},
+		},
+		{
+			// Without a Content-Length the download is cut off once it goes over
+			name: "chunked",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < len(content); i += 512 {
+					w.Write(content[i : i+512])
+					w.(http.Flusher).Flush()
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			s, driver, fetchDir := newFetchService(t, config.FetchConfig{AllowPrivateNetworks: true, MaxSize: 1000, Retries: 2})
+			_, err := s.Fetch(context.Background(), entities.Actor{}, &entities.FetchRequest{URL: server.URL + "/big.bin", Path: "/vault"}, nil)
+			if !errors.Is(err, ErrFetchTooLarge) {
+				t.Errorf("Fetch error = %v, want ErrFetchTooLarge", err)
+			}
+			if len(driver.saved) != 0 {
+				t.Errorf("a file over the limit was saved")
+			}
+			assertNoPartials(t, fetchDir)
+		})
+	}
+}
+
+func TestFetchChecksum(t *testing.T) {
+	content := fetchContent(10000)
+	server := httptest.NewServer(&fetchServer{content: content, etag: `"v1"`})
+	defer server.Close()
+	sum := sha256.Sum256(content)
+
+	s, driver, fetchDir := newFetchService(t, config.FetchConfig{AllowPrivateNetworks: true})
+
+	wrong := "sha256:" + strings.Repeat("0", 64)
+	_, err := s.Fetch(context.Background(), entities.Actor{}, &entities.FetchRequest{URL: server.URL + "/a.bin", Path: "/vault", Checksum: wrong}, nil)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Fetch with a wrong checksum error = %v, want ErrChecksumMismatch", err)
+	}
+	if len(driver.saved) != 0 {
+		t.Errorf("a file that doesn't match the checksum was saved")
+	}
+	assertNoPartials(t, fetchDir)
+
+	right := "SHA256:" + hex.EncodeToString(sum[:])
+	stored, err := s.Fetch(context.Background(), entities.Actor{}, &entities.FetchRequest{URL: server.URL + "/a.bin", Path: "/vault", Name: "b.bin", Checksum: right}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Path != filepath.Join("/vault", "b.bin") || !bytes.Equal(driver.saved[stored.Path], content) {
+		t.Errorf("Fetch with the right checksum saved %s", stored.Path)
+	}
+	assertNoPartials(t, fetchDir)
+
+	if err := s.Check(context.Background(), &entities.FetchRequest{URL: server.URL, Path: "/vault", Checksum: "sha256:abc"}); !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("Check with a short digest error = %v, want ErrInvalidChecksum", err)
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	var secretRequests int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://127.0.0.1:"+r.URL.Query().Get("port")+"/secret", http.StatusFound)
+			return
+		}
+		mu.Lock()
+		secretRequests++
+		mu.Unlock()
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	s, driver, fetchDir := newFetchService(t, config.FetchConfig{Retries: 5})
+	// files.example.com stands for a public server: requests to it reach the test server
+	// directly, every other one goes through the guarded transport
+	guarded := s.client.Transport
+	s.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Hostname() == "files.example.com" {
+			req = req.Clone(req.Context())
+			req.URL.Host = serverURL.Host
+			return http.DefaultTransport.RoundTrip(req)
+		}
+		return guarded.RoundTrip(req)
+	})
+
+	for _, target := range []string{
+		server.URL + "/secret",
+		"http://localhost:" + serverURL.Port() + "/secret",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://192.168.1.1/",
+		"http://files.example.com/redirect?port=" + serverURL.Port(),
+	} {
+		start := time.Now()
+		_, err := s.Fetch(context.Background(), entities.Actor{}, &entities.FetchRequest{URL: target, Path: "/vault"}, nil)
+		if !errors.Is(err, netguard.ErrBlockedAddress) {
+			t.Errorf("Fetch(%s) error = %v, want ErrBlockedAddress", target, err)
+		}
+		// Blocked addresses aren't retried
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Fetch(%s) took %s, it must fail without retrying", target, elapsed)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if secretRequests != 0 {
+		t.Errorf("the private server was reached %d times", secretRequests)
+	}
+	if len(driver.saved) != 0 {
+		t.Errorf("%d files were saved", len(driver.saved))
+	}
+	assertNoPartials(t, fetchDir)
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+)
+
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+type HealthService interface {
+	Ready(ctx context.Context) *entities.ReadinessReport
+	MarkShuttingDown()
+}
+
+type HealthServiceImpl struct {
+	healthRepo   repositories.HealthRepository
+	driverRepo   repositories.DriverRepository
+	logger       *slog.Logger
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(healthRepo repositories.HealthRepository, driverRepo repositories.DriverRepository, logger *slog.Logger) HealthService {
+	return &HealthServiceImpl{
+		healthRepo: healthRepo,
+		driverRepo: driverRepo,
+		logger:     logger.With("component", "service.health"),
+	}
+}
+
+// MarkShuttingDown makes every following readiness check fail so load
+// balancers stop routing new requests while in-flight ones drain.
+func (s *HealthServiceImpl) MarkShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// Ready checks database connectivity and that every vault root can be read.
+// Each check gives up when ctx is done, so a hung network mount can't block
+// the probe forever.
+func (s *HealthServiceImpl) Ready(ctx context.Context) *entities.ReadinessReport {
+	report := &entities.ReadinessReport{Status: HealthStatusOK}
+
+	add := func(name string, err error) {
+		check := entities.HealthCheck{Name: name, Status: HealthStatusOK}
+		if err != nil {
+			check.Status = HealthStatusFail
+			check.Error = err.Error()
+			report.Status = HealthStatusFail
+			s.logger.WarnContext(ctx, "readiness check failed", "check", name, "error", err)
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	if s.shuttingDown.Load() {
+		add("shutdown", ErrShuttingDown)
+		return report
+	}
+
+	add("database", runCheck(ctx, s.healthRepo.Ping))
+
+	roots, err := s.driverRepo.GetRoots(ctx)
+	if err != nil {
+		add("roots", err)
+		return report
+	}
+
+	for _, root := range roots {
+		add("root:"+root, runCheck(ctx, func(ctx context.Context) error {
+			return s.driverRepo.CheckReadable(ctx, root)
+		}))
+	}
+
+	return report
+}
+
+func runCheck(ctx context.Context, check func(ctx context.Context) error) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- check(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
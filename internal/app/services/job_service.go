@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/google/uuid"
+)
+
+// jobNotifyInterval is how often subscribers are sent the progress of running jobs
+const jobNotifyInterval = 500 * time.Millisecond
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobFinished  = errors.New("job has already finished")
+	ErrJobsShutdown = errors.New("server is shutting down")
+)
+
+// JobFunc does the work of a job, reporting on progress. It must return soon after ctx is
+// cancelled.
+type JobFunc func(ctx context.Context, progress *JobProgress) error
+
+// JobService runs long operations in the background so requests can return right away
+// with a job ID. Jobs report per-file progress, can be cancelled by their owner, and are
+// pushed to the owner's subscribers as they progress.
+//
+// Jobs only live in memory: they are cancelled when the server stops, and finished jobs are
+// forgotten after the configured retention.
+type JobService interface {
+	Start(actor entities.Actor, kind, target string, run JobFunc) (*entities.Job, error)
+	Get(userID, id uuid.UUID) (*entities.Job, error)
+	List(userID uuid.UUID) []entities.Job
+	Cancel(userID, id uuid.UUID) error
+	// Subscribe returns snapshots of the user's jobs whenever they change, until ctx is
+	// done or the service is closed. Snapshots only list the files that changed since
+	// the previous one.
+	Subscribe(ctx context.Context, userID uuid.UUID) <-chan entities.Job
+	// Close cancels every running job and waits for them to return.
+	Close()
+}
+
+type JobServiceImpl struct {
+	retention time.Duration
+	logger    *slog.Logger
+
+	ctx    context.Context // parent of every job's context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	done   chan struct{}
+
+	mu          sync.Mutex
+	jobs        map[uuid.UUID]*JobProgress
+	subscribers map[*jobSubscriber]struct{}
+	closeOnce   sync.Once
+}
+
+func NewJobService(cfg config.JobsConfig, logger *slog.Logger) JobService {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &JobServiceImpl{
+		retention:   cfg.Retention,
+		logger:      logger.With("component", "service.job"),
+		ctx:         ctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		jobs:        make(map[uuid.UUID]*JobProgress),
+		subscribers: make(map[*jobSubscriber]struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+func (s *JobServiceImpl) Start(actor entities.Actor, kind, target string, run JobFunc) (*entities.Job, error) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	progress := &JobProgress{
+		job: entities.Job{
+			ID:        uuid.New(),
+			UserID:    actor.UserID,
+			Kind:      kind,
+			Target:    target,
+			Status:    entities.JobStatusRunning,
+			CreatedAt: time.Now(),
+		},
+		cancel: cancel,
+		dirty:  make(map[int]struct{}),
+	}
+
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		cancel()
+		return nil, ErrJobsShutdown
+	}
+	s.jobs[progress.job.ID] = progress
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	logger := s.logger.With("job", progress.job.ID, "kind", kind, "target", target)
+	logger.Info("started job")
+
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		err := run(ctx, progress)
+		progress.finish(ctx, err)
+		if err != nil {
+			logger.Warn("job did not succeed", "error", err)
+		} else {
+			logger.Info("finished job")
+		}
+	}()
+
+	return progress.snapshot(true), nil
+}
+
+func (s *JobServiceImpl) Get(userID, id uuid.UUID) (*entities.Job, error) {
+	progress, err := s.find(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return progress.snapshot(true), nil
+}
+
+// List returns the user's jobs, newest first and without their files.
+func (s *JobServiceImpl) List(userID uuid.UUID) []entities.Job {
+	s.mu.Lock()
+	var jobs []entities.Job
+	for _, progress := range s.jobs {
+		if progress.job.UserID == userID {
+			jobs = append(jobs, *progress.snapshot(false))
+		}
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(jobs, func(a, b entities.Job) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return jobs
+}
+
+func (s *JobServiceImpl) Cancel(userID, id uuid.UUID) error {
+	progress, err := s.find(userID, id)
+	if err != nil {
+		return err
+	}
+
+	progress.mu.Lock()
+	finished := progress.job.Finished()
+	progress.mu.Unlock()
+	if finished {
+		return ErrJobFinished
+	}
+
+	progress.cancel()
+	s.logger.Info("cancelled job", "job", id)
+	return nil
+}
+
+// find returns the job if it belongs to the user. Other users' jobs are reported as not
+// found, so their IDs can't be probed.
+func (s *JobServiceImpl) find(userID, id uuid.UUID) (*JobProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	progress, ok := s.jobs[id]
+	if !ok || progress.job.UserID != userID {
+		return nil, ErrJobNotFound
+	}
+	return progress, nil
+}
+
+func (s *JobServiceImpl) Subscribe(ctx context.Context, userID uuid.UUID) <-chan entities.Job {
+	sub := &jobSubscriber{
+		userID:  userID,
+		events:  make(chan entities.Job, 64),
+		pending: make(map[uuid.UUID]entities.Job),
+	}
+
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		close(sub.events)
+		return sub.events
+	default:
+	}
+	s.subscribers[sub] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		}
+		s.mu.Lock()
+		if _, ok := s.subscribers[sub]; ok {
+			delete(s.subscribers, sub)
+			close(sub.events)
+		}
+		s.mu.Unlock()
+	}()
+
+	return sub.events
+}
+
+func (s *JobServiceImpl) Close() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.cancel()
+		s.mu.Unlock()
+
+		s.wg.Wait()
+		s.notify()
+		close(s.done)
+	})
+}
+
+// run pushes progress to subscribers and forgets finished jobs after the retention.
+func (s *JobServiceImpl) run() {
+	ticker := time.NewTicker(jobNotifyInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.notify()
+			s.expire()
+		}
+	}
+}
+
+// notify hands the changes since the last call to the subscribers. Delivery never blocks:
+// a subscriber that can't keep up is sent the latest snapshot of each job later, so it may
+// skip intermediate progress but never a job's final state.
+func (s *JobServiceImpl) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, progress := range s.jobs {
+		job, changed := progress.changes()
+		if !changed {
+			continue
+		}
+		for sub := range s.subscribers {
+			if sub.userID != job.UserID {
+				continue
+			}
+			if prev, ok := sub.pending[job.ID]; ok {
+				job.Files = mergeJobFiles(prev.Files, job.Files)
+			}
+			sub.pending[job.ID] = *job
+		}
+	}
+
+	for sub := range s.subscribers {
+		for id, job := range sub.pending {
+			select {
+			case sub.events <- job:
+				delete(sub.pending, id)
+			default:
+			}
+		}
+	}
+}
+
+func (s *JobServiceImpl) expire() {
+	cutoff := time.Now().Add(-s.retention)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maps.DeleteFunc(s.jobs, func(_ uuid.UUID, progress *JobProgress) bool {
+		progress.mu.Lock()
+		defer progress.mu.Unlock()
+		return progress.job.FinishedAt != nil && progress.job.FinishedAt.Before(cutoff) && len(progress.dirty) == 0 && !progress.changed
+	})
+}
+
+type jobSubscriber struct {
+	userID  uuid.UUID
+	events  chan entities.Job
+	pending map[uuid.UUID]entities.Job // snapshots not delivered yet
+}
+
+// mergeJobFiles adds the files of newer to older, replacing files with the same name.
+func mergeJobFiles(older, newer []entities.JobFile) []entities.JobFile {
+	merged := slices.Clone(older)
+	for _, file := range newer {
+		i := slices.IndexFunc(merged, func(f entities.JobFile) bool { return f.Name == file.Name })
+		if i >= 0 {
+			merged[i] = file
+		} else {
+			merged = append(merged, file)
+		}
+	}
+	return merged
+}
+
+// JobProgress is how a job reports what it is doing. Files are addressed by their index
+// in AddFiles. A nil *JobProgress is valid and reports nothing, so code can be shared
+// between jobs and plain requests.
+type JobProgress struct {
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	job     entities.Job
+	dirty   map[int]struct{} // files changed since the last snapshot pushed
+	changed bool             // totals changed since the last snapshot pushed
+}
+
+// AddFiles registers files the job will work on, with their sizes (0 if unknown), and
+// returns the index of the first one.
+func (p *JobProgress) AddFiles(names []string, sizes []int64) int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	first := len(p.job.Files)
+	for i, name := range names {
+		p.job.Files = append(p.job.Files, entities.JobFile{Name: name, Size: sizes[i], Status: entities.JobStatusPending})
+		p.job.TotalBytes += sizes[i]
+		p.dirty[first+i] = struct{}{}
+	}
+	p.job.TotalFiles += len(names)
+	p.changed = true
+	return first
+}
+
+// SetSize updates the size of a file once it is known.
+func (p *JobProgress) SetSize(index int, size int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file := &p.job.Files[index]
+	p.job.TotalBytes += size - file.Size
+	file.Size = size
+	p.dirty[index] = struct{}{}
+	p.changed = true
+}
+
+// StartFile marks a file as being worked on.
+func (p *JobProgress) StartFile(index int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.job.Files[index].Status = entities.JobStatusRunning
+	p.dirty[index] = struct{}{}
+}
+
+// AddBytes records n more bytes of a file as done.
+func (p *JobProgress) AddBytes(index int, n int64) {
+	if p == nil || n == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.job.Files[index].Done += n
+	p.job.DoneBytes += n
+	p.dirty[index] = struct{}{}
+	p.changed = true
+}
+
+// FinishFile records the outcome of a file and where it ended up.
+func (p *JobProgress) FinishFile(index int, path string, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file := &p.job.Files[index]
+	file.Path = path
+	if err != nil {
+		file.Status = entities.JobStatusFailed
+		file.Error = err.Error()
+		p.job.FailedFiles++
+	} else {
+		file.Status = entities.JobStatusSucceeded
+		p.job.DoneFiles++
+	}
+	p.dirty[index] = struct{}{}
+	p.changed = true
+}
+
+// Reader counts what is read from r as progress of a file.
+func (p *JobProgress) Reader(index int, r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &progressReader{r: r, progress: p, index: index}
+}
+
+func (p *JobProgress) finish(ctx context.Context, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	p.job.FinishedAt = &now
+	switch {
+	case err == nil:
+		p.job.Status = entities.JobStatusSucceeded
+	case ctx.Err() != nil:
+		p.job.Status = entities.JobStatusCancelled
+		p.job.Error = context.Canceled.Error()
+	default:
+		p.job.Status = entities.JobStatusFailed
+		p.job.Error = err.Error()
+	}
+
+	// Files that were never reached stay pending; files cut off midway were cancelled
+	for i := range p.job.Files {
+		if p.job.Files[i].Status == entities.JobStatusRunning {
+			p.job.Files[i].Status = entities.JobStatusCancelled
+			p.dirty[i] = struct{}{}
+		}
+	}
+	p.changed = true
+}
+
+// snapshot copies the job, with or without its files.
+func (p *JobProgress) snapshot(withFiles bool) *entities.Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	job := p.job
+	job.Files = nil
+	if withFiles {
+		job.Files = slices.Clone(p.job.Files)
+	}
+	return &job
+}
+
+// changes returns a snapshot with only the files changed since the previous call.
+func (p *JobProgress) changes() (*entities.Job, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.changed && len(p.dirty) == 0 {
+		return nil, false
+	}
+
+	job := p.job
+	job.Files = make([]entities.JobFile, 0, len(p.dirty))
+	for _, index := range slices.Sorted(maps.Keys(p.dirty)) {
+		job.Files = append(job.Files, p.job.Files[index])
+	}
+	clear(p.dirty)
+	p.changed = false
+	return &job, true
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress *JobProgress
+	index    int
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.progress.AddBytes(r.index, int64(n))
+	return n, err
+}
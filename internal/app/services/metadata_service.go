@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/google/uuid"
+)
+
+const defaultMetadataPageSize = 100
+
+var (
+	ErrInvalidColor = fmt.Errorf("invalid color: use one of %s", strings.Join(entities.FileColors, ", "))
+	ErrInvalidTag   = errors.New("invalid tag: tags can't be empty or contain commas")
+)
+
+type MetadataService interface {
+	Get(ctx context.Context, userID uuid.UUID, path string) (*entities.FileMeta, error)
+	Update(ctx context.Context, userID uuid.UUID, req *entities.FileMetadataRequest) (*entities.FileMeta, error)
+	Delete(ctx context.Context, userID uuid.UUID, path string) error
+	Search(ctx context.Context, userID uuid.UUID, query *entities.FileMetadataQuery) ([]entities.FileMeta, int64, error)
+	Tags(ctx context.Context, userID uuid.UUID) ([]entities.TagCount, error)
+	// Annotate adds the user's tags, favorite flags and color labels to the files of a
+	// listing.
+	Annotate(ctx context.Context, userID uuid.UUID, files []entities.FileInfo)
+
+	// FileReplaced, FileRemoved and FileMoved keep the metadata with its file when the
+	// driver service writes, deletes or moves it.
+	FileReplaced(ctx context.Context, path string)
+	FileRemoved(ctx context.Context, path string)
+	FileMoved(ctx context.Context, src, dst string)
+}
+
+// MetadataServiceImpl stores what users record about files under the identity of the file
+// rather than its path: the device and inode on local storage on Linux, so the metadata
+// follows a file that is renamed, and the path elsewhere. Writes through the vault replace
+// files with new ones, and moves may cross file systems, so the driver service reports
+// them and the metadata is moved to the new identity.
+type MetadataServiceImpl struct {
+	MetadataRepo repositories.MetadataRepository
+	DriverRepo   repositories.DriverRepository
+	Encryption   EncryptionService
+	logger       *slog.Logger
+}
+
+func NewMetadataService(metadataRepo repositories.MetadataRepository, driverRepo repositories.DriverRepository, encryption EncryptionService, logger *slog.Logger) MetadataService {
+	return &MetadataServiceImpl{
+		MetadataRepo: metadataRepo,
+		DriverRepo:   driverRepo,
+		Encryption:   encryption,
+		logger:       logger.With("component", "service.metadata"),
+	}
+}
+
+// fileID returns the identity of the file at path.
+func (s *MetadataServiceImpl) fileID(ctx context.Context, path string) (string, error) {
+	diskPath, err := s.Encryption.DiskPath(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	info, err := s.DriverRepo.Stat(ctx, diskPath)
+	if err != nil {
+		return "", err
+	}
+	if id := repositories.FileID(info); id != "" {
+		return id, nil
+	}
+	return pathFileID(path), nil
+}
+
+func pathFileID(path string) string {
+	return "path:" + path
+}
+
+func (s *MetadataServiceImpl) Get(ctx context.Context, userID uuid.UUID, path string) (*entities.FileMeta, error) {
+	path = filepath.Clean(path)
+	metadata, err := s.lookup(ctx, userID, path)
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		return emptyFileMeta(path), nil
+	}
+	return toFileMeta(metadata), nil
+}
+
+// lookup returns the user's metadata of the file at path, or nil if there is none.
+func (s *MetadataServiceImpl) lookup(ctx context.Context, userID uuid.UUID, path string) (*entities.FileMetadata, error) {
+	id, err := s.fileID(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file metadata: %w", err)
+	}
+	metadata, err := s.MetadataRepo.Get(ctx, userID, id)
+	if errors.Is(err, repositories.ErrMetadataNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file metadata: %w", err)
+	}
+	if metadata.Path != path {
+		// Renamed outside the vault
+		if err := s.MetadataRepo.Relocate(ctx, metadata.ID, metadata.FileID, path); err != nil {
+			s.logger.WarnContext(ctx, "failed to update path of file metadata", "path", path, "error", err)
+		}
+		metadata.Path = path
+	}
+	return metadata, nil
+}
+
+func (s *MetadataServiceImpl) Update(ctx context.Context, userID uuid.UUID, req *entities.FileMetadataRequest) (*entities.FileMeta, error) {
+	path := filepath.Clean(req.Path)
+	if req.Color != nil && *req.Color != "" && !slices.Contains(entities.FileColors, *req.Color) {
+		return nil, ErrInvalidColor
+	}
+	var tags []string
+	if req.Tags != nil {
+		var err error
+		if tags, err = normalizeTags(*req.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	metadata, err := s.lookup(ctx, userID, path)
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		id, err := s.fileID(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update file metadata: %w", err)
+		}
+		metadata = &entities.FileMetadata{UserID: userID, FileID: id, Path: path}
+	}
+
+	if req.Tags != nil {
+		metadata.Tags = make([]entities.FileTag, len(tags))
+		for i, tag := range tags {
+			metadata.Tags[i] = entities.FileTag{Tag: tag}
+		}
+	}
+	if req.Favorite != nil {
+		metadata.Favorite = *req.Favorite
+	}
+	if req.Color != nil {
+		metadata.Color = *req.Color
+	}
+	if req.Notes != nil {
+		metadata.Notes = make([]entities.FileNote, 0, len(req.Notes))
+		for key, value := range req.Notes {
+			metadata.Notes = append(metadata.Notes, entities.FileNote{Key: key, Value: value})
+		}
+		sort.Slice(metadata.Notes, func(i, j int) bool { return metadata.Notes[i].Key < metadata.Notes[j].Key })
+	}
+
+	// Nothing left to remember
+	if len(metadata.Tags) == 0 && !metadata.Favorite && metadata.Color == "" && len(metadata.Notes) == 0 {
+		if metadata.ID != uuid.Nil {
+			if err := s.MetadataRepo.Delete(ctx, metadata.ID); err != nil {
+				return nil, fmt.Errorf("failed to update file metadata: %w", err)
+			}
+		}
+		return emptyFileMeta(path), nil
+	}
+
+	if err := s.MetadataRepo.Save(ctx, metadata); err != nil {
+		return nil, fmt.Errorf("failed to update file metadata: %w", err)
+	}
+	s.logger.InfoContext(ctx, "updated file metadata", "path", path, "tags", len(metadata.Tags), "notes", len(metadata.Notes))
+	return toFileMeta(metadata), nil
+}
+
+// normalizeTags trims and lowercases tags and removes duplicates, so "Apollo" and "apollo "
+// are the same tag.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || strings.Contains(tag, ",") {
+			return nil, ErrInvalidTag
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+func (s *MetadataServiceImpl) Delete(ctx context.Context, userID uuid.UUID, path string) error {
+	metadata, err := s.lookup(ctx, userID, filepath.Clean(path))
+	if err != nil || metadata == nil {
+		return err
+	}
+	if err := s.MetadataRepo.Delete(ctx, metadata.ID); err != nil {
+		return fmt.Errorf("failed to delete file metadata: %w", err)
+	}
+	return nil
+}
+
+func (s *MetadataServiceImpl) Search(ctx context.Context, userID uuid.UUID, query *entities.FileMetadataQuery) ([]entities.FileMeta, int64, error) {
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PageSize == 0 {
+		query.PageSize = defaultMetadataPageSize
+	}
+	filter := repositories.FileMetadataFilter{
+		UserID:   userID,
+		Favorite: query.Favorite,
+		Color:    query.Color,
+		Offset:   (query.Page - 1) * query.PageSize,
+		Limit:    query.PageSize,
+	}
+	if query.Path != "" {
+		filter.Path = filepath.Clean(query.Path)
+	}
+	for _, tag := range query.Tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+
+	found, total, err := s.MetadataRepo.Search(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search file metadata: %w", err)
+	}
+	files := make([]entities.FileMeta, len(found))
+	for i := range found {
+		files[i] = *toFileMeta(&found[i])
+	}
+	return files, total, nil
+}
+
+func (s *MetadataServiceImpl) Tags(ctx context.Context, userID uuid.UUID) ([]entities.TagCount, error) {
+	tags, err := s.MetadataRepo.Tags(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	if tags == nil {
+		tags = []entities.TagCount{}
+	}
+	return tags, nil
+}
+
+func (s *MetadataServiceImpl) Annotate(ctx context.Context, userID uuid.UUID, files []entities.FileInfo) {
+	ids := make([]string, len(files))
+	for i := range files {
+		if files[i].FileID == "" {
+			files[i].FileID = pathFileID(files[i].Path)
+		}
+		ids[i] = files[i].FileID
+	}
+
+	found, err := s.MetadataRepo.ForFiles(ctx, userID, ids)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get file metadata for listing", "error", err)
+		return
+	}
+	byID := make(map[string]*entities.FileMetadata, len(found))
+	for i := range found {
+		byID[found[i].FileID] = &found[i]
+	}
+
+	for i := range files {
+		metadata, ok := byID[files[i].FileID]
+		if !ok {
+			continue
+		}
+		files[i].Favorite = metadata.Favorite
+		files[i].Color = metadata.Color
+		for _, tag := range metadata.Tags {
+			files[i].Tags = append(files[i].Tags, tag.Tag)
+		}
+		if metadata.Path != files[i].Path {
+			// Renamed outside the vault
+			if err := s.MetadataRepo.Relocate(ctx, metadata.ID, metadata.FileID, files[i].Path); err != nil {
+				s.logger.WarnContext(ctx, "failed to update path of file metadata", "path", files[i].Path, "error", err)
+			}
+		}
+	}
+}
+
+func (s *MetadataServiceImpl) FileReplaced(ctx context.Context, path string) {
+	path = filepath.Clean(path)
+	found, err := s.MetadataRepo.AtPath(ctx, path)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get metadata of replaced file", "path", path, "error", err)
+		return
+	}
+	s.reidentify(ctx, found)
+}
+
+func (s *MetadataServiceImpl) FileRemoved(ctx context.Context, path string) {
+	path = filepath.Clean(path)
+	if err := s.MetadataRepo.Remove(ctx, path); err != nil {
+		s.logger.WarnContext(ctx, "failed to remove metadata of deleted path", "path", path, "error", err)
+	}
+}
+
+func (s *MetadataServiceImpl) FileMoved(ctx context.Context, src, dst string) {
+	src, dst = filepath.Clean(src), filepath.Clean(dst)
+	moved, err := s.MetadataRepo.Move(ctx, src, dst)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to move metadata of moved path", "src", src, "dst", dst, "error", err)
+		return
+	}
+	// A move to another file system, or metadata kept by path, changes the identity
+	s.reidentify(ctx, moved)
+}
+
+// reidentify moves metadata to the current identity of the file at its path.
+func (s *MetadataServiceImpl) reidentify(ctx context.Context, found []entities.FileMetadata) {
+	for _, metadata := range found {
+		id, err := s.fileID(ctx, metadata.Path)
+		if err != nil || id == metadata.FileID {
+			continue
+		}
+		if err := s.MetadataRepo.Relocate(ctx, metadata.ID, id, metadata.Path); err != nil {
+			s.logger.WarnContext(ctx, "failed to update identity of file metadata", "path", metadata.Path, "error", err)
+		}
+	}
+}
+
+func emptyFileMeta(path string) *entities.FileMeta {
+	return &entities.FileMeta{Path: path, Tags: []string{}, Notes: map[string]string{}}
+}
+
+func toFileMeta(metadata *entities.FileMetadata) *entities.FileMeta {
+	meta := emptyFileMeta(metadata.Path)
+	meta.Favorite = metadata.Favorite
+	meta.Color = metadata.Color
+	for _, tag := range metadata.Tags {
+		meta.Tags = append(meta.Tags, tag.Tag)
+	}
+	for _, note := range metadata.Notes {
+		meta.Notes[note.Key] = note.Value
+	}
+	if !metadata.UpdatedAt.IsZero() {
+		updated := metadata.UpdatedAt
+		meta.UpdatedAt = &updated
+	}
+	return meta
+}
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/exif"
+)
+
+const (
+	photoQueueSize           = 1024
+	maxPhotoIndexDepth       = 64
+	defaultPhotoTimelineSize = 200
+)
+
+var (
+	ErrPhotoNotFound   = repositories.ErrPhotoNotFound
+	ErrPhotosEncrypted = errors.New("photos in encrypted folders are not indexed")
+)
+
+// photoExtensions are the images whose metadata is indexed: JPEG, and TIFF and the raw
+// formats built on it.
+var photoExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".jpe": true,
+	".tif": true, ".tiff": true,
+	".dng": true, ".nef": true, ".nrw": true, ".arw": true, ".cr2": true, ".pef": true, ".srw": true,
+}
+
+type PhotoService interface {
+	Index(ctx context.Context, path string, progress *JobProgress) (entities.PhotoIndexResult, error)
+	Get(ctx context.Context, path string) (*entities.PhotoMetadata, error)
+	Timeline(ctx context.Context, req *entities.PhotoTimelineRequest) ([]entities.PhotoDay, int64, error)
+	// StripGPS makes preview serve the image without its GPS data when stripping was
+	// requested or PHOTOS_STRIP_GPS is set. Other files are left alone.
+	StripGPS(preview *entities.PreviewInfo, requested bool) error
+
+	// FileChanged, FileRemoved and FileMoved keep the index up to date as the vault is
+	// changed. They never block: the index is updated in the background.
+	FileChanged(path string)
+	FileRemoved(path string)
+	FileMoved(src, dst string)
+	Close()
+}
+
+// PhotoServiceImpl indexes the EXIF metadata of images into the database. Images written
+// through the vault are indexed as they are saved; folders are indexed on request and
+// PHOTOS_INDEX_PATHS at startup and every PHOTOS_INDEX_INTERVAL, which also picks up
+// files changed outside the vault. An index run only reads images that are new or whose
+// size or modification time changed, and forgets the ones that are gone.
+//
+// Files in encrypted folders are never indexed, since their metadata would be stored in
+// the clear.
+type PhotoServiceImpl struct {
+	PhotoRepo  repositories.PhotoRepository
+	DriverRepo repositories.DriverRepository
+	Encryption EncryptionService
+	cfg        config.PhotosConfig
+	logger     *slog.Logger
+
+	updates   chan photoUpdate
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// photoUpdate is a change to the index queued by the driver service.
+type photoUpdate struct {
+	op       string // "changed", "removed" or "moved"
+	path     string
+	fromPath string
+}
+
+func NewPhotoService(photoRepo repositories.PhotoRepository, driverRepo repositories.DriverRepository, encryption EncryptionService, cfg config.PhotosConfig, logger *slog.Logger) PhotoService {
+	s := &PhotoServiceImpl{
+		PhotoRepo:  photoRepo,
+		DriverRepo: driverRepo,
+		Encryption: encryption,
+		cfg:        cfg,
+		logger:     logger.With("component", "service.photo"),
+		updates:    make(chan photoUpdate, photoQueueSize),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	s.wg.Add(1)
+	go s.runUpdates()
+	if len(cfg.IndexPaths) > 0 {
+		s.wg.Add(1)
+		go s.runScheduledIndex()
+	}
+	return s
+}
+
+// Close stops indexing and waits for the updates in progress.
+func (s *PhotoServiceImpl) Close() {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.wg.Wait()
+	})
+}
+
+func (s *PhotoServiceImpl) Index(ctx context.Context, path string, progress *JobProgress) (entities.PhotoIndexResult, error) {
+	var result entities.PhotoIndexResult
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return result, err
+	}
+	if s.Encryption.FolderOf(ctx, dir) != nil {
+		return result, ErrPhotosEncrypted
+	}
+	info, err := s.DriverRepo.Stat(ctx, dir)
+	if err != nil {
+		return result, err
+	}
+	if !info.IsDir() {
+		return result, fmt.Errorf("%w: %s", ErrNotDirectory, path)
+	}
+
+	indexed, err := s.PhotoRepo.ListUnder(ctx, dir)
+	if err != nil {
+		return result, fmt.Errorf("failed to load photo index: %w", err)
+	}
+	known := make(map[string]entities.PhotoMetadata, len(indexed))
+	for _, photo := range indexed {
+		known[photo.Path] = photo
+	}
+
+	index := progress.AddFiles([]string{dir}, []int64{0})
+	progress.StartFile(index)
+
+	w := &photoWalk{PhotoServiceImpl: s, known: known, seen: make(map[string]bool), progress: progress, index: index}
+	err = w.walk(ctx, dir, 0)
+	if err == nil {
+		result.Removed, err = w.forget(ctx)
+	}
+	result.Scanned, result.Indexed, result.Failed = len(w.seen), w.indexed, w.failed
+
+	progress.FinishFile(index, dir, err)
+	if err != nil {
+		return result, err
+	}
+	s.logger.InfoContext(ctx, "indexed photos", "path", dir, "scanned", result.Scanned, "indexed", result.Indexed, "removed", result.Removed, "failed", result.Failed)
+	return result, nil
+}
+
+// photoWalk is the state of one index run.
+type photoWalk struct {
+	*PhotoServiceImpl
+	known    map[string]entities.PhotoMetadata
+	seen     map[string]bool
+	skipped  []string // folders that couldn't be listed; what is known below them is kept
+	indexed  int
+	failed   int
+	progress *JobProgress
+	index    int
+}
+
+func (w *photoWalk) walk(ctx context.Context, dir string, depth int) error {
+	if depth > maxPhotoIndexDepth {
+		w.skipped = append(w.skipped, dir)
+		return nil
+	}
+	files, err := w.DriverRepo.ListPath(ctx, dir)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		w.logger.WarnContext(ctx, "failed to list folder for photo index", "path", dir, "error", err)
+		w.skipped = append(w.skipped, dir)
+		return nil
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if file.Type == "folder" {
+			if w.Encryption.FolderOf(ctx, file.Path) != nil {
+				continue
+			}
+			if err := w.walk(ctx, file.Path, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isPhoto(file.Path) {
+			continue
+		}
+
+		w.seen[file.Path] = true
+		if prev, ok := w.known[file.Path]; ok && prev.Size == file.Size && prev.Modified.Equal(file.Modified.Truncate(time.Microsecond)) {
+			continue
+		}
+		if err := w.indexFile(ctx, file.Path); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.logger.WarnContext(ctx, "failed to index photo", "path", file.Path, "error", err)
+			w.failed++
+			continue
+		}
+		w.indexed++
+	}
+	return nil
+}
+
+// forget removes the photos that are no longer there from the index.
+func (w *photoWalk) forget(ctx context.Context) (int, error) {
+	var gone []string
+	for path := range w.known {
+		if !w.seen[path] && !w.underSkipped(path) {
+			gone = append(gone, path)
+		}
+	}
+	if len(gone) == 0 {
+		return 0, nil
+	}
+	removed, err := w.PhotoRepo.Delete(ctx, gone...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove deleted photos from the index: %w", err)
+	}
+	return int(removed), nil
+}
+
+func (w *photoWalk) underSkipped(path string) bool {
+	for _, dir := range w.skipped {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexFile reads the metadata of the image at path and stores it. Images without EXIF
+// data are stored too, so they aren't read again until they change.
+func (s *PhotoServiceImpl) indexFile(ctx context.Context, path string) error {
+	file, info, absPath, err := s.DriverRepo.OpenFile(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	meta, err := exif.Decode(file)
+	if err != nil && !errors.Is(err, exif.ErrNoExif) && !errors.Is(err, exif.ErrFormat) {
+		return fmt.Errorf("failed to read EXIF data: %w", err)
+	}
+
+	photo := &entities.PhotoMetadata{
+		Path:      absPath,
+		Size:      info.Size(),
+		Modified:  info.ModTime().Truncate(time.Microsecond),
+		IndexedAt: time.Now(),
+	}
+	if meta != nil {
+		if t := meta.CapturedAt; !t.IsZero() {
+			// Stored as the time on the camera's clock, so photos are grouped by the day
+			// they were taken where they were taken
+			captured := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+			photo.CapturedAt = &captured
+			if meta.HasOffset {
+				photo.CapturedOffset = t.Format("-07:00")
+			}
+		}
+		photo.CameraMake = meta.Make
+		photo.CameraModel = meta.Model
+		photo.Orientation = meta.Orientation
+		if meta.GPS != nil {
+			photo.Latitude = &meta.GPS.Latitude
+			photo.Longitude = &meta.GPS.Longitude
+			photo.Altitude = meta.GPS.Altitude
+		}
+	}
+	return s.PhotoRepo.Save(ctx, photo)
+}
+
+func (s *PhotoServiceImpl) Get(ctx context.Context, path string) (*entities.PhotoMetadata, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	photo, err := s.PhotoRepo.Get(ctx, absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photo metadata: %w", err)
+	}
+	return photo, nil
+}
+
+// Timeline returns a page of the photos with a capture date, newest first, grouped by the
+// day they were taken, along with how many photos match in total.
+func (s *PhotoServiceImpl) Timeline(ctx context.Context, req *entities.PhotoTimelineRequest) ([]entities.PhotoDay, int64, error) {
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = defaultPhotoTimelineSize
+	}
+
+	filter := repositories.PhotoTimelineFilter{
+		From:   req.From,
+		Offset: (req.Page - 1) * req.PageSize,
+		Limit:  req.PageSize,
+	}
+	if !req.To.IsZero() {
+		// To is inclusive
+		filter.To = req.To.AddDate(0, 0, 1)
+	}
+	if req.Path != "" {
+		path, err := filepath.Abs(req.Path)
+		if err != nil {
+			return nil, 0, err
+		}
+		filter.Path = path
+	}
+
+	photos, total, err := s.PhotoRepo.Timeline(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get photo timeline: %w", err)
+	}
+
+	days := []entities.PhotoDay{}
+	for _, photo := range photos {
+		date := photo.CapturedAt.Format("2006-01-02")
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, entities.PhotoDay{Date: date})
+		}
+		days[len(days)-1].Photos = append(days[len(days)-1].Photos, photo)
+	}
+	return days, total, nil
+}
+
+func (s *PhotoServiceImpl) StripGPS(preview *entities.PreviewInfo, requested bool) error {
+	if !requested && !s.cfg.StripGPS || !isPhoto(preview.AbsPath) {
+		return nil
+	}
+	stripped, err := exif.StripGPS(preview.File)
+	if err != nil {
+		return fmt.Errorf("failed to strip GPS data: %w", err)
+	}
+	preview.File = strippedFile{stripped, preview.File}
+	return nil
+}
+
+type strippedFile struct {
+	io.ReadSeeker
+	io.Closer
+}
+
+func (s *PhotoServiceImpl) FileChanged(path string) {
+	if isPhoto(path) {
+		s.enqueue(photoUpdate{op: "changed", path: path})
+	}
+}
+
+func (s *PhotoServiceImpl) FileRemoved(path string) {
+	s.enqueue(photoUpdate{op: "removed", path: path})
+}
+
+func (s *PhotoServiceImpl) FileMoved(src, dst string) {
+	s.enqueue(photoUpdate{op: "moved", path: dst, fromPath: src})
+}
+
+func (s *PhotoServiceImpl) enqueue(update photoUpdate) {
+	select {
+	case s.updates <- update:
+	default:
+		s.logger.Warn("photo index queue full, dropping update", "op", update.op, "path", update.path)
+	}
+}
+
+func (s *PhotoServiceImpl) runUpdates() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case update := <-s.updates:
+			if err := s.apply(s.ctx, update); err != nil && s.ctx.Err() == nil {
+				s.logger.Warn("failed to update photo index", "op", update.op, "path", update.path, "error", err)
+			}
+		}
+	}
+}
+
+func (s *PhotoServiceImpl) apply(ctx context.Context, update photoUpdate) error {
+	path, err := filepath.Abs(update.path)
+	if err != nil {
+		return err
+	}
+	switch update.op {
+	case "changed":
+		if s.Encryption.FolderOf(ctx, path) != nil {
+			return nil
+		}
+		return s.indexFile(ctx, path)
+	case "removed":
+		_, err := s.PhotoRepo.Delete(ctx, path)
+		return err
+	case "moved":
+		src, err := filepath.Abs(update.fromPath)
+		if err != nil {
+			return err
+		}
+		return s.PhotoRepo.Move(ctx, src, path)
+	}
+	return nil
+}
+
+// runScheduledIndex indexes PHOTOS_INDEX_PATHS at startup and then every
+// PHOTOS_INDEX_INTERVAL.
+func (s *PhotoServiceImpl) runScheduledIndex() {
+	defer s.wg.Done()
+	for {
+		for _, path := range s.cfg.IndexPaths {
+			if _, err := s.Index(s.ctx, path, nil); err != nil && s.ctx.Err() == nil {
+				s.logger.Warn("failed to index photos", "path", path, "error", err)
+			}
+		}
+		if s.cfg.IndexInterval == 0 {
+			return
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(s.cfg.IndexInterval):
+		}
+	}
+}
+
+func isPhoto(path string) bool {
+	return photoExtensions[strings.ToLower(filepath.Ext(path))]
+}
@@ -0,0 +1,540 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/s3"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNoSuchBucket = errors.New("bucket does not exist")
+	ErrNoSuchKey    = errors.New("key does not exist")
+	ErrInvalidKey   = errors.New("invalid object key")
+	ErrNoSuchUpload = repositories.ErrUploadNotFound
+	ErrInvalidPart  = errors.New("invalid part list")
+)
+
+// S3 limits clients rely on
+const (
+	maxListKeys       = 1000
+	maxUploadParts    = 10000
+	minBucketNameSize = 3
+	maxBucketNameSize = 63
+)
+
+// S3GatewayService maps the S3 object model onto the vault for the S3-compatible front end.
+//
+// Every vault root is a bucket, named after the root ("/" is "root", "/mnt/photos" is
+// "photos"), and keys are slash-separated paths below it. Folders show up as common
+// prefixes; an empty "folder/" key creates or removes a folder. All file operations go
+// through DriverService, so path rules, atomic writes and versioning apply as they do for
+// the REST API and WebDAV.
+type S3GatewayService interface {
+	ListBuckets(ctx context.Context) ([]s3.BucketInfo, error)
+	// Resolve maps bucket and key to a vault path; an empty key is the bucket itself
+	Resolve(ctx context.Context, bucket, key string) (entities.S3Object, error)
+	// ListObjects lists keys after startAfter in lexicographic order. When the result is
+	// truncated, NextMarker holds the last key returned.
+	ListObjects(ctx context.Context, bucket, prefix, delimiter, startAfter string, maxKeys int) (*s3.ListBucketResult, error)
+
+	HeadObject(ctx context.Context, obj entities.S3Object) (os.FileInfo, error)
+	GetObject(ctx context.Context, obj entities.S3Object) (*entities.PreviewInfo, error)
+	PutObject(ctx context.Context, actor entities.Actor, obj entities.S3Object, body io.Reader) (os.FileInfo, error)
+	DeleteObject(ctx context.Context, obj entities.S3Object) error
+
+	CreateMultipartUpload(ctx context.Context, actor entities.Actor, obj entities.S3Object) (*entities.MultipartUpload, error)
+	UploadPart(ctx context.Context, actor entities.Actor, uploadID string, number int, body io.Reader) (entities.UploadPart, error)
+	CompleteMultipartUpload(ctx context.Context, actor entities.Actor, uploadID string, parts []s3.CompletedPart) (*entities.MultipartUpload, os.FileInfo, error)
+	AbortMultipartUpload(ctx context.Context, actor entities.Actor, uploadID string) (*entities.MultipartUpload, error)
+	// RemoveStaleUploads aborts multipart uploads older than the configured expiry
+	RemoveStaleUploads(ctx context.Context) (int, error)
+}
+
+type S3GatewayServiceImpl struct {
+	Driver        DriverService
+	MultipartRepo repositories.MultipartRepository
+	cfg           config.S3GatewayConfig
+	logger        *slog.Logger
+}
+
+func NewS3GatewayService(driver DriverService, multipartRepo repositories.MultipartRepository, cfg config.S3GatewayConfig, logger *slog.Logger) S3GatewayService {
+	return &S3GatewayServiceImpl{
+		Driver:        driver,
+		MultipartRepo: multipartRepo,
+		cfg:           cfg,
+		logger:        logger.With("component", "service.s3_gateway"),
+	}
+}
+
+// ObjectETag is the ETag the gateway reports for a file. Computing a content hash would
+// mean reading the whole file on every HEAD, so it is derived from the modification time
+// and size instead; the dash tells clients not to compare it with an MD5.
+func ObjectETag(info os.FileInfo) string {
+	return objectETag(info.ModTime(), info.Size())
+}
+
+func objectETag(modified time.Time, size int64) string {
+	return fmt.Sprintf(`"%x-%x"`, modified.UnixNano(), size)
+}
+
+type s3Bucket struct {
+	name    string
+	root    string
+	created time.Time
+}
+
+// buckets names the current vault roots. Roots are sorted first so a name that collides
+// (two "data" folders) always gets the same suffix.
+func (s *S3GatewayServiceImpl) buckets(ctx context.Context) ([]s3Bucket, error) {
+	roots, err := s.Driver.GetRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].Path < roots[j].Path })
+
+	taken := make(map[string]bool, len(roots))
+	buckets := make([]s3Bucket, 0, len(roots))
+	for _, root := range roots {
+		base := bucketName(root.Path)
+		name := base
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[name] = true
+		buckets = append(buckets, s3Bucket{name: name, root: root.Path, created: root.Modified})
+	}
+	return buckets, nil
+}
+
+// bucketName turns a root path into a valid S3 bucket name: lowercase letters, digits and
+// dashes, 3 to 63 characters.
+func bucketName(root string) string {
+	trimmed := strings.TrimRight(filepath.ToSlash(root), "/")
+	if trimmed == "" {
+		return "root"
+	}
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(path.Base(trimmed)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+
+	name := strings.Trim(b.String(), "-")
+	if len(name) < minBucketNameSize {
+		// Drive letters ("c") and very short folder names
+		name = strings.TrimSuffix("drive-"+name, "-")
+	}
+	if len(name) > maxBucketNameSize {
+		name = strings.TrimRight(name[:maxBucketNameSize], "-")
+	}
+	return name
+}
+
+func (s *S3GatewayServiceImpl) ListBuckets(ctx context.Context) ([]s3.BucketInfo, error) {
+	buckets, err := s.buckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]s3.BucketInfo, 0, len(buckets))
+	for _, bucket := range buckets {
+		infos = append(infos, s3.BucketInfo{Name: bucket.name, CreationDate: bucket.created.UTC()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+func (s *S3GatewayServiceImpl) Resolve(ctx context.Context, bucket, key string) (entities.S3Object, error) {
+	buckets, err := s.buckets(ctx)
+	if err != nil {
+		return entities.S3Object{}, err
+	}
+
+	obj := entities.S3Object{Bucket: bucket, Key: key}
+	for _, b := range buckets {
+		if b.name == bucket {
+			obj.Path = b.root
+			break
+		}
+	}
+	if obj.Path == "" {
+		return entities.S3Object{}, fmt.Errorf("%w: %s", ErrNoSuchBucket, bucket)
+	}
+	if key == "" {
+		return obj, nil
+	}
+
+	// Keys are plain paths below the root: no empty, "." or ".." segments that would make
+	// two keys name the same file or escape the bucket
+	for _, segment := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." ||
+			strings.ContainsRune(segment, filepath.Separator) || strings.ContainsRune(segment, 0) {
+			return entities.S3Object{}, fmt.Errorf("%w: %s", ErrInvalidKey, key)
+		}
+	}
+	obj.Path = filepath.Join(obj.Path, filepath.FromSlash(strings.TrimSuffix(key, "/")))
+	return obj, nil
+}
+
+// ListObjects walks the folders below the bucket in key order. Keys sort by byte value with
+// a folder's content following "name/", so each folder's entries are sorted by their key
+// form and folders entirely before startAfter are skipped without being read.
+func (s *S3GatewayServiceImpl) ListObjects(ctx context.Context, bucket, prefix, delimiter, startAfter string, maxKeys int) (*s3.ListBucketResult, error) {
+	root, err := s.Resolve(ctx, bucket, "")
+	if err != nil {
+		return nil, err
+	}
+	if maxKeys < 0 || maxKeys > maxListKeys {
+		maxKeys = maxListKeys
+	}
+
+	result := &s3.ListBucketResult{
+		Name:      bucket,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		MaxKeys:   maxKeys,
+	}
+
+	// Only the folder named by the prefix up to its last slash can hold matching keys
+	dirKey := prefix[:strings.LastIndex(prefix, "/")+1]
+	dir := root.Path
+	if dirKey != "" {
+		dirObj, err := s.Resolve(ctx, bucket, dirKey)
+		if err != nil {
+			if errors.Is(err, ErrInvalidKey) {
+				return result, nil
+			}
+			return nil, err
+		}
+		dir = dirObj.Path
+	}
+
+	lister := &objectLister{
+		driver:     s.Driver,
+		result:     result,
+		maxKeys:    maxKeys,
+		startAfter: startAfter,
+		recursive:  delimiter != "/",
+	}
+	if _, err := lister.walk(ctx, dir, dirKey, prefix[len(dirKey):]); err != nil {
+		return nil, err
+	}
+
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+	if result.IsTruncated {
+		result.NextMarker = lister.last
+	}
+	return result, nil
+}
+
+type objectLister struct {
+	driver     DriverService
+	result     *s3.ListBucketResult
+	maxKeys    int
+	startAfter string
+	recursive  bool
+	last       string
+}
+
+// walk lists the folder at dir, whose keys start with keyPrefix, keeping the entries whose
+// name starts with namePrefix. It returns false once the page is full.
+func (l *objectLister) walk(ctx context.Context, dir, keyPrefix, namePrefix string) (bool, error) {
+	entries, err := l.driver.ListPath(ctx, dir)
+	if err != nil {
+		// A prefix naming a file or nothing simply matches no keys
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrNotDirectory) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	sortKey := func(entry entities.FileInfo) string {
+		if entry.Type == "folder" {
+			return entry.Name + "/"
+		}
+		return entry.Name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortKey(entries[i]) < sortKey(entries[j]) })
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name, namePrefix) {
+			continue
+		}
+		key := keyPrefix + sortKey(entry)
+
+		if entry.Type != "folder" {
+			if key > l.startAfter && !l.add(key, &entry) {
+				return false, nil
+			}
+			continue
+		}
+
+		if !l.recursive {
+			if key > l.startAfter && !l.add(key, nil) {
+				return false, nil
+			}
+			continue
+		}
+
+		// Every key below the folder is greater than its prefix; they are all before
+		// startAfter when startAfter is greater and doesn't lie inside the folder
+		if key <= l.startAfter && !strings.HasPrefix(l.startAfter, key) {
+			continue
+		}
+		more, err := l.walk(ctx, entry.Path, key, "")
+		if err != nil || !more {
+			return more, err
+		}
+	}
+	return true, nil
+}
+
+// add appends an object, or a common prefix when entry is nil, unless the page is full.
+func (l *objectLister) add(key string, entry *entities.FileInfo) bool {
+	if len(l.result.Contents)+len(l.result.CommonPrefixes) >= l.maxKeys {
+		l.result.IsTruncated = true
+		return false
+	}
+
+	if entry == nil {
+		l.result.CommonPrefixes = append(l.result.CommonPrefixes, s3.CommonPrefix{Prefix: key})
+	} else {
+		l.result.Contents = append(l.result.Contents, s3.Object{
+			Key:          key,
+			LastModified: entry.Modified.UTC(),
+			ETag:         objectETag(entry.Modified, entry.Size),
+			Size:         entry.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+	l.last = key
+	return true
+}
+
+// HeadObject returns the file a key names. A key ending in "/" names a folder and is
+// reported as an empty object, the way S3 clients create folders.
+func (s *S3GatewayServiceImpl) HeadObject(ctx context.Context, obj entities.S3Object) (os.FileInfo, error) {
+	if obj.Key == "" {
+		return nil, fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+
+	info, err := s.Driver.Stat(ctx, obj.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNoSuchKey, obj.Key)
+		}
+		return nil, err
+	}
+	if info.IsDir() != strings.HasSuffix(obj.Key, "/") {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchKey, obj.Key)
+	}
+	return info, nil
+}
+
+// GetObject opens the file a key names. Folder keys have no content to open; callers check
+// HeadObject for those first.
+func (s *S3GatewayServiceImpl) GetObject(ctx context.Context, obj entities.S3Object) (*entities.PreviewInfo, error) {
+	if _, err := s.HeadObject(ctx, obj); err != nil {
+		return nil, err
+	}
+	return s.Driver.StreamFile(ctx, obj.Path)
+}
+
+// PutObject stores body at the key, replacing (and versioning) an existing file. Missing
+// parent folders are created, since S3 has no notion of them.
+func (s *S3GatewayServiceImpl) PutObject(ctx context.Context, actor entities.Actor, obj entities.S3Object, body io.Reader) (os.FileInfo, error) {
+	if obj.Key == "" {
+		return nil, fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+
+	if strings.HasSuffix(obj.Key, "/") {
+		if err := s.Driver.CreateFolder(ctx, obj.Path); err != nil {
+			return nil, err
+		}
+		return s.Driver.Stat(ctx, obj.Path)
+	}
+
+	if err := s.Driver.CreateFolder(ctx, filepath.Dir(obj.Path)); err != nil {
+		return nil, err
+	}
+	stored, err := s.Driver.SaveFile(ctx, actor, obj.Path, body, entities.OverwriteReplace)
+	if err != nil {
+		return nil, err
+	}
+	return s.Driver.Stat(ctx, stored.Path)
+}
+
+// DeleteObject removes the file a key names. Like S3 it succeeds when there is nothing to
+// delete. A folder key only removes the folder if it is empty: its content are other keys.
+func (s *S3GatewayServiceImpl) DeleteObject(ctx context.Context, obj entities.S3Object) error {
+	info, err := s.HeadObject(ctx, obj)
+	if err != nil {
+		if errors.Is(err, ErrNoSuchKey) {
+			return nil
+		}
+		return err
+	}
+
+	if info.IsDir() {
+		if err := s.Driver.DeleteEmptyFolder(ctx, obj.Path); err != nil && !errors.Is(err, ErrDirNotEmpty) {
+			return err
+		}
+		return nil
+	}
+	return s.Driver.Delete(ctx, obj.Path)
+}
+
+func (s *S3GatewayServiceImpl) CreateMultipartUpload(ctx context.Context, actor entities.Actor, obj entities.S3Object) (*entities.MultipartUpload, error) {
+	if obj.Key == "" || strings.HasSuffix(obj.Key, "/") {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, obj.Key)
+	}
+
+	upload := &entities.MultipartUpload{
+		UserID: actor.UserID,
+		Bucket: obj.Bucket,
+		Key:    obj.Key,
+		Path:   obj.Path,
+	}
+	if err := s.MultipartRepo.Create(ctx, upload); err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "started multipart upload", "upload_id", upload.ID, "path", upload.Path)
+	return upload, nil
+}
+
+func (s *S3GatewayServiceImpl) UploadPart(ctx context.Context, actor entities.Actor, uploadID string, number int, body io.Reader) (entities.UploadPart, error) {
+	if number < 1 || number > maxUploadParts {
+		return entities.UploadPart{}, fmt.Errorf("%w: part number must be between 1 and %d", ErrInvalidPart, maxUploadParts)
+	}
+
+	upload, err := s.upload(ctx, actor, uploadID)
+	if err != nil {
+		return entities.UploadPart{}, err
+	}
+
+	part, err := s.MultipartRepo.SavePart(ctx, upload, number, body)
+	if err != nil {
+		return entities.UploadPart{}, fmt.Errorf("failed to store part: %w", err)
+	}
+	return part, nil
+}
+
+// CompleteMultipartUpload joins the listed parts into the target file in one atomic write,
+// then discards the upload. The part list must be in ascending order and name stored parts
+// with their ETags.
+func (s *S3GatewayServiceImpl) CompleteMultipartUpload(ctx context.Context, actor entities.Actor, uploadID string, parts []s3.CompletedPart) (*entities.MultipartUpload, os.FileInfo, error) {
+	upload, err := s.upload(ctx, actor, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(parts) == 0 {
+		return nil, nil, fmt.Errorf("%w: no parts", ErrInvalidPart)
+	}
+
+	stored, err := s.MultipartRepo.ListParts(ctx, upload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list parts: %w", err)
+	}
+	byNumber := make(map[int]entities.UploadPart, len(stored))
+	for _, part := range stored {
+		byNumber[part.Number] = part
+	}
+
+	selected := make([]entities.UploadPart, 0, len(parts))
+	for i, requested := range parts {
+		if i > 0 && requested.PartNumber <= parts[i-1].PartNumber {
+			return nil, nil, fmt.Errorf("%w: parts must be in ascending order", ErrInvalidPart)
+		}
+		part, ok := byNumber[requested.PartNumber]
+		if !ok || strings.Trim(requested.ETag, `"`) != part.ETag {
+			return nil, nil, fmt.Errorf("%w: part %d was not uploaded", ErrInvalidPart, requested.PartNumber)
+		}
+		selected = append(selected, part)
+	}
+
+	content, err := s.MultipartRepo.OpenParts(ctx, upload, selected)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read parts: %w", err)
+	}
+	defer content.Close()
+
+	obj := entities.S3Object{Bucket: upload.Bucket, Key: upload.Key, Path: upload.Path}
+	info, err := s.PutObject(ctx, actor, obj, content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.MultipartRepo.Delete(ctx, upload); err != nil {
+		s.logger.WarnContext(ctx, "failed to remove completed multipart upload", "upload_id", upload.ID, "error", err)
+	}
+	s.logger.InfoContext(ctx, "completed multipart upload", "upload_id", upload.ID, "path", upload.Path, "parts", len(selected))
+	return upload, info, nil
+}
+
+func (s *S3GatewayServiceImpl) AbortMultipartUpload(ctx context.Context, actor entities.Actor, uploadID string) (*entities.MultipartUpload, error) {
+	upload, err := s.upload(ctx, actor, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.MultipartRepo.Delete(ctx, upload); err != nil {
+		return nil, fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+
+	s.logger.InfoContext(ctx, "aborted multipart upload", "upload_id", upload.ID, "path", upload.Path)
+	return upload, nil
+}
+
+func (s *S3GatewayServiceImpl) RemoveStaleUploads(ctx context.Context) (int, error) {
+	uploads, err := s.MultipartRepo.ListStale(ctx, time.Now().Add(-s.cfg.UploadExpiry))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list stale multipart uploads: %w", err)
+	}
+
+	removed := 0
+	for i := range uploads {
+		if err := s.MultipartRepo.Delete(ctx, &uploads[i]); err != nil {
+			return removed, fmt.Errorf("failed to remove multipart upload %s: %w", uploads[i].ID, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// upload returns the multipart upload with the given ID if it belongs to actor. Uploads of
+// other users are reported as missing rather than forbidden.
+func (s *S3GatewayServiceImpl) upload(ctx context.Context, actor entities.Actor, uploadID string) (*entities.MultipartUpload, error) {
+	id, err := uuid.Parse(uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchUpload, uploadID)
+	}
+
+	upload, err := s.MultipartRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.UserID != actor.UserID {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchUpload, uploadID)
+	}
+	return upload, nil
+}
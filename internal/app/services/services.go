@@ -1,17 +1,62 @@
 package services
 
-import "github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+import (
+	"log/slog"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+)
 
 type Services struct {
-	User   UserService
-	Auth   AuthService
-	Driver DriverService
+	User       UserService
+	Auth       AuthService
+	Driver     DriverService
+	Audit      AuditService
+	Health     HealthService
+	Version    VersionService
+	AccessKey  AccessKeyService
+	S3Gateway  S3GatewayService
+	Encryption EncryptionService
+	Events     EventService
+	Jobs       JobService
+	Fetch      FetchService
+	Photos     PhotoService
+	Text       TextService
+	Metadata   MetadataService
+	Activity   ActivityService
+	DiskUsage  DiskUsageService
+	Sync       SyncService
+	Transfer   TransferService
 }
 
-func NewServices(repo *repositories.Repositories) *Services {
+func NewServices(repo *repositories.Repositories, cfg *config.Config, logger *slog.Logger) *Services {
+	encryption := NewEncryptionService(repo.Encryption, repo.Auth, repo.Driver, cfg.Encryption, logger)
+	events := NewEventService(repo.Driver, encryption, repo.Watcher, cfg.Events, logger)
+	photos := NewPhotoService(repo.Photo, repo.Driver, encryption, cfg.Photos, logger)
+	activity := NewActivityService(repo.Activity, cfg.Activity, logger)
+	metadata := NewMetadataService(repo.Metadata, repo.Driver, encryption, logger)
+	versions := NewVersionService(repo.Version, encryption, events, photos, metadata, cfg.Versioning, logger)
+	driver := NewDriverService(repo.Driver, versions, encryption, events, photos, metadata, cfg.Upload, logger)
+
 	return &Services{
-		User:   NewUserService(repo.User),
-		Auth:   NewAuthService(repo.Auth),
-		Driver: NewDriverService(repo.Driver),
+		User:       NewUserService(repo.User),
+		Auth:       NewAuthService(repo.Auth, encryption, cfg.JWT, logger),
+		Driver:     driver,
+		Audit:      NewAuditService(repo.Audit, activity, logger),
+		Health:     NewHealthService(repo.Health, repo.Driver, logger),
+		Version:    versions,
+		AccessKey:  NewAccessKeyService(repo.AccessKey, logger),
+		S3Gateway:  NewS3GatewayService(driver, repo.Multipart, cfg.S3Gateway, logger),
+		Encryption: encryption,
+		Events:     events,
+		Jobs:       NewJobService(cfg.Jobs, logger),
+		Fetch:      NewFetchService(repo.Fetch, driver, cfg.Fetch, logger),
+		Photos:     photos,
+		Text:       NewTextService(driver, cfg.TextPreview, logger),
+		Metadata:   metadata,
+		Activity:   activity,
+		DiskUsage:  NewDiskUsageService(repo.Driver, encryption, events, cfg.DiskUsage, logger),
+		Sync:       NewSyncService(repo.Sync, driver, events, cfg.Sync, logger),
+		Transfer:   NewTransferService(repo.Transfer, repo.User, cfg.Transfer, logger),
 	}
 }
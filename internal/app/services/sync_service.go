@@ -0,0 +1,659 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/delta"
+)
+
+const (
+	syncQueueSize     = 4096
+	syncBatchSize     = 200
+	syncFlushInterval = 500 * time.Millisecond
+	syncPruneInterval = time.Hour
+
+	defaultSyncChangeLimit = 1000
+	// MaxSnapshotEntries is the most files and folders a snapshot lists
+	MaxSnapshotEntries = 100000
+	// syncHashBatch is how many cached hashes are looked up at once
+	syncHashBatch   = 1000
+	syncLockStripes = 64
+)
+
+var (
+	ErrSyncConflict     = errors.New("file was changed since the given version")
+	ErrSnapshotTooLarge = fmt.Errorf("folder has more than %d files and folders, sync its subfolders separately", MaxSnapshotEntries)
+	ErrTooManyBlocks    = fmt.Errorf("file has more than %d blocks, use a larger block size", delta.MaxBlocks)
+)
+
+// SyncConflictError is returned when a conditional write finds something other than what
+// the client expected at Path. Current is what is there now, nil if nothing is.
+type SyncConflictError struct {
+	Path    string
+	Current *entities.SyncEntry
+}
+
+func (e *SyncConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrSyncConflict, e.Path)
+}
+
+func (e *SyncConflictError) Unwrap() error { return ErrSyncConflict }
+
+// SyncCondition is the precondition of a write: IfMatch is the hash the file at the path
+// must have, or "*" for anything to be there; IfNoneMatch requires nothing to be there.
+// With neither, the write is unconditional.
+type SyncCondition struct {
+	IfMatch     string
+	IfNoneMatch bool
+}
+
+type SyncService interface {
+	Snapshot(ctx context.Context, req *entities.SyncSnapshotRequest) (*entities.SyncSnapshot, error)
+	Changes(ctx context.Context, req *entities.SyncChangesRequest) (*entities.SyncDelta, error)
+	// Upload writes src to path if cond holds, creating the folders above it.
+	Upload(ctx context.Context, actor entities.Actor, path string, src io.Reader, cond SyncCondition) (*entities.SyncEntry, error)
+	// Delete removes the file or folder tree at path if cond holds.
+	Delete(ctx context.Context, path string, cond SyncCondition) error
+	// Signature returns the block checksums of the file at path, from which a client
+	// computes the delta to its own version of the file.
+	Signature(ctx context.Context, req *entities.SyncSignatureRequest) (*entities.SyncSignature, error)
+	// UploadDelta replaces the file at path by the one rebuilt from it and the delta read
+	// from src, if the file still has the hash basis the delta was computed against.
+	UploadDelta(ctx context.Context, actor entities.Actor, path string, src io.Reader, basis string) (*entities.SyncEntry, error)
+	Close()
+}
+
+// SyncServiceImpl lets clients keep a local folder in sync with a vault folder: a snapshot
+// lists everything below the folder along with the cursor of the sync journal, and the
+// changes after a cursor are listed from the journal with the current state of each path.
+//
+// The journal is filled from the change events of the event service, so it holds every
+// change made through the vault (API, WebDAV, S3 gateway and the sync API itself), and the
+// changes made directly on disk to folders the file watcher is watching. Like the audit
+// log, changes are queued in memory and written in batches. When changes may have been
+// lost (a full queue, the file watcher falling behind, a restart) a resync is journaled,
+// which makes every client take a new snapshot. Changes older than
+// SYNC_JOURNAL_RETENTION_DAYS are deleted.
+//
+// Content hashes are the SHA-256 of files as clients see them, cached by path for as long
+// as the file's size and modification time are unchanged. Conditional writes are
+// serialized per path, so two clients can't both replace the version they last saw.
+//
+// Large files changed in a few places are sent as deltas, as rsync does: the client asks
+// for the block checksums of the file, and sends back copies of the blocks it still has
+// along with the data that changed. The file is rebuilt into a temp file like any upload,
+// and only replaces the old one if its SHA-256 is the one the client computed.
+type SyncServiceImpl struct {
+	syncRepo repositories.SyncRepository
+	Driver   DriverService
+	cfg      config.SyncConfig
+	logger   *slog.Logger
+
+	locks [syncLockStripes]sync.Mutex
+
+	queue     chan entities.SyncChange
+	dropped   atomic.Bool
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func NewSyncService(syncRepo repositories.SyncRepository, driver DriverService, events EventService, cfg config.SyncConfig, logger *slog.Logger) SyncService {
+	s := &SyncServiceImpl{
+		syncRepo: syncRepo,
+		Driver:   driver,
+		cfg:      cfg,
+		logger:   logger.With("component", "service.sync"),
+		queue:    make(chan entities.SyncChange, syncQueueSize),
+		stop:     make(chan struct{}),
+	}
+
+	events.Observe(s.record)
+	s.wg.Add(2)
+	go s.run()
+	go s.runRetention()
+	return s
+}
+
+// record queues a change for the journal. It never blocks.
+func (s *SyncServiceImpl) record(event entities.ChangeEvent) {
+	change := entities.SyncChange{
+		Type:      event.Type,
+		Path:      event.Path,
+		OldPath:   event.OldPath,
+		IsDir:     event.IsDir,
+		CreatedAt: event.Time,
+	}
+
+	select {
+	case <-s.stop:
+	case s.queue <- change:
+	default:
+		if !s.dropped.Swap(true) {
+			s.logger.Warn("sync journal queue full, clients will take new snapshots")
+		}
+	}
+}
+
+// Close stops accepting changes and writes everything still queued.
+func (s *SyncServiceImpl) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+}
+
+func (s *SyncServiceImpl) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(syncFlushInterval)
+	defer ticker.Stop()
+
+	// Changes made while the server wasn't running aren't known
+	batch := []entities.SyncChange{resyncChange()}
+	batch = s.flush(batch)
+
+	for {
+		select {
+		case change := <-s.queue:
+			batch = append(batch, change)
+			if len(batch) >= syncBatchSize {
+				batch = s.flush(batch)
+			}
+		case <-ticker.C:
+			batch = s.flush(batch)
+		case <-s.stop:
+			for {
+				select {
+				case change := <-s.queue:
+					batch = append(batch, change)
+				default:
+					s.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func resyncChange() entities.SyncChange {
+	return entities.SyncChange{Type: entities.ChangeResync, CreatedAt: time.Now()}
+}
+
+// flush journals batch, along with a resync if changes were dropped, and keeps the cached
+// hashes of moved and removed files.
+func (s *SyncServiceImpl) flush(batch []entities.SyncChange) []entities.SyncChange {
+	if s.dropped.Swap(false) {
+		batch = append(batch, resyncChange())
+	}
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx := context.Background()
+	if err := s.syncRepo.CreateChanges(ctx, batch); err != nil {
+		s.logger.Error("failed to write sync journal", "count", len(batch), "error", err)
+		s.dropped.Store(true)
+	}
+
+	for _, change := range batch {
+		var err error
+		switch change.Type {
+		case entities.ChangeRename:
+			err = s.syncRepo.MoveHashes(ctx, change.OldPath, change.Path)
+		case entities.ChangeDelete:
+			err = s.syncRepo.RemoveHashes(ctx, change.Path)
+		}
+		if err != nil {
+			s.logger.Error("failed to update cached hashes", "path", change.Path, "error", err)
+		}
+	}
+
+	return batch[:0]
+}
+
+func (s *SyncServiceImpl) runRetention() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(syncPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().AddDate(0, 0, -s.cfg.JournalRetentionDays)
+		if deleted, err := s.syncRepo.DeleteChangesBefore(context.Background(), before); err != nil {
+			s.logger.Error("failed to delete old sync journal entries", "error", err)
+		} else if deleted > 0 {
+			s.logger.Info("deleted old sync journal entries", "count", deleted, "before", before)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SyncServiceImpl) Snapshot(ctx context.Context, req *entities.SyncSnapshotRequest) (*entities.SyncSnapshot, error) {
+	root := filepath.Clean(req.Path)
+	info, err := s.Driver.Stat(ctx, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take snapshot of %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrNotDirectory, root)
+	}
+
+	// Taken first, so changes made while listing come again as changes
+	head, err := s.syncRepo.Head(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take snapshot of %s: %w", root, err)
+	}
+
+	entries := []entities.SyncEntry{}
+	folders := []string{root}
+	for len(folders) > 0 {
+		folder := folders[0]
+		folders = folders[1:]
+
+		files, err := s.Driver.ListPath(ctx, folder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to take snapshot of %s: %w", root, err)
+		}
+		for _, file := range files {
+			if len(entries) == MaxSnapshotEntries {
+				return nil, ErrSnapshotTooLarge
+			}
+			entries = append(entries, entities.SyncEntry{
+				Path:     file.Path,
+				Type:     file.Type,
+				Size:     file.Size,
+				Modified: file.Modified,
+			})
+			if file.Type == "folder" {
+				folders = append(folders, file.Path)
+			}
+		}
+	}
+
+	if !req.NoHashes {
+		if err := s.fillHashes(ctx, entries); err != nil {
+			return nil, fmt.Errorf("failed to take snapshot of %s: %w", root, err)
+		}
+	}
+
+	return &entities.SyncSnapshot{Path: root, Cursor: head, Entries: entries}, nil
+}
+
+func (s *SyncServiceImpl) Changes(ctx context.Context, req *entities.SyncChangesRequest) (*entities.SyncDelta, error) {
+	if req.Limit == 0 {
+		req.Limit = defaultSyncChangeLimit
+	}
+	root := filepath.Clean(req.Path)
+
+	head, err := s.syncRepo.Head(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	first, err := s.syncRepo.First(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+
+	delta := &entities.SyncDelta{Path: root, Cursor: max(head, req.Cursor), Changes: []entities.SyncChange{}}
+	// The changes right after the cursor were deleted
+	if first > req.Cursor+1 {
+		delta.Reset = true
+		return delta, nil
+	}
+
+	changes, err := s.syncRepo.ListChanges(ctx, repositories.SyncChangeFilter{
+		Root:   root,
+		Cursor: req.Cursor,
+		Head:   head,
+		Limit:  req.Limit + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	if len(changes) > req.Limit {
+		changes = changes[:req.Limit]
+		delta.HasMore = true
+		delta.Cursor = changes[len(changes)-1].ID
+	}
+	for _, change := range changes {
+		if change.Type == entities.ChangeResync {
+			delta.Reset, delta.HasMore, delta.Cursor = true, false, head
+			return delta, nil
+		}
+	}
+
+	// Attach the current state of every path changed, once per path
+	var entries []entities.SyncEntry
+	index := make(map[string]int)
+	for _, change := range changes {
+		if change.Type == entities.ChangeDelete {
+			continue
+		}
+		if _, ok := index[change.Path]; ok {
+			continue
+		}
+		entry, err := s.stat(ctx, change.Path)
+		if err != nil {
+			s.logger.DebugContext(ctx, "failed to stat changed path", "path", change.Path, "error", err)
+		}
+		index[change.Path] = -1
+		if entry != nil {
+			index[change.Path] = len(entries)
+			entries = append(entries, *entry)
+		}
+	}
+	if !req.NoHashes {
+		if err := s.fillHashes(ctx, entries); err != nil {
+			return nil, fmt.Errorf("failed to list changes: %w", err)
+		}
+	}
+	for i := range changes {
+		if changes[i].Type == entities.ChangeDelete {
+			continue
+		}
+		if j := index[changes[i].Path]; j >= 0 {
+			entry := entries[j]
+			changes[i].Entry = &entry
+		}
+	}
+
+	delta.Changes = changes
+	return delta, nil
+}
+
+// stat returns the entry of the file or folder at path without its hash, nil if nothing
+// is there.
+func (s *SyncServiceImpl) stat(ctx context.Context, path string) (*entities.SyncEntry, error) {
+	info, err := s.Driver.Stat(ctx, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &entities.SyncEntry{Path: path, Type: "file", Size: info.Size(), Modified: info.ModTime()}
+	if info.IsDir() {
+		entry.Type, entry.Size = "folder", 0
+	}
+	return entry, nil
+}
+
+// fillHashes sets the hash of every file of entries, from the cache or by reading it.
+// Files that can't be read (in a locked encrypted folder, or removed since they were
+// listed) are left without one.
+func (s *SyncServiceImpl) fillHashes(ctx context.Context, entries []entities.SyncEntry) error {
+	for start := 0; start < len(entries); start += syncHashBatch {
+		batch := entries[start:min(start+syncHashBatch, len(entries))]
+
+		paths := make([]string, 0, len(batch))
+		for _, entry := range batch {
+			if entry.Type == "file" {
+				paths = append(paths, entry.Path)
+			}
+		}
+		cached, err := s.syncRepo.Hashes(ctx, paths)
+		if err != nil {
+			return err
+		}
+
+		for i := range batch {
+			entry := &batch[i]
+			if entry.Type != "file" {
+				continue
+			}
+			if hash, ok := cached[entry.Path]; ok && hash.Size == entry.Size && hash.ModTime == entry.Modified.UnixNano() {
+				entry.Hash = hash.Hash
+				continue
+			}
+			if err := s.hashFile(ctx, entry); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				s.logger.DebugContext(ctx, "failed to hash file", "path", entry.Path, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// hashFile reads the file of entry to set its hash, and caches it. The size and
+// modification time of entry are updated to those of the content hashed.
+func (s *SyncServiceImpl) hashFile(ctx context.Context, entry *entities.SyncEntry) error {
+	preview, _, err := s.Driver.Downloadfile(ctx, entry.Path)
+	if err != nil {
+		return err
+	}
+	defer preview.File.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, repositories.NewContextReader(ctx, preview.File)); err != nil {
+		return err
+	}
+
+	entry.Hash = hex.EncodeToString(hash.Sum(nil))
+	entry.Size, entry.Modified = preview.Info.Size(), preview.Info.ModTime()
+	s.saveHash(ctx, entry)
+	return nil
+}
+
+func (s *SyncServiceImpl) saveHash(ctx context.Context, entry *entities.SyncEntry) {
+	err := s.syncRepo.SaveHash(ctx, &entities.FileHash{
+		Path:    entry.Path,
+		Size:    entry.Size,
+		ModTime: entry.Modified.UnixNano(),
+		Hash:    entry.Hash,
+	})
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to cache file hash", "path", entry.Path, "error", err)
+	}
+}
+
+// lock serializes conditional writes to path.
+func (s *SyncServiceImpl) lock(path string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	mu := &s.locks[h.Sum32()%syncLockStripes]
+	mu.Lock()
+	return mu
+}
+
+// check fails with a SyncConflictError unless cond holds for path.
+func (s *SyncServiceImpl) check(ctx context.Context, path string, cond SyncCondition) error {
+	if cond.IfMatch == "" && !cond.IfNoneMatch {
+		return nil
+	}
+
+	current, err := s.stat(ctx, path)
+	if err != nil {
+		return err
+	}
+	if current != nil && current.Type == "file" {
+		entries := []entities.SyncEntry{*current}
+		if err := s.fillHashes(ctx, entries); err != nil {
+			return err
+		}
+		current = &entries[0]
+	}
+
+	conflict := false
+	switch {
+	case cond.IfNoneMatch:
+		conflict = current != nil
+	case cond.IfMatch == "*":
+		conflict = current == nil
+	default:
+		conflict = current == nil || current.Type != "file" || current.Hash != cond.IfMatch
+	}
+	if conflict {
+		return &SyncConflictError{Path: path, Current: current}
+	}
+	return nil
+}
+
+func (s *SyncServiceImpl) Upload(ctx context.Context, actor entities.Actor, path string, src io.Reader, cond SyncCondition) (*entities.SyncEntry, error) {
+	path = filepath.Clean(path)
+	defer s.lock(path).Unlock()
+
+	if err := s.check(ctx, path, cond); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Driver.Stat(ctx, filepath.Dir(path)); errors.Is(err, fs.ErrNotExist) {
+		if err := s.Driver.CreateFolder(ctx, filepath.Dir(path)); err != nil {
+			return nil, err
+		}
+	}
+
+	mode := entities.OverwriteReplace
+	if cond.IfNoneMatch {
+		mode = entities.OverwriteNever
+	}
+	hash := sha256.New()
+	stored, err := s.Driver.SaveFile(ctx, actor, path, io.TeeReader(src, hash), mode)
+	if errors.Is(err, ErrFileExists) {
+		// Created outside the sync API since it was checked
+		current, _ := s.stat(ctx, path)
+		return nil, &SyncConflictError{Path: path, Current: current}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.written(ctx, stored, hex.EncodeToString(hash.Sum(nil))), nil
+}
+
+// written returns the entry of a file just written with hash, and caches its hash.
+func (s *SyncServiceImpl) written(ctx context.Context, stored entities.StoredFile, hash string) *entities.SyncEntry {
+	entry := &entities.SyncEntry{
+		Path: stored.Path,
+		Type: "file",
+		Size: stored.Size,
+		Hash: hash,
+	}
+	if info, err := s.Driver.Stat(ctx, stored.Path); err == nil {
+		entry.Modified = info.ModTime()
+		s.saveHash(ctx, entry)
+	}
+	return entry
+}
+
+func (s *SyncServiceImpl) Delete(ctx context.Context, path string, cond SyncCondition) error {
+	path = filepath.Clean(path)
+	defer s.lock(path).Unlock()
+
+	if err := s.check(ctx, path, cond); err != nil {
+		return err
+	}
+	return s.Driver.Delete(ctx, path)
+}
+
+func (s *SyncServiceImpl) Signature(ctx context.Context, req *entities.SyncSignatureRequest) (*entities.SyncSignature, error) {
+	path := filepath.Clean(req.Path)
+	preview, _, err := s.Driver.Downloadfile(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute signature of %s: %w", path, err)
+	}
+	defer preview.File.Close()
+
+	size := preview.Info.Size()
+	blockSize := req.BlockSize
+	if blockSize == 0 {
+		blockSize = delta.BlockSizeFor(size)
+	}
+	if delta.BlockCount(size, blockSize) > delta.MaxBlocks {
+		return nil, ErrTooManyBlocks
+	}
+
+	hash := sha256.New()
+	sig, err := delta.NewSignature(io.TeeReader(repositories.NewContextReader(ctx, preview.File), hash), blockSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute signature of %s: %w", path, err)
+	}
+
+	entry := &entities.SyncEntry{Path: path, Type: "file", Size: sig.Size, Modified: preview.Info.ModTime(), Hash: hex.EncodeToString(hash.Sum(nil))}
+	s.saveHash(ctx, entry)
+
+	blocks := make([]entities.SyncBlock, len(sig.Blocks))
+	for i, block := range sig.Blocks {
+		blocks[i] = entities.SyncBlock{Weak: block.Weak, Strong: hex.EncodeToString(block.Strong[:])}
+	}
+	return &entities.SyncSignature{
+		Path:      path,
+		Size:      sig.Size,
+		Hash:      entry.Hash,
+		BlockSize: sig.BlockSize,
+		Blocks:    blocks,
+	}, nil
+}
+
+func (s *SyncServiceImpl) UploadDelta(ctx context.Context, actor entities.Actor, path string, src io.Reader, basis string) (*entities.SyncEntry, error) {
+	path = filepath.Clean(path)
+	defer s.lock(path).Unlock()
+
+	if err := s.check(ctx, path, SyncCondition{IfMatch: basis}); err != nil {
+		return nil, err
+	}
+
+	preview, _, err := s.Driver.Downloadfile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	patcher := delta.NewPatcher(preview.File, preview.Info.Size(), src)
+	rebuilt := &basisReader{r: patcher, basis: preview.File}
+	defer rebuilt.Close()
+
+	hash := sha256.New()
+	stored, err := s.Driver.SaveFile(ctx, actor, path, io.TeeReader(rebuilt, hash), entities.OverwriteReplace)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "rebuilt file from delta", "path", path, "copied", patcher.Copied, "sent", patcher.Literal)
+	return s.written(ctx, stored, hex.EncodeToString(hash.Sum(nil))), nil
+}
+
+// basisReader reads a file rebuilt from a delta and closes its basis once it is read to
+// the end, since Windows can't replace a file that is still open.
+type basisReader struct {
+	r      io.Reader
+	basis  io.Closer
+	closed bool
+}
+
+func (b *basisReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		b.Close()
+	}
+	return n, err
+}
+
+func (b *basisReader) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	return b.basis.Close()
+}
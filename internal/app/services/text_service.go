@@ -0,0 +1,684 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/textfile"
+)
+
+const (
+	defaultTextLines     = 100
+	defaultTablePageSize = 100
+	maxMarkdownBlocks    = 10000
+
+	// maxTextLineBytes is where long lines are cut, so a file without line breaks can't
+	// be returned whole
+	maxTextLineBytes = 16 * 1024
+	// maxTextPreviewBytes is how much text a preview returns at most
+	maxTextPreviewBytes = 8 << 20
+	// tailScanLimit is how far back from the end of a file a tail looks for line breaks
+	tailScanLimit = 64 << 20
+	tailChunkSize = 64 * 1024
+	// maxFollowReadBytes is how much of a followed file is read per check
+	maxFollowReadBytes = 1 << 20
+	followQueueSize    = 16
+)
+
+var (
+	ErrNotText           = textfile.ErrBinary
+	ErrInvalidLineRange  = errors.New("invalid line range: from and to are required and to must not be before from")
+	ErrUnsupportedFormat = errors.New("no structured preview for this file type, use format markdown, json or csv")
+	ErrPreviewTooLarge   = errors.New("file is too large for a structured preview")
+	ErrInvalidDocument   = errors.New("file could not be parsed")
+	ErrPreviewsClosed    = errors.New("server is shutting down")
+)
+
+// structuredFormats are the structured preview formats of file extensions
+var structuredFormats = map[string]string{
+	".md":       "markdown",
+	".markdown": "markdown",
+	".json":     "json",
+	".csv":      "csv",
+	".tsv":      "csv",
+}
+
+type TextService interface {
+	Lines(ctx context.Context, req *entities.TextPreviewRequest) (*entities.TextPreview, error)
+	Structured(ctx context.Context, req *entities.StructuredPreviewRequest) (*entities.StructuredPreview, error)
+	// Follow returns the last lines of the file at path, then sends the lines appended to
+	// it until ctx is done or the service is closed, when the channel is closed.
+	Follow(ctx context.Context, path string, lines int) (*entities.TextPreview, <-chan entities.TextFollowEvent, error)
+	Close()
+}
+
+// TextServiceImpl previews text files of any size by reading only the lines asked for.
+// Files are decoded to UTF-8 from the encoding their byte order mark names, or from UTF-8
+// or Latin-1 otherwise; files with NUL bytes are refused as binary. Files are opened
+// through the driver service, so path rules and encrypted folders apply.
+type TextServiceImpl struct {
+	Driver DriverService
+	cfg    config.TextPreviewConfig
+	logger *slog.Logger
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func NewTextService(driver DriverService, cfg config.TextPreviewConfig, logger *slog.Logger) TextService {
+	return &TextServiceImpl{
+		Driver: driver,
+		cfg:    cfg,
+		logger: logger.With("component", "service.text"),
+		closed: make(chan struct{}),
+	}
+}
+
+// Close ends every follow stream.
+func (s *TextServiceImpl) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// textFile is an open file with its encoding.
+type textFile struct {
+	file io.ReadSeekCloser
+	size int64
+	enc  textfile.Encoding
+	bom  int64
+}
+
+func (s *TextServiceImpl) open(ctx context.Context, path string) (*textFile, error) {
+	preview, err := s.Driver.PreviewFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	tf := &textFile{file: preview.File, size: preview.Info.Size()}
+
+	sample := make([]byte, min(tf.size, textfile.SampleSize))
+	if _, err := io.ReadFull(tf.file, sample); err != nil {
+		tf.file.Close()
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	enc, bom, err := textfile.Detect(sample)
+	if err != nil {
+		tf.file.Close()
+		return nil, err
+	}
+	tf.enc, tf.bom = enc, int64(bom)
+	return tf, nil
+}
+
+// text returns a reader of the decoded text from offset on.
+func (tf *textFile) text(offset, limit int64) (io.Reader, error) {
+	if _, err := tf.file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return textfile.NewReader(io.LimitReader(tf.file, limit), tf.enc), nil
+}
+
+func (s *TextServiceImpl) Lines(ctx context.Context, req *entities.TextPreviewRequest) (*entities.TextPreview, error) {
+	view := req.View
+	if view == "" {
+		view = entities.TextViewHead
+	}
+	count := req.Lines
+	if count == 0 {
+		count = defaultTextLines
+	}
+	first := 1
+	if view == entities.TextViewRange {
+		if req.From == 0 || req.To < req.From {
+			return nil, ErrInvalidLineRange
+		}
+		first, count = req.From, req.To-req.From+1
+	}
+	count = min(count, s.cfg.MaxLines)
+
+	tf, err := s.open(ctx, req.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for preview: %w", err)
+	}
+	defer tf.file.Close()
+
+	preview := &entities.TextPreview{
+		Path:     req.Path,
+		Encoding: string(tf.enc),
+		Size:     tf.size,
+		View:     view,
+		Lines:    []string{},
+	}
+	if view == entities.TextViewTail {
+		err = s.tail(ctx, tf, preview, count)
+	} else {
+		err = s.head(ctx, tf, preview, first, count)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return preview, nil
+}
+
+// head reads count lines starting at line first.
+func (s *TextServiceImpl) head(ctx context.Context, tf *textFile, preview *entities.TextPreview, first, count int) error {
+	text, err := tf.text(tf.bom, tf.size-tf.bom)
+	if err != nil {
+		return err
+	}
+	lines := newLineReader(text)
+	preview.FirstLine = first
+
+	for i := 1; i < first; i++ {
+		if i%4096 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, err := lines.next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+
+	size := 0
+	for len(preview.Lines) < count && size < maxTextPreviewBytes {
+		line, err := lines.next()
+		if err == io.EOF {
+			preview.Truncated = lines.truncated
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		preview.Lines = append(preview.Lines, line)
+		size += len(line)
+	}
+	preview.Truncated = lines.truncated
+
+	_, err = lines.next()
+	preview.HasMore = err == nil
+	return nil
+}
+
+// tail reads the last count lines.
+func (s *TextServiceImpl) tail(ctx context.Context, tf *textFile, preview *entities.TextPreview, count int) error {
+	start, partial, more, err := tf.tailStart(ctx, count)
+	if err != nil {
+		return err
+	}
+	text, err := tf.text(start, tf.size-start)
+	if err != nil {
+		return err
+	}
+	lines := newLineReader(text)
+	if partial {
+		// The scan stopped in the middle of a line
+		if _, err := lines.next(); err != nil && err != io.EOF {
+			return err
+		}
+		lines.truncated = false
+	}
+
+	size := 0
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		preview.Lines = append(preview.Lines, line)
+		size += len(line)
+		// Keep the last lines that fit
+		for size > maxTextPreviewBytes || len(preview.Lines) > count {
+			size -= len(preview.Lines[0])
+			preview.Lines = preview.Lines[1:]
+			more = true
+		}
+	}
+	preview.HasMore = more
+	preview.Truncated = lines.truncated
+	return nil
+}
+
+// tailStart finds where the last count lines of the file start, scanning back from the
+// end for line breaks. partial reports that the scan gave up before finding them all, in
+// the middle of a line; more that there are lines before start.
+func (tf *textFile) tailStart(ctx context.Context, count int) (start int64, partial, more bool, err error) {
+	unit := int64(tf.enc.UnitSize())
+	newline := tf.enc.Newline()
+
+	end := tf.bom + (tf.size-tf.bom)/unit*unit
+	// A line break at the very end doesn't start another line
+	if end-unit >= tf.bom {
+		last := make([]byte, unit)
+		if _, err := tf.file.Seek(end-unit, io.SeekStart); err != nil {
+			return 0, false, false, err
+		}
+		if _, err := io.ReadFull(tf.file, last); err != nil {
+			return 0, false, false, err
+		}
+		if bytes.Equal(last, newline) {
+			end -= unit
+		}
+	}
+
+	limit := max(tf.bom, end-tailScanLimit)
+	limit = tf.bom + (limit-tf.bom+unit-1)/unit*unit
+
+	buf := make([]byte, tailChunkSize)
+	found := 0
+	for pos := end; pos > limit; {
+		if err := ctx.Err(); err != nil {
+			return 0, false, false, err
+		}
+		chunkStart := max(limit, pos-tailChunkSize)
+		chunk := buf[:pos-chunkStart]
+		if _, err := tf.file.Seek(chunkStart, io.SeekStart); err != nil {
+			return 0, false, false, err
+		}
+		if _, err := io.ReadFull(tf.file, chunk); err != nil {
+			return 0, false, false, err
+		}
+		for i := int64(len(chunk)) - unit; i >= 0; i -= unit {
+			if bytes.Equal(chunk[i:i+unit], newline) {
+				if found++; found == count {
+					return chunkStart + i + unit, false, true, nil
+				}
+			}
+		}
+		pos = chunkStart
+	}
+	if limit > tf.bom {
+		return limit, true, true, nil
+	}
+	return tf.bom, false, false, nil
+}
+
+func (s *TextServiceImpl) Follow(ctx context.Context, path string, lines int) (*entities.TextPreview, <-chan entities.TextFollowEvent, error) {
+	select {
+	case <-s.closed:
+		return nil, nil, ErrPreviewsClosed
+	default:
+	}
+
+	preview, err := s.Lines(ctx, &entities.TextPreviewRequest{Path: path, View: entities.TextViewTail, Lines: lines})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := &follower{
+		TextServiceImpl: s,
+		path:            path,
+		enc:             textfile.Encoding(preview.Encoding),
+		offset:          preview.Size,
+		events:          make(chan entities.TextFollowEvent, followQueueSize),
+	}
+	go f.run(ctx)
+	return preview, f.events, nil
+}
+
+// follower sends the lines appended to a file.
+type follower struct {
+	*TextServiceImpl
+	path    string
+	enc     textfile.Encoding
+	offset  int64 // where the next line starts
+	partial int   // bytes after offset known to hold an unfinished line
+	events  chan entities.TextFollowEvent
+}
+
+func (f *follower) run(ctx context.Context) {
+	defer close(f.events)
+
+	ticker := time.NewTicker(f.cfg.FollowInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.closed:
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			event, read, err := f.check(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					f.logger.DebugContext(ctx, "failed to read followed file", "path", f.path, "error", err)
+				}
+				break
+			}
+			if event != nil && !f.send(ctx, *event) {
+				return
+			}
+			// Keep reading while a lot was appended
+			if read < maxFollowReadBytes {
+				break
+			}
+		}
+	}
+}
+
+func (f *follower) send(ctx context.Context, event entities.TextFollowEvent) bool {
+	select {
+	case f.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-f.closed:
+		return false
+	}
+}
+
+// check reads what was appended since the last check and returns the complete lines in
+// it, or a reset if the file shrank.
+func (f *follower) check(ctx context.Context) (*entities.TextFollowEvent, int, error) {
+	info, err := f.Driver.Stat(ctx, f.path)
+	var size int64
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// Removed, maybe to be recreated: follow it from the start when it is
+	case err != nil:
+		return nil, 0, err
+	default:
+		size = info.Size()
+	}
+
+	if size < f.offset {
+		f.offset, f.partial = 0, 0
+		return &entities.TextFollowEvent{Type: entities.TextFollowReset}, 0, nil
+	}
+	if size <= f.offset+int64(f.partial) {
+		return nil, 0, nil
+	}
+
+	tf, err := f.open(ctx, f.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tf.file.Close()
+	if f.offset == 0 {
+		// A new file, which may have another encoding
+		f.enc, f.offset = tf.enc, tf.bom
+	}
+
+	data := make([]byte, min(size-f.offset, maxFollowReadBytes))
+	if _, err := tf.file.Seek(f.offset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	n, err := io.ReadFull(tf.file, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, 0, err
+	}
+	data = data[:n]
+
+	// Only complete lines are sent, unless a line is too long to wait for
+	complete := lastLineEnd(data, f.enc)
+	if complete == 0 {
+		if len(data) < maxTextLineBytes && len(data) < maxFollowReadBytes {
+			f.partial = len(data)
+			return nil, n, nil
+		}
+		complete = len(data) / f.enc.UnitSize() * f.enc.UnitSize()
+	}
+	f.offset += int64(complete)
+	f.partial = 0
+
+	lines := newLineReader(textfile.NewReader(bytes.NewReader(data[:complete]), f.enc))
+	event := &entities.TextFollowEvent{Type: entities.TextFollowLines}
+	for {
+		line, err := lines.next()
+		if err != nil {
+			break
+		}
+		event.Lines = append(event.Lines, line)
+	}
+	event.Truncated = lines.truncated
+	return event, n, nil
+}
+
+// lastLineEnd returns the length of the complete lines at the start of data.
+func lastLineEnd(data []byte, enc textfile.Encoding) int {
+	unit := enc.UnitSize()
+	newline := enc.Newline()
+	for i := len(data)/unit*unit - unit; i >= 0; i -= unit {
+		if bytes.Equal(data[i:i+unit], newline) {
+			return i + unit
+		}
+	}
+	return 0
+}
+
+func (s *TextServiceImpl) Structured(ctx context.Context, req *entities.StructuredPreviewRequest) (*entities.StructuredPreview, error) {
+	ext := strings.ToLower(filepath.Ext(req.Path))
+	format := req.Format
+	if format == "" {
+		format = structuredFormats[ext]
+	}
+	if format == "" {
+		return nil, ErrUnsupportedFormat
+	}
+
+	tf, err := s.open(ctx, req.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for preview: %w", err)
+	}
+	defer tf.file.Close()
+	if format != "csv" && tf.size > s.cfg.MaxStructuredSize {
+		return nil, fmt.Errorf("%w: the limit is %d MB", ErrPreviewTooLarge, s.cfg.MaxStructuredSize>>20)
+	}
+
+	text, err := tf.text(tf.bom, tf.size-tf.bom)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	preview := &entities.StructuredPreview{
+		Path:     req.Path,
+		Encoding: string(tf.enc),
+		Format:   format,
+	}
+
+	switch format {
+	case "markdown":
+		blocks, more, err := textfile.ParseMarkdown(text, maxMarkdownBlocks)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+		preview.Markdown = make([]entities.MarkdownBlock, len(blocks))
+		for i, block := range blocks {
+			preview.Markdown[i] = entities.MarkdownBlock(block)
+		}
+		preview.HasMore = more
+	case "json":
+		preview.JSON, err = parseJSON(text)
+	case "csv":
+		comma := rune(0)
+		if ext == ".tsv" {
+			comma = '\t'
+		}
+		err = readTable(ctx, text, comma, req, preview)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// parseJSON parses a JSON document, keeping numbers exact. Syntax errors are reported
+// with their line and column.
+func parseJSON(text io.Reader) (any, error) {
+	data, err := io.ReadAll(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	err = dec.Decode(&value)
+	if err == nil {
+		if _, extra := dec.Token(); extra != io.EOF {
+			err = fmt.Errorf("unexpected data after the document")
+		}
+	}
+	if err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			line, col := lineAndColumn(data, syntax.Offset)
+			return nil, fmt.Errorf("%w: invalid JSON at line %d, column %d: %v", ErrInvalidDocument, line, col, err)
+		}
+		return nil, fmt.Errorf("%w: invalid JSON: %v", ErrInvalidDocument, err)
+	}
+	return value, nil
+}
+
+func lineAndColumn(data []byte, offset int64) (int, int) {
+	before := data[:min(offset, int64(len(data)))]
+	line := bytes.Count(before, []byte{'\n'}) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// readTable reads a page of the rows of a CSV file. Unless comma is given, the delimiter
+// is the most common of comma, semicolon and tab on the first line.
+func readTable(ctx context.Context, text io.Reader, comma rune, req *entities.StructuredPreviewRequest, preview *entities.StructuredPreview) error {
+	br := bufio.NewReaderSize(text, 64*1024)
+	if comma == 0 {
+		comma = sniffDelimiter(br)
+	}
+
+	r := csv.NewReader(br)
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	page, pageSize := req.Page, req.PageSize
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = defaultTablePageSize
+	}
+	table := &entities.TablePreview{Delimiter: string(comma), Rows: [][]string{}}
+	preview.Table, preview.Page, preview.PageSize = table, page, pageSize
+
+	read := func() ([]string, error) {
+		record, err := r.Read()
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+		return record, err
+	}
+
+	if !req.NoHeader {
+		header, err := read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		table.Columns = header
+	}
+
+	for i := 0; i < (page-1)*pageSize; i++ {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, err := read(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+	for len(table.Rows) < pageSize {
+		record, err := read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		table.Rows = append(table.Rows, record)
+	}
+
+	_, err := read()
+	preview.HasMore = err == nil
+	return nil
+}
+
+func sniffDelimiter(br *bufio.Reader) rune {
+	sample, _ := br.Peek(br.Size())
+	if i := bytes.IndexByte(sample, '\n'); i >= 0 {
+		sample = sample[:i]
+	}
+	best, bestCount := ',', bytes.Count(sample, []byte{','})
+	for _, candidate := range []rune{';', '\t', '|'} {
+		if n := bytes.Count(sample, []byte(string(candidate))); n > bestCount {
+			best, bestCount = candidate, n
+		}
+	}
+	return best
+}
+
+// lineReader reads lines of text, cutting lines longer than maxTextLineBytes.
+type lineReader struct {
+	r *bufio.Reader
+	// truncated reports that a line was cut
+	truncated bool
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// next returns the next line without its line break, or io.EOF after the last one.
+func (l *lineReader) next() (string, error) {
+	var line []byte
+	cut := false
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		if err == nil {
+			chunk = chunk[:len(chunk)-1]
+		}
+		if room := max(maxTextLineBytes-len(line), 0); len(chunk) > room {
+			line = append(line, chunk[:room]...)
+			cut = true
+		} else {
+			line = append(line, chunk...)
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0 && !cut) {
+			return "", err
+		}
+		break
+	}
+
+	s := strings.TrimSuffix(string(line), "\r")
+	if cut {
+		// The cut may have split a character
+		s = strings.ToValidUTF8(s, "")
+		l.truncated = true
+	}
+	return s, nil
+}
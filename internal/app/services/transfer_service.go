@@ -0,0 +1,502 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/throttle"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	transferFlushInterval = 10 * time.Second
+	transferPruneInterval = time.Hour
+	// transferUsageRetentionDays is how long the daily usage of users is kept
+	transferUsageRetentionDays = 30
+
+	transferDayLayout = "2006-01-02"
+)
+
+var (
+	ErrTransferQuotaExceeded = errors.New("daily transfer quota exceeded")
+	ErrUserNotFound          = errors.New("user not found")
+)
+
+type TransferService interface {
+	// Begin returns the limits of a download or upload by the user. Its daily quota may
+	// already be used up, see Transfer.Status. The transfer must be ended with End.
+	Begin(ctx context.Context, userID uuid.UUID) (*Transfer, error)
+	// Status returns the user's limits and what they transferred today.
+	Status(ctx context.Context, userID uuid.UUID) (*entities.TransferStatus, error)
+	// GetLimit returns the limits of the user, the server defaults if none were set.
+	GetLimit(ctx context.Context, userID uuid.UUID) (*entities.TransferLimit, error)
+	SetLimit(ctx context.Context, userID uuid.UUID, req *entities.TransferLimitRequest) (*entities.TransferLimit, error)
+	// ResetLimit puts the user back on the server defaults.
+	ResetLimit(ctx context.Context, userID uuid.UUID) error
+	Close()
+}
+
+// TransferServiceImpl limits the bandwidth of downloads and uploads with token buckets:
+// one for the whole server in each direction (TRANSFER_DOWNLOAD_RATE_KB and
+// TRANSFER_UPLOAD_RATE_KB) and one per user in each direction, so a single user streaming
+// a video can't take all of the uplink. A user also has a daily quota of bytes downloaded
+// and uploaded; once it is used up, transfers in progress are cut off and new ones are
+// refused until midnight (server time).
+//
+// Users get the TRANSFER_USER_* defaults unless an admin set limits for them. Usage is
+// counted in memory and written to the database every few seconds, so it survives a
+// restart; usage older than 30 days is deleted. Once the usage of a user without
+// transfers in progress is written, their state is dropped from memory and loaded again
+// on their next transfer.
+type TransferServiceImpl struct {
+	transferRepo repositories.TransferRepository
+	userRepo     repositories.UserRepository
+	cfg          config.TransferConfig
+	logger       *slog.Logger
+
+	download *throttle.Bucket
+	upload   *throttle.Bucket
+
+	mu    sync.Mutex
+	users map[uuid.UUID]*transferUser
+
+	// carriedMu guards carried. It is taken while holding the mu of a user, so no other
+	// lock may be taken while holding it.
+	carriedMu sync.Mutex
+	// carried is the unsaved usage of past days, of users whose day changed since the
+	// last flush, and the usage that failed to be saved
+	carried []entities.TransferUsage
+
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// transferUser is the state of a user's transfers. It is loaded from the database on
+// their first transfer and kept until they have no transfers in progress and their usage
+// was written.
+type transferUser struct {
+	id uuid.UUID
+	// active is the number of transfers in progress, guarded by the service's mu
+	active int
+
+	mu       sync.Mutex
+	loaded   bool
+	download *throttle.Bucket
+	upload   *throttle.Bucket
+	// quota is how many bytes the user may transfer a day, 0 for no limit
+	quota int64
+
+	day        string
+	downloaded int64
+	uploaded   int64
+	// unsavedDown and unsavedUp are the bytes of day not written to the database yet
+	unsavedDown int64
+	unsavedUp   int64
+}
+
+func NewTransferService(transferRepo repositories.TransferRepository, userRepo repositories.UserRepository, cfg config.TransferConfig, logger *slog.Logger) TransferService {
+	s := &TransferServiceImpl{
+		transferRepo: transferRepo,
+		userRepo:     userRepo,
+		cfg:          cfg,
+		logger:       logger.With("component", "service.transfer"),
+		download:     throttle.NewBucket(cfg.DownloadRate),
+		upload:       throttle.NewBucket(cfg.UploadRate),
+		users:        make(map[uuid.UUID]*transferUser),
+		stop:         make(chan struct{}),
+	}
+
+	s.wg.Add(2)
+	go s.run()
+	go s.runRetention()
+	return s
+}
+
+// Transfer is a request's share of the bandwidth of the server and of its user.
+type Transfer struct {
+	service *TransferServiceImpl
+	// user is nil for transfers that aren't made by a user
+	user  *transferUser
+	ended sync.Once
+}
+
+// End marks the transfer as finished, so the state of its user can be dropped once they
+// have no other transfer in progress. Calling it more than once has no effect.
+func (t *Transfer) End() {
+	if t.user == nil {
+		return
+	}
+	t.ended.Do(func() {
+		t.service.mu.Lock()
+		t.user.active--
+		t.service.mu.Unlock()
+	})
+}
+
+// Download takes n bytes sent to the client from the limits, waiting for the buckets to
+// allow them. It fails with ErrTransferQuotaExceeded once the user's quota is used up.
+func (t *Transfer) Download(ctx context.Context, n int) error {
+	return t.take(ctx, n, true)
+}
+
+// Upload takes n bytes received from the client from the limits, like Download.
+func (t *Transfer) Upload(ctx context.Context, n int) error {
+	return t.take(ctx, n, false)
+}
+
+func (t *Transfer) take(ctx context.Context, n int, download bool) error {
+	global, own := t.service.upload, (*throttle.Bucket)(nil)
+	if download {
+		global = t.service.download
+	}
+
+	if u := t.user; u != nil {
+		u.mu.Lock()
+		t.service.rollover(u, time.Now())
+		if u.quota > 0 && u.downloaded+u.uploaded >= u.quota {
+			u.mu.Unlock()
+			return ErrTransferQuotaExceeded
+		}
+		if download {
+			u.downloaded += int64(n)
+			u.unsavedDown += int64(n)
+			own = u.download
+		} else {
+			u.uploaded += int64(n)
+			u.unsavedUp += int64(n)
+			own = u.upload
+		}
+		u.mu.Unlock()
+	}
+
+	return throttle.Wait(ctx, n, global, own)
+}
+
+// Status returns the user's limits and what they transferred today.
+func (t *Transfer) Status() *entities.TransferStatus {
+	now := time.Now()
+	year, month, day := now.Date()
+	status := &entities.TransferStatus{
+		DownloadRateKB: t.service.cfg.UserDownloadRate >> 10,
+		UploadRateKB:   t.service.cfg.UserUploadRate >> 10,
+		ResetsAt:       time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()),
+	}
+
+	u := t.user
+	if u == nil {
+		return status
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	t.service.rollover(u, now)
+	status.DownloadRateKB = u.download.Rate() >> 10
+	status.UploadRateKB = u.upload.Rate() >> 10
+	status.DailyQuotaMB = u.quota >> 20
+	status.Downloaded = u.downloaded
+	status.Uploaded = u.uploaded
+	if u.quota > 0 {
+		remaining := max(u.quota-u.downloaded-u.uploaded, 0)
+		status.Remaining = &remaining
+	}
+	return status
+}
+
+func (s *TransferServiceImpl) Begin(ctx context.Context, userID uuid.UUID) (*Transfer, error) {
+	if userID == uuid.Nil {
+		return &Transfer{service: s}, nil
+	}
+
+	s.mu.Lock()
+	u, ok := s.users[userID]
+	if !ok {
+		u = &transferUser{id: userID}
+		s.users[userID] = u
+	}
+	u.active++
+	s.mu.Unlock()
+	transfer := &Transfer{service: s, user: u}
+
+	var err error
+	u.mu.Lock()
+	if !u.loaded {
+		err = s.load(ctx, u)
+	}
+	u.mu.Unlock()
+	if err != nil {
+		transfer.End()
+		return nil, fmt.Errorf("failed to load transfer limits: %w", err)
+	}
+	return transfer, nil
+}
+
+// load reads the limits of u and what they transferred today. u.mu must be held.
+func (s *TransferServiceImpl) load(ctx context.Context, u *transferUser) error {
+	limit, err := s.transferRepo.GetLimit(ctx, u.id)
+	if err != nil {
+		return err
+	}
+	day := time.Now().Format(transferDayLayout)
+	usage, err := s.transferRepo.GetUsage(ctx, u.id, day)
+	if err != nil {
+		return err
+	}
+
+	if limit == nil {
+		limit = s.defaultLimit(u.id)
+	}
+	u.download = throttle.NewBucket(limit.DownloadRateKB << 10)
+	u.upload = throttle.NewBucket(limit.UploadRateKB << 10)
+	u.quota = limit.DailyQuotaMB << 20
+	u.day, u.downloaded, u.uploaded = day, usage.Downloaded, usage.Uploaded
+	u.loaded = true
+	return nil
+}
+
+// apply changes the limits of u to limit, the defaults if nil. u.mu must be held.
+func (s *TransferServiceImpl) apply(u *transferUser, limit *entities.TransferLimit) {
+	if limit == nil {
+		limit = s.defaultLimit(u.id)
+	}
+	u.download.SetRate(limit.DownloadRateKB << 10)
+	u.upload.SetRate(limit.UploadRateKB << 10)
+	u.quota = limit.DailyQuotaMB << 20
+}
+
+func (s *TransferServiceImpl) defaultLimit(userID uuid.UUID) *entities.TransferLimit {
+	return &entities.TransferLimit{
+		UserID:         userID,
+		DownloadRateKB: s.cfg.UserDownloadRate >> 10,
+		UploadRateKB:   s.cfg.UserUploadRate >> 10,
+		DailyQuotaMB:   s.cfg.UserDailyQuota >> 20,
+	}
+}
+
+// rollover starts a new day for u if the day changed, keeping the unsaved usage of the
+// previous one for the next flush. u.mu must be held.
+func (s *TransferServiceImpl) rollover(u *transferUser, now time.Time) {
+	day := now.Format(transferDayLayout)
+	if u.day == day {
+		return
+	}
+
+	if u.unsavedDown > 0 || u.unsavedUp > 0 {
+		s.carriedMu.Lock()
+		s.carried = append(s.carried, entities.TransferUsage{UserID: u.id, Day: u.day, Downloaded: u.unsavedDown, Uploaded: u.unsavedUp})
+		s.carriedMu.Unlock()
+	}
+	u.day = day
+	u.downloaded, u.uploaded = 0, 0
+	u.unsavedDown, u.unsavedUp = 0, 0
+}
+
+func (s *TransferServiceImpl) Status(ctx context.Context, userID uuid.UUID) (*entities.TransferStatus, error) {
+	transfer, err := s.Begin(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer transfer.End()
+	return transfer.Status(), nil
+}
+
+func (s *TransferServiceImpl) GetLimit(ctx context.Context, userID uuid.UUID) (*entities.TransferLimit, error) {
+	if err := s.checkUser(userID); err != nil {
+		return nil, err
+	}
+
+	limit, err := s.transferRepo.GetLimit(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transfer limits: %w", err)
+	}
+	if limit == nil {
+		limit = s.defaultLimit(userID)
+	}
+	return limit, nil
+}
+
+func (s *TransferServiceImpl) SetLimit(ctx context.Context, userID uuid.UUID, req *entities.TransferLimitRequest) (*entities.TransferLimit, error) {
+	if err := s.checkUser(userID); err != nil {
+		return nil, err
+	}
+
+	limit := &entities.TransferLimit{
+		UserID:         userID,
+		DownloadRateKB: req.DownloadRateKB,
+		UploadRateKB:   req.UploadRateKB,
+		DailyQuotaMB:   req.DailyQuotaMB,
+	}
+	if err := s.transferRepo.SaveLimit(ctx, limit); err != nil {
+		return nil, fmt.Errorf("failed to save transfer limits: %w", err)
+	}
+
+	s.updateLoaded(userID, limit)
+	s.logger.InfoContext(ctx, "transfer limits set", "user_id", userID, "download_rate_kb", limit.DownloadRateKB, "upload_rate_kb", limit.UploadRateKB, "daily_quota_mb", limit.DailyQuotaMB)
+	return limit, nil
+}
+
+func (s *TransferServiceImpl) ResetLimit(ctx context.Context, userID uuid.UUID) error {
+	if err := s.checkUser(userID); err != nil {
+		return err
+	}
+	if err := s.transferRepo.DeleteLimit(ctx, userID); err != nil {
+		return fmt.Errorf("failed to reset transfer limits: %w", err)
+	}
+
+	s.updateLoaded(userID, nil)
+	s.logger.InfoContext(ctx, "transfer limits reset", "user_id", userID)
+	return nil
+}
+
+// updateLoaded applies new limits to the transfers of the user, including the ones in
+// progress.
+func (s *TransferServiceImpl) updateLoaded(userID uuid.UUID, limit *entities.TransferLimit) {
+	s.mu.Lock()
+	u, ok := s.users[userID]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.loaded {
+		s.apply(u, limit)
+	}
+}
+
+func (s *TransferServiceImpl) checkUser(userID uuid.UUID) error {
+	if _, err := s.userRepo.GetByID(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	return nil
+}
+
+// Close stops the background work and writes the usage not saved yet.
+func (s *TransferServiceImpl) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+}
+
+func (s *TransferServiceImpl) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(transferFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush adds the usage counted since the last flush to the database, then drops the
+// users that have nothing left to save.
+func (s *TransferServiceImpl) flush() {
+	s.carriedMu.Lock()
+	usages := s.carried
+	s.carried = nil
+	s.carriedMu.Unlock()
+
+	s.mu.Lock()
+	users := make([]*transferUser, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	s.mu.Unlock()
+
+	for _, u := range users {
+		u.mu.Lock()
+		if u.unsavedDown > 0 || u.unsavedUp > 0 {
+			usages = append(usages, entities.TransferUsage{UserID: u.id, Day: u.day, Downloaded: u.unsavedDown, Uploaded: u.unsavedUp})
+			u.unsavedDown, u.unsavedUp = 0, 0
+		}
+		u.mu.Unlock()
+	}
+	if len(usages) == 0 {
+		s.evict()
+		return
+	}
+
+	// A row can only be updated once per statement
+	merged := usages[:0]
+	index := make(map[entities.TransferUsage]int)
+	for _, usage := range usages {
+		key := entities.TransferUsage{UserID: usage.UserID, Day: usage.Day}
+		if i, ok := index[key]; ok {
+			merged[i].Downloaded += usage.Downloaded
+			merged[i].Uploaded += usage.Uploaded
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, usage)
+	}
+
+	if err := s.transferRepo.AddUsage(context.Background(), merged); err != nil {
+		s.logger.Error("failed to save transfer usage, retrying later", "count", len(merged), "error", err)
+		s.carriedMu.Lock()
+		s.carried = append(s.carried, merged...)
+		s.carriedMu.Unlock()
+		return
+	}
+	s.evict()
+}
+
+// evict drops the users without transfers in progress whose usage was all written, so
+// the memory used doesn't grow with every user who ever transferred a file. A user
+// dropped before midnight starts the new day when loaded again.
+func (s *TransferServiceImpl) evict() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, u := range s.users {
+		if u.active > 0 {
+			continue
+		}
+		u.mu.Lock()
+		saved := u.unsavedDown == 0 && u.unsavedUp == 0
+		u.mu.Unlock()
+		if saved {
+			delete(s.users, id)
+		}
+	}
+}
+
+func (s *TransferServiceImpl) runRetention() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(transferPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().AddDate(0, 0, -transferUsageRetentionDays).Format(transferDayLayout)
+		if deleted, err := s.transferRepo.DeleteUsageBefore(context.Background(), before); err != nil {
+			s.logger.Error("failed to delete old transfer usage", "error", err)
+		} else if deleted > 0 {
+			s.logger.Info("deleted old transfer usage", "count", deleted, "before", before)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/google/uuid"
+)
+
+// transferRepo keeps usage in memory and can be made to fail saving it.
+type transferRepo struct {
+	repositories.TransferRepository
+
+	mu    sync.Mutex
+	usage map[uuid.UUID]int64
+	fail  bool
+}
+
+func (r *transferRepo) GetLimit(ctx context.Context, userID uuid.UUID) (*entities.TransferLimit, error) {
+	return nil, nil
+}
+
+func (r *transferRepo) GetUsage(ctx context.Context, userID uuid.UUID, day string) (*entities.TransferUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &entities.TransferUsage{UserID: userID, Day: day, Downloaded: r.usage[userID]}, nil
+}
+
+func (r *transferRepo) AddUsage(ctx context.Context, usages []entities.TransferUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		return errors.New("database is down")
+	}
+	for _, usage := range usages {
+		r.usage[usage.UserID] += usage.Downloaded + usage.Uploaded
+	}
+	return nil
+}
+
+func (r *transferRepo) DeleteUsageBefore(ctx context.Context, day string) (int64, error) {
+	return 0, nil
+}
+
+func newTransferService(t *testing.T) (*TransferServiceImpl, *transferRepo) {
+	t.Helper()
+	repo := &transferRepo{usage: make(map[uuid.UUID]int64)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewTransferService(repo, nil, config.TransferConfig{}, logger).(*TransferServiceImpl)
+	t.Cleanup(s.Close)
+	return s, repo
+}
+
+func (s *TransferServiceImpl) loadedUsers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.users)
+}
+
+func TestTransferEvictsIdleUsers(t *testing.T) {
+	s, repo := newTransferService(t)
+	ctx := context.Background()
+	idle, busy := uuid.New(), uuid.New()
+
+	first, err := s.Begin(ctx, idle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Download(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	first.End()
+	first.End() // ending twice must not count another transfer as finished
+
+	inProgress, err := s.Begin(ctx, busy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Status(ctx, busy); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unsaved usage keeps the user loaded
+	repo.fail = true
+	s.flush()
+	if got := s.loadedUsers(); got != 2 {
+		t.Errorf("%d users loaded after a failed flush, want 2", got)
+	}
+
+	repo.fail = false
+	s.flush()
+	if got := s.loadedUsers(); got != 1 {
+		t.Errorf("%d users loaded after a flush, want only the one with a transfer in progress", got)
+	}
+	if repo.usage[idle] != 100 {
+		t.Errorf("saved usage = %d, want 100", repo.usage[idle])
+	}
+
+	// The dropped user gets their usage back from the database
+	status, err := s.Status(ctx, idle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Downloaded != 100 {
+		t.Errorf("downloaded = %d after reloading, want 100", status.Downloaded)
+	}
+
+	inProgress.End()
+	s.flush()
+	if got := s.loadedUsers(); got != 0 {
+		t.Errorf("%d users loaded once all transfers ended, want 0", got)
+	}
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/crypt"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrVersionNotFound  = repositories.ErrVersionNotFound
+	ErrVersionIsCurrent = errors.New("version is the current content of the file")
+)
+
+// VersionService keeps the previous content of overwritten files.
+//
+// Every write through the vault records a "current" version row (hash, size, author). When
+// the file is overwritten, Archive copies the old content into the version store and marks
+// that row archived. If the file changed outside the vault in the meantime, the hashes no
+// longer match and the archived version is recorded without an author.
+type VersionService interface {
+	Archive(ctx context.Context, actor entities.Actor, path string) error
+	RecordWrite(ctx context.Context, actor entities.Actor, stored entities.StoredFile)
+
+	List(ctx context.Context, path string) ([]entities.FileVersion, error)
+	Open(ctx context.Context, id uuid.UUID) (*entities.FileVersion, *entities.PreviewInfo, error)
+	Restore(ctx context.Context, actor entities.Actor, id uuid.UUID) (*entities.FileVersion, error)
+	Delete(ctx context.Context, id uuid.UUID) (*entities.FileVersion, error)
+	Prune(ctx context.Context, actor entities.Actor, path string) (int, error)
+
+	GetRetention(ctx context.Context, userID uuid.UUID) (*entities.VersionRetention, error)
+	SetRetention(ctx context.Context, userID uuid.UUID, req *entities.VersionRetentionRequest) (*entities.VersionRetention, error)
+}
+
+// VersionServiceImpl works with paths as stored on disk. Files in encrypted folders are
+// versioned sealed; versions handed out to clients get their plaintext path and size, and
+// their content is decrypted when opened.
+type VersionServiceImpl struct {
+	VersionRepo      repositories.VersionRepository
+	Encryption       EncryptionService
+	Events           EventService
+	Photos           PhotoService
+	Metadata         MetadataService
+	defaultRetention config.VersioningConfig
+	logger           *slog.Logger
+}
+
+func NewVersionService(versionRepo repositories.VersionRepository, encryption EncryptionService, events EventService, photos PhotoService, metadata MetadataService, defaultRetention config.VersioningConfig, logger *slog.Logger) VersionService {
+	return &VersionServiceImpl{
+		VersionRepo:      versionRepo,
+		Encryption:       encryption,
+		Events:           events,
+		Photos:           photos,
+		Metadata:         metadata,
+		defaultRetention: defaultRetention,
+		logger:           logger.With("component", "service.version"),
+	}
+}
+
+// Archive keeps the current content of path as a previous version before it is replaced,
+// then prunes the file's history with the actor's retention policy. A missing file is not
+// an error: there is simply nothing to keep.
+func (s *VersionServiceImpl) Archive(ctx context.Context, actor entities.Actor, path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	archived, err := s.archive(ctx, absPath)
+	if err != nil || !archived {
+		return err
+	}
+
+	if _, err := s.prune(ctx, actor.UserID, absPath); err != nil {
+		s.logger.WarnContext(ctx, "failed to prune versions", "path", absPath, "error", err)
+	}
+	return nil
+}
+
+func (s *VersionServiceImpl) archive(ctx context.Context, absPath string) (bool, error) {
+	stored, info, err := s.VersionRepo.StoreBlob(ctx, absPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to keep previous version: %w", err)
+	}
+
+	if err := s.recordArchived(ctx, absPath, stored, info.ModTime()); err != nil {
+		if removeErr := s.VersionRepo.RemoveBlob(ctx, stored.Path); removeErr != nil {
+			s.logger.ErrorContext(ctx, "failed to remove orphaned version content", "blob", stored.Path, "error", removeErr)
+		}
+		return false, fmt.Errorf("failed to record previous version: %w", err)
+	}
+
+	s.logger.DebugContext(ctx, "archived file version", "path", absPath, "bytes", stored.Size)
+	return true, nil
+}
+
+func (s *VersionServiceImpl) recordArchived(ctx context.Context, absPath string, stored entities.StoredFile, modified time.Time) error {
+	now := time.Now()
+
+	current, err := s.VersionRepo.Current(ctx, absPath)
+	if err != nil {
+		return err
+	}
+
+	if current != nil && current.Hash == stored.Hash {
+		current.BlobPath = stored.Path
+		current.ArchivedAt = &now
+		return s.VersionRepo.Update(ctx, current)
+	}
+
+	// The file was changed outside the vault since it was last written (or predates
+	// versioning), so who wrote this content is unknown.
+	if current != nil {
+		if err := s.VersionRepo.DeleteCurrent(ctx, absPath); err != nil {
+			return err
+		}
+	}
+	return s.VersionRepo.Create(ctx, &entities.FileVersion{
+		Path:       absPath,
+		Size:       stored.Size,
+		Hash:       stored.Hash,
+		CreatedAt:  modified,
+		ArchivedAt: &now,
+		BlobPath:   stored.Path,
+	})
+}
+
+// RecordWrite notes that actor just wrote stored.Path. Failures are only logged: the file
+// itself has already been written successfully.
+func (s *VersionServiceImpl) RecordWrite(ctx context.Context, actor entities.Actor, stored entities.StoredFile) {
+	if _, err := s.recordWrite(ctx, actor, stored); err != nil {
+		s.logger.WarnContext(ctx, "failed to record file version", "path", stored.Path, "error", err)
+	}
+}
+
+func (s *VersionServiceImpl) recordWrite(ctx context.Context, actor entities.Actor, stored entities.StoredFile) (*entities.FileVersion, error) {
+	if err := s.VersionRepo.DeleteCurrent(ctx, stored.Path); err != nil {
+		return nil, err
+	}
+
+	version := &entities.FileVersion{
+		Path:       stored.Path,
+		Size:       stored.Size,
+		Hash:       stored.Hash,
+		AuthorName: actor.Username,
+		CreatedAt:  time.Now(),
+	}
+	if actor.UserID != uuid.Nil {
+		version.AuthorID = &actor.UserID
+	}
+
+	if err := s.VersionRepo.Create(ctx, version); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+func (s *VersionServiceImpl) List(ctx context.Context, path string) ([]entities.FileVersion, error) {
+	absPath, err := s.diskPath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.VersionRepo.ListArchived(ctx, absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	for i := range versions {
+		s.plainVersion(ctx, &versions[i])
+	}
+	return versions, nil
+}
+
+// diskPath returns the absolute path path is stored at.
+func (s *VersionServiceImpl) diskPath(ctx context.Context, path string) (string, error) {
+	diskPath, err := s.Encryption.DiskPath(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(diskPath)
+}
+
+// plainVersion switches version to the path and size clients see.
+func (s *VersionServiceImpl) plainVersion(ctx context.Context, version *entities.FileVersion) {
+	if s.Encryption.FolderOf(ctx, version.Path) == nil {
+		return
+	}
+	version.Path = s.Encryption.PlainPath(ctx, version.Path)
+	version.Size = crypt.PlainSize(version.Size)
+}
+
+// Open returns the stored content of a previous version for download or preview. The
+// mime type is derived from the original file name.
+func (s *VersionServiceImpl) Open(ctx context.Context, id uuid.UUID) (*entities.FileVersion, *entities.PreviewInfo, error) {
+	version, err := s.VersionRepo.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version.ArchivedAt == nil {
+		return nil, nil, ErrVersionIsCurrent
+	}
+
+	file, info, err := s.VersionRepo.OpenBlob(ctx, version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open version: %w", err)
+	}
+
+	s.plainVersion(ctx, version)
+	plain, plainInfo, err := s.Encryption.Open(ctx, version.Path, file, info)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to open version: %w", err)
+	}
+
+	return version, &entities.PreviewInfo{
+		File:           plain,
+		Info:           plainInfo,
+		AbsPath:        version.Path,
+		MimeType:       detectMimeType(version.Path),
+		ShouldUseRange: true,
+	}, nil
+}
+
+// Restore makes a previous version the current content again. The content being replaced
+// is archived first, so a restore can itself be undone.
+func (s *VersionServiceImpl) Restore(ctx context.Context, actor entities.Actor, id uuid.UUID) (*entities.FileVersion, error) {
+	version, err := s.VersionRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if version.ArchivedAt == nil {
+		return nil, ErrVersionIsCurrent
+	}
+
+	// Not pruning here: the version being restored could be the one that gets pruned
+	if _, err := s.archive(ctx, version.Path); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.VersionRepo.RestoreBlob(ctx, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore version: %w", err)
+	}
+
+	current, err := s.recordWrite(ctx, actor, stored)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to record restored version", "path", stored.Path, "error", err)
+	}
+
+	if _, err := s.prune(ctx, actor.UserID, version.Path); err != nil {
+		s.logger.WarnContext(ctx, "failed to prune versions", "path", version.Path, "error", err)
+	}
+
+	plainPath := s.Encryption.PlainPath(ctx, version.Path)
+	s.Events.Publish(entities.ChangeEvent{Type: entities.ChangeModify, Path: plainPath})
+	s.Photos.FileChanged(plainPath)
+	s.Metadata.FileReplaced(ctx, plainPath)
+	s.logger.InfoContext(ctx, "restored file version", "path", version.Path, "version", version.ID)
+	if current != nil {
+		s.plainVersion(ctx, current)
+	}
+	return current, nil
+}
+
+func (s *VersionServiceImpl) Delete(ctx context.Context, id uuid.UUID) (*entities.FileVersion, error) {
+	version, err := s.VersionRepo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if version.ArchivedAt == nil {
+		return nil, ErrVersionIsCurrent
+	}
+
+	if err := s.VersionRepo.Delete(ctx, version); err != nil {
+		return nil, fmt.Errorf("failed to delete version: %w", err)
+	}
+	s.plainVersion(ctx, version)
+	return version, nil
+}
+
+// Prune applies the actor's retention policy to the history of path and returns how many
+// versions were removed.
+func (s *VersionServiceImpl) Prune(ctx context.Context, actor entities.Actor, path string) (int, error) {
+	absPath, err := s.diskPath(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	return s.prune(ctx, actor.UserID, absPath)
+}
+
+func (s *VersionServiceImpl) prune(ctx context.Context, userID uuid.UUID, absPath string) (int, error) {
+	retention, err := s.GetRetention(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	versions, err := s.VersionRepo.ListArchived(ctx, absPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	pruned := 0
+	for _, version := range prunableVersions(versions, retention, time.Now()) {
+		if err := s.VersionRepo.Delete(ctx, &version); err != nil {
+			return pruned, fmt.Errorf("failed to delete version %s: %w", version.ID, err)
+		}
+		pruned++
+	}
+
+	if pruned > 0 {
+		s.logger.DebugContext(ctx, "pruned file versions", "path", absPath, "count", pruned)
+	}
+	return pruned, nil
+}
+
+// prunableVersions returns the versions the policy doesn't keep. versions must be sorted
+// newest first. A version is kept if it is one of the last KeepLast versions, or the newest
+// version of its day within the last KeepDailyDays days.
+func prunableVersions(versions []entities.FileVersion, retention *entities.VersionRetention, now time.Time) []entities.FileVersion {
+	if retention.KeepLast == 0 && retention.KeepDailyDays == 0 {
+		return nil
+	}
+
+	cutoff := now.AddDate(0, 0, -retention.KeepDailyDays)
+	seenDays := make(map[string]bool)
+
+	var prunable []entities.FileVersion
+	for i, version := range versions {
+		keep := i < retention.KeepLast
+
+		day := version.CreatedAt.Local().Format(time.DateOnly)
+		if retention.KeepDailyDays > 0 && version.CreatedAt.After(cutoff) && !seenDays[day] {
+			keep = true
+		}
+		seenDays[day] = true
+
+		if !keep {
+			prunable = append(prunable, version)
+		}
+	}
+	return prunable
+}
+
+// GetRetention returns the user's retention policy, falling back to the configured default.
+func (s *VersionServiceImpl) GetRetention(ctx context.Context, userID uuid.UUID) (*entities.VersionRetention, error) {
+	retention, err := s.VersionRepo.GetRetention(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retention policy: %w", err)
+	}
+	if retention == nil {
+		retention = &entities.VersionRetention{
+			UserID:        userID,
+			KeepLast:      s.defaultRetention.KeepLast,
+			KeepDailyDays: s.defaultRetention.KeepDailyDays,
+		}
+	}
+	return retention, nil
+}
+
+func (s *VersionServiceImpl) SetRetention(ctx context.Context, userID uuid.UUID, req *entities.VersionRetentionRequest) (*entities.VersionRetention, error) {
+	retention := &entities.VersionRetention{
+		UserID:        userID,
+		KeepLast:      req.KeepLast,
+		KeepDailyDays: req.KeepDailyDays,
+	}
+	if err := s.VersionRepo.SaveRetention(ctx, retention); err != nil {
+		return nil, fmt.Errorf("failed to save retention policy: %w", err)
+	}
+	return retention, nil
+}
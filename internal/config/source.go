@@ -0,0 +1,222 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/ratelimit"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// source looks settings up by their environment variable names. A setting given with
+// -set wins over the environment (which includes .env), which wins over the config file
+// unless it is blank.
+type source struct {
+	flags map[string]string
+	file  map[string]string
+	// used are the settings that were looked up, to find the unknown ones in the file
+	// and flags
+	used map[string]bool
+}
+
+// newSource parses the command line and loads the .env file and the config file.
+//
+//	-config file     YAML (.yaml, .yml) or TOML (.toml) settings, also CONFIG_FILE
+//	-env-file file   environment file loaded into the environment, .env by default
+//	-set KEY=value   a setting, may be repeated
+//
+// A missing .env is not an error, unless -env-file names it.
+func newSource(args []string) (*source, error) {
+	s := &source{
+		flags: make(map[string]string),
+		file:  make(map[string]string),
+		used:  make(map[string]bool),
+	}
+
+	flags := flag.NewFlagSet("personalvault", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML or TOML config file (also CONFIG_FILE)")
+	envFile := flags.String("env-file", ".env", "environment file to load")
+	flags.Func("set", "set a setting, as KEY=value; may be repeated", func(value string) error {
+		key, value, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return errors.New("must be KEY=value")
+		}
+		s.flags[settingName(key)] = value
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	envFileSet := false
+	flags.Visit(func(f *flag.Flag) {
+		envFileSet = envFileSet || f.Name == "env-file"
+	})
+	if err := godotenv.Load(*envFile); err != nil && (envFileSet || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, fmt.Errorf("error loading env file %s: %w", *envFile, err)
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := s.readFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// readFile reads the settings of a YAML or TOML file. Keys are the names of the
+// environment variables, which can be nested by their prefix: "db: {host: x}" is DB_HOST.
+// Lists are joined with commas.
+func (s *source) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return fmt.Errorf("invalid config file %s: must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	return flattenSettings("", tree, s.file)
+}
+
+func flattenSettings(prefix string, tree map[string]any, values map[string]string) error {
+	for key, value := range tree {
+		name := settingName(key)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		if nested, ok := value.(map[string]any); ok {
+			if err := flattenSettings(name, nested, values); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, exists := values[name]; exists {
+			return fmt.Errorf("invalid config file: %s is set twice", name)
+		}
+		if list, ok := value.([]any); ok {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = formatSetting(item)
+			}
+			values[name] = strings.Join(items, ",")
+			continue
+		}
+		values[name] = formatSetting(value)
+	}
+	return nil
+}
+
+// settingName turns a key of the config file or of -set into the name of its
+// environment variable: "read-timeout" is READ_TIMEOUT.
+func settingName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(key), "-", "_"))
+}
+
+func formatSetting(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (s *source) lookup(key string) (string, bool) {
+	s.used[key] = true
+	if value, ok := s.flags[key]; ok {
+		return value, true
+	}
+	fileValue, inFile := s.file[key]
+	// Blank variables, as .env files are full of, don't override the file
+	if value, ok := os.LookupEnv(key); ok && (value != "" || !inFile) {
+		return value, true
+	}
+	return fileValue, inFile
+}
+
+// unknown returns the settings of the config file and flags that were never looked up,
+// which are most likely misspelled.
+func (s *source) unknown() []string {
+	var keys []string
+	for _, values := range []map[string]string{s.flags, s.file} {
+		for key := range values {
+			if !s.used[key] && !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (s *source) get(key, defaultValue string) string {
+	if value, exists := s.lookup(key); exists {
+		return value
+	}
+	return defaultValue
+}
+
+func (s *source) duration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value, exists := s.lookup(key)
+	if !exists || value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration for %s: %w", key, err)
+	}
+	return d, nil
+}
+
+// rateLimit reads a rate limit written as requests/period, e.g. "600/1m"; "0" turns the
+// limit off.
+func (s *source) rateLimit(key, defaultValue string) (ratelimit.Limit, error) {
+	limit, err := ratelimit.ParseLimit(s.get(key, defaultValue))
+	if err != nil {
+		return ratelimit.Limit{}, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return limit, nil
+}
+
+// list reads a comma-separated setting, leaving out empty items.
+func (s *source) list(key, defaultValue string) []string {
+	var items []string
+	for _, item := range strings.Split(s.get(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -2,9 +2,9 @@ package helper
 
 import (
 	"fmt"
-	"os"
 	"time"
 
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
@@ -20,12 +20,12 @@ type RefreshTokenClaims struct {
 	jwt.RegisteredClaims
 }
 
-func ValidateJWT(tokenString string) (*JWTClaims, error) {
+func ValidateJWT(cfg config.JWTConfig, tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
+		return []byte(cfg.Secret), nil
 	})
 
 	if err != nil {
@@ -40,50 +40,40 @@ func ValidateJWT(tokenString string) (*JWTClaims, error) {
 
 }
 
-func GenerateJWT(userId uuid.UUID, username string) (string, error) {
-	expHours := 24
-	if val := os.Getenv("JWT_EXPIRATION_HOURS"); val != "" {
-		fmt.Sscanf(val, "%d", &expHours)
-	}
-
+func GenerateJWT(cfg config.JWTConfig, userId uuid.UUID, username string) (string, error) {
 	claims := JWTClaims{
 		UserID:   userId,
 		Username: username,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expHours))),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(cfg.ExpiresInHrs))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return token.SignedString([]byte(cfg.Secret))
 }
 
-func GenerateRefreshToken(userId uuid.UUID) (string, error) {
-	expDays := 7
-	if val := os.Getenv("REFRESH_TOKEN_EXPIRATION_DAYS"); val != "" {
-		fmt.Sscanf(val, "%d", &expDays)
-	}
-
+func GenerateRefreshToken(cfg config.JWTConfig, userId uuid.UUID) (string, error) {
 	claims := JWTClaims{
 		UserID: userId,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * time.Duration(expDays))),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * time.Duration(cfg.RefreshExpiresInDays))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("REFRESH_TOKEN_SECRET")))
+	return token.SignedString([]byte(cfg.RefreshSecret))
 
 }
 
-func ValidateRefreshToken(tokenString string) (*RefreshTokenClaims, error) {
+func ValidateRefreshToken(cfg config.JWTConfig, tokenString string) (*RefreshTokenClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &RefreshTokenClaims{}, func(token *jwt.Token) (any, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected siging method: %v", token.Header["alg"])
 		}
-		return []byte(os.Getenv("REFRESH_TOKEN_SECRET")), nil
+		return []byte(cfg.RefreshSecret), nil
 	})
 
 	if err != nil {
@@ -4,17 +4,33 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
 	"github.com/RaihanurRahman2022/PersonalVault/internal/helper"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
+// AuthMiddleware puts the user of the request's bearer token into the context. With
+// clientCertLogin, a request without an Authorization header is logged in as the user
+// named by its verified client certificate, see clientCertUser.
+func AuthMiddleware(db *gorm.DB, jwtConfig config.JWTConfig, clientCertLogin bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 
+		if authHeader == "" && clientCertLogin {
+			if user := clientCertUser(c, db); user != nil {
+				c.Set("user_id", user.ID)
+				c.Set("username", user.UserName)
+				c.Next()
+				return
+			}
+		}
+
 		// Authorization Header missing
 		if authHeader == "" {
+			monitoring.AuthFailures.WithLabelValues("missing_header").Inc()
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "authorization header is required",
 			})
@@ -24,14 +40,16 @@ func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 		// Invalid Authorization Header
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 {
+			monitoring.AuthFailures.WithLabelValues("invalid_header").Inc()
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid authorization header format",
 			})
 			return
 		}
 
-		claims, err := helper.ValidateJWT(parts[1])
+		claims, err := helper.ValidateJWT(jwtConfig, parts[1])
 		if err != nil {
+			monitoring.AuthFailures.WithLabelValues("invalid_token").Inc()
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid token",
 			})
@@ -44,3 +62,23 @@ func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// clientCertUser returns the user whose name is the common name of the verified client
+// certificate of the connection, nil without one or if no user has that name. Only
+// certificates signed by a CA of TLS_CLIENT_CA_FILE are verified, so whoever holds the
+// CA decides which devices can log in as whom.
+func clientCertUser(c *gin.Context, db *gorm.DB) *entities.User {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	name := c.Request.TLS.VerifiedChains[0][0].Subject.CommonName
+	if name == "" {
+		return nil
+	}
+
+	var user entities.User
+	if err := db.WithContext(c.Request.Context()).Where("user_name = ?", name).First(&user).Error; err != nil {
+		return nil
+	}
+	return &user
+}
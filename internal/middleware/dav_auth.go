@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/helper"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// basicAuthCacheTTL is how long verified Basic credentials are remembered. File managers
+// send the password with every request, and bcrypt on each of them would make browsing
+// noticeably slow.
+const basicAuthCacheTTL = 5 * time.Minute
+
+// DAVAuthMiddleware accepts the same bearer tokens and client certificates as
+// AuthMiddleware and falls back to HTTP Basic authentication for WebDAV clients that
+// can't send tokens (Finder, Explorer). Failures answer with a WWW-Authenticate challenge
+// so those clients prompt for a login.
+func DAVAuthMiddleware(db *gorm.DB, jwtConfig config.JWTConfig, clientCertLogin bool) gin.HandlerFunc {
+	cache := &basicAuthCache{entries: make(map[[32]byte]basicAuthEntry)}
+
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+
+		if authHeader == "" && clientCertLogin {
+			if user := clientCertUser(c, db); user != nil {
+				c.Set("user_id", user.ID)
+				c.Set("username", user.UserName)
+				c.Next()
+				return
+			}
+		}
+
+		if authHeader == "" {
+			monitoring.AuthFailures.WithLabelValues("missing_header").Inc()
+			davUnauthorized(c)
+			return
+		}
+
+		scheme, credentials, _ := strings.Cut(authHeader, " ")
+		switch strings.ToLower(scheme) {
+		case "bearer":
+			claims, err := helper.ValidateJWT(jwtConfig, credentials)
+			if err != nil {
+				monitoring.AuthFailures.WithLabelValues("invalid_token").Inc()
+				davUnauthorized(c)
+				return
+			}
+			c.Set("user_id", claims.UserID)
+			c.Set("username", claims.Username)
+
+		case "basic":
+			username, password, ok := c.Request.BasicAuth()
+			if !ok {
+				monitoring.AuthFailures.WithLabelValues("invalid_header").Inc()
+				davUnauthorized(c)
+				return
+			}
+
+			userID, ok := cache.get(username, password)
+			if !ok {
+				var user entities.User
+				if err := db.WithContext(c.Request.Context()).Where("user_name = ?", username).First(&user).Error; err != nil {
+					monitoring.AuthFailures.WithLabelValues("unknown_user").Inc()
+					davUnauthorized(c)
+					return
+				}
+				if !helper.CheckPassword(password, user.Password) {
+					monitoring.AuthFailures.WithLabelValues("wrong_password").Inc()
+					davUnauthorized(c)
+					return
+				}
+				userID = user.ID
+				cache.put(username, password, userID)
+			}
+			c.Set("user_id", userID)
+			c.Set("username", username)
+
+		default:
+			monitoring.AuthFailures.WithLabelValues("invalid_header").Inc()
+			davUnauthorized(c)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func davUnauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Basic realm="PersonalVault", charset="UTF-8"`)
+	c.AbortWithStatus(http.StatusUnauthorized)
+}
+
+type basicAuthEntry struct {
+	userID  uuid.UUID
+	expires time.Time
+}
+
+// basicAuthCache remembers verified credentials by the hash of username and password, so
+// plain-text passwords are never kept in memory and a changed password misses the cache.
+type basicAuthCache struct {
+	mu      sync.Mutex
+	entries map[[32]byte]basicAuthEntry
+}
+
+func basicAuthKey(username, password string) [32]byte {
+	return sha256.Sum256([]byte(username + "\x00" + password))
+}
+
+func (c *basicAuthCache) get(username, password string) (uuid.UUID, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[basicAuthKey(username, password)]
+	if !ok || time.Now().After(entry.expires) {
+		return uuid.Nil, false
+	}
+	return entry.userID, true
+}
+
+func (c *basicAuthCache) put(username, password string, userID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[basicAuthKey(username, password)] = basicAuthEntry{userID: userID, expires: now.Add(basicAuthCacheTTL)}
+}
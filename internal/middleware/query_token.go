@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// QueryTokenMiddleware lets a bearer token be passed as the access_token query parameter,
+// for clients that can't set headers (the browser's EventSource). It only moves the token
+// into the Authorization header, so it must run before AuthMiddleware. The parameter is
+// removed from the URL so nothing further down logs it.
+func QueryTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		token := query.Get("access_token")
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		if c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		query.Del("access_token")
+		c.Request.URL.RawQuery = query.Encode()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/monitoring"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter hands out the rate limiting middleware of the route groups, which keep
+// their buckets in the same store.
+type RateLimiter struct {
+	store  ratelimit.Store
+	logger *slog.Logger
+}
+
+func NewRateLimiter(store ratelimit.Store, logger *slog.Logger) *RateLimiter {
+	return &RateLimiter{
+		store:  store,
+		logger: logger.With("component", "middleware.rate_limit"),
+	}
+}
+
+// Middleware limits the requests of a route group to limit. Requests are counted against
+// the bucket of the client IP and, once an auth middleware ran, also against the bucket
+// of the user; they are refused if either is empty. Middleware of the same group share
+// their buckets. It can run both before and after an auth middleware, so that requests
+// with wrong credentials are limited before the credentials are checked; the IP is only
+// counted once. Responses carry the RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers of the emptier bucket, and requests over the limit are
+// refused with 429 and Retry-After. If the store fails, the request is let through.
+func (l *RateLimiter) Middleware(group string, limit ratelimit.Limit) gin.HandlerFunc {
+	if limit.Unlimited() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int64(limit.Period/time.Second))
+	// ipResultKey keeps the result of the IP's bucket in the context once it was taken
+	ipResultKey := "rate_limit_ip:" + group
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var result ratelimit.Result
+		if previous, exists := c.Get(ipResultKey); exists {
+			result = previous.(ratelimit.Result)
+		} else {
+			ipResult, err := l.store.Take(ctx, group+":ip:"+c.ClientIP(), limit)
+			if err != nil {
+				l.logger.WarnContext(ctx, "failed to check rate limit", "group", group, "error", err)
+				c.Next()
+				return
+			}
+			c.Set(ipResultKey, ipResult)
+			result = ipResult
+		}
+
+		if userID, exists := c.Get("user_id"); exists && result.Allowed {
+			userResult, err := l.store.Take(ctx, fmt.Sprintf("%s:user:%v", group, userID), limit)
+			if err != nil {
+				l.logger.WarnContext(ctx, "failed to check rate limit", "group", group, "error", err)
+				c.Next()
+				return
+			}
+			result = stricter(result, userResult)
+		}
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.FormatInt(int64(result.Reset/time.Second), 10))
+		if !result.Allowed {
+			monitoring.RateLimited.WithLabelValues(group).Inc()
+			c.Header("Retry-After", strconv.FormatInt(int64(result.RetryAfter/time.Second), 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many requests",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// stricter merges the results of two buckets a request was counted against: it is allowed
+// if both allowed it, and can be repeated as soon as both have room again.
+func stricter(a, b ratelimit.Result) ratelimit.Result {
+	return ratelimit.Result{
+		Allowed:    a.Allowed && b.Allowed,
+		Remaining:  min(a.Remaining, b.Remaining),
+		Reset:      max(a.Reset, b.Reset),
+		RetryAfter: max(a.RetryAfter, b.RetryAfter),
+	}
+}
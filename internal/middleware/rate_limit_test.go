@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitedRouter limits GET / with the same middleware before and after a fake auth
+// middleware, which takes the user from the X-User header and refuses requests without.
+func rateLimitedRouter(limit ratelimit.Limit) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	rateLimit := limiter.Middleware("test", limit)
+	auth := func(c *gin.Context) {
+		user := c.GetHeader("X-User")
+		if user == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set("user_id", user)
+	}
+
+	router := gin.New()
+	router.GET("/", rateLimit, auth, rateLimit, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func rateLimitedRequest(router *gin.Engine, ip, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ip + ":1234"
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitCountsFailedAuthPerIP(t *testing.T) {
+	router := rateLimitedRouter(ratelimit.Limit{Requests: 3, Period: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		if w := rateLimitedRequest(router, "192.0.2.1", ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("request %d status = %d, want 401", i, w.Code)
+		}
+	}
+	// The address used up its limit on failed logins, even with the right credentials now
+	w := rateLimitedRequest(router, "192.0.2.1", "alice")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 without Retry-After")
+	}
+
+	if w := rateLimitedRequest(router, "192.0.2.2", "alice"); w.Code != http.StatusOK {
+		t.Errorf("status from another address = %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitTakesUserAndIP(t *testing.T) {
+	router := rateLimitedRouter(ratelimit.Limit{Requests: 2, Period: time.Hour})
+
+	// Running before and after auth counts the address once per request
+	for i := 0; i < 2; i++ {
+		w := rateLimitedRequest(router, "192.0.2.1", "alice")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i, w.Code)
+		}
+		if got, want := w.Header().Get("RateLimit-Remaining"), []string{"1", "0"}[i]; got != want {
+			t.Errorf("request %d RateLimit-Remaining = %s, want %s", i, got, want)
+		}
+	}
+
+	// The user's limit holds on another address
+	if w := rateLimitedRequest(router, "192.0.2.2", "alice"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("user over their limit from another address: status = %d, want 429", w.Code)
+	}
+	// And the address's limit holds for another user
+	if w := rateLimitedRequest(router, "192.0.2.1", "bob"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("another user from an address over its limit: status = %d, want 429", w.Code)
+	}
+	if w := rateLimitedRequest(router, "192.0.2.3", "bob"); w.Code != http.StatusOK {
+		t.Errorf("another user from another address: status = %d, want 200", w.Code)
+	}
+}
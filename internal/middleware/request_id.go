@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Client supplied request IDs are only trusted if they look harmless.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9\-_.]{1,128}$`)
+
+// RequestIDMiddleware assigns every request an ID, reusing the one sent by the
+// client or a proxy when present. The ID is echoed in the response header,
+// stored in the gin context and attached to the request context so every log
+// line written for the request carries it.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+	}
+}
@@ -0,0 +1,124 @@
+// Package monitoring defines the application metrics exposed on /metrics.
+package monitoring
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/RaihanurRahman2022/PersonalVault/internal/app/repositories"
+	"github.com/RaihanurRahman2022/PersonalVault/pkg/metrics"
+)
+
+var Registry = metrics.NewRegistry()
+
+var (
+	HTTPRequests = metrics.NewCounterVec(metrics.Opts{
+		Name: "vault_http_requests_total",
+		Help: "Total number of HTTP requests by method, route and status code.",
+	}, "method", "route", "status")
+
+	HTTPRequestDuration = metrics.NewHistogramVec(metrics.Opts{
+		Name: "vault_http_request_duration_seconds",
+		Help: "HTTP request latency in seconds by method and route.",
+	}, metrics.DefBuckets, "method", "route")
+
+	BytesUploaded = metrics.NewCounter(metrics.Opts{
+		Name: "vault_uploaded_bytes_total",
+		Help: "Total number of bytes written by uploads.",
+	})
+
+	BytesDownloaded = metrics.NewCounterVec(metrics.Opts{
+		Name: "vault_downloaded_bytes_total",
+		Help: "Total number of bytes sent to clients by action (download, preview, stream).",
+	}, "action")
+
+	ActiveStreams = metrics.NewGauge(metrics.Opts{
+		Name: "vault_active_streams",
+		Help: "Number of file streams currently being served.",
+	})
+
+	UploadFailures = metrics.NewCounterVec(metrics.Opts{
+		Name: "vault_upload_failures_total",
+		Help: "Total number of files that failed to upload by reason.",
+	}, "reason")
+
+	AuthFailures = metrics.NewCounterVec(metrics.Opts{
+		Name: "vault_auth_failures_total",
+		Help: "Total number of rejected logins and API requests by reason.",
+	}, "reason")
+
+	UploadQueueDepth = metrics.NewGauge(metrics.Opts{
+		Name: "vault_upload_queue_depth",
+		Help: "Number of uploaded files waiting for a free upload worker.",
+	})
+
+	RateLimited = metrics.NewCounterVec(metrics.Opts{
+		Name: "vault_rate_limited_requests_total",
+		Help: "Total number of requests refused for going over the rate limit by route group.",
+	}, "group")
+)
+
+func init() {
+	Registry.MustRegister(
+		HTTPRequests,
+		HTTPRequestDuration,
+		BytesUploaded,
+		BytesDownloaded,
+		ActiveStreams,
+		UploadFailures,
+		AuthFailures,
+		UploadQueueDepth,
+		RateLimited,
+	)
+}
+
+// Handler serves all application metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return Registry.Handler()
+}
+
+// RegisterDBStats exposes the connection pool statistics of db.
+func RegisterDBStats(db *sql.DB) {
+	Registry.MustRegister(metrics.CollectorFunc(func() []metrics.Family {
+		stats := db.Stats()
+
+		gauge := func(name, help string, v float64) metrics.Family {
+			return metrics.Family{Name: name, Help: help, Type: metrics.TypeGauge, Samples: []metrics.Sample{{Value: v}}}
+		}
+		counter := func(name, help string, v float64) metrics.Family {
+			return metrics.Family{Name: name, Help: help, Type: metrics.TypeCounter, Samples: []metrics.Sample{{Value: v}}}
+		}
+
+		return []metrics.Family{
+			gauge("vault_db_max_open_connections", "Maximum number of open connections to the database.", float64(stats.MaxOpenConnections)),
+			gauge("vault_db_open_connections", "Number of established connections, both in use and idle.", float64(stats.OpenConnections)),
+			gauge("vault_db_in_use_connections", "Number of connections currently in use.", float64(stats.InUse)),
+			gauge("vault_db_idle_connections", "Number of idle connections.", float64(stats.Idle)),
+			counter("vault_db_wait_count_total", "Total number of connections waited for.", float64(stats.WaitCount)),
+			counter("vault_db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", stats.WaitDuration.Seconds()),
+			counter("vault_db_max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns.", float64(stats.MaxIdleClosed)),
+			counter("vault_db_max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime.", float64(stats.MaxLifetimeClosed)),
+		}
+	}))
+}
+
+// UploadFailureReason maps an upload error to a low-cardinality label value.
+func UploadFailureReason(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case errors.Is(err, repositories.ErrPathNotAllowed):
+		return "path_not_allowed"
+	case errors.Is(err, repositories.ErrFileExists), errors.Is(err, os.ErrExist):
+		return "already_exists"
+	case errors.Is(err, os.ErrPermission):
+		return "permission_denied"
+	case errors.Is(err, repositories.ErrNotDirectory):
+		return "not_a_directory"
+	default:
+		return "io_error"
+	}
+}
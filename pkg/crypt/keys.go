@@ -0,0 +1,89 @@
+// Package crypt seals file content and names for encrypted vault folders.
+//
+// Keys form a small hierarchy: a key derived from the user's password with Argon2id wraps
+// the user's master key, the master key wraps one key per folder, and every file gets its
+// own content key derived from the folder key and a random salt in the file header.
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// KeySize is the size of every key in the hierarchy (AES-256)
+const KeySize = 32
+
+var ErrAuthentication = errors.New("message authentication failed")
+
+// KDFParams are the Argon2id cost parameters. They are stored next to the salt so keys
+// derived before a change of the defaults still unlock.
+type KDFParams struct {
+	Time    uint32
+	Memory  uint32 // in KiB
+	Threads uint8
+}
+
+// DeriveKey derives a key-encryption key from a password with Argon2id.
+func DeriveKey(password string, salt []byte, params KDFParams) []byte {
+	return argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, KeySize)
+}
+
+// NewKey returns a random key; it is also used for salts.
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// Wrap encrypts key with kek. aad binds the wrapped key to its owner (a user or folder
+// ID), so a wrapped key copied onto another record fails to unwrap.
+func Wrap(kek, key, aad []byte) ([]byte, error) {
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(key)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, key, aad), nil
+}
+
+// Unwrap reverses Wrap. A wrong kek (and so a wrong password) yields ErrAuthentication.
+func Unwrap(kek, wrapped, aad []byte) ([]byte, error) {
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrAuthentication
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// subkey derives an independent key for one purpose from a folder key.
+func subkey(key, salt []byte, purpose string, size int) ([]byte, error) {
+	return hkdf.Key(sha256.New, key, salt, "PersonalVault "+purpose, size)
+}
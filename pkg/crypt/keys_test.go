@@ -0,0 +1,58 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// testKDFParams keeps Argon2id cheap; the cost doesn't change what is tested.
+var testKDFParams = KDFParams{Time: 1, Memory: 64, Threads: 1}
+
+func TestWrapUnwrap(t *testing.T) {
+	salt := testKey(t)
+	masterKey := testKey(t)
+	owner := []byte("user 1")
+
+	wrapped, err := Wrap(DeriveKey("correct horse", salt, testKDFParams), masterKey, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Wrap(DeriveKey("correct horse", salt, testKDFParams), masterKey, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(wrapped, again) {
+		t.Error("wrapping the same key twice gave the same bytes")
+	}
+
+	key, err := Unwrap(DeriveKey("correct horse", salt, testKDFParams), wrapped, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, masterKey) {
+		t.Error("unwrapped another key")
+	}
+
+	changed := bytes.Clone(wrapped)
+	changed[len(changed)-1] ^= 1
+	tests := []struct {
+		name    string
+		kek     []byte
+		wrapped []byte
+		owner   []byte
+	}{
+		{"wrong password", DeriveKey("correct horse!", salt, testKDFParams), wrapped, owner},
+		{"other salt", DeriveKey("correct horse", testKey(t), testKDFParams), wrapped, owner},
+		{"other cost", DeriveKey("correct horse", salt, KDFParams{Time: 2, Memory: 64, Threads: 1}), wrapped, owner},
+		{"other owner", DeriveKey("correct horse", salt, testKDFParams), wrapped, []byte("user 2")},
+		{"changed", DeriveKey("correct horse", salt, testKDFParams), changed, owner},
+		{"truncated", DeriveKey("correct horse", salt, testKDFParams), wrapped[:20], owner},
+		{"empty", DeriveKey("correct horse", salt, testKDFParams), nil, owner},
+	}
+	for _, tt := range tests {
+		if _, err := Unwrap(tt.kek, tt.wrapped, tt.owner); !errors.Is(err, ErrAuthentication) {
+			t.Errorf("%s: error = %v, want ErrAuthentication", tt.name, err)
+		}
+	}
+}
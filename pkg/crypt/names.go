@@ -0,0 +1,70 @@
+package crypt
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// Names are sealed deterministically so a path can be looked up without listing its
+// folder: the nonce is an HMAC of the name itself (a synthetic IV), which only reveals
+// whether two names in the same folder tree are equal. The result is base64url, so it is
+// a valid file name on every platform the vault runs on.
+
+// MaxSealedNameLength is the longest file name most file systems accept
+const MaxSealedNameLength = 255
+
+var ErrNameTooLong = errors.New("file name is too long to encrypt")
+
+var nameEncoding = base64.RawURLEncoding
+
+// EncryptName seals one path element with folderKey.
+func EncryptName(folderKey []byte, name string) (string, error) {
+	macKey, aead, err := nameKeys(folderKey)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write([]byte(name))
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+
+	sealed := nameEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(name), nil))
+	if len(sealed) > MaxSealedNameLength {
+		return "", ErrNameTooLong
+	}
+	return sealed, nil
+}
+
+// DecryptName reverses EncryptName. Names that weren't sealed with folderKey fail with
+// ErrAuthentication.
+func DecryptName(folderKey []byte, sealed string) (string, error) {
+	_, aead, err := nameKeys(folderKey)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := nameEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize()+aead.Overhead() {
+		return "", ErrAuthentication
+	}
+	name, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrAuthentication
+	}
+	return string(name), nil
+}
+
+func nameKeys(folderKey []byte) ([]byte, cipher.AEAD, error) {
+	keys, err := subkey(folderKey, nil, "names", 2*KeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := newGCM(keys[KeySize:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys[:KeySize], aead, nil
+}
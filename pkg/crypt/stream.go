@@ -0,0 +1,241 @@
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted files are a header followed by segments of SegmentSize plaintext bytes, each
+// sealed on its own with AES-256-GCM, so any byte range can be read by decrypting only the
+// segments it touches:
+//
+//	header:  magic "PVE1" | 32-byte random salt
+//	segment: ciphertext | 16-byte tag
+//
+// The content key is derived from the folder key and the salt. Segment i is sealed with
+// the nonce (i as big-endian uint64 | 0 0 0 | last), with last = 1 only for the final
+// segment, and the header as additional data. Reordering, dropping or truncating segments
+// therefore fails authentication. An empty file still has one (empty, final) segment.
+const (
+	SegmentSize = 64 * 1024
+
+	magic      = "PVE1"
+	saltSize   = 32
+	headerSize = len(magic) + saltSize
+	tagSize    = 16
+
+	sealedSegmentSize = SegmentSize + tagSize
+)
+
+var ErrNotEncrypted = errors.New("file is not encrypted or is damaged")
+
+// PlainSize returns the plaintext size of an encrypted file from its size on disk, without
+// needing the key.
+func PlainSize(sealedSize int64) int64 {
+	n := sealedSize - int64(headerSize)
+	if n < tagSize {
+		return 0
+	}
+	full, rest := n/sealedSegmentSize, n%sealedSegmentSize
+	if rest == 0 {
+		return full * SegmentSize
+	}
+	return full*SegmentSize + max(rest-tagSize, 0)
+}
+
+func segmentNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func contentAEAD(folderKey, header []byte) (cipher.AEAD, error) {
+	key, err := subkey(folderKey, header[len(magic):], "content", KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(key)
+}
+
+// Encrypt returns a reader yielding src sealed with folderKey.
+func Encrypt(src io.Reader, folderKey []byte) (io.Reader, error) {
+	salt, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	header := append([]byte(magic), salt...)
+
+	aead, err := contentAEAD(folderKey, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptReader{
+		src:    bufio.NewReader(src),
+		aead:   aead,
+		header: header,
+		plain:  make([]byte, SegmentSize),
+		out:    bytes.Clone(header),
+	}, nil
+}
+
+type encryptReader struct {
+	src    *bufio.Reader
+	aead   cipher.AEAD
+	header []byte
+	index  uint64
+	plain  []byte
+	out    []byte // sealed bytes not returned yet
+	done   bool
+	err    error
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.sealNext()
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// sealNext seals the next segment. A segment is the last one if the source ends inside
+// it or right after it, so a full segment peeks one byte ahead to find out.
+func (r *encryptReader) sealNext() {
+	n, err := io.ReadFull(r.src, r.plain)
+	last := false
+	switch {
+	case err == io.EOF, err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		r.err = err
+		return
+	default:
+		if _, err := r.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			r.err = err
+			return
+		}
+	}
+
+	r.out = r.aead.Seal(r.out[:0], segmentNonce(r.index, last), r.plain[:n], r.header)
+	r.index++
+	r.done = last
+}
+
+// NewReader returns a seekable reader over the plaintext of the encrypted file src of
+// sealedSize bytes. Only the segments actually read are decrypted; a damaged or tampered
+// segment fails the read with ErrAuthentication.
+func NewReader(src io.ReadSeeker, sealedSize int64, folderKey []byte) (io.ReadSeeker, error) {
+	body := sealedSize - int64(headerSize)
+	if body < tagSize || (body%sealedSegmentSize != 0 && body%sealedSegmentSize < tagSize) {
+		return nil, ErrNotEncrypted
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrNotEncrypted
+	}
+
+	aead, err := contentAEAD(folderKey, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		src:      src,
+		aead:     aead,
+		header:   header,
+		size:     PlainSize(sealedSize),
+		segments: uint64((body + sealedSegmentSize - 1) / sealedSegmentSize),
+		loaded:   -1,
+		sealed:   make([]byte, sealedSegmentSize),
+	}, nil
+}
+
+type decryptReader struct {
+	src      io.ReadSeeker
+	aead     cipher.AEAD
+	header   []byte
+	size     int64 // plaintext size
+	segments uint64
+	pos      int64
+	loaded   int64 // index of the segment in plain, -1 for none
+	plain    []byte
+	sealed   []byte
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	index := r.pos / SegmentSize
+	if index != r.loaded {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plain[r.pos-index*SegmentSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+func (r *decryptReader) load(index int64) error {
+	if _, err := r.src.Seek(int64(headerSize)+index*sealedSegmentSize, io.SeekStart); err != nil {
+		return err
+	}
+	n, err := io.ReadFull(r.src, r.sealed)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	last := uint64(index) == r.segments-1
+	plain, err := r.aead.Open(r.plain[:0], segmentNonce(uint64(index), last), r.sealed[:n], r.header)
+	if err != nil {
+		r.loaded = -1
+		return ErrAuthentication
+	}
+	r.plain = plain
+	r.loaded = index
+	return nil
+}
+
+func (r *decryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("crypt: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("crypt: negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
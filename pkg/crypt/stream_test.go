@@ -0,0 +1,233 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// testContent returns size bytes that differ from one segment to the next, so a segment
+// read from the wrong place doesn't go unnoticed.
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	return content
+}
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func seal(t *testing.T, plain, folderKey []byte) []byte {
+	t.Helper()
+	r, err := Encrypt(bytes.NewReader(plain), folderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
+
+func open(sealed, folderKey []byte) (io.ReadSeeker, error) {
+	return NewReader(bytes.NewReader(sealed), int64(len(sealed)), folderKey)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		segments int
+	}{
+		{"empty", 0, 1},
+		{"one byte", 1, 1},
+		{"short of a segment", SegmentSize - 1, 1},
+		{"one segment", SegmentSize, 1},
+		{"one byte over a segment", SegmentSize + 1, 2},
+		{"two segments", 2 * SegmentSize, 2},
+		{"three segments and a bit", 3*SegmentSize + 100, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := testKey(t)
+			plain := testContent(tt.size)
+			sealed := seal(t, plain, key)
+
+			if want := headerSize + tt.size + tt.segments*tagSize; len(sealed) != want {
+				t.Errorf("sealed %d bytes into %d, want %d", tt.size, len(sealed), want)
+			}
+			if got := PlainSize(int64(len(sealed))); got != int64(tt.size) {
+				t.Errorf("PlainSize = %d, want %d", got, tt.size)
+			}
+
+			r, err := open(sealed, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Reads, seeks back and forth and reads again
+			if err := iotest.TestReader(r, plain); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestEncryptSmallReads(t *testing.T) {
+	key := testKey(t)
+	plain := testContent(SegmentSize + 10)
+	r, err := Encrypt(iotest.OneByteReader(bytes.NewReader(plain)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := open(sealed, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := iotest.TestReader(got, plain); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPlainSize(t *testing.T) {
+	tests := []struct {
+		sealed, want int64
+	}{
+		{0, 0},
+		{int64(headerSize), 0},
+		{int64(headerSize + tagSize), 0},
+		{int64(headerSize + tagSize + 1), 1},
+		{int64(headerSize + sealedSegmentSize), SegmentSize},
+		{int64(headerSize + 2*sealedSegmentSize), 2 * SegmentSize},
+		{int64(headerSize + sealedSegmentSize + tagSize + 5), SegmentSize + 5},
+		// A damaged last segment shorter than a tag has no plaintext
+		{int64(headerSize + sealedSegmentSize + 3), SegmentSize},
+	}
+	for _, tt := range tests {
+		if got := PlainSize(tt.sealed); got != tt.want {
+			t.Errorf("PlainSize(%d) = %d, want %d", tt.sealed, got, tt.want)
+		}
+	}
+}
+
+func TestReaderSeekAcrossSegments(t *testing.T) {
+	key := testKey(t)
+	plain := testContent(3*SegmentSize + 100)
+	r, err := open(seal(t, plain, key), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		offset int64
+		whence int
+		length int
+		pos    int64
+	}{
+		{"across the first boundary", SegmentSize - 10, io.SeekStart, 20, SegmentSize - 10},
+		{"over a whole segment", SegmentSize / 2, io.SeekStart, 2 * SegmentSize, SegmentSize / 2},
+		{"back to the start", 0, io.SeekStart, 10, 0},
+		{"forward from the current position", 2 * SegmentSize, io.SeekCurrent, 5, 2*SegmentSize + 10},
+		{"into the last segment", -50, io.SeekEnd, 50, int64(len(plain)) - 50},
+		{"at a boundary", 3 * SegmentSize, io.SeekStart, 100, 3 * SegmentSize},
+		{"back to an earlier segment", SegmentSize, io.SeekStart, SegmentSize, SegmentSize},
+	}
+	for _, tt := range tests {
+		pos, err := r.Seek(tt.offset, tt.whence)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if pos != tt.pos {
+			t.Fatalf("%s: position %d, want %d", tt.name, pos, tt.pos)
+		}
+		got := make([]byte, tt.length)
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, plain[pos:pos+int64(tt.length)]) {
+			t.Errorf("%s: read the wrong bytes", tt.name)
+		}
+	}
+
+	if _, err := r.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Errorf("read at the end = %d, %v, want 0, EOF", n, err)
+	}
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("seeking before the start succeeded")
+	}
+}
+
+func TestReaderDetectsTampering(t *testing.T) {
+	key := testKey(t)
+	plain := testContent(3*SegmentSize + 100)
+	sealed := seal(t, plain, key)
+	segment := func(i int) []byte {
+		start := headerSize + i*sealedSegmentSize
+		return sealed[start:min(start+sealedSegmentSize, len(sealed))]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	flip := func(i int) []byte {
+		changed := bytes.Clone(sealed)
+		changed[i] ^= 1
+		return changed
+	}
+	header := sealed[:headerSize]
+
+	tests := []struct {
+		name   string
+		sealed []byte
+		want   error
+	}{
+		{"flipped salt byte", flip(len(magic)), ErrAuthentication},
+		{"flipped ciphertext byte", flip(headerSize + SegmentSize + 7), ErrAuthentication},
+		{"flipped tag byte", flip(headerSize + sealedSegmentSize - 1), ErrAuthentication},
+		{"flipped magic byte", flip(0), ErrNotEncrypted},
+		{"truncated last segment", sealed[:len(sealed)-20], ErrAuthentication},
+		{"last segment dropped", sealed[:headerSize+3*sealedSegmentSize], ErrAuthentication},
+		{"last segment cut to less than a tag", sealed[:headerSize+3*sealedSegmentSize+tagSize-1], ErrNotEncrypted},
+		{"only the header", header, ErrNotEncrypted},
+		{"swapped segments", join(header, segment(1), segment(0), segment(2), segment(3)), ErrAuthentication},
+		{"repeated segment", join(header, segment(0), segment(0), segment(2), segment(3)), ErrAuthentication},
+		{"segment appended", join(sealed, segment(3)), ErrAuthentication},
+		{"another file's header", join(seal(t, plain, key)[:headerSize], sealed[headerSize:]), ErrAuthentication},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := open(tt.sealed, key)
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	r, err := open(sealed, testKey(t))
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	if !errors.Is(err, ErrAuthentication) {
+		t.Errorf("reading with another key: error = %v, want ErrAuthentication", err)
+	}
+}
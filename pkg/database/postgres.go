@@ -2,10 +2,11 @@ package database
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"time"
 
 	"github.com/RaihanurRahman2022/PersonalVault/internal/app/entities"
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -13,20 +14,20 @@ import (
 
 var DB *gorm.DB
 
-func InitDB() (*gorm.DB, error) {
+func InitDB(cfg config.DatabaseConfig, log *slog.Logger) (*gorm.DB, error) {
 
-	sslMode := os.Getenv("DB_SSL_MODE")
-	if sslMode == "" {
-		sslMode = "disable"
-	}
-
-	dsn := GetDSN()
+	dsn := GetDSN(cfg)
 
+	// Only slow queries and errors are logged, and query parameters are left
+	// out so password hashes and other values never reach the logs.
 	config := &gorm.Config{
-		Logger: logger.New(
-			log.New(os.Stdout, "\r\n", log.LstdFlags),
+		Logger: logger.NewSlogLogger(
+			log.With("component", "database"),
 			logger.Config{
-				LogLevel: logger.Info,
+				LogLevel:                  logger.Warn,
+				SlowThreshold:             200 * time.Millisecond,
+				ParameterizedQueries:      true,
+				IgnoreRecordNotFoundError: true,
 			},
 		),
 	}
@@ -38,14 +39,40 @@ func InitDB() (*gorm.DB, error) {
 	}
 
 	// Auto-migrate the database schema
-	if err := db.AutoMigrate(&entities.User{}); err != nil {
+	if err := db.AutoMigrate(&entities.User{}, &entities.AuditLog{}, &entities.FileVersion{}, &entities.VersionRetention{}, &entities.AccessKey{}, &entities.MultipartUpload{}, &entities.Keyring{}, &entities.EncryptedFolder{}, &entities.PhotoMetadata{}, &entities.FileMetadata{}, &entities.FileTag{}, &entities.FileNote{}, &entities.Activity{}, &entities.SyncChange{}, &entities.FileHash{}, &entities.TransferLimit{}, &entities.TransferUsage{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
 
+	if err := makeAppendOnly(db, "audit_logs"); err != nil {
+		return nil, fmt.Errorf("failed to protect audit log table: %v", err)
+	}
+
 	DB = db
 	return db, nil
 }
 
+// makeAppendOnly installs a trigger that rejects UPDATE and DELETE on the
+// given table, so rows can only ever be inserted.
+func makeAppendOnly(db *gorm.DB, table string) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION reject_append_only_mutation() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'table % is append-only', TG_TABLE_NAME;
+		END;
+		$$ LANGUAGE plpgsql`,
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS %s_append_only ON %s`, table, table),
+		fmt.Sprintf(`CREATE TRIGGER %s_append_only BEFORE UPDATE OR DELETE ON %s
+		FOR EACH ROW EXECUTE FUNCTION reject_append_only_mutation()`, table, table),
+	}
+
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func GetDB() *gorm.DB {
 	return DB
 }
@@ -2,14 +2,15 @@ package database
 
 import (
 	"fmt"
-	"os"
+	"strings"
 
+	"github.com/RaihanurRahman2022/PersonalVault/internal/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func SetupDatabase() error {
-	dsn := GetDSNWithoutDBName()
+func SetupDatabase(cfg config.DatabaseConfig) error {
+	dsn := GetDSNWithoutDBName(cfg)
 
 	// connecting default database of postgres
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
@@ -19,11 +20,10 @@ func SetupDatabase() error {
 	}
 
 	// create our own database
-	dbName := os.Getenv("DB_NAME")
-	result := db.Exec(fmt.Sprintf("CREATE DATABASE %s", dbName))
+	result := db.Exec(fmt.Sprintf("CREATE DATABASE %s", cfg.DBName))
 
 	if result.Error != nil {
-		if !isDatabaseExistsError(result.Error) {
+		if !isDatabaseExistsError(result.Error, cfg.DBName) {
 			return fmt.Errorf("failed to create database: %v", result.Error)
 		}
 	}
@@ -37,7 +37,7 @@ func SetupDatabase() error {
 	sqlDB.Close()
 
 	// connecting newly created database.
-	dsn = GetDSN()
+	dsn = GetDSN(cfg)
 	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		return fmt.Errorf("failed to connect to new database: %v", err)
@@ -51,25 +51,30 @@ func SetupDatabase() error {
 	return nil
 }
 
-func isDatabaseExistsError(err error) bool {
-	return err != nil && err.Error() == fmt.Sprintf("ERROR: database \"%s\" already exists (SQLSTATE 42P04)", os.Getenv("DB_NAME"))
+func isDatabaseExistsError(err error, dbName string) bool {
+	return err != nil && err.Error() == fmt.Sprintf("ERROR: database \"%s\" already exists (SQLSTATE 42P04)", dbName)
 }
 
-func GetDSNWithoutDBName() string {
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=postgres sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-	)
+// GetDSNWithoutDBName connects to the postgres database, to create the vault's own.
+func GetDSNWithoutDBName(cfg config.DatabaseConfig) string {
+	cfg.DBName = "postgres"
+	return GetDSN(cfg)
 }
 
-func GetDSN() string {
-	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
+func GetDSN(cfg config.DatabaseConfig) string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		dsnValue(cfg.Host),
+		dsnValue(cfg.Port),
+		dsnValue(cfg.User),
+		dsnValue(cfg.Password),
+		dsnValue(cfg.DBName),
+		dsnValue(cfg.SSLMode),
 	)
 }
+
+// dsnValue quotes a value of a DSN, so passwords with spaces or quotes stay whole.
+func dsnValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
@@ -0,0 +1,153 @@
+// Package delta implements an rsync-style delta transfer of a file whose previous version
+// the other side already has.
+//
+// The side with the old file (the basis) publishes its Signature: a weak rolling checksum
+// and a strong checksum of every block of BlockSize bytes. The side with the new file
+// finds, with Diff, the blocks of the basis that appear anywhere in it, at any offset, and
+// encodes the new file as copies of basis ranges and literal data. A Patcher rebuilds the
+// new file from the basis and that delta.
+//
+// A delta starts with the magic "PVD1" and is a sequence of operations, integers being
+// big-endian:
+//
+//	0x01 offset:uint64 length:uint32    copy length bytes of the basis from offset
+//	0x02 length:uint32 data             append data
+//	0x00 sha256:[32]byte                end; the SHA-256 of the whole new file
+//
+// The checksum at the end lets the receiver refuse a file rebuilt wrongly, whether
+// because of a weak and strong checksum collision, a basis that changed since its
+// signature was taken or a bug in a client.
+package delta
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math"
+)
+
+const (
+	// MinBlockSize and MaxBlockSize bound the block size of a signature
+	MinBlockSize = 1 << 10
+	MaxBlockSize = 1 << 20
+	// MaxBlocks is the most blocks a signature can have
+	MaxBlocks = 1 << 18
+	// StrongSize is the length of the strong checksum of a block, a truncated SHA-256
+	StrongSize = 16
+	// MaxLiteral is the longest literal operation Diff writes
+	MaxLiteral = 1 << 20
+
+	magic = "PVD1"
+
+	opEnd     byte = 0x00
+	opCopy    byte = 0x01
+	opLiteral byte = 0x02
+)
+
+var (
+	ErrFormat   = errors.New("malformed delta")
+	ErrChecksum = errors.New("rebuilt file doesn't match the checksum of the delta")
+)
+
+// Block is the checksums of a block of the basis.
+type Block struct {
+	Weak   uint32
+	Strong [StrongSize]byte
+}
+
+// Signature is the checksums of every block of a file of Size bytes, in order. The last
+// block is shorter than BlockSize unless Size is a multiple of it.
+type Signature struct {
+	BlockSize int
+	Size      int64
+	Blocks    []Block
+}
+
+// BlockSizeFor returns the block size to use for a file of size bytes: about its square
+// root, which balances the size of the signature against the data resent around each
+// change, as rsync does.
+func BlockSizeFor(size int64) int {
+	blockSize := int(math.Sqrt(float64(size)))
+	blockSize = (blockSize + MinBlockSize - 1) / MinBlockSize * MinBlockSize
+	return min(max(blockSize, MinBlockSize), MaxBlockSize)
+}
+
+// BlockCount returns how many blocks of blockSize bytes a file of size bytes has.
+func BlockCount(size int64, blockSize int) int64 {
+	return (size + int64(blockSize) - 1) / int64(blockSize)
+}
+
+// NewSignature reads the basis from r and returns its signature.
+func NewSignature(r io.Reader, blockSize int) (*Signature, error) {
+	if blockSize < MinBlockSize || blockSize > MaxBlockSize {
+		return nil, errors.New("block size out of range")
+	}
+
+	sig := &Signature{BlockSize: blockSize}
+	buf := make([]byte, blockSize)
+	br := bufio.NewReaderSize(r, blockSize)
+	for {
+		n, err := io.ReadFull(br, buf)
+		if n > 0 {
+			if len(sig.Blocks) == MaxBlocks {
+				return nil, errors.New("file has too many blocks for its block size")
+			}
+			sig.Size += int64(n)
+			sig.Blocks = append(sig.Blocks, Block{Weak: weakSum(buf[:n]), Strong: strongSum(buf[:n])})
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sig, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// blockLen returns the length of block i.
+func (s *Signature) blockLen(i int) int {
+	if i == len(s.Blocks)-1 {
+		return int(s.Size - int64(i)*int64(s.BlockSize))
+	}
+	return s.BlockSize
+}
+
+func strongSum(p []byte) [StrongSize]byte {
+	sum := sha256.Sum256(p)
+	var strong [StrongSize]byte
+	copy(strong[:], sum[:])
+	return strong
+}
+
+// rolling is rsync's weak checksum of a window of n bytes, which can slide by one byte in
+// constant time.
+type rolling struct {
+	a, b uint32
+	n    uint32
+}
+
+func newRolling(p []byte) rolling {
+	var r rolling
+	r.n = uint32(len(p))
+	for i, c := range p {
+		r.a += uint32(c)
+		r.b += uint32(len(p)-i) * uint32(c)
+	}
+	return r
+}
+
+// roll slides the window by one byte: out leaves it and in enters it.
+func (r *rolling) roll(out, in byte) {
+	r.a += uint32(in) - uint32(out)
+	r.b += r.a - r.n*uint32(out)
+}
+
+func (r *rolling) sum() uint32 {
+	return r.a&0xffff | r.b<<16
+}
+
+func weakSum(p []byte) uint32 {
+	r := newRolling(p)
+	return r.sum()
+}
@@ -0,0 +1,244 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// makeDelta returns the delta that rebuilds newData from basis.
+func makeDelta(t *testing.T, basis, newData []byte, blockSize int) []byte {
+	t.Helper()
+	sig, err := NewSignature(bytes.NewReader(basis), blockSize)
+	if err != nil {
+		t.Fatalf("NewSignature: %v", err)
+	}
+	if sig.Size != int64(len(basis)) || int64(len(sig.Blocks)) != BlockCount(sig.Size, blockSize) {
+		t.Fatalf("signature of %d bytes has %d blocks, want %d", sig.Size, len(sig.Blocks), BlockCount(int64(len(basis)), blockSize))
+	}
+
+	var delta bytes.Buffer
+	if err := Diff(sig, bytes.NewReader(newData), &delta); err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	return delta.Bytes()
+}
+
+// patch rebuilds the file of delta from basis.
+func patch(basis, delta []byte) ([]byte, *Patcher, error) {
+	p := NewPatcher(bytes.NewReader(basis), int64(len(basis)), bytes.NewReader(delta))
+	out, err := io.ReadAll(p)
+	return out, p, err
+}
+
+// roundTrip diffs newData against basis, patches basis with the delta and checks that the
+// result is newData.
+func roundTrip(t *testing.T, basis, newData []byte, blockSize int) *Patcher {
+	t.Helper()
+	out, p, err := patch(basis, makeDelta(t, basis, newData, blockSize))
+	if err != nil {
+		t.Fatalf("Patcher (basis %d bytes, new %d bytes, block %d): %v", len(basis), len(newData), blockSize, err)
+	}
+	if !bytes.Equal(out, newData) {
+		t.Fatalf("rebuilt %d bytes that differ from the %d of the new file (basis %d bytes, block %d)", len(out), len(newData), len(basis), blockSize)
+	}
+	if p.Copied+p.Literal != int64(len(newData)) {
+		t.Errorf("Copied %d + Literal %d != %d", p.Copied, p.Literal, len(newData))
+	}
+	return p
+}
+
+func randomData(rng *rand.Rand, n int) []byte {
+	data := make([]byte, n)
+	rng.Read(data)
+	return data
+}
+
+// edit applies a random insert, delete, modify or block move to data.
+func edit(rng *rand.Rand, data []byte) []byte {
+	pos := 0
+	if len(data) > 0 {
+		pos = rng.Intn(len(data) + 1)
+	}
+	n := 1 + rng.Intn(3000)
+
+	switch rng.Intn(4) {
+	case 0: // insert
+		return append(data[:pos:pos], append(randomData(rng, n), data[pos:]...)...)
+	case 1: // delete
+		end := min(pos+n, len(data))
+		return append(data[:pos:pos], data[end:]...)
+	case 2: // modify in place
+		out := bytes.Clone(data)
+		for i := pos; i < min(pos+n, len(out)); i++ {
+			if rng.Intn(4) == 0 {
+				out[i] ^= byte(1 + rng.Intn(255))
+			}
+		}
+		return out
+	default: // move a range elsewhere, so blocks are copied out of order
+		end := min(pos+n, len(data))
+		moved := bytes.Clone(data[pos:end])
+		rest := append(data[:pos:pos], data[end:]...)
+		at := rng.Intn(len(rest) + 1)
+		return append(rest[:at:at], append(moved, rest[at:]...)...)
+	}
+}
+
+func TestRoundTripRandomEdits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		size := rng.Intn(200 << 10)
+		if i%10 == 0 {
+			size = rng.Intn(3 * MinBlockSize)
+		}
+		basis := randomData(rng, size)
+
+		blockSize := []int{MinBlockSize, 3000, BlockSizeFor(int64(size))}[rng.Intn(3)]
+		newData := basis
+		for edits := rng.Intn(8); edits > 0; edits-- {
+			newData = edit(rng, newData)
+		}
+		roundTrip(t, basis, newData, blockSize)
+	}
+}
+
+func TestRoundTripEdgeCases(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	block := randomData(rng, MinBlockSize)
+	twoBlocks := randomData(rng, 2*MinBlockSize)
+	tail := randomData(rng, 2*MinBlockSize+100)
+
+	tests := []struct {
+		name         string
+		basis, data  []byte
+		wantLiterals int64
+	}{
+		{name: "both empty"},
+		{name: "empty basis", data: block, wantLiterals: MinBlockSize},
+		{name: "empty new file", basis: block},
+		{name: "identical", basis: tail, data: tail},
+		{name: "exact blocks", basis: twoBlocks, data: twoBlocks},
+		{name: "shorter than a block", basis: block[:100], data: block[:100]},
+		{name: "last block only", basis: tail, data: tail[2*MinBlockSize:]},
+		{name: "blocks swapped", basis: twoBlocks, data: append(bytes.Clone(twoBlocks[MinBlockSize:]), twoBlocks[:MinBlockSize]...)},
+		{name: "repeated block", basis: block, data: bytes.Repeat(block, 3)},
+		{name: "prefix byte", basis: tail, data: append([]byte{'x'}, tail...), wantLiterals: 1},
+		{name: "appended bytes", basis: twoBlocks, data: append(bytes.Clone(twoBlocks), 'x', 'y'), wantLiterals: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := roundTrip(t, tt.basis, tt.data, MinBlockSize)
+			if p.Literal != tt.wantLiterals {
+				t.Errorf("sent %d literal bytes, want %d", p.Literal, tt.wantLiterals)
+			}
+		})
+	}
+}
+
+func TestDiffOnlySendsChanges(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	basis := randomData(rng, 1<<20)
+	blockSize := BlockSizeFor(int64(len(basis)))
+
+	// A few bytes changed in the middle cost about one block
+	newData := bytes.Clone(basis)
+	copy(newData[500000:], "changed")
+	if p := roundTrip(t, basis, newData, blockSize); p.Literal > int64(blockSize) {
+		t.Errorf("sent %d literal bytes for a 7 byte change, want at most a block (%d)", p.Literal, blockSize)
+	}
+
+	// Inserted data shifts everything after it, which must still be found
+	newData = append(bytes.Clone(basis[:300000]), append([]byte("inserted"), basis[300000:]...)...)
+	if p := roundTrip(t, basis, newData, blockSize); p.Literal > int64(2*blockSize) {
+		t.Errorf("sent %d literal bytes for an 8 byte insert, want at most two blocks (%d)", p.Literal, 2*blockSize)
+	}
+}
+
+func TestPatcherChangedBasis(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	basis := randomData(rng, 10*MinBlockSize)
+	newData := append([]byte("prefix"), basis...)
+	delta := makeDelta(t, basis, newData, MinBlockSize)
+
+	// The basis changed since its signature was taken, under a copied block
+	changed := bytes.Clone(basis)
+	changed[5*MinBlockSize+3] ^= 0xff
+	if _, _, err := patch(changed, delta); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Patcher with a changed basis error = %v, want ErrChecksum", err)
+	}
+
+	// A literal changed in transit
+	literal := makeDelta(t, nil, []byte("some literal data"), MinBlockSize)
+	literal[len(magic)+5] ^= 0xff
+	if _, _, err := patch(nil, literal); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Patcher with a changed literal error = %v, want ErrChecksum", err)
+	}
+
+	// A shorter basis fails too, though not as a malformed delta
+	if _, _, err := patch(basis[:len(basis)-1], delta); err == nil {
+		t.Error("Patcher with a shorter basis succeeded")
+	}
+}
+
+func TestPatcherTruncatedDelta(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	basis := randomData(rng, 4*MinBlockSize)
+	newData := append(append(bytes.Clone(basis[:MinBlockSize]), randomData(rng, 100)...), basis[2*MinBlockSize:]...)
+	delta := makeDelta(t, basis, newData, MinBlockSize)
+
+	for n := 0; n < len(delta); n++ {
+		if _, _, err := patch(basis, delta[:n]); !errors.Is(err, ErrFormat) {
+			t.Fatalf("Patcher of the first %d of %d bytes error = %v, want ErrFormat", n, len(delta), err)
+		}
+	}
+}
+
+func TestPatcherCorruptDelta(t *testing.T) {
+	basis := bytes.Repeat([]byte("0123456789"), 300)
+	valid := makeDelta(t, basis, basis, MinBlockSize)
+
+	copyOp := func(offset uint64, length uint32) []byte {
+		op := []byte{opCopy}
+		op = binary.BigEndian.AppendUint64(op, offset)
+		return binary.BigEndian.AppendUint32(op, length)
+	}
+
+	tests := []struct {
+		name  string
+		delta []byte
+	}{
+		{name: "empty"},
+		{name: "bad magic", delta: append([]byte("PVD2"), valid[len(magic):]...)},
+		{name: "unknown operation", delta: []byte(magic + "\x07")},
+		{name: "copy past the end", delta: append([]byte(magic), copyOp(uint64(len(basis)-10), 11)...)},
+		{name: "copy offset past the end", delta: append([]byte(magic), copyOp(uint64(len(basis)+1), 0)...)},
+		{name: "copy offset overflow", delta: append([]byte(magic), copyOp(1<<63, 1)...)},
+		{name: "data after the end", delta: append(bytes.Clone(valid), 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := patch(basis, tt.delta); !errors.Is(err, ErrFormat) {
+				t.Errorf("Patcher error = %v, want ErrFormat", err)
+			}
+		})
+	}
+}
+
+func TestNewSignatureBlockSize(t *testing.T) {
+	for _, blockSize := range []int{0, MinBlockSize - 1, MaxBlockSize + 1} {
+		if _, err := NewSignature(bytes.NewReader(nil), blockSize); err == nil {
+			t.Errorf("NewSignature with a block size of %d succeeded", blockSize)
+		}
+	}
+
+	for _, size := range []int64{0, 1, 1 << 20, 1 << 30, 1 << 40} {
+		blockSize := BlockSizeFor(size)
+		if blockSize < MinBlockSize || blockSize > MaxBlockSize || blockSize%MinBlockSize != 0 {
+			t.Errorf("BlockSizeFor(%d) = %d", size, blockSize)
+		}
+	}
+}
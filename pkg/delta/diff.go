@@ -0,0 +1,211 @@
+package delta
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"io"
+)
+
+// Diff reads the new file from src and writes to w the delta that rebuilds it from the
+// basis of sig. Blocks of the basis are found at any offset of the new file, so data
+// inserted or removed anywhere only costs the blocks around it.
+func Diff(sig *Signature, src io.Reader, w io.Writer) error {
+	d := &differ{
+		sig:   sig,
+		index: make(map[uint32][]int, len(sig.Blocks)),
+		src:   src,
+		w:     bufio.NewWriter(w),
+		hash:  sha256.New(),
+		buf:   make([]byte, 0, MaxLiteral+2*sig.BlockSize),
+	}
+	for i, block := range sig.Blocks {
+		d.index[block.Weak] = append(d.index[block.Weak], i)
+	}
+
+	if _, err := d.w.WriteString(magic); err != nil {
+		return err
+	}
+	if err := d.run(); err != nil {
+		return err
+	}
+	if err := d.flushCopy(); err != nil {
+		return err
+	}
+
+	d.w.WriteByte(opEnd)
+	d.w.Write(d.hash.Sum(nil))
+	return d.w.Flush()
+}
+
+type differ struct {
+	sig   *Signature
+	index map[uint32][]int
+	src   io.Reader
+	w     *bufio.Writer
+	hash  hash.Hash
+
+	// buf holds the new file from the start of the pending literal (lit) past the window
+	// being matched, which starts at pos
+	buf      []byte
+	lit, pos int
+	eof      bool
+
+	// The pending copy, written once the next one doesn't extend it
+	copyOffset int64
+	copyLen    int64
+}
+
+func (d *differ) run() error {
+	blockSize := d.sig.BlockSize
+	var sum rolling
+	valid := false
+
+	for {
+		if err := d.fill(blockSize + 1); err != nil {
+			return err
+		}
+		n := min(blockSize, len(d.buf)-d.pos)
+		if n == 0 {
+			break
+		}
+		if n < blockSize {
+			// Only the last block of the basis can match the end of the new file
+			return d.finish()
+		}
+
+		if !valid {
+			sum = newRolling(d.buf[d.pos : d.pos+n])
+			valid = true
+		}
+		if i, ok := d.match(sum.sum(), d.buf[d.pos:d.pos+n]); ok {
+			if err := d.literal(d.buf[d.lit:d.pos]); err != nil {
+				return err
+			}
+			if err := d.copyBlock(i); err != nil {
+				return err
+			}
+			d.pos += n
+			d.lit = d.pos
+			valid = false
+			continue
+		}
+
+		if d.pos+n < len(d.buf) {
+			sum.roll(d.buf[d.pos], d.buf[d.pos+n])
+		} else {
+			valid = false
+		}
+		d.pos++
+		if d.pos-d.lit >= MaxLiteral {
+			if err := d.literal(d.buf[d.lit:d.pos]); err != nil {
+				return err
+			}
+			d.lit = d.pos
+		}
+	}
+	return d.literal(d.buf[d.lit:d.pos])
+}
+
+// finish encodes the end of the new file, shorter than a block.
+func (d *differ) finish() error {
+	last := len(d.sig.Blocks) - 1
+	if last >= 0 {
+		lastLen := d.sig.blockLen(last)
+		if tail := len(d.buf) - lastLen; lastLen < d.sig.BlockSize && tail >= d.pos {
+			p := d.buf[tail:]
+			if d.sig.Blocks[last].Weak == weakSum(p) && d.sig.Blocks[last].Strong == strongSum(p) {
+				if err := d.literal(d.buf[d.lit:tail]); err != nil {
+					return err
+				}
+				return d.copyBlock(last)
+			}
+		}
+	}
+	return d.literal(d.buf[d.lit:])
+}
+
+// match returns the block of the basis that p, of weak checksum weak, is a copy of.
+func (d *differ) match(weak uint32, p []byte) (int, bool) {
+	candidates := d.index[weak]
+	if len(candidates) == 0 {
+		return 0, false
+	}
+	strong := strongSum(p)
+	for _, i := range candidates {
+		if d.sig.blockLen(i) == len(p) && d.sig.Blocks[i].Strong == strong {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// fill reads the new file until buf holds n bytes from pos, or the file ends.
+func (d *differ) fill(n int) error {
+	if d.eof || len(d.buf)-d.pos >= n {
+		return nil
+	}
+	if d.lit > 0 {
+		d.buf = d.buf[:copy(d.buf, d.buf[d.lit:])]
+		d.pos -= d.lit
+		d.lit = 0
+	}
+	for len(d.buf)-d.pos < n {
+		read, err := d.src.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.hash.Write(d.buf[len(d.buf) : len(d.buf)+read])
+		d.buf = d.buf[:len(d.buf)+read]
+		if err == io.EOF {
+			d.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *differ) copyBlock(i int) error {
+	offset := int64(i) * int64(d.sig.BlockSize)
+	length := int64(d.sig.blockLen(i))
+	if d.copyLen > 0 && d.copyOffset+d.copyLen == offset && d.copyLen+length <= 1<<32-1 {
+		d.copyLen += length
+		return nil
+	}
+	if err := d.flushCopy(); err != nil {
+		return err
+	}
+	d.copyOffset, d.copyLen = offset, length
+	return nil
+}
+
+func (d *differ) flushCopy() error {
+	if d.copyLen == 0 {
+		return nil
+	}
+	var op [13]byte
+	op[0] = opCopy
+	binary.BigEndian.PutUint64(op[1:], uint64(d.copyOffset))
+	binary.BigEndian.PutUint32(op[9:], uint32(d.copyLen))
+	d.copyLen = 0
+	_, err := d.w.Write(op[:])
+	return err
+}
+
+func (d *differ) literal(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	if err := d.flushCopy(); err != nil {
+		return err
+	}
+	var op [5]byte
+	op[0] = opLiteral
+	binary.BigEndian.PutUint32(op[1:], uint32(len(p)))
+	if _, err := d.w.Write(op[:]); err != nil {
+		return err
+	}
+	_, err := d.w.Write(p)
+	return err
+}
@@ -0,0 +1,148 @@
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Patcher reads the file a delta rebuilds from its basis. It fails, instead of returning
+// io.EOF, if the delta is truncated or malformed or if the file doesn't match the
+// checksum at its end, so nothing reading it to completion can mistake a bad file for a
+// good one.
+type Patcher struct {
+	basis     io.ReadSeeker
+	basisSize int64
+	delta     *bufio.Reader
+	hash      hash.Hash
+
+	started   bool
+	cur       io.Reader
+	left      int64
+	fromDelta bool
+	err       error
+
+	// Copied and Literal count the bytes of the file copied from the basis and sent in
+	// the delta
+	Copied  int64
+	Literal int64
+}
+
+// NewPatcher returns a Patcher of the delta read from delta, against the basis of
+// basisSize bytes read from basis.
+func NewPatcher(basis io.ReadSeeker, basisSize int64, delta io.Reader) *Patcher {
+	return &Patcher{
+		basis:     basis,
+		basisSize: basisSize,
+		delta:     bufio.NewReader(delta),
+		hash:      sha256.New(),
+	}
+}
+
+func (p *Patcher) Read(b []byte) (int, error) {
+	for p.err == nil {
+		if p.left > 0 {
+			return p.readOp(b)
+		}
+		p.err = p.next()
+	}
+	return 0, p.err
+}
+
+// readOp reads the data of the current operation.
+func (p *Patcher) readOp(b []byte) (int, error) {
+	if int64(len(b)) > p.left {
+		b = b[:p.left]
+	}
+	n, err := p.cur.Read(b)
+	p.hash.Write(b[:n])
+	p.left -= int64(n)
+	if p.fromDelta {
+		p.Literal += int64(n)
+	} else {
+		p.Copied += int64(n)
+	}
+
+	if err == io.EOF && p.left > 0 {
+		if p.fromDelta {
+			err = fmt.Errorf("%w: truncated literal", ErrFormat)
+		} else {
+			err = errors.New("basis file is shorter than its size")
+		}
+	} else if err == io.EOF {
+		err = nil
+	}
+	if err != nil {
+		p.err = err
+	}
+	return n, err
+}
+
+// next reads the next operation of the delta. It returns io.EOF after the end.
+func (p *Patcher) next() error {
+	if !p.started {
+		var head [len(magic)]byte
+		if _, err := io.ReadFull(p.delta, head[:]); err != nil || string(head[:]) != magic {
+			return fmt.Errorf("%w: not a delta", ErrFormat)
+		}
+		p.started = true
+	}
+
+	op, err := p.delta.ReadByte()
+	if err != nil {
+		return truncated(err)
+	}
+
+	switch op {
+	case opCopy:
+		var args [12]byte
+		if _, err := io.ReadFull(p.delta, args[:]); err != nil {
+			return truncated(err)
+		}
+		offset := binary.BigEndian.Uint64(args[:8])
+		length := int64(binary.BigEndian.Uint32(args[8:]))
+		if offset > uint64(p.basisSize) || int64(offset)+length > p.basisSize {
+			return fmt.Errorf("%w: copy of %d bytes at %d is past the end of the basis file", ErrFormat, length, offset)
+		}
+		if _, err := p.basis.Seek(int64(offset), io.SeekStart); err != nil {
+			return err
+		}
+		p.cur, p.left, p.fromDelta = p.basis, length, false
+
+	case opLiteral:
+		var args [4]byte
+		if _, err := io.ReadFull(p.delta, args[:]); err != nil {
+			return truncated(err)
+		}
+		p.cur, p.left, p.fromDelta = p.delta, int64(binary.BigEndian.Uint32(args[:])), true
+
+	case opEnd:
+		var sum [sha256.Size]byte
+		if _, err := io.ReadFull(p.delta, sum[:]); err != nil {
+			return truncated(err)
+		}
+		if !bytes.Equal(sum[:], p.hash.Sum(nil)) {
+			return ErrChecksum
+		}
+		if _, err := p.delta.ReadByte(); err != io.EOF {
+			return fmt.Errorf("%w: data after the end", ErrFormat)
+		}
+		return io.EOF
+
+	default:
+		return fmt.Errorf("%w: unknown operation %#x", ErrFormat, op)
+	}
+	return nil
+}
+
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated", ErrFormat)
+	}
+	return err
+}
@@ -0,0 +1,544 @@
+// Package exif reads the EXIF metadata of JPEG and TIFF-based images (which includes most
+// raw formats, such as DNG, NEF and ARW) and removes GPS coordinates from it.
+//
+// Only the tags a photo library needs are decoded: capture time, camera, orientation and
+// position. GPS data is removed in place, without changing the size of the file or the
+// position of anything in it, so a stripped image can still be served with Range requests.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoExif = errors.New("no EXIF data")
+	ErrFormat = errors.New("malformed EXIF data")
+)
+
+// Metadata is the EXIF data of an image.
+type Metadata struct {
+	// CapturedAt is when the photo was taken, as shown on the camera's clock. Its location
+	// is UTC unless the camera recorded its offset from UTC; zero if not recorded.
+	CapturedAt time.Time
+	HasOffset  bool
+	Make       string
+	Model      string
+	// Orientation is the EXIF orientation, 1 to 8; zero if not recorded.
+	Orientation int
+	GPS         *GPS
+}
+
+// GPS is the position a photo was taken at, in degrees and meters.
+type GPS struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  *float64
+}
+
+const (
+	tagMake        = 0x010F
+	tagModel       = 0x0110
+	tagOrientation = 0x0112
+	tagDateTime    = 0x0132
+	tagExifIFD     = 0x8769
+	tagGPSIFD      = 0x8825
+
+	tagDateTimeOriginal    = 0x9003
+	tagDateTimeDigitized   = 0x9004
+	tagOffsetTime          = 0x9010
+	tagOffsetTimeOriginal  = 0x9011
+	tagOffsetTimeDigitized = 0x9012
+
+	tagGPSLatitudeRef  = 1
+	tagGPSLatitude     = 2
+	tagGPSLongitudeRef = 3
+	tagGPSLongitude    = 4
+	tagGPSAltitudeRef  = 5
+	tagGPSAltitude     = 6
+)
+
+const (
+	maxIFDEntries = 1000
+	maxValueSize  = 64 * 1024
+	maxSegments   = 256
+)
+
+// Decode reads the EXIF metadata of the image in r. It fails with ErrNoExif if the image
+// isn't a JPEG or TIFF file or carries no EXIF data.
+func Decode(r io.ReadSeeker) (*Metadata, error) {
+	b, err := locate(r)
+	if err != nil {
+		return nil, err
+	}
+	ifd0, err := b.ifd(b.ifd0)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{}
+	var dateTime string
+	var exifIFD, gpsIFD *entry
+	for i := range ifd0 {
+		e := &ifd0[i]
+		switch e.tag {
+		case tagMake:
+			m.Make = b.str(e)
+		case tagModel:
+			m.Model = b.str(e)
+		case tagOrientation:
+			if o, ok := b.uint(e); ok && o >= 1 && o <= 8 {
+				m.Orientation = int(o)
+			}
+		case tagDateTime:
+			dateTime = b.str(e)
+		case tagExifIFD:
+			exifIFD = e
+		case tagGPSIFD:
+			gpsIFD = e
+		}
+	}
+
+	var offset string
+	if exifIFD != nil {
+		tags := b.subIFD(exifIFD)
+		var original, digitized, offsetOriginal, offsetDigitized string
+		for i := range tags {
+			e := &tags[i]
+			switch e.tag {
+			case tagDateTimeOriginal:
+				original = b.str(e)
+			case tagDateTimeDigitized:
+				digitized = b.str(e)
+			case tagOffsetTime:
+				offset = b.str(e)
+			case tagOffsetTimeOriginal:
+				offsetOriginal = b.str(e)
+			case tagOffsetTimeDigitized:
+				offsetDigitized = b.str(e)
+			}
+		}
+		switch {
+		case original != "":
+			dateTime, offset = original, offsetOriginal
+		case digitized != "":
+			dateTime, offset = digitized, offsetDigitized
+		}
+	}
+	m.CapturedAt, m.HasOffset = parseTime(dateTime, offset)
+
+	if gpsIFD != nil {
+		m.GPS = b.gps(b.subIFD(gpsIFD))
+	}
+	return m, nil
+}
+
+// StripGPS returns a reader over the image in r with its GPS tags removed. Images without
+// GPS data, and files that aren't images, are returned as they are, rewound to the start.
+func StripGPS(r io.ReadSeeker) (io.ReadSeeker, error) {
+	patches, err := gpsPatches(r)
+	if err != nil && !errors.Is(err, ErrNoExif) && !errors.Is(err, ErrFormat) {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if len(patches) == 0 {
+		return r, nil
+	}
+	return &patchedReader{r: r, patches: patches}, nil
+}
+
+// gpsPatches returns the writes that blank out the GPS directories: their values and then
+// the directories themselves, leaving empty directories behind. Every GPS directory IFD0
+// points to is blanked, as Decode reads the last one; those it can't read are skipped.
+func gpsPatches(r io.ReadSeeker) ([]patch, error) {
+	b, err := locate(r)
+	if err != nil {
+		return nil, err
+	}
+	ifd0, err := b.ifd(b.ifd0)
+	if err != nil {
+		return nil, err
+	}
+
+	var patches []patch
+	for i := range ifd0 {
+		if ifd0[i].tag != tagGPSIFD {
+			continue
+		}
+		offset, ok := b.uint(&ifd0[i])
+		if !ok {
+			continue
+		}
+		entries, err := b.ifd(int64(offset))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if size, inline := b.dataSize(&e); !inline && size <= maxValueSize {
+				if start := int64(b.order.Uint32(e.value[:])); b.inBounds(start, size) {
+					patches = append(patches, patch{off: b.base + start, data: make([]byte, size)})
+				}
+			}
+		}
+		// Entry count, entries, and the offset of the next directory if it is in the block
+		size := min(int64(2+12*len(entries)+4), b.limit-int64(offset))
+		patches = append(patches, patch{off: b.base + int64(offset), data: make([]byte, size)})
+	}
+	return patches, nil
+}
+
+// block is the TIFF structure holding the EXIF data, at base in the file.
+type block struct {
+	r     io.ReaderAt
+	base  int64
+	limit int64 // size of the block
+	order binary.ByteOrder
+	ifd0  int64
+}
+
+type entry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value [4]byte
+}
+
+// locate finds the TIFF structure in a JPEG's APP1 segment or at the start of a TIFF file.
+func locate(r io.ReadSeeker) (*block, error) {
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		ra = &seekReaderAt{r}
+	}
+
+	var head [4]byte
+	if _, err := ra.ReadAt(head[:], 0); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNoExif
+		}
+		return nil, err
+	}
+
+	var b *block
+	switch {
+	case head[0] == 0xFF && head[1] == 0xD8:
+		base, size, err := findAPP1(ra)
+		if err != nil {
+			return nil, err
+		}
+		b = &block{r: ra, base: base, limit: size}
+	case string(head[:]) == "II*\x00", string(head[:]) == "MM\x00*":
+		size, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		b = &block{r: ra, limit: size}
+	default:
+		return nil, ErrNoExif
+	}
+
+	header, err := b.read(0, 8)
+	if err != nil {
+		return nil, err
+	}
+	switch string(header[:2]) {
+	case "II":
+		b.order = binary.LittleEndian
+	case "MM":
+		b.order = binary.BigEndian
+	default:
+		return nil, ErrFormat
+	}
+	if b.order.Uint16(header[2:]) != 42 {
+		return nil, ErrFormat
+	}
+	b.ifd0 = int64(b.order.Uint32(header[4:]))
+	return b, nil
+}
+
+// findAPP1 returns the position and size of the TIFF structure in the Exif APP1 segment.
+func findAPP1(r io.ReaderAt) (int64, int64, error) {
+	pos := int64(2)
+	var buf [10]byte
+	for range maxSegments {
+		if _, err := r.ReadAt(buf[:4], pos); err != nil {
+			return 0, 0, ErrNoExif
+		}
+		if buf[0] != 0xFF {
+			return 0, 0, ErrFormat
+		}
+		marker := buf[1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			pos++
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			pos += 2
+			continue
+		case marker == 0xD9 || marker == 0xDA:
+			// End of image, or the image data starts: no metadata follows
+			return 0, 0, ErrNoExif
+		}
+
+		length := int64(binary.BigEndian.Uint16(buf[2:4]))
+		if length < 2 {
+			return 0, 0, ErrFormat
+		}
+		if marker == 0xE1 && length >= 8+6 {
+			if _, err := r.ReadAt(buf[4:10], pos+4); err != nil {
+				return 0, 0, ErrFormat
+			}
+			if string(buf[4:10]) == "Exif\x00\x00" {
+				return pos + 10, length - 8, nil
+			}
+		}
+		pos += 2 + length
+	}
+	return 0, 0, ErrNoExif
+}
+
+func (b *block) inBounds(off, n int64) bool {
+	return off >= 0 && n >= 0 && off <= b.limit && n <= b.limit-off
+}
+
+func (b *block) read(off, n int64) ([]byte, error) {
+	if !b.inBounds(off, n) || n > maxValueSize {
+		return nil, ErrFormat
+	}
+	buf := make([]byte, n)
+	if _, err := b.r.ReadAt(buf, b.base+off); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	return buf, nil
+}
+
+func (b *block) ifd(off int64) ([]entry, error) {
+	head, err := b.read(off, 2)
+	if err != nil {
+		return nil, err
+	}
+	count := int64(b.order.Uint16(head))
+	if count > maxIFDEntries {
+		return nil, ErrFormat
+	}
+	data, err := b.read(off+2, 12*count)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, count)
+	for i := range entries {
+		raw := data[12*i:]
+		e := &entries[i]
+		e.tag = b.order.Uint16(raw)
+		e.typ = b.order.Uint16(raw[2:])
+		e.count = b.order.Uint32(raw[4:])
+		copy(e.value[:], raw[8:12])
+	}
+	return entries, nil
+}
+
+// subIFD reads the directory e points to; a broken one is treated as empty.
+func (b *block) subIFD(e *entry) []entry {
+	off, ok := b.uint(e)
+	if !ok {
+		return nil
+	}
+	entries, err := b.ifd(int64(off))
+	if err != nil {
+		return nil
+	}
+	return entries
+}
+
+var typeSizes = map[uint16]int64{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// dataSize returns the size of e's value and whether it is stored in the entry itself.
+func (b *block) dataSize(e *entry) (int64, bool) {
+	size := typeSizes[e.typ] * int64(e.count)
+	return size, size <= 4
+}
+
+func (b *block) data(e *entry) ([]byte, bool) {
+	size, inline := b.dataSize(e)
+	if size == 0 {
+		return nil, false
+	}
+	if inline {
+		return e.value[:size], true
+	}
+	data, err := b.read(int64(b.order.Uint32(e.value[:])), size)
+	return data, err == nil
+}
+
+func (b *block) str(e *entry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	data, ok := b.data(e)
+	if !ok {
+		return ""
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(string(data), ""))
+}
+
+func (b *block) uint(e *entry) (uint32, bool) {
+	if e.count == 0 {
+		return 0, false
+	}
+	switch e.typ {
+	case 1, 7:
+		return uint32(e.value[0]), true
+	case 3:
+		return uint32(b.order.Uint16(e.value[:])), true
+	case 4:
+		return b.order.Uint32(e.value[:]), true
+	}
+	return 0, false
+}
+
+func (b *block) rationals(e *entry) []float64 {
+	if e.typ != 5 {
+		return nil
+	}
+	data, ok := b.data(e)
+	if !ok {
+		return nil
+	}
+	values := make([]float64, e.count)
+	for i := range values {
+		num := b.order.Uint32(data[8*i:])
+		den := b.order.Uint32(data[8*i+4:])
+		if den == 0 {
+			return nil
+		}
+		values[i] = float64(num) / float64(den)
+	}
+	return values
+}
+
+func (b *block) gps(entries []entry) *GPS {
+	var latRef, lonRef string
+	var lat, lon, alt []float64
+	var altRef uint32
+	for i := range entries {
+		e := &entries[i]
+		switch e.tag {
+		case tagGPSLatitudeRef:
+			latRef = b.str(e)
+		case tagGPSLatitude:
+			lat = b.rationals(e)
+		case tagGPSLongitudeRef:
+			lonRef = b.str(e)
+		case tagGPSLongitude:
+			lon = b.rationals(e)
+		case tagGPSAltitudeRef:
+			altRef, _ = b.uint(e)
+		case tagGPSAltitude:
+			alt = b.rationals(e)
+		}
+	}
+	if len(lat) != 3 || len(lon) != 3 || latRef == "" || lonRef == "" {
+		return nil
+	}
+
+	g := &GPS{
+		Latitude:  lat[0] + lat[1]/60 + lat[2]/3600,
+		Longitude: lon[0] + lon[1]/60 + lon[2]/3600,
+	}
+	if latRef == "S" {
+		g.Latitude = -g.Latitude
+	}
+	if lonRef == "W" {
+		g.Longitude = -g.Longitude
+	}
+	if math.Abs(g.Latitude) > 90 || math.Abs(g.Longitude) > 180 {
+		return nil
+	}
+	if len(alt) == 1 {
+		altitude := alt[0]
+		if altRef == 1 {
+			altitude = -altitude
+		}
+		g.Altitude = &altitude
+	}
+	return g
+}
+
+// parseTime parses an EXIF date ("2006:01:02 15:04:05") and its offset ("+02:00").
+func parseTime(value, offset string) (time.Time, bool) {
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}, false
+	}
+	if len(value) > 19 {
+		value = value[:19]
+	}
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", value+offset); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, false
+}
+
+// seekReaderAt reads at an offset by seeking, for readers that can't do it themselves.
+type seekReaderAt struct {
+	r io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := s.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(s.r, p)
+}
+
+type patch struct {
+	off  int64
+	data []byte
+}
+
+// patchedReader reads r with some of its bytes replaced.
+type patchedReader struct {
+	r       io.ReadSeeker
+	pos     int64
+	patches []patch
+}
+
+func (p *patchedReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	for _, pt := range p.patches {
+		start := max(pt.off, p.pos)
+		end := min(pt.off+int64(len(pt.data)), p.pos+int64(n))
+		if start < end {
+			copy(buf[start-p.pos:end-p.pos], pt.data[start-pt.off:end-pt.off])
+		}
+	}
+	p.pos += int64(n)
+	return n, err
+}
+
+func (p *patchedReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.r.Seek(offset, whence)
+	if err == nil {
+		p.pos = pos
+	}
+	return pos, err
+}
@@ -0,0 +1,598 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// byteOrder is one of the byte orders of encoding/binary.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// field is one directory entry. Values of more than 4 bytes are laid out after the
+// directories, unless raw is set: then data is the 4-byte value field as it is, which
+// lets a test point an entry anywhere.
+type field struct {
+	tag, typ uint16
+	count    uint32
+	data     []byte
+	raw      bool
+}
+
+func ascii(tag uint16, s string) field {
+	return field{tag: tag, typ: 2, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+func byteField(tag uint16, v byte) field {
+	return field{tag: tag, typ: 1, count: 1, data: []byte{v}}
+}
+
+func short(order byteOrder, tag, v uint16) field {
+	return field{tag: tag, typ: 3, count: 1, data: order.AppendUint16(nil, v)}
+}
+
+func long(order byteOrder, tag uint16, v uint32) field {
+	return field{tag: tag, typ: 4, count: 1, data: order.AppendUint32(nil, v)}
+}
+
+// rational takes numerator and denominator pairs.
+func rational(order byteOrder, tag uint16, values ...uint32) field {
+	f := field{tag: tag, typ: 5, count: uint32(len(values) / 2)}
+	for _, v := range values {
+		f.data = order.AppendUint32(f.data, v)
+	}
+	return f
+}
+
+// pointer is an entry whose value is offset, whatever its count says.
+func pointer(order byteOrder, tag, typ uint16, count, offset uint32) field {
+	return field{tag: tag, typ: typ, count: count, data: order.AppendUint32(nil, offset), raw: true}
+}
+
+// tiff lays out a TIFF structure: the header, IFD0, the Exif and GPS directories (if not
+// nil, with IFD0 pointing to them) and then the values.
+type tiff struct {
+	order     byteOrder
+	ifd0      []field
+	exif, gps []field
+}
+
+func ifdSize(fields []field) int {
+	if fields == nil {
+		return 0
+	}
+	return 2 + 12*len(fields) + 4
+}
+
+// gpsOffset returns the offset of the GPS directory in the TIFF structure.
+func (t tiff) gpsOffset() int {
+	ifd0 := len(t.ifd0)
+	if t.exif != nil {
+		ifd0++
+	}
+	if t.gps != nil {
+		ifd0++
+	}
+	return 8 + 2 + 12*ifd0 + 4 + ifdSize(t.exif)
+}
+
+func (t tiff) bytes() []byte {
+	ifd0 := append([]field(nil), t.ifd0...)
+	if t.exif != nil {
+		ifd0 = append(ifd0, long(t.order, tagExifIFD, uint32(t.gpsOffset()-ifdSize(t.exif))))
+	}
+	if t.gps != nil {
+		ifd0 = append(ifd0, long(t.order, tagGPSIFD, uint32(t.gpsOffset())))
+	}
+	dataOffset := t.gpsOffset() + ifdSize(t.gps)
+
+	out := []byte("II*\x00")
+	if t.order == binary.BigEndian {
+		out = []byte("MM\x00*")
+	}
+	out = t.order.AppendUint32(out, 8)
+	var data []byte
+	for _, fields := range [][]field{ifd0, t.exif, t.gps} {
+		if fields == nil {
+			continue
+		}
+		out = t.order.AppendUint16(out, uint16(len(fields)))
+		for _, f := range fields {
+			out = t.order.AppendUint16(out, f.tag)
+			out = t.order.AppendUint16(out, f.typ)
+			out = t.order.AppendUint32(out, f.count)
+			switch {
+			case f.raw || len(f.data) <= 4:
+				var value [4]byte
+				copy(value[:], f.data)
+				out = append(out, value[:]...)
+			default:
+				out = t.order.AppendUint32(out, uint32(dataOffset+len(data)))
+				data = append(data, f.data...)
+				if len(data)%2 == 1 {
+					data = append(data, 0)
+				}
+			}
+		}
+		out = t.order.AppendUint32(out, 0)
+	}
+	return append(out, data...)
+}
+
+// jpegHead is the start of a JPEG file up to its APP1 segment: the start of image and a
+// JFIF APP0 segment.
+var jpegHead = []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+
+// jpeg wraps the TIFF structure in the APP1 segment of a JPEG file, followed by some image
+// data.
+func jpeg(tiff []byte) []byte {
+	out := bytes.Clone(jpegHead)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(2+6+len(tiff)))
+	out = append(out, "Exif\x00\x00"...)
+	out = append(out, tiff...)
+	return append(out, "\xFF\xDA\x00\x08\x01\x01\x00\x00\x3F\x00\x12\x34\x56\xFF\xD9"...)
+}
+
+// photo is a photo with every tag Decode reads: taken in Berlin, 34.5 m above sea level.
+func photo(order byteOrder) tiff {
+	return tiff{
+		order: order,
+		ifd0: []field{
+			ascii(tagMake, "Canon"),
+			ascii(tagModel, "EOS R5"),
+			short(order, tagOrientation, 6),
+			ascii(tagDateTime, "2021:06:16 09:00:00"),
+		},
+		exif: []field{
+			ascii(tagDateTimeOriginal, "2021:06:15 14:30:00"),
+			ascii(tagOffsetTimeOriginal, "+02:00"),
+			ascii(tagDateTimeDigitized, "2021:06:15 14:31:00"),
+		},
+		gps: []field{
+			ascii(tagGPSLatitudeRef, "N"),
+			rational(order, tagGPSLatitude, 52, 1, 31, 1, 125, 10),
+			ascii(tagGPSLongitudeRef, "E"),
+			rational(order, tagGPSLongitude, 13, 1, 24, 1, 36, 1),
+			byteField(tagGPSAltitudeRef, 0),
+			rational(order, tagGPSAltitude, 345, 10),
+		},
+	}
+}
+
+var orders = []byteOrder{binary.LittleEndian, binary.BigEndian}
+
+// images returns the TIFF structure as a TIFF and as a JPEG file.
+func images(t tiff) map[string][]byte {
+	data := t.bytes()
+	return map[string][]byte{"TIFF " + t.order.String(): data, "JPEG " + t.order.String(): jpeg(data)}
+}
+
+func altitude(v float64) *float64 {
+	return &v
+}
+
+func checkMetadata(t *testing.T, got, want *Metadata) {
+	t.Helper()
+	if !got.CapturedAt.Equal(want.CapturedAt) || got.HasOffset != want.HasOffset {
+		t.Errorf("CapturedAt = %v (offset %v), want %v (offset %v)", got.CapturedAt, got.HasOffset, want.CapturedAt, want.HasOffset)
+	}
+	if _, offset := got.CapturedAt.Zone(); !got.CapturedAt.IsZero() && want.HasOffset {
+		if _, wantOffset := want.CapturedAt.Zone(); offset != wantOffset {
+			t.Errorf("CapturedAt is at offset %d, want %d", offset, wantOffset)
+		}
+	}
+	if got.Make != want.Make || got.Model != want.Model || got.Orientation != want.Orientation {
+		t.Errorf("Make, Model, Orientation = %q, %q, %d, want %q, %q, %d", got.Make, got.Model, got.Orientation, want.Make, want.Model, want.Orientation)
+	}
+
+	switch {
+	case got.GPS == nil && want.GPS == nil:
+	case got.GPS == nil || want.GPS == nil:
+		t.Errorf("GPS = %+v, want %+v", got.GPS, want.GPS)
+	default:
+		near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+		if !near(got.GPS.Latitude, want.GPS.Latitude) || !near(got.GPS.Longitude, want.GPS.Longitude) {
+			t.Errorf("position = %v, %v, want %v, %v", got.GPS.Latitude, got.GPS.Longitude, want.GPS.Latitude, want.GPS.Longitude)
+		}
+		if (got.GPS.Altitude == nil) != (want.GPS.Altitude == nil) || got.GPS.Altitude != nil && !near(*got.GPS.Altitude, *want.GPS.Altitude) {
+			t.Errorf("altitude = %v, want %v", got.GPS.Altitude, want.GPS.Altitude)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	berlin := time.FixedZone("", 2*60*60)
+	tests := []struct {
+		name   string
+		change func(t *tiff)
+		want   Metadata
+	}{
+		{
+			name: "every tag",
+			want: Metadata{
+				CapturedAt: time.Date(2021, 6, 15, 14, 30, 0, 0, berlin), HasOffset: true,
+				Make: "Canon", Model: "EOS R5", Orientation: 6,
+				GPS: &GPS{Latitude: 52 + 31.0/60 + 12.5/3600, Longitude: 13.41, Altitude: altitude(34.5)},
+			},
+		},
+		{
+			name: "digitized time",
+			change: func(t *tiff) {
+				t.exif = []field{ascii(tagDateTimeDigitized, "2021:06:15 14:31:00"), ascii(tagOffsetTimeDigitized, "-05:00")}
+				t.gps = nil
+			},
+			want: Metadata{
+				CapturedAt: time.Date(2021, 6, 15, 14, 31, 0, 0, time.FixedZone("", -5*60*60)), HasOffset: true,
+				Make: "Canon", Model: "EOS R5", Orientation: 6,
+			},
+		},
+		{
+			name:   "only the time of IFD0",
+			change: func(t *tiff) { t.exif, t.gps = nil, nil },
+			want: Metadata{
+				CapturedAt: time.Date(2021, 6, 16, 9, 0, 0, 0, time.UTC),
+				Make:       "Canon", Model: "EOS R5", Orientation: 6,
+			},
+		},
+		{
+			name: "blank time and orientation out of range",
+			change: func(t *tiff) {
+				t.ifd0 = []field{short(t.order, tagOrientation, 9), ascii(tagDateTime, "0000:00:00 00:00:00")}
+				t.exif, t.gps = nil, nil
+			},
+		},
+		{
+			name: "south, west and below sea level",
+			change: func(t *tiff) {
+				t.exif = nil
+				t.gps[0], t.gps[2], t.gps[4] = ascii(tagGPSLatitudeRef, "S"), ascii(tagGPSLongitudeRef, "W"), byteField(tagGPSAltitudeRef, 1)
+			},
+			want: Metadata{
+				CapturedAt: time.Date(2021, 6, 16, 9, 0, 0, 0, time.UTC),
+				Make:       "Canon", Model: "EOS R5", Orientation: 6,
+				GPS: &GPS{Latitude: -(52 + 31.0/60 + 12.5/3600), Longitude: -13.41, Altitude: altitude(-34.5)},
+			},
+		},
+		{
+			name: "position without altitude",
+			change: func(t *tiff) {
+				t.ifd0, t.exif, t.gps = nil, nil, t.gps[:4]
+			},
+			want: Metadata{GPS: &GPS{Latitude: 52 + 31.0/60 + 12.5/3600, Longitude: 13.41}},
+		},
+		{
+			name: "zero denominator",
+			change: func(t *tiff) {
+				t.ifd0, t.exif, t.gps[1] = nil, nil, rational(t.order, tagGPSLatitude, 52, 1, 31, 0, 125, 10)
+			},
+		},
+		{
+			name: "latitude out of range",
+			change: func(t *tiff) {
+				t.ifd0, t.exif, t.gps[1] = nil, nil, rational(t.order, tagGPSLatitude, 91, 1, 0, 1, 0, 1)
+			},
+		},
+		{
+			name:   "two values for the latitude",
+			change: func(t *tiff) { t.ifd0, t.exif, t.gps[1] = nil, nil, rational(t.order, tagGPSLatitude, 52, 1, 31, 1) },
+		},
+		{
+			name:   "latitude as integers",
+			change: func(t *tiff) { t.ifd0, t.exif, t.gps[1] = nil, nil, long(t.order, tagGPSLatitude, 52) },
+		},
+		{
+			name:   "no longitude reference",
+			change: func(t *tiff) { t.ifd0, t.exif, t.gps = nil, nil, append(t.gps[:2], t.gps[3:]...) },
+		},
+	}
+	for _, tt := range tests {
+		for _, order := range orders {
+			img := photo(order)
+			if tt.change != nil {
+				tt.change(&img)
+			}
+			for name, data := range images(img) {
+				t.Run(tt.name+"/"+name, func(t *testing.T) {
+					m, err := Decode(bytes.NewReader(data))
+					if err != nil {
+						t.Fatal(err)
+					}
+					checkMetadata(t, m, &tt.want)
+				})
+			}
+		}
+	}
+}
+
+func TestDecodeWithoutReaderAt(t *testing.T) {
+	m, err := Decode(struct{ io.ReadSeeker }{bytes.NewReader(jpeg(photo(binary.BigEndian).bytes()))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Make != "Canon" || m.GPS == nil {
+		t.Errorf("decoded %+v", m)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	le := binary.LittleEndian
+	header := []byte("II*\x00\x08\x00\x00\x00")
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+		want Metadata
+	}{
+		{name: "empty", err: ErrNoExif},
+		{name: "not an image", data: []byte("hello, world"), err: ErrNoExif},
+		{name: "JPEG without EXIF", data: append(bytes.Clone(jpegHead), "\xFF\xDA\x00\x02\xFF\xD9"...), err: ErrNoExif},
+		{name: "JPEG cut off in a segment", data: []byte("\xFF\xD8\xFF\xE1\x00"), err: ErrNoExif},
+		{name: "JPEG with a broken marker", data: []byte("\xFF\xD8\x00\x00\x00\x00"), err: ErrFormat},
+		{name: "JPEG segment shorter than its length", data: []byte("\xFF\xD8\xFF\xE1\x00\x01"), err: ErrFormat},
+		{name: "APP1 longer than the file", data: append([]byte("\xFF\xD8\xFF\xE1\xFF\xFFExif\x00\x00"), header...), err: ErrFormat},
+		{name: "unknown byte order", data: jpeg([]byte("XX*\x00\x08\x00\x00\x00")), err: ErrFormat},
+		{name: "not TIFF", data: jpeg([]byte("II+\x00\x08\x00\x00\x00")), err: ErrFormat},
+		{name: "TIFF header cut off", data: []byte("II*\x00\x08"), err: ErrFormat},
+		{name: "IFD0 past the end", data: []byte("II*\x00\xF0\xFF\xFF\xFF"), err: ErrFormat},
+		{name: "IFD0 cut off", data: append(bytes.Clone(header), 5, 0, 0x0F, 0x01, 2, 0), err: ErrFormat},
+		{name: "IFD0 with too many entries", data: append(bytes.Clone(header), 0xFF, 0xFF), err: ErrFormat},
+		{
+			name: "values past the end",
+			data: tiff{order: le, ifd0: []field{
+				pointer(le, tagMake, 2, 100, 0xFFFFFF00),
+				pointer(le, tagModel, 2, 10, 0x7FFFFFFF),
+				ascii(tagDateTime, "2021:06:16 09:00:00"),
+			}}.bytes(),
+			want: Metadata{CapturedAt: time.Date(2021, 6, 16, 9, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "huge counts",
+			data: tiff{order: le, ifd0: []field{
+				pointer(le, tagMake, 2, 0xFFFFFFFF, 8),
+				ascii(tagModel, "EOS R5"),
+			}, gps: []field{
+				ascii(tagGPSLatitudeRef, "N"),
+				pointer(le, tagGPSLatitude, 5, 0xFFFFFFFF, 8),
+				ascii(tagGPSLongitudeRef, "E"),
+				rational(le, tagGPSLongitude, 13, 1, 24, 1, 36, 1),
+			}}.bytes(),
+			want: Metadata{Model: "EOS R5"},
+		},
+		{
+			name: "sub-directories past the end",
+			data: tiff{order: le, ifd0: []field{
+				ascii(tagMake, "Canon"),
+				long(le, tagExifIFD, 0xFFFFFFF0),
+				long(le, tagGPSIFD, 0xFFFFFFF0),
+			}}.bytes(),
+			want: Metadata{Make: "Canon"},
+		},
+		{
+			name: "sub-directories that point back to IFD0",
+			data: tiff{order: le, ifd0: []field{
+				ascii(tagMake, "Canon"),
+				long(le, tagExifIFD, 8),
+				long(le, tagGPSIFD, 8),
+			}}.bytes(),
+			want: Metadata{Make: "Canon"},
+		},
+		{
+			name: "sub-directory pointers of the wrong type",
+			data: tiff{order: le, ifd0: []field{
+				ascii(tagMake, "Canon"),
+				ascii(tagExifIFD, "x"),
+				rational(le, tagGPSIFD, 8, 1),
+			}}.bytes(),
+			want: Metadata{Make: "Canon"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			m, err := Decode(bytes.NewReader(tt.data))
+			runtime.ReadMemStats(&after)
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+				t.Errorf("allocated %d bytes", allocated)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if err == nil {
+				checkMetadata(t, m, &tt.want)
+			}
+		})
+	}
+}
+
+func TestStripGPS(t *testing.T) {
+	for _, order := range orders {
+		img := photo(order)
+		for name, data := range images(img) {
+			t.Run(name, func(t *testing.T) {
+				stripped, err := StripGPS(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				out, err := io.ReadAll(stripped)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(out) != len(data) {
+					t.Fatalf("stripped image has %d bytes, want %d", len(out), len(data))
+				}
+				changed := 0
+				for i := range out {
+					if out[i] != data[i] {
+						changed++
+						if out[i] != 0 {
+							t.Fatalf("byte %d set to %#x, want it blanked", i, out[i])
+						}
+					}
+				}
+				if changed == 0 {
+					t.Fatal("nothing stripped")
+				}
+				// Everything up to the GPS directory is left alone
+				if start := bytes.Index(data, img.bytes()) + img.gpsOffset(); !bytes.Equal(out[:start], data[:start]) {
+					t.Error("changed bytes before the GPS directory")
+				}
+
+				m, err := Decode(bytes.NewReader(out))
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _ := Decode(bytes.NewReader(data))
+				want.GPS = nil
+				checkMetadata(t, m, want)
+
+				// The patches hold across reads of any size and after seeking
+				if _, err := stripped.Seek(0, io.SeekStart); err != nil {
+					t.Fatal(err)
+				}
+				if err := iotest.TestReader(stripped, out); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
+
+func TestStripGPSUnchanged(t *testing.T) {
+	noGPS := photo(binary.LittleEndian)
+	noGPS.gps = nil
+	le := binary.LittleEndian
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"without GPS", jpeg(noGPS.bytes())},
+		{"not an image", []byte("hello, world")},
+		{"malformed", []byte("II*\x00\xF0\xFF\xFF\xFF")},
+		{"GPS directory past the end", tiff{order: le, ifd0: []field{long(le, tagGPSIFD, 0xFFFFFFF0)}}.bytes()},
+	}
+	for _, tt := range tests {
+		r := bytes.NewReader(tt.data)
+		r.Seek(5, io.SeekStart)
+		stripped, err := StripGPS(r)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if stripped != io.ReadSeeker(r) {
+			t.Errorf("%s: returned another reader", tt.name)
+		}
+		if out, _ := io.ReadAll(stripped); !bytes.Equal(out, tt.data) {
+			t.Errorf("%s: returned the image changed or not rewound", tt.name)
+		}
+	}
+}
+
+func TestStripGPSMalformed(t *testing.T) {
+	// A second GPS directory, after an empty one: the zero value of the altitude reference
+	twice := photo(binary.LittleEndian)
+	twice.ifd0 = append(twice.ifd0, field{})
+	altitudeRef := twice.gpsOffset() + 2 + 12*4 + 8
+	twice.ifd0[len(twice.ifd0)-1] = long(twice.order, tagGPSIFD, uint32(altitudeRef))
+
+	stripped, err := StripGPS(bytes.NewReader(twice.bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(stripped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := Decode(bytes.NewReader(out)); err != nil || m.GPS != nil {
+		t.Errorf("with two GPS directories: decoded %+v, %v after stripping", m, err)
+	}
+
+	// A GPS directory that ends with the APP1 segment, without the offset of a next one
+	gps := tiff{order: binary.LittleEndian, gps: []field{ascii(tagGPSLatitudeRef, "N")}}.bytes()
+	data := jpeg(gps[:len(gps)-4])
+	stripped, err = StripGPS(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err = io.ReadAll(stripped); err != nil {
+		t.Fatal(err)
+	}
+	segmentEnd := len(data) - len("\xFF\xDA\x00\x08\x01\x01\x00\x00\x3F\x00\x12\x34\x56\xFF\xD9")
+	if !bytes.Equal(out[segmentEnd:], data[segmentEnd:]) {
+		t.Errorf("stripping changed the image data after the APP1 segment: % x", out[segmentEnd:])
+	}
+	if bytes.Equal(out, data) {
+		t.Error("nothing stripped")
+	}
+}
+
+// mutate applies a random byte change, offset overwrite, cut or insertion to data.
+func mutate(rng *rand.Rand, data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	out := bytes.Clone(data)
+	pos := rng.Intn(len(out))
+	switch rng.Intn(4) {
+	case 0:
+		for range 1 + rng.Intn(4) {
+			out[rng.Intn(len(out))] ^= byte(1 + rng.Intn(255))
+		}
+	case 1:
+		// Offsets and counts are 2 or 4 bytes: make them small, large or anything
+		values := []uint32{0, 1, 8, 0xFFFF, 0xFFFFFFFF, uint32(len(out)), rng.Uint32()}
+		var value [4]byte
+		binary.LittleEndian.PutUint32(value[:], values[rng.Intn(len(values))])
+		copy(out[pos:], value[:])
+	case 2:
+		out = out[:pos]
+	case 3:
+		out = append(out[:pos:pos], append(bytes.Repeat([]byte{byte(rng.Intn(256))}, 1+rng.Intn(16)), data[pos:]...)...)
+	}
+	return out
+}
+
+func TestMutatedImages(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var samples [][]byte
+	for _, order := range orders {
+		for _, data := range images(photo(order)) {
+			samples = append(samples, data)
+		}
+	}
+
+	for i := 0; i < 5000; i++ {
+		data := samples[i%len(samples)]
+		for range 1 + rng.Intn(3) {
+			data = mutate(rng, data)
+		}
+
+		// Neither may panic, and whatever Decode makes of the stripped file has no position
+		Decode(bytes.NewReader(data))
+		stripped, err := StripGPS(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("mutation %d: %v", i, err)
+		}
+		out, err := io.ReadAll(stripped)
+		if err != nil {
+			t.Fatalf("mutation %d: %v", i, err)
+		}
+		if len(out) != len(data) {
+			t.Fatalf("mutation %d: stripped image has %d bytes, want %d", i, len(out), len(data))
+		}
+		if m, err := Decode(bytes.NewReader(out)); err == nil && m.GPS != nil {
+			t.Fatalf("mutation %d: GPS %+v left after stripping", i, m.GPS)
+		}
+	}
+}
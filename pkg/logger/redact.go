@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are matched case-insensitively as substrings of attribute and
+// map keys.
+var sensitiveKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"authorization",
+	"cookie",
+	"api_key",
+	"apikey",
+	"access_key",
+	"private_key",
+}
+
+var sensitiveValuePatterns = []*regexp.Regexp{
+	// Bearer and Basic credentials from Authorization headers
+	regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`),
+	// JSON Web Tokens
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	// key=value pairs in query strings and DSNs
+	regexp.MustCompile(`(?i)\b(password|secret|token)=[^\s&]+`),
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redactString(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, redactString(v.Error()))
+		case map[string]any:
+			return slog.Any(a.Key, redactMap(v))
+		case map[string]string:
+			out := make(map[string]string, len(v))
+			for k, val := range v {
+				if isSensitiveKey(k) {
+					out[k] = redacted
+				} else {
+					out[k] = redactString(val)
+				}
+			}
+			return slog.Any(a.Key, out)
+		}
+	}
+
+	return a
+}
+
+func redactMap(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		switch {
+		case isSensitiveKey(k):
+			out[k] = redacted
+		default:
+			switch val := v.(type) {
+			case string:
+				out[k] = redactString(val)
+			case map[string]any:
+				out[k] = redactMap(val)
+			default:
+				out[k] = v
+			}
+		}
+	}
+	return out
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactString(s string) string {
+	for _, re := range sensitiveValuePatterns {
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			if i := strings.IndexAny(match, " ="); i >= 0 && !strings.HasPrefix(match, "eyJ") {
+				return match[:i+1] + redacted
+			}
+			return redacted
+		})
+	}
+	return s
+}
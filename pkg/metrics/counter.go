@@ -0,0 +1,58 @@
+package metrics
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	opts  Opts
+	value atomicFloat
+}
+
+// NewCounter returns a counter without labels.
+func NewCounter(opts Opts) *Counter {
+	return &Counter{opts: opts}
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add increases the counter. Negative values are ignored because counters
+// can only go up.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.value.Add(delta)
+}
+
+func (c *Counter) Value() float64 {
+	return c.value.Load()
+}
+
+func (c *Counter) Collect() []Family {
+	return []Family{{
+		Name:    c.opts.Name,
+		Help:    c.opts.Help,
+		Type:    TypeCounter,
+		Samples: []Sample{{Value: c.Value()}},
+	}}
+}
+
+type CounterVec struct {
+	*vec[Counter]
+}
+
+func NewCounterVec(opts Opts, labelNames ...string) *CounterVec {
+	return &CounterVec{newVec(opts, labelNames, func() *Counter { return &Counter{} })}
+}
+
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return v.with(values...)
+}
+
+func (v *CounterVec) Collect() []Family {
+	f := Family{Name: v.opts.Name, Help: v.opts.Help, Type: TypeCounter}
+	for _, c := range v.sorted() {
+		f.Samples = append(f.Samples, Sample{Labels: c.labels, Value: c.metric.Value()})
+	}
+	return []Family{f}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	opts  Opts
+	value atomicFloat
+}
+
+// NewGauge returns a gauge without labels.
+func NewGauge(opts Opts) *Gauge {
+	return &Gauge{opts: opts}
+}
+
+func (g *Gauge) Set(v float64) {
+	g.value.Set(v)
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.value.Add(delta)
+}
+
+func (g *Gauge) Inc() {
+	g.value.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.Add(-1)
+}
+
+func (g *Gauge) Value() float64 {
+	return g.value.Load()
+}
+
+func (g *Gauge) Collect() []Family {
+	return []Family{{
+		Name:    g.opts.Name,
+		Help:    g.opts.Help,
+		Type:    TypeGauge,
+		Samples: []Sample{{Value: g.Value()}},
+	}}
+}
+
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+func NewGaugeVec(opts Opts, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newVec(opts, labelNames, func() *Gauge { return &Gauge{} })}
+}
+
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return v.with(values...)
+}
+
+func (v *GaugeVec) Collect() []Family {
+	f := Family{Name: v.opts.Name, Help: v.opts.Help, Type: TypeGauge}
+	for _, c := range v.sorted() {
+		f.Samples = append(f.Samples, Sample{Labels: c.labels, Value: c.metric.Value()})
+	}
+	return []Family{f}
+}
+
+// CollectorFunc adapts a function to the Collector interface, for values
+// that are only known at scrape time such as connection pool statistics.
+type CollectorFunc func() []Family
+
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// DefBuckets are the default latency buckets, in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	opts    Opts
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     atomicFloat
+}
+
+// NewHistogram returns a histogram without labels. Buckets must be sorted in
+// increasing order; DefBuckets is used when none are given.
+func NewHistogram(opts Opts, buckets []float64) *Histogram {
+	return newHistogram(opts, buckets)
+}
+
+func newHistogram(opts Opts, buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: histogram buckets must be sorted")
+	}
+	return &Histogram{
+		opts:    opts,
+		buckets: buckets,
+		counts:  make([]atomic.Uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	// Buckets are cumulative when exported; internally each observation is
+	// only counted in the first bucket it fits.
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	h.sum.Add(v)
+}
+
+func (h *Histogram) samples(labels []Label) []Sample {
+	samples := make([]Sample, 0, len(h.buckets)+3)
+
+	var cumulative uint64
+	for i, upper := range h.buckets {
+		cumulative += h.counts[i].Load()
+		samples = append(samples, Sample{
+			Suffix: "_bucket",
+			Labels: withLabel(labels, "le", formatFloat(upper)),
+			Value:  float64(cumulative),
+		})
+	}
+
+	count := h.count.Load()
+	samples = append(samples,
+		Sample{Suffix: "_bucket", Labels: withLabel(labels, "le", formatFloat(math.Inf(1))), Value: float64(count)},
+		Sample{Suffix: "_sum", Labels: labels, Value: h.sum.Load()},
+		Sample{Suffix: "_count", Labels: labels, Value: float64(count)},
+	)
+	return samples
+}
+
+func (h *Histogram) Collect() []Family {
+	return []Family{{
+		Name:    h.opts.Name,
+		Help:    h.opts.Help,
+		Type:    TypeHistogram,
+		Samples: h.samples(nil),
+	}}
+}
+
+type HistogramVec struct {
+	*vec[Histogram]
+}
+
+func NewHistogramVec(opts Opts, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{newVec(opts, labelNames, func() *Histogram { return newHistogram(Opts{}, buckets) })}
+}
+
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return v.with(values...)
+}
+
+func (v *HistogramVec) Collect() []Family {
+	f := Family{Name: v.opts.Name, Help: v.opts.Help, Type: TypeHistogram}
+	for _, c := range v.sorted() {
+		f.Samples = append(f.Samples, c.metric.samples(c.labels)...)
+	}
+	return []Family{f}
+}
+
+// ExponentialBuckets returns count buckets starting at start, each factor
+// times the previous one.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+func withLabel(labels []Label, name, value string) []Label {
+	out := make([]Label, 0, len(labels)+1)
+	out = append(out, labels...)
+	return append(out, Label{Name: name, Value: value})
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTextCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	requests := NewCounterVec(Opts{Name: "test_requests_total", Help: "Requests by path."}, "method", "path")
+	inFlight := NewGauge(Opts{Name: "test_in_flight", Help: "Requests in flight."})
+	r.MustRegister(requests, inFlight)
+
+	requests.WithLabelValues("GET", "/b").Add(2)
+	requests.WithLabelValues("GET", "/a").Inc()
+	requests.WithLabelValues("GET", "/a").Add(-5) // ignored, counters only go up
+	inFlight.Set(3)
+	inFlight.Dec()
+
+	want := `# HELP test_in_flight Requests in flight.
+# TYPE test_in_flight gauge
+test_in_flight 2
+# HELP test_requests_total Requests by path.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",path="/a"} 1
+test_requests_total{method="GET",path="/b"} 2
+`
+	if got := writeText(t, r); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTextEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounterVec(Opts{Name: "test_escaped_total", Help: "Help with a \\ backslash\nand a newline."}, "value")
+	r.MustRegister(c)
+
+	c.WithLabelValues(`a "quoted" \ value` + "\nwith a newline").Inc()
+
+	want := `# HELP test_escaped_total Help with a \\ backslash\nand a newline.
+# TYPE test_escaped_total counter
+test_escaped_total{value="a \"quoted\" \\ value\nwith a newline"} 1
+`
+	if got := writeText(t, r); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTextHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := NewHistogramVec(Opts{Name: "test_duration_seconds", Help: "Durations."}, []float64{0.1, 1}, "route")
+	r.MustRegister(h)
+
+	h.WithLabelValues("/a").Observe(0.05)
+	h.WithLabelValues("/a").Observe(0.1) // upper bounds are inclusive
+	h.WithLabelValues("/a").Observe(0.5)
+	h.WithLabelValues("/a").Observe(7)
+
+	want := `# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 2
+test_duration_seconds_bucket{route="/a",le="1"} 3
+test_duration_seconds_bucket{route="/a",le="+Inf"} 4
+test_duration_seconds_sum{route="/a"} 7.65
+test_duration_seconds_count{route="/a"} 4
+`
+	if got := writeText(t, r); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTextHistogramWithoutLabels(t *testing.T) {
+	r := NewRegistry()
+	h := NewHistogram(Opts{Name: "test_size_bytes"}, ExponentialBuckets(1, 10, 2))
+	r.MustRegister(h)
+
+	h.Observe(5)
+
+	// No HELP line without help text
+	want := `# TYPE test_size_bytes histogram
+test_size_bytes_bucket{le="1"} 0
+test_size_bytes_bucket{le="10"} 1
+test_size_bytes_bucket{le="+Inf"} 1
+test_size_bytes_sum 5
+test_size_bytes_count 1
+`
+	if got := writeText(t, r); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCollectorFunc(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(CollectorFunc(func() []Family {
+		return []Family{{Name: "test_pool_open", Type: TypeGauge, Samples: []Sample{{Value: 4}}}}
+	}))
+
+	want := "# TYPE test_pool_open gauge\ntest_pool_open 4\n"
+	if got := writeText(t, r); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounter(Opts{Name: "test_total", Help: "A counter."})
+	r.MustRegister(c)
+	c.Add(1.5)
+
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, ContentType)
+	}
+	if want := "# HELP test_total A counter.\n# TYPE test_total counter\ntest_total 1.5\n"; string(body) != want {
+		t.Errorf("body:\n%s\nwant:\n%s", body, want)
+	}
+}
+
+func writeText(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// vec keeps one child per distinct combination of label values.
+type vec[T any] struct {
+	opts       Opts
+	labelNames []string
+	newChild   func() *T
+
+	mu       sync.RWMutex
+	children map[string]*child[T]
+}
+
+type child[T any] struct {
+	labels []Label
+	metric *T
+}
+
+func newVec[T any](opts Opts, labelNames []string, newChild func() *T) *vec[T] {
+	return &vec[T]{
+		opts:       opts,
+		labelNames: labelNames,
+		newChild:   newChild,
+		children:   make(map[string]*child[T]),
+	}
+}
+
+func (v *vec[T]) with(values ...string) *T {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.opts.Name, len(v.labelNames), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return c.metric
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if c, ok := v.children[key]; ok {
+		return c.metric
+	}
+
+	labels := make([]Label, len(values))
+	for i, name := range v.labelNames {
+		labels[i] = Label{Name: name, Value: values[i]}
+	}
+
+	c = &child[T]{labels: labels, metric: v.newChild()}
+	v.children[key] = c
+	return c.metric
+}
+
+// sorted returns the children ordered by label values so output is stable.
+func (v *vec[T]) sorted() []*child[T] {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]*child[T], len(keys))
+	for i, k := range keys {
+		out[i] = v.children[k]
+	}
+	return out
+}
+
+// atomicFloat is a float64 that can be updated concurrently.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) Add(delta float64) {
+	for {
+		old := f.bits.Load()
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if f.bits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) Set(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
@@ -0,0 +1,89 @@
+// Package ratelimit counts requests against limits with token buckets.
+//
+// A bucket holds as many requests as its limit allows at once and refills continuously,
+// so a client can make a burst of Limit.Requests requests and then Limit.Requests per
+// Limit.Period. Buckets are kept by key in a Store; MemoryStore keeps them in the
+// process, and a store shared between servers can implement the same interface.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is how many requests a bucket allows. The zero value is no limit.
+type Limit struct {
+	// Requests is how many requests can be made at once, and again in every Period
+	Requests int
+	Period   time.Duration
+}
+
+// Unlimited reports whether the limit lets every request through.
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// rate is how many requests the bucket gets back per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// ParseLimit parses a limit written as requests/period, e.g. "600/1m" or "10/s". An empty
+// string or "0" is no limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, errors.New("must be requests/period, e.g. 600/1m")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 0 {
+		return Limit{}, errors.New("requests must be a non-negative integer")
+	}
+	period = strings.TrimSpace(period)
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		// "10/s" is 10 per second
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, errors.New("period must be a positive duration, e.g. 1m")
+	}
+
+	if n == 0 {
+		return Limit{}, nil
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// Result is the state of a bucket after a request was counted against it.
+type Result struct {
+	Allowed bool
+	// Remaining is how many more requests can be made at once
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a request is allowed again, 0 if this one was
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets of the clients being limited.
+type Store interface {
+	// Take counts a request against the bucket of key, created full with limit if there
+	// is none. Requests that aren't allowed aren't counted.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// ceilSeconds rounds a number of seconds up to a duration of whole seconds, as clients
+// are told them in headers.
+func ceilSeconds(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds)) * time.Second
+}
@@ -0,0 +1,293 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody bounds how much of an error response is read
+const maxErrorBody = 64 * 1024
+
+type Config struct {
+	// Endpoint is the base URL of the store, e.g. http://minio.local:9000
+	Endpoint    string
+	Region      string
+	Bucket      string
+	Credentials Credentials
+	HTTPClient  *http.Client
+}
+
+// Client is a minimal S3 REST client for one bucket. Requests use path-style addressing
+// (endpoint/bucket/key), which every S3-compatible store understands.
+type Client struct {
+	endpoint *url.URL
+	region   string
+	bucket   string
+	creds    Credentials
+	http     *http.Client
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q: must be http(s)://host[:port]", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &Client{
+		endpoint: endpoint,
+		region:   region,
+		bucket:   cfg.Bucket,
+		creds:    cfg.Credentials,
+		http:     httpClient,
+	}, nil
+}
+
+func (c *Client) Bucket() string {
+	return c.bucket
+}
+
+// HeadObject returns the metadata of key.
+func (c *Client) HeadObject(ctx context.Context, key string) (ObjectInfo, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	return objectInfoFromHeader(key, resp.Header), nil
+}
+
+// GetObject returns the content of key from offset to the end. A non-empty etag makes the
+// request fail with ErrPreconditionFailed if the object was replaced in the meantime.
+func (c *Client) GetObject(ctx context.Context, key string, offset int64, etag string) (io.ReadCloser, error) {
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, key, nil, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// PutObject stores body as key in a single request.
+func (c *Client) PutObject(ctx context.Context, key string, body []byte) (string, error) {
+	resp, err := c.do(ctx, http.MethodPut, key, nil, nil, body)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// CopyObject copies srcKey to dstKey on the server side.
+func (c *Client) CopyObject(ctx context.Context, srcKey, dstKey string) error {
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", "/"+c.bucket+"/"+URIEncode(srcKey, false))
+
+	resp, err := c.do(ctx, http.MethodPut, dstKey, nil, header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A copy can fail after the 200 status was sent; the error is then in the body
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(body, []byte("<Error>")) {
+		return parseError(resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListObjects returns one page of ListObjectsV2. With delimiter "/" keys below the next
+// slash are folded into CommonPrefixes, which is how folders are listed.
+func (c *Client) ListObjects(ctx context.Context, prefix, delimiter, continuationToken string, maxKeys int) (*ListBucketResult, error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if continuationToken != "" {
+		query.Set("continuation-token", continuationToken)
+	}
+	if maxKeys > 0 {
+		query.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+
+	var result ListBucketResult
+	if err := c.doXML(ctx, http.MethodGet, "", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	query := url.Values{"uploads": {""}}
+
+	var result InitiateMultipartUploadResult
+	if err := c.doXML(ctx, http.MethodPost, key, query, nil, &result); err != nil {
+		return "", err
+	}
+	return result.UploadID, nil
+}
+
+func (c *Client) UploadPart(ctx context.Context, key, uploadID string, partNumber int, body []byte) (CompletedPart, error) {
+	query := url.Values{}
+	query.Set("partNumber", strconv.Itoa(partNumber))
+	query.Set("uploadId", uploadID)
+
+	resp, err := c.do(ctx, http.MethodPut, key, query, nil, body)
+	if err != nil {
+		return CompletedPart{}, err
+	}
+	resp.Body.Close()
+	return CompletedPart{PartNumber: partNumber, ETag: resp.Header.Get("ETag")}, nil
+}
+
+func (c *Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []CompletedPart) (string, error) {
+	body, err := xml.Marshal(CompleteMultipartUpload{Parts: parts})
+	if err != nil {
+		return "", err
+	}
+
+	var result CompleteMultipartUploadResult
+	if err := c.doXML(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body, &result); err != nil {
+		return "", err
+	}
+	return result.ETag, nil
+}
+
+func (c *Client) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) doXML(ctx context.Context, method, key string, query url.Values, body []byte, result any) error {
+	resp, err := c.do(ctx, method, key, query, nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// CompleteMultipartUpload reports some failures with a 200 status
+	if bytes.Contains(data, []byte("<Error>")) {
+		return parseError(resp.StatusCode, data)
+	}
+	if err := xml.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("s3: invalid response: %w", err)
+	}
+	return nil
+}
+
+// do sends a signed request and turns non-2xx responses into *Error. The caller must close
+// the body of the returned response.
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket + "/" + key
+	u.RawPath = URIEncode(u.Path, false)
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	payloadHash := EmptyPayloadHash
+	if body != nil {
+		req.ContentLength = int64(len(body))
+		payloadHash = PayloadHash(body)
+	}
+	SignRequest(req, c.creds, c.region, payloadHash, time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return nil, parseError(resp.StatusCode, data)
+}
+
+func parseError(statusCode int, body []byte) error {
+	s3Err := &Error{StatusCode: statusCode}
+	if len(body) > 0 {
+		if err := xml.Unmarshal(body, s3Err); err != nil {
+			s3Err.Message = strings.TrimSpace(string(body))
+		}
+	}
+	if s3Err.Code == "" {
+		// HEAD responses have no body
+		s3Err.Code = strings.ReplaceAll(http.StatusText(statusCode), " ", "")
+	}
+	return s3Err
+}
+
+func objectInfoFromHeader(key string, header http.Header) ObjectInfo {
+	info := ObjectInfo{
+		Key:         key,
+		ETag:        header.Get("ETag"),
+		ContentType: header.Get("Content-Type"),
+	}
+	info.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	info.LastModified, _ = http.ParseTime(header.Get("Last-Modified"))
+	return info
+}
@@ -0,0 +1,183 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Signature Version 4, as used by S3 and every S3-compatible store.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
+const (
+	SigningAlgorithm = "AWS4-HMAC-SHA256"
+	ServiceName      = "s3"
+
+	// UnsignedPayload is sent as the payload hash when the body isn't part of the signature
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// EmptyPayloadHash is the SHA-256 of an empty body
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	amzDateFormat   = "20060102T150405Z"
+	shortDateFormat = "20060102"
+)
+
+type Credentials struct {
+	AccessKey string
+	SecretKey string
+}
+
+// SignRequest adds the X-Amz-Date, X-Amz-Content-Sha256 and Authorization headers to req.
+// payloadHash is the hex SHA-256 of the body, or UnsignedPayload.
+func SignRequest(req *http.Request, creds Credentials, region string, payloadHash string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := signedHeaderNames(req)
+	scope := Scope(now, region)
+	canonical := CanonicalRequest(req, signedHeaders, payloadHash)
+	signature := Signature(SigningKey(creds.SecretKey, now, region), StringToSign(now, scope, canonical))
+
+	req.Header.Set("Authorization", SigningAlgorithm+
+		" Credential="+creds.AccessKey+"/"+scope+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+
+		", Signature="+signature)
+}
+
+// signedHeaderNames signs the host and every x-amz-* header, plus the few standard headers
+// whose tampering would change the meaning of the request.
+func signedHeaderNames(req *http.Request) []string {
+	names := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, "x-amz-"), lower == "content-type", lower == "content-md5", lower == "range":
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Scope is the credential scope of a signature made at t: date/region/s3/aws4_request.
+func Scope(t time.Time, region string) string {
+	return t.UTC().Format(shortDateFormat) + "/" + region + "/" + ServiceName + "/aws4_request"
+}
+
+// CanonicalRequest builds the canonical form of req over the given (lower-case, sorted)
+// signed headers.
+func CanonicalRequest(req *http.Request, signedHeaders []string, payloadHash string) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte('\n')
+	b.WriteString(URIEncode(req.URL.Path, false))
+	b.WriteByte('\n')
+	b.WriteString(canonicalQuery(req.URL.Query()))
+	b.WriteByte('\n')
+	for _, name := range signedHeaders {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(canonicalHeaderValue(req, name))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	b.WriteString(strings.Join(signedHeaders, ";"))
+	b.WriteByte('\n')
+	b.WriteString(payloadHash)
+	return b.String()
+}
+
+func canonicalHeaderValue(req *http.Request, name string) string {
+	if name == "host" {
+		if req.Host != "" {
+			return req.Host
+		}
+		return req.URL.Host
+	}
+
+	values := req.Header.Values(name)
+	for i, v := range values {
+		values[i] = strings.Join(strings.Fields(v), " ")
+	}
+	return strings.Join(values, ",")
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		// Presigned URLs sign everything except the signature itself
+		if k == "X-Amz-Signature" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, URIEncode(k, true)+"="+URIEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func StringToSign(t time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	return SigningAlgorithm + "\n" + t.UTC().Format(amzDateFormat) + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+}
+
+// SigningKey derives the per-day, per-region key from the secret key.
+func SigningKey(secretKey string, t time.Time, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), t.UTC().Format(shortDateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, ServiceName)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func Signature(signingKey []byte, stringToSign string) string {
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// URIEncode percent-encodes everything except the unreserved characters, the way SigV4
+// expects. Slashes are kept unless encodeSlash is set (query strings).
+func URIEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
+
+// PayloadHash returns the hex SHA-256 of body.
+func PayloadHash(body []byte) string {
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:])
+}
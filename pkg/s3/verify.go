@@ -0,0 +1,411 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Payload hashes announcing an aws-chunked body
+const (
+	StreamingSignedPayload          = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	chunkSigningAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+
+	// MaxClockSkew is how far the request date may be from the server's clock
+	MaxClockSkew = 15 * time.Minute
+	// MaxPresignExpiry is the longest a presigned URL can be valid for, as on S3
+	MaxPresignExpiry = 7 * 24 * time.Hour
+
+	// maxChunkSize bounds the memory a single aws-chunked chunk may take
+	maxChunkSize = 16 << 20
+)
+
+var (
+	ErrMissingAuth          = errors.New("s3: request is not signed")
+	ErrMalformedAuth        = errors.New("s3: malformed authorization header")
+	ErrUnsupportedPayload   = errors.New("s3: unsupported payload signing mode")
+	ErrRequestTimeTooSkewed = errors.New("s3: request time too skewed")
+	ErrRequestExpired       = errors.New("s3: presigned request has expired")
+	ErrSignatureMismatch    = errors.New("s3: signature does not match")
+	ErrContentSHA256        = errors.New("s3: payload does not match x-amz-content-sha256")
+	ErrIncompleteBody       = errors.New("s3: malformed chunked body")
+)
+
+// Authorization is the parsed Authorization header of a SigV4 request, or the query of a
+// presigned URL.
+type Authorization struct {
+	AccessKey     string
+	Date          string // yyyymmdd from the credential scope
+	Region        string
+	SignedHeaders []string
+	Signature     string
+
+	// SignedAt and Expires are when a presigned URL was signed and for how long it is
+	// valid, zero for the Authorization header
+	SignedAt time.Time
+	Expires  time.Duration
+}
+
+// Presigned reports whether the signature came from the query of a presigned URL.
+func (a *Authorization) Presigned() bool {
+	return a.Expires > 0
+}
+
+func (a *Authorization) Scope() string {
+	return a.Date + "/" + a.Region + "/" + ServiceName + "/aws4_request"
+}
+
+// ParseAuthorization parses
+// "AWS4-HMAC-SHA256 Credential=AK/20130524/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=hex".
+func ParseAuthorization(header string) (*Authorization, error) {
+	if header == "" {
+		return nil, ErrMissingAuth
+	}
+	algorithm, rest, ok := strings.Cut(header, " ")
+	if !ok || algorithm != SigningAlgorithm {
+		return nil, ErrMalformedAuth
+	}
+
+	auth := &Authorization{}
+	for _, field := range strings.Split(rest, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, ErrMalformedAuth
+		}
+		switch name {
+		case "Credential":
+			if !auth.parseCredential(value) {
+				return nil, ErrMalformedAuth
+			}
+		case "SignedHeaders":
+			auth.SignedHeaders = strings.Split(value, ";")
+		case "Signature":
+			auth.Signature = value
+		}
+	}
+
+	if auth.AccessKey == "" || len(auth.SignedHeaders) == 0 || auth.Signature == "" {
+		return nil, ErrMalformedAuth
+	}
+	return auth, nil
+}
+
+// ParsePresigned parses the X-Amz-* parameters of a presigned URL's query.
+func ParsePresigned(query url.Values) (*Authorization, error) {
+	if query.Get("X-Amz-Signature") == "" {
+		return nil, ErrMissingAuth
+	}
+	if query.Get("X-Amz-Algorithm") != SigningAlgorithm {
+		return nil, ErrMalformedAuth
+	}
+
+	auth := &Authorization{Signature: query.Get("X-Amz-Signature")}
+	if !auth.parseCredential(query.Get("X-Amz-Credential")) {
+		return nil, ErrMalformedAuth
+	}
+	if signedHeaders := query.Get("X-Amz-SignedHeaders"); signedHeaders != "" {
+		auth.SignedHeaders = strings.Split(signedHeaders, ";")
+	}
+	signedAt, err := time.Parse(amzDateFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return nil, ErrMalformedAuth
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires <= 0 || time.Duration(expires)*time.Second > MaxPresignExpiry {
+		return nil, ErrMalformedAuth
+	}
+	auth.SignedAt, auth.Expires = signedAt, time.Duration(expires)*time.Second
+	return auth, nil
+}
+
+// parseCredential parses "AK/20130524/us-east-1/s3/aws4_request" into a.
+func (a *Authorization) parseCredential(credential string) bool {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[0] == "" || parts[3] != ServiceName || parts[4] != "aws4_request" {
+		return false
+	}
+	a.AccessKey, a.Date, a.Region = parts[0], parts[1], parts[2]
+	return true
+}
+
+// VerifyRequest checks the signature of req made with secretKey. The signature must cover
+// the host, the date and the payload hash headers; a presigned URL only signs the host,
+// the date and expiry being in its query. On success the body of req
+// is replaced by one that checks the payload as it is read: a body that doesn't match
+// x-amz-content-sha256, or an aws-chunked chunk with a bad signature, fails the read instead
+// of ending it, so a tampered upload is never committed.
+func VerifyRequest(req *http.Request, auth *Authorization, secretKey string, now time.Time) error {
+	var signedAt time.Time
+	var payloadHash string
+	var required []string
+	if auth.Presigned() {
+		signedAt = auth.SignedAt
+		if signedAt.Sub(now) > MaxClockSkew {
+			return ErrRequestTimeTooSkewed
+		}
+		if now.After(signedAt.Add(auth.Expires)) {
+			return ErrRequestExpired
+		}
+		// The body of a presigned request isn't signed
+		payloadHash = UnsignedPayload
+		required = []string{"host"}
+	} else {
+		dateHeader := "x-amz-date"
+		var err error
+		signedAt, err = time.Parse(amzDateFormat, req.Header.Get("X-Amz-Date"))
+		if err != nil {
+			dateHeader = "date"
+			signedAt, err = http.ParseTime(req.Header.Get("Date"))
+			if err != nil {
+				return ErrMalformedAuth
+			}
+		}
+		if signedAt.Sub(now) > MaxClockSkew || now.Sub(signedAt) > MaxClockSkew {
+			return ErrRequestTimeTooSkewed
+		}
+
+		payloadHash = req.Header.Get("X-Amz-Content-Sha256")
+		if payloadHash == "" {
+			return ErrMalformedAuth
+		}
+		required = []string{"host", dateHeader, "x-amz-content-sha256"}
+	}
+	if signedAt.UTC().Format(shortDateFormat) != auth.Date {
+		return ErrMalformedAuth
+	}
+	// Headers left out of the signature could be changed without invalidating it
+	for _, name := range required {
+		if !slices.Contains(auth.SignedHeaders, name) {
+			return ErrSignatureMismatch
+		}
+	}
+
+	signingKey := SigningKey(secretKey, signedAt, auth.Region)
+	canonical := CanonicalRequest(req, auth.SignedHeaders, payloadHash)
+	expected := Signature(signingKey, StringToSign(signedAt, auth.Scope(), canonical))
+	if !hmac.Equal([]byte(expected), []byte(auth.Signature)) {
+		return ErrSignatureMismatch
+	}
+
+	switch {
+	case payloadHash == UnsignedPayload:
+	case payloadHash == StreamingSignedPayload || payloadHash == StreamingUnsignedPayloadTrailer:
+		chunked := &chunkedReader{
+			body:       req.Body,
+			r:          bufio.NewReader(req.Body),
+			signed:     payloadHash == StreamingSignedPayload,
+			trailer:    payloadHash == StreamingUnsignedPayloadTrailer,
+			signingKey: signingKey,
+			amzDate:    signedAt.UTC().Format(amzDateFormat),
+			scope:      auth.Scope(),
+			prevSig:    auth.Signature,
+			length:     -1,
+		}
+		req.ContentLength = -1
+		if decoded, err := strconv.ParseInt(req.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64); err == nil && decoded >= 0 {
+			req.ContentLength = decoded
+			chunked.length = decoded
+		}
+		req.Body = chunked
+	case len(payloadHash) == sha256.Size*2:
+		req.Body = &hashVerifyingReader{body: req.Body, hash: sha256.New(), expected: strings.ToLower(payloadHash)}
+	default:
+		return ErrUnsupportedPayload
+	}
+	return nil
+}
+
+// hashVerifyingReader returns ErrContentSHA256 instead of io.EOF if the body didn't hash to
+// the expected value.
+type hashVerifyingReader struct {
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (r *hashVerifyingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(r.hash.Sum(nil)) != r.expected {
+		return n, ErrContentSHA256
+	}
+	return n, err
+}
+
+func (r *hashVerifyingReader) Close() error {
+	return r.body.Close()
+}
+
+// chunkedReader decodes an aws-chunked body:
+//
+//	<hex size>;chunk-signature=<sig>\r\n<data>\r\n ... 0;chunk-signature=<sig>\r\n\r\n
+//
+// Each chunk is buffered and its signature, chained from the previous one, verified before
+// any of its data is returned. The unsigned trailer variant has no signatures and ends with
+// trailing headers (checksums) that are skipped. A body cut off before the last chunk, or
+// whose data doesn't add up to x-amz-decoded-content-length, fails with ErrIncompleteBody.
+type chunkedReader struct {
+	body    io.Closer
+	r       *bufio.Reader
+	signed  bool
+	trailer bool
+
+	signingKey []byte
+	amzDate    string
+	scope      string
+	prevSig    string
+
+	// length is x-amz-decoded-content-length, -1 if unknown, and decoded the bytes of the
+	// chunks read so far
+	length  int64
+	decoded int64
+
+	buf  []byte
+	pos  int
+	done bool
+	err  error
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	for r.pos == len(r.buf) {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.nextChunk(); err != nil {
+			r.err = err
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buf[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+func (r *chunkedReader) nextChunk() error {
+	header, err := r.readLine()
+	if err != nil {
+		// The body must end with the last, empty chunk, not between two chunks
+		return ErrIncompleteBody
+	}
+
+	sizeField, ext, _ := strings.Cut(header, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return ErrIncompleteBody
+	}
+	r.decoded += size
+	if r.length >= 0 && r.decoded > r.length {
+		return ErrIncompleteBody
+	}
+
+	if cap(r.buf) < int(size) {
+		r.buf = make([]byte, size)
+	}
+	r.buf, r.pos = r.buf[:size], 0
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		return ErrIncompleteBody
+	}
+
+	if r.signed {
+		signature, ok := strings.CutPrefix(ext, "chunk-signature=")
+		if !ok || !r.verifyChunk(signature) {
+			return ErrSignatureMismatch
+		}
+	}
+
+	if size > 0 {
+		if line, err := r.readLine(); err != nil || line != "" {
+			return ErrIncompleteBody
+		}
+		return nil
+	}
+
+	// Last chunk: skip trailing headers up to the closing empty line
+	if r.length >= 0 && r.decoded != r.length {
+		return ErrIncompleteBody
+	}
+	r.done = true
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			if err == io.EOF && !r.trailer {
+				return nil
+			}
+			return ErrIncompleteBody
+		}
+		if line == "" {
+			return nil
+		}
+	}
+}
+
+func (r *chunkedReader) verifyChunk(signature string) bool {
+	chunkHash := sha256.Sum256(r.buf)
+	stringToSign := chunkSigningAlgorithm + "\n" + r.amzDate + "\n" + r.scope + "\n" + r.prevSig + "\n" +
+		EmptyPayloadHash + "\n" + hex.EncodeToString(chunkHash[:])
+
+	expected := Signature(r.signingKey, stringToSign)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return false
+	}
+	r.prevSig = signature
+	return true
+}
+
+func (r *chunkedReader) readLine() (string, error) {
+	line, err := r.r.ReadSlice('\n')
+	if err != nil {
+		if err == io.EOF && len(line) == 0 {
+			return "", io.EOF
+		}
+		return "", ErrIncompleteBody
+	}
+	return string(bytes.TrimRight(line, "\r\n")), nil
+}
+
+func (r *chunkedReader) Close() error {
+	return r.body.Close()
+}
+
+// Namespace is the XML namespace of S3 response bodies
+const Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// WriteXML writes v as an S3 XML response body.
+func WriteXML(w http.ResponseWriter, status int, v any) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	body := append([]byte(xml.Header), data...)
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// WriteError writes an S3 error response.
+func WriteError(w http.ResponseWriter, status int, code, message, resource, requestID string) {
+	if err := WriteXML(w, status, &Error{Code: code, Message: message, Resource: resource, RequestID: requestID}); err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", code, message), status)
+	}
+}
@@ -0,0 +1,200 @@
+// Package textfile reads text files of unknown encoding as UTF-8 and parses Markdown into
+// blocks for previews.
+package textfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var ErrBinary = errors.New("file is not text")
+
+// Encoding is a text encoding a file can be read in.
+type Encoding string
+
+const (
+	UTF8    Encoding = "utf-8"
+	UTF16LE Encoding = "utf-16le"
+	UTF16BE Encoding = "utf-16be"
+	Latin1  Encoding = "iso-8859-1"
+)
+
+// SampleSize is how much of the start of a file Detect needs to tell its encoding.
+const SampleSize = 8 * 1024
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Detect returns the encoding of a file starting with sample and the length of its byte
+// order mark. UTF-16 is only recognised by its byte order mark; other files are UTF-8 if
+// sample is valid UTF-8 and Latin-1 otherwise. Files containing NUL bytes are binary.
+func Detect(sample []byte) (Encoding, int, error) {
+	switch {
+	case bytes.HasPrefix(sample, bomUTF8):
+		return UTF8, len(bomUTF8), nil
+	case bytes.HasPrefix(sample, bomUTF16LE):
+		return UTF16LE, len(bomUTF16LE), nil
+	case bytes.HasPrefix(sample, bomUTF16BE):
+		return UTF16BE, len(bomUTF16BE), nil
+	}
+
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return "", 0, ErrBinary
+	}
+	// The sample may end in the middle of a character
+	if len(sample) >= SampleSize {
+		for i := 0; i < utf8.UTFMax && len(sample) > 0; i++ {
+			if utf8.Valid(sample) {
+				break
+			}
+			sample = sample[:len(sample)-1]
+		}
+	}
+	if utf8.Valid(sample) {
+		return UTF8, 0, nil
+	}
+	return Latin1, 0, nil
+}
+
+// UnitSize is the size in bytes of the code units of enc; a line ends at a newline that
+// starts at a multiple of it.
+func (enc Encoding) UnitSize() int {
+	if enc == UTF16LE || enc == UTF16BE {
+		return 2
+	}
+	return 1
+}
+
+// Newline is the encoding of "\n" in enc.
+func (enc Encoding) Newline() []byte {
+	switch enc {
+	case UTF16LE:
+		return []byte{'\n', 0}
+	case UTF16BE:
+		return []byte{0, '\n'}
+	}
+	return []byte{'\n'}
+}
+
+// NewReader returns a reader that decodes r from enc to UTF-8. Invalid input is replaced
+// with U+FFFD.
+func NewReader(r io.Reader, enc Encoding) io.Reader {
+	switch enc {
+	case UTF16LE, UTF16BE:
+		return &utf16Reader{r: bufio.NewReader(r), bigEndian: enc == UTF16BE}
+	case Latin1:
+		return &latin1Reader{r: r}
+	}
+	return r
+}
+
+type latin1Reader struct {
+	r       io.Reader
+	buf     []byte
+	pending []byte // a character that didn't fit in the last read
+}
+
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(l.pending) > 0 {
+		n := copy(p, l.pending)
+		l.pending = l.pending[n:]
+		return n, nil
+	}
+
+	// Each byte becomes at most two
+	n := max(len(p)/2, 1)
+	if cap(l.buf) < n {
+		l.buf = make([]byte, n)
+	}
+	n, err := l.r.Read(l.buf[:n])
+	out := 0
+	for _, b := range l.buf[:n] {
+		var enc [2]byte
+		size := utf8.EncodeRune(enc[:], rune(b))
+		copied := copy(p[out:], enc[:size])
+		out += copied
+		if copied < size {
+			l.pending = append(l.pending[:0], enc[copied:size]...)
+		}
+	}
+	return out, err
+}
+
+type utf16Reader struct {
+	r         *bufio.Reader
+	bigEndian bool
+	pending   []byte // encoded runes that didn't fit in the last read
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	n := copy(p, u.pending)
+	u.pending = u.pending[n:]
+	for n < len(p) {
+		r, err := u.readRune()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+		var buf [utf8.UTFMax]byte
+		size := utf8.EncodeRune(buf[:], r)
+		copied := copy(p[n:], buf[:size])
+		n += copied
+		if copied < size {
+			u.pending = append(u.pending[:0], buf[copied:size]...)
+		}
+	}
+	return n, nil
+}
+
+func (u *utf16Reader) readRune() (rune, error) {
+	unit, err := u.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(rune(unit)) {
+		return rune(unit), nil
+	}
+	if unit >= 0xDC00 {
+		// A low surrogate on its own
+		return utf8.RuneError, nil
+	}
+
+	next, err := u.r.Peek(2)
+	if err != nil {
+		return utf8.RuneError, nil
+	}
+	low := u.unit(next)
+	if low < 0xDC00 || low > 0xDFFF {
+		return utf8.RuneError, nil
+	}
+	u.r.Discard(2)
+	return utf16.DecodeRune(rune(unit), rune(low)), nil
+}
+
+func (u *utf16Reader) readUnit() (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(u.r, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			// A trailing odd byte
+			return utf8.RuneError, nil
+		}
+		return 0, err
+	}
+	return u.unit(b[:]), nil
+}
+
+func (u *utf16Reader) unit(b []byte) uint16 {
+	if u.bigEndian {
+		return uint16(b[0])<<8 | uint16(b[1])
+	}
+	return uint16(b[1])<<8 | uint16(b[0])
+}
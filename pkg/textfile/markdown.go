@@ -0,0 +1,182 @@
+package textfile
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Markdown block types
+const (
+	BlockHeading   = "heading"
+	BlockParagraph = "paragraph"
+	BlockCode      = "code"
+	BlockList      = "list"
+	BlockQuote     = "quote"
+	BlockTable     = "table"
+	BlockRule      = "rule"
+)
+
+// Block is a top-level block of a Markdown document. Inline markup is left in the text.
+type Block struct {
+	Type     string     `json:"type" example:"heading"`
+	Level    int        `json:"level,omitempty" example:"2"`
+	Text     string     `json:"text,omitempty" example:"Installation"`
+	Language string     `json:"language,omitempty" example:"go"`
+	Ordered  bool       `json:"ordered,omitempty" example:"false"`
+	Items    []string   `json:"items,omitempty"`
+	Rows     [][]string `json:"rows,omitempty"`
+}
+
+var (
+	atxHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextLine   = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	thematic     = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceOpen    = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	bulletItem   = regexp.MustCompile(`^ {0,3}[-*+][ \t]+(.*)$`)
+	orderedItem  = regexp.MustCompile(`^ {0,3}\d{1,9}[.)][ \t]+(.*)$`)
+	quoteLine    = regexp.MustCompile(`^ {0,3}>[ ]?(.*)$`)
+	tableDivider = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+)
+
+// ParseMarkdown splits a Markdown document into its top-level blocks: headings,
+// paragraphs, fenced code, lists, block quotes, pipe tables and rules. It reads at most
+// maxBlocks blocks and reports whether there was more.
+func ParseMarkdown(r io.Reader, maxBlocks int) ([]Block, bool, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	p := &mdParser{}
+	for scanner.Scan() {
+		p.line(strings.TrimRight(scanner.Text(), "\r"))
+		if len(p.blocks) > maxBlocks {
+			return p.blocks[:maxBlocks], true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, err
+	}
+	p.flush()
+	if len(p.blocks) > maxBlocks {
+		return p.blocks[:maxBlocks], true, nil
+	}
+	return p.blocks, false, nil
+}
+
+type mdParser struct {
+	blocks []Block
+	cur    *Block // block still receiving lines
+	fence  string // closing fence of the current code block
+}
+
+func (p *mdParser) line(line string) {
+	if p.fence != "" {
+		if strings.HasPrefix(strings.TrimLeft(line, " "), p.fence) && strings.Trim(strings.TrimSpace(line), p.fence[:1]) == "" {
+			p.fence = ""
+			p.flush()
+			return
+		}
+		p.cur.Text += line + "\n"
+		return
+	}
+
+	if strings.TrimSpace(line) == "" {
+		p.flush()
+		return
+	}
+
+	// An underline turns the paragraph above into a heading
+	if p.cur != nil && p.cur.Type == BlockParagraph && !strings.Contains(p.cur.Text, "\n") {
+		if m := setextLine.FindStringSubmatch(line); m != nil {
+			p.cur.Type = BlockHeading
+			p.cur.Level = 1
+			if m[1][0] == '-' {
+				p.cur.Level = 2
+			}
+			p.flush()
+			return
+		}
+	}
+
+	// A divider turns the line above into a table header
+	if p.cur != nil && p.cur.Type == BlockParagraph && !strings.Contains(p.cur.Text, "\n") &&
+		strings.Contains(p.cur.Text, "|") && strings.Contains(line, "-") && tableDivider.MatchString(line) {
+		p.cur.Type = BlockTable
+		p.cur.Rows = [][]string{splitRow(p.cur.Text)}
+		p.cur.Text = ""
+		return
+	}
+
+	switch {
+	case thematic.MatchString(line):
+		p.flush()
+		p.blocks = append(p.blocks, Block{Type: BlockRule})
+	case atxHeading.MatchString(line):
+		m := atxHeading.FindStringSubmatch(line)
+		p.flush()
+		p.blocks = append(p.blocks, Block{Type: BlockHeading, Level: len(m[1]), Text: m[2]})
+	case fenceOpen.MatchString(line):
+		m := fenceOpen.FindStringSubmatch(line)
+		p.start(BlockCode)
+		p.cur.Language = m[2]
+		p.fence = m[1]
+	case p.cur != nil && p.cur.Type == BlockTable && strings.Contains(line, "|"):
+		p.cur.Rows = append(p.cur.Rows, splitRow(line))
+	case bulletItem.MatchString(line):
+		p.item(false, bulletItem.FindStringSubmatch(line)[1])
+	case orderedItem.MatchString(line):
+		p.item(true, orderedItem.FindStringSubmatch(line)[1])
+	case quoteLine.MatchString(line):
+		text := quoteLine.FindStringSubmatch(line)[1]
+		if p.cur == nil || p.cur.Type != BlockQuote {
+			p.start(BlockQuote)
+			p.cur.Text = text
+		} else {
+			p.cur.Text += "\n" + text
+		}
+	case p.cur != nil && (p.cur.Type == BlockParagraph || p.cur.Type == BlockQuote):
+		// Continuation line
+		p.cur.Text += "\n" + strings.TrimSpace(line)
+	case p.cur != nil && p.cur.Type == BlockList && len(p.cur.Items) > 0:
+		p.cur.Items[len(p.cur.Items)-1] += "\n" + strings.TrimSpace(line)
+	default:
+		p.start(BlockParagraph)
+		p.cur.Text = strings.TrimSpace(line)
+	}
+}
+
+func (p *mdParser) item(ordered bool, text string) {
+	if p.cur == nil || p.cur.Type != BlockList || p.cur.Ordered != ordered {
+		p.start(BlockList)
+		p.cur.Ordered = ordered
+	}
+	p.cur.Items = append(p.cur.Items, text)
+}
+
+func (p *mdParser) start(typ string) {
+	p.flush()
+	p.cur = &Block{Type: typ}
+}
+
+func (p *mdParser) flush() {
+	if p.cur == nil {
+		return
+	}
+	if p.cur.Type == BlockCode {
+		p.cur.Text = strings.TrimSuffix(p.cur.Text, "\n")
+	}
+	p.blocks = append(p.blocks, *p.cur)
+	p.cur = nil
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells
+}
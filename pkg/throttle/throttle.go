@@ -0,0 +1,111 @@
+// Package throttle limits the rate of data transfers with token buckets.
+//
+// A Bucket holds up to a second's worth of bytes and refills continuously at its rate.
+// Taking bytes from it never fails: the bucket goes into debt and the caller waits until
+// the debt is paid back, so concurrent transfers sharing a bucket get the rate between
+// them. A transfer can be limited by several buckets at once (its user's and the server's)
+// and goes at the pace of the slowest.
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ChunkSize is the most bytes a transfer should take from its buckets at once, so a slow
+// rate is spread over many small waits instead of a few long ones.
+const ChunkSize = 32 << 10
+
+// Bucket is a token bucket of bytes. The zero value, like a nil *Bucket, is unlimited.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a bucket refilling at rate bytes per second, unlimited if rate is 0.
+func NewBucket(rate int64) *Bucket {
+	b := &Bucket{rate: float64(rate), last: time.Now()}
+	b.tokens = b.burst()
+	return b
+}
+
+// SetRate changes the rate of the bucket, which applies to the transfers already using it.
+func (b *Bucket) SetRate(rate int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	unlimited := b.rate == 0
+	b.rate = float64(rate)
+	if unlimited {
+		// Nothing was taken while unlimited, and the debt from before is forgiven
+		b.tokens = b.burst()
+	}
+	b.tokens = min(b.tokens, b.burst())
+}
+
+// Rate returns the rate of the bucket in bytes per second, 0 if unlimited.
+func (b *Bucket) Rate() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return int64(b.rate)
+}
+
+// burst is how many bytes the bucket holds when full: a second's worth, and at least a
+// chunk so a single chunk never has to wait for more than the bucket can hold.
+func (b *Bucket) burst() float64 {
+	return max(b.rate, ChunkSize)
+}
+
+func (b *Bucket) refill(now time.Time) {
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst(), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// reserve takes n bytes from the bucket and returns how long to wait before using them.
+func (b *Bucket) reserve(n int) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate == 0 {
+		return 0
+	}
+	b.refill(time.Now())
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Wait takes n bytes from each of buckets and waits until all of them allow it, or ctx is
+// done. Nil buckets are unlimited.
+func Wait(ctx context.Context, n int, buckets ...*Bucket) error {
+	var delay time.Duration
+	for _, b := range buckets {
+		delay = max(delay, b.reserve(n))
+	}
+	if delay == 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// near reports whether the wait d is want, give or take the time the test took to run.
+func near(d, want time.Duration) bool {
+	return d >= want-50*time.Millisecond && d <= want
+}
+
+func TestBucketBurst(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  int64
+		burst int
+	}{
+		{"a second's worth", 1 << 20, 1 << 20},
+		{"at least a chunk", 1000, ChunkSize},
+	}
+	for _, tt := range tests {
+		b := NewBucket(tt.rate)
+		if d := b.reserve(tt.burst); d != 0 {
+			t.Errorf("%s: waited %v for a full bucket", tt.name, d)
+		}
+		if d := b.reserve(int(tt.rate)); !near(d, time.Second) {
+			t.Errorf("%s: waited %v for a second's worth past the burst, want 1s", tt.name, d)
+		}
+	}
+}
+
+func TestBucketKeepsRateAcrossChunks(t *testing.T) {
+	b := NewBucket(10 * ChunkSize)
+	b.reserve(10 * ChunkSize)
+
+	// Each chunk waits for the ones taken before it
+	for i := 1; i <= 5; i++ {
+		if d := b.reserve(ChunkSize); !near(d, time.Duration(i)*100*time.Millisecond) {
+			t.Fatalf("chunk %d waits %v, want %v", i, d, time.Duration(i)*100*time.Millisecond)
+		}
+	}
+}
+
+func TestBucketRefill(t *testing.T) {
+	b := NewBucket(ChunkSize)
+	b.reserve(3 * ChunkSize)
+
+	// Two seconds later, the debt of two chunks is paid back
+	b.last = b.last.Add(-2 * time.Second)
+	if d := b.reserve(0); d != 0 {
+		t.Errorf("waits %v after the debt was paid back", d)
+	}
+	// An hour later, the bucket holds no more than its burst
+	b.last = b.last.Add(-time.Hour)
+	if d := b.reserve(ChunkSize); d != 0 {
+		t.Errorf("waits %v for a chunk in a full bucket", d)
+	}
+	if d := b.reserve(ChunkSize); !near(d, time.Second) {
+		t.Errorf("waits %v past the burst, want 1s", d)
+	}
+}
+
+func TestBucketSetRate(t *testing.T) {
+	b := NewBucket(ChunkSize)
+	b.reserve(2 * ChunkSize)
+
+	// The debt left is now paid back at the new rate
+	b.SetRate(2 * ChunkSize)
+	if got := b.Rate(); got != 2*ChunkSize {
+		t.Errorf("Rate = %d, want %d", got, 2*ChunkSize)
+	}
+	if d := b.reserve(ChunkSize); !near(d, time.Second) {
+		t.Errorf("waits %v after speeding up, want 1s", d)
+	}
+	b.SetRate(ChunkSize / 2)
+	if d := b.reserve(0); !near(d, 4*time.Second) {
+		t.Errorf("waits %v after slowing down, want 4s", d)
+	}
+
+	// Without a limit, the debt is forgotten
+	b.SetRate(0)
+	if d := b.reserve(100 * ChunkSize); d != 0 {
+		t.Errorf("waits %v without a limit", d)
+	}
+	// And a new limit starts from a full bucket
+	b.SetRate(ChunkSize)
+	if d := b.reserve(ChunkSize); d != 0 {
+		t.Errorf("waits %v for a chunk after limiting again", d)
+	}
+}
+
+func TestUnlimitedBuckets(t *testing.T) {
+	var nilBucket *Bucket
+	for name, b := range map[string]*Bucket{"nil": nilBucket, "zero": {}, "rate 0": NewBucket(0)} {
+		if d := b.reserve(1 << 30); d != 0 {
+			t.Errorf("%s bucket waits %v", name, d)
+		}
+		if got := b.Rate(); got != 0 {
+			t.Errorf("%s bucket has rate %d", name, got)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	ctx := context.Background()
+	slow, fast := NewBucket(10*ChunkSize), NewBucket(100*ChunkSize)
+	slow.reserve(10 * ChunkSize)
+
+	// Goes at the pace of the slowest bucket, and takes from all of them
+	start := time.Now()
+	if err := Wait(ctx, ChunkSize, slow, fast, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > time.Second {
+		t.Errorf("waited %v, want 100ms", elapsed)
+	}
+	if d := fast.reserve(99 * ChunkSize); d != 0 {
+		t.Errorf("fast bucket waits %v, want the chunk taken from it", d)
+	}
+	if d := fast.reserve(10 * ChunkSize); !near(d, 100*time.Millisecond) {
+		t.Errorf("fast bucket waits %v, want 100ms", d)
+	}
+
+	// Cancelling stops the wait
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if err := Wait(ctx, 10*ChunkSize, slow); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("waited %v past the deadline", elapsed)
+	}
+	if err := Wait(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("without delay: error = %v, want the deadline", err)
+	}
+}